@@ -0,0 +1,104 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/os/file"
+	"github.com/google/gapid/gapil/analysis"
+)
+
+func init() {
+	app.AddVerb(&app.Verb{
+		Name:      "footprint",
+		ShortHelp: "Prints the global state each command may read and write",
+		Action:    &footprintVerb{},
+	})
+}
+
+type footprintVerb struct {
+	JSON   bool          `help:"Emit the footprints as JSON, as consumed by dependencygraph"`
+	Out    string        `help:"The output file path. Defaults to stdout"`
+	Search file.PathList `help:"The set of paths to search for includes"`
+}
+
+// footprintJSON is the JSON form of the footprint of a single command.
+type footprintJSON struct {
+	Reads  []analysis.StatePath `json:"reads"`
+	Writes []analysis.StatePath `json:"writes"`
+}
+
+func (v *footprintVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	api, _, err := resolve(ctx, v.Search, flags)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if v.Out != "" {
+		f, err := os.Create(v.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	footprints := analysis.Footprints(api)
+	names := make([]string, 0, len(footprints))
+	byName := make(map[string]*analysis.Footprint, len(footprints))
+	for f, fp := range footprints {
+		names = append(names, f.Name())
+		byName[f.Name()] = fp
+	}
+	sort.Strings(names)
+
+	if v.JSON {
+		out := struct {
+			API      string                   `json:"api"`
+			Commands map[string]footprintJSON `json:"commands"`
+		}{
+			API:      api.Name(),
+			Commands: make(map[string]footprintJSON, len(names)),
+		}
+		for _, name := range names {
+			fp := byName[name]
+			out.Commands[name] = footprintJSON{Reads: fp.Reads, Writes: fp.Writes}
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(out)
+	}
+
+	for _, name := range names {
+		fp := byName[name]
+		fmt.Fprintf(w, "%v:\n", name)
+		for _, p := range fp.Reads {
+			fmt.Fprintf(w, "  read  %v\n", p)
+		}
+		for _, p := range fp.Writes {
+			fmt.Fprintf(w, "  write %v\n", p)
+		}
+	}
+	return nil
+}
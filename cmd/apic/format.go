@@ -14,7 +14,8 @@
 
 // Package format registers and implements the "format" apic command.
 //
-// The format command re-formats an API file to a consistent style.
+// The format command re-formats an API file to a consistent style. With
+// --check, files are not rewritten and the command fails if any would change.
 package main
 
 import (
@@ -26,9 +27,7 @@ import (
 
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/log"
-	"github.com/google/gapid/core/text/parse"
 	"github.com/google/gapid/gapil/format"
-	"github.com/google/gapid/gapil/parser"
 )
 
 func init() {
@@ -39,7 +38,9 @@ func init() {
 	})
 }
 
-type formatVerb struct{}
+type formatVerb struct {
+	Check bool `help:"Report files that are not formatted, without rewriting them"`
+}
 
 func (v *formatVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	args := flags.Args()
@@ -55,6 +56,7 @@ func (v *formatVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		}
 		paths = append(paths, files...)
 	}
+	failed, unformatted := 0, 0
 	for _, path := range paths {
 		ctx := log.V{"file": path}.Bind(ctx)
 		f, err := ioutil.ReadFile(path)
@@ -62,22 +64,29 @@ func (v *formatVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 			log.F(ctx, true, "Failed to read api file. Error: %v", err)
 			continue
 		}
-		m := parse.NewCSTMap()
-		api, errs := parser.Parse(path, string(f), m)
-		if len(errs) > 0 {
-			l := log.From(ctx)
-			l.E("Errors while parsing api file:")
-			for i, e := range errs {
-				l.E("%d: %v", i, e)
-			}
+		formatted, err := format.Verify(path, f)
+		if err != nil {
+			log.E(ctx, "Failed to format api file. Error: %v", err)
+			failed++
 			continue
 		}
-
-		buf := &bytes.Buffer{}
-		format.Format(api, m, buf)
-		if err = ioutil.WriteFile(path, buf.Bytes(), 0777); err != nil {
+		if bytes.Equal(f, formatted) {
+			continue
+		}
+		if v.Check {
+			log.W(ctx, "Api file is not formatted")
+			unformatted++
+			continue
+		}
+		if err = ioutil.WriteFile(path, formatted, 0777); err != nil {
 			log.E(ctx, "Failed to write formatted api file. Error: %v", err)
 		}
 	}
+	if failed > 0 {
+		return log.Errf(ctx, nil, "%d api files could not be formatted", failed)
+	}
+	if unformatted > 0 {
+		return log.Errf(ctx, nil, "%d api files are not formatted", unformatted)
+	}
 	return nil
 }
@@ -17,6 +17,8 @@ package main
 import (
 	"context"
 	"flag"
+	"net"
+	"net/http"
 	"path/filepath"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/app/crash"
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
@@ -54,7 +57,15 @@ var (
 	addLocalDevice   = flag.Bool("add-local-device", true, "Server will create a new local replay device")
 	idleTimeout      = flag.Duration("idle-timeout", 0, "_Closes GAPIS if the server is not repeatedly pinged within this duration")
 	adbPath          = flag.String("adb", "", "Path to the adb executable; leave empty to search the environment")
+	adbServer        = flag.String("adb-server", "", "_TCP host:port of the adb server to talk to directly instead of running the adb executable")
 	enableLocalFiles = flag.Bool("enable-local-files", false, "Allow clients to access local .gfxtrace files by path")
+	metricsHTTP      = flag.String("metrics-http", "", "_TCP host:port to serve the performance counters on at /metrics, in the Prometheus text format")
+	resolveParallel  = flag.Int("resolve-parallel", 0, "_Maximum number of database resolves to run at once, prioritized by request; 0 is unlimited")
+	logJSON          = flag.String("log-json", "", "_File to write log messages to as JSON, one object per line")
+	logJSONMaxSize   = flag.Int("log-json-max-size", 64, "_Size in MB after which the JSON log file is rotated; 0 disables")
+	logJSONMaxAge    = flag.Duration("log-json-max-age", 24*time.Hour, "_Age after which the JSON log file is rotated; 0 disables")
+	logJSONBackups   = flag.Int("log-json-backups", 5, "_Number of rotated JSON log files to keep")
+	exportCompatible = flag.Bool("export-compatible", false, "_Export captures without compression or an index, so they can be read by older versions")
 )
 
 func main() {
@@ -83,18 +94,53 @@ func run(ctx context.Context) error {
 	oldHandler := app.LogHandler.SetTarget(logBroadcaster)
 	addFallbackLogHandler(logBroadcaster, oldHandler)
 
+	if *logJSON != "" {
+		f, err := log.NewRotatingFile(*logJSON, log.RotateOptions{
+			MaxSize:    int64(*logJSONMaxSize) << 20,
+			MaxAge:     *logJSONMaxAge,
+			MaxBackups: *logJSONBackups,
+		})
+		if err != nil {
+			return log.Errf(ctx, err, "Couldn't create JSON log file %v", *logJSON)
+		}
+		log.I(ctx, "Logging JSON to: %v", *logJSON)
+		// The JSON log sits alongside the broadcaster so that it does not count
+		// as a listener that would silence the fallback handler.
+		jsonHandler := log.Channel(log.JSON(f), 64)
+		app.LogHandler.SetTarget(log.Broadcast(logBroadcaster, jsonHandler))
+		defer func() {
+			// Flush the pending messages, and close the file.
+			app.LogHandler.SetTarget(logBroadcaster)
+			jsonHandler.Close()
+		}()
+	}
+
 	if *adbPath != "" {
 		adb.ADB = file.Abs(*adbPath)
 	}
+	if *adbServer != "" {
+		adb.Server = adb.NewClient(*adbServer)
+	}
 
 	r := bind.NewRegistry()
 	ctx = bind.PutRegistry(ctx, r)
 	m := replay.New(ctx)
 	ctx = replay.PutManager(ctx, m)
-	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	if *resolveParallel > 0 {
+		pool := task.NewPriorityPool(*resolveParallel, benchmark.GlobalCounters, "database.resolve")
+		ctx = database.Put(ctx, database.NewInMemoryWithExecutor(ctx, pool.Execute))
+	} else {
+		ctx = database.Put(ctx, database.NewInMemory(ctx))
+	}
 
 	grpclog.SetLogger(log.From(ctx))
 
+	if *metricsHTTP != "" {
+		if err := serveMetrics(ctx, *metricsHTTP); err != nil {
+			return err
+		}
+	}
+
 	if *addLocalDevice {
 		host := bind.Host(ctx)
 		r.AddDevice(ctx, host)
@@ -122,7 +168,27 @@ func run(ctx context.Context) error {
 		DeviceScanDone:   deviceScanDone,
 		LogBroadcaster:   logBroadcaster,
 		IdleTimeout:      *idleTimeout,
+		ExportCompatible: *exportCompatible,
+	})
+}
+
+// serveMetrics serves the global performance counters in the Prometheus text
+// format at /metrics on addr, until ctx is cancelled.
+func serveMetrics(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return log.Errf(ctx, err, "Couldn't listen for metrics on %v", addr)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", benchmark.PrometheusHandler(benchmark.GlobalCounters))
+	s := &http.Server{Handler: mux}
+	crash.Go(func() { s.Serve(l) })
+	crash.Go(func() {
+		<-task.ShouldStop(ctx)
+		s.Close()
 	})
+	log.I(ctx, "Serving metrics at http://%v/metrics", l.Addr())
+	return nil
 }
 
 func monitorAndroidDevices(ctx context.Context, r *bind.Registry, onDeviceScanDone task.Task) {
@@ -0,0 +1,61 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+)
+
+type convertInputsVerb struct{ ConvertInputsFlags }
+
+func init() {
+	verb := &convertInputsVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "convert_inputs",
+		ShortHelp: "Converts recorded inputs into an editable input script",
+		Action:    verb,
+	})
+}
+
+func (verb *convertInputsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one recorded inputs file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	inputs, err := loadReplayInputs(flags.Arg(0))
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load recorded inputs")
+	}
+	script := inputRecording(inputs).Script()
+
+	var w io.Writer = os.Stdout
+	if verb.Out != "" {
+		f, err := os.Create(verb.Out)
+		if err != nil {
+			return log.Err(ctx, err, "Failed to create input script file")
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = io.WriteString(w, script.String())
+	return err
+}
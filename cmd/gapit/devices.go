@@ -19,11 +19,17 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/client"
 )
 
 type devicesVerb struct{ DevicesFlags }
@@ -32,17 +38,91 @@ func init() {
 	verb := &devicesVerb{}
 	app.AddVerb(&app.Verb{
 		Name:      "devices",
-		ShortHelp: "Lists the devices available",
+		ShortHelp: "Lists, snapshots or compares the devices available",
 		Action:    verb,
 	})
 }
 
 func (verb *devicesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
-	client, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if verb.Diff && flags.NArg() != 2 {
+		app.Usage(ctx, "Exactly two devices or snapshots expected, got %d", flags.NArg())
+		return nil
+	}
+
+	var gapis client.Client
+	getClient := func() (client.Client, error) {
+		if gapis == nil {
+			c, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+			if err != nil {
+				return nil, log.Err(ctx, err, "Failed to connect to the GAPIS server")
+			}
+			gapis = c
+		}
+		return gapis, nil
+	}
+	defer func() {
+		if gapis != nil {
+			gapis.Close()
+		}
+	}()
+
+	var live []*device.Snapshot
+	getSnapshots := func() ([]*device.Snapshot, error) {
+		if live == nil {
+			client, err := getClient()
+			if err != nil {
+				return nil, err
+			}
+			if live, err = snapshotDevices(ctx, client); err != nil {
+				return nil, err
+			}
+		}
+		return live, nil
+	}
+
+	if verb.Snapshot != "" {
+		snapshots, err := getSnapshots()
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(snapshots, "", "  ")
+		if err != nil {
+			return log.Err(ctx, err, "Couldn't marshal device snapshots to JSON")
+		}
+		if err := ioutil.WriteFile(verb.Snapshot, data, 0666); err != nil {
+			return log.Err(ctx, err, "Couldn't write the device snapshots")
+		}
+	}
+
+	if verb.Diff {
+		a, err := findSnapshot(ctx, flags.Arg(0), getSnapshots)
+		if err != nil {
+			return err
+		}
+		b, err := findSnapshot(ctx, flags.Arg(1), getSnapshots)
+		if err != nil {
+			return err
+		}
+		stdout := os.Stdout
+		fmt.Fprintf(stdout, "--- %v\n+++ %v\n", snapshotName(a, flags.Arg(0)), snapshotName(b, flags.Arg(1)))
+		diffs := device.Diff(a, b)
+		if len(diffs) == 0 {
+			fmt.Fprintln(stdout, "No differences")
+		}
+		for _, d := range diffs {
+			fmt.Fprintln(stdout, d)
+		}
+		return nil
+	}
+
+	if verb.Snapshot != "" {
+		return nil
+	}
+
+	client, err := getClient()
 	if err != nil {
-		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+		return err
 	}
-	defer client.Close()
 
 	devices, err := client.GetDevices(ctx)
 	if err != nil {
@@ -68,3 +148,105 @@ func (verb *devicesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 
 	return nil
 }
+
+// snapshotDevices returns snapshots of all the devices known to the GAPIS
+// server. The system properties of Android devices are queried with adb.
+func snapshotDevices(ctx context.Context, client client.Client) ([]*device.Snapshot, error) {
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Failed to get device list")
+	}
+
+	var androidDevices []adb.Device
+	out := make([]*device.Snapshot, len(devices))
+	for i, p := range devices {
+		o, err := client.Get(ctx, p.Path())
+		if err != nil {
+			return nil, log.Errf(ctx, err, "Couldn't resolve device %v", i)
+		}
+		d := o.(*device.Instance)
+		out[i] = &device.Snapshot{Instance: d, Timestamp: time.Now().UnixNano()}
+
+		if d.GetConfiguration().GetOS().GetKind() != device.Android || d.Serial == "" {
+			continue
+		}
+		if androidDevices == nil {
+			if androidDevices, err = adb.Devices(ctx); err != nil {
+				log.W(ctx, "Couldn't list the Android devices: %v", err)
+				androidDevices = []adb.Device{}
+			}
+		}
+		for _, a := range androidDevices {
+			if a.Instance().Serial != d.Serial {
+				continue
+			}
+			props, err := a.SystemProperties(ctx)
+			if err != nil {
+				log.W(ctx, "Couldn't get the system properties of %v: %v", d.Serial, err)
+			}
+			out[i].Properties = props
+		}
+	}
+	return out, nil
+}
+
+// findSnapshot returns the snapshot identified by arg, which is either a
+// device index, serial or name, a snapshot file, or a snapshot file followed
+// by '#' and the device index, serial or name within the file.
+func findSnapshot(ctx context.Context, arg string, live func() ([]*device.Snapshot, error)) (*device.Snapshot, error) {
+	path, selector := arg, ""
+	if i := strings.LastIndex(arg, "#"); i >= 0 {
+		path, selector = arg[:i], arg[i+1:]
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, log.Err(ctx, err, "Couldn't read the device snapshots")
+		}
+		snapshots := []*device.Snapshot{}
+		if err := json.Unmarshal(data, &snapshots); err != nil {
+			return nil, log.Errf(ctx, err, "Couldn't parse the device snapshots in '%v'", path)
+		}
+		if selector == "" {
+			if len(snapshots) != 1 {
+				return nil, log.Errf(ctx, nil, "'%v' holds %d snapshots. Select one with %v#<device>", path, len(snapshots), path)
+			}
+			return snapshots[0], nil
+		}
+		return selectSnapshot(ctx, snapshots, selector)
+	}
+
+	snapshots, err := live()
+	if err != nil {
+		return nil, err
+	}
+	return selectSnapshot(ctx, snapshots, arg)
+}
+
+// selectSnapshot returns the snapshot with the given index, serial or name.
+func selectSnapshot(ctx context.Context, snapshots []*device.Snapshot, selector string) (*device.Snapshot, error) {
+	if i, err := strconv.Atoi(selector); err == nil {
+		if i < 0 || i >= len(snapshots) {
+			return nil, log.Errf(ctx, nil, "Device index %d out of range [0..%d]", i, len(snapshots)-1)
+		}
+		return snapshots[i], nil
+	}
+	for _, s := range snapshots {
+		if i := s.GetInstance(); i.GetSerial() == selector || i.GetName() == selector {
+			return s, nil
+		}
+	}
+	return nil, log.Errf(ctx, nil, "Device '%v' not found", selector)
+}
+
+func snapshotName(s *device.Snapshot, arg string) string {
+	i := s.GetInstance()
+	name := i.GetName()
+	if i.GetSerial() != "" {
+		name += " (" + i.GetSerial() + ")"
+	}
+	if s.Timestamp != 0 {
+		name += " at " + time.Unix(0, s.Timestamp).Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v: %v", arg, strings.TrimSpace(name))
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/inspect"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/file"
+)
+
+type dumpJavaVerb struct{ DumpJavaFlags }
+
+func init() {
+	verb := &dumpJavaVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "dump_java",
+		ShortHelp: "Dumps the Java threads, classes and static fields of a debuggable app",
+		Action:    verb,
+	})
+}
+
+func (verb *dumpJavaVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one package name expected, got %d", flags.NArg())
+		return nil
+	}
+
+	opts := inspect.Options{
+		Statics:   verb.Statics,
+		MaxFrames: verb.Frames,
+	}
+	if verb.Classes != "" {
+		re, err := regexp.Compile(verb.Classes)
+		if err != nil {
+			return log.Err(ctx, err, "Invalid classes regular expression")
+		}
+		opts.Classes = re
+	}
+
+	if verb.ADB != "" {
+		adb.ADB = file.Abs(verb.ADB)
+	}
+	if verb.ADBServer != "" {
+		adb.Server = adb.NewClient(verb.ADBServer)
+	}
+
+	d, err := getADBDevice(ctx, verb.Device)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := d.InstalledPackage(ctx, flags.Arg(0))
+	if err != nil {
+		return log.Err(ctx, err, "Finding package")
+	}
+	if !pkg.Debuggable {
+		log.W(ctx, "Package '%v' does not appear to be debuggable", pkg.Name)
+	}
+	pid, err := pkg.Pid(ctx)
+	if err != nil {
+		return log.Errf(ctx, err, "Finding the process of '%v'. Is it running?", pkg.Name)
+	}
+
+	var snapshot *inspect.Snapshot
+	err = withJDWP(ctx, d, pid, func(conn *jdwp.Connection) error {
+		snapshot, err = inspect.Take(ctx, conn, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if verb.Out != "" {
+		f, err := os.Create(verb.Out)
+		if err != nil {
+			return log.Err(ctx, err, "Failed to create output file")
+		}
+		defer f.Close()
+		w = f
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// withJDWP connects to the JDWP agent of the process with the given pid and
+// calls f with the connection.
+func withJDWP(ctx context.Context, d adb.Device, pid int, f func(*jdwp.Connection) error) error {
+	const (
+		reconnectAttempts = 10
+		reconnectDelay    = time.Second
+	)
+
+	port, err := adb.LocalFreeTCPPort()
+	if err != nil {
+		return log.Err(ctx, err, "Finding free port")
+	}
+	if err := d.Forward(ctx, port, adb.Jdwp(pid)); err != nil {
+		return log.Err(ctx, err, "Setting up JDWP port forwarding")
+	}
+	defer d.RemoveForward(ctx, port)
+
+	var sock net.Conn
+	var conn *jdwp.Connection
+	err = task.Retry(ctx, reconnectAttempts, reconnectDelay, func(ctx context.Context) (bool, error) {
+		if sock, err = net.Dial("tcp", fmt.Sprintf("localhost:%v", port)); err != nil {
+			return false, err
+		}
+		if conn, err = jdwp.Open(ctx, sock); err != nil {
+			sock.Close()
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return log.Err(ctx, err, "Connecting to JDWP. Is another debugger attached?")
+	}
+	defer sock.Close()
+
+	return f(conn)
+}
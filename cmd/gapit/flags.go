@@ -79,7 +79,9 @@ type (
 		Device string `help:"Device to spawn on. One of: 'none', 'host', 'android' or <device-serial>"`
 	}
 	DevicesFlags struct {
-		Gapis GapisFlags
+		Gapis    GapisFlags
+		Snapshot string `help:"write snapshots of the devices, including their system properties, to this JSON file"`
+		Diff     bool   `help:"compare the two devices given as arguments, each a device index, serial, snapshot file or file#device"`
 	}
 	GapisFlags struct {
 		Profile string `help:"_produce a pprof file from gapis"`
@@ -159,11 +161,12 @@ type (
 		OutputTraceFile string `help:"file name for the updated trace"`
 	}
 	StateFlags struct {
-		Gapis  GapisFlags
-		Gapir  GapirFlags
-		At     flags.U64Slice    `help:"command/subcommand index to get the state after. Empty for last"`
-		Depth  int               `help: "How many nodes deep should the state tree be displayed. -1 for all"`
-		Filter flags.StringSlice `help: "Which path through the tree should we filter to, default All"`
+		Gapis    GapisFlags
+		Gapir    GapirFlags
+		At       flags.U64Slice    `help:"command/subcommand index to get the state after. Empty for last"`
+		DiffFrom flags.U64Slice    `name:"diff-from" help:"command/subcommand index of the state to compare against. Only changed state is printed"`
+		Depth    int               `help: "How many nodes deep should the state tree be displayed. -1 for all"`
+		Filter   flags.StringSlice `help: "Which path through the tree should we filter to, default All"`
 	}
 	StressTestFlags struct {
 		Gapis GapisFlags
@@ -184,7 +187,7 @@ type (
 			Activity       string `help:"the full activity name"`
 			Action         string `help:"the full action name"`
 			Attach         bool   `help:"attach to running instance of the specified package"`
-			Logcat         bool   `help:"print the output of logcat while tracing"`
+			Logcat         bool   `help:"print the output of logcat while tracing and store it in the capture"`
 			AdditionalArgs string `help:"additional arguments to pass to am start"`
 		}
 		APK     file.Path `help:"the path to an apk to install"`
@@ -204,7 +207,8 @@ type (
 			Cache bool `help:"clear package data before running it"`
 		}
 		Input struct {
-			File string `help:"_the file to use for recorded inputs"`
+			File   string `help:"_the file to use for recorded inputs"`
+			Script string `help:"_the input script to play, see convert_inputs"`
 		}
 		Replay struct {
 			Inputs bool `help:"_replay the inputs from file"`
@@ -221,17 +225,20 @@ type (
 		No struct {
 			Buffer bool `help:"Do not buffer the output, this helps if the application crashes"`
 		}
-		API string `help:"only capture the given API valid options are gles and vulkan"`
-		ADB string `help:"Path to the adb executable; leave empty to search the environment"`
+		API       string `help:"only capture the given API valid options are gles and vulkan"`
+		ADB       string `help:"Path to the adb executable; leave empty to search the environment"`
+		ADBServer string `help:"_host:port of the adb server to talk to directly instead of running the adb executable"`
 	}
 	PackagesFlags struct {
 		DeviceFlags
 		Icons       bool           `help:"if true then package icons are also dumped."`
 		IconDensity float64        `help:"_scale multiplier on icon density."`
+		Labels      bool           `help:"if true then the application labels and version names are read from the installed APKs. This pulls every APK from the device, so it is slow."`
 		Format      PackagesOutput `help:"output format"`
 		Out         string         `help:"output file, standard output if none"`
 		DataHeader  string         `help:"marker to write before package data"`
 		ADB         string         `help:"Path to the adb executable; leave empty to search the environment"`
+		ADBServer   string         `help:"_host:port of the adb server to talk to directly instead of running the adb executable"`
 	}
 	ScreenshotFlags struct {
 		Gapis GapisFlags
@@ -242,7 +249,40 @@ type (
 		NoOpt bool           `help:"disables optimization of the replay stream"`
 		CommandFilterFlags
 	}
+	ConvertInputsFlags struct {
+		Out string `help:"output file, standard output if none"`
+	}
+	DumpJavaFlags struct {
+		Device    string            `help:"serial of the android device; the only attached device if empty"`
+		Classes   string            `help:"regular expression matching the names of the loaded classes to dump"`
+		Statics   flags.StringSlice `help:"static fields to dump, as class names or class#field"`
+		Frames    int               `help:"maximum number of stack frames to dump per thread, 0 for all"`
+		Out       string            `help:"output file, standard output if none"`
+		ADB       string            `help:"Path to the adb executable; leave empty to search the environment"`
+		ADBServer string            `help:"_host:port of the adb server to talk to directly instead of running the adb executable"`
+	}
 	UnpackFlags struct {
 		Verbose bool `help:"if true, then output will not be truncated"`
 	}
+	PackOutputFlags struct {
+		Out      string `help:"the output file"`
+		Text     bool   `help:"if true, write the editable text form instead of a protopack file"`
+		Compress bool   `help:"if true, compress the chunks of the output protopack file"`
+		Index    bool   `help:"if true, end the output protopack file with an index of its root groups"`
+	}
+	PackFilterFlags struct {
+		Drop flags.StringSlice `help:"proto type names of the groups and objects to drop with their descendants"`
+		PackOutputFlags
+	}
+	PackExtractFlags struct {
+		Groups  flags.U64Slice `help:"identifiers of the groups to extract with their descendants"`
+		Objects bool           `help:"if true, also keep the objects that are not in any group"`
+		PackOutputFlags
+	}
+	PackConcatFlags struct {
+		PackOutputFlags
+	}
+	PackConvertFlags struct {
+		PackOutputFlags
+	}
 )
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -30,7 +31,9 @@ import (
 	"github.com/google/gapid/core/app/crash"
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
 	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/android/inputscript"
 )
 
 // The input file is essentially a sparse table of integer values.
@@ -344,3 +347,137 @@ func startReplayingInputs(ctx context.Context, d adb.Device, replayInputsIn stri
 	})
 	return nil
 }
+
+// Convert the raw inputs into a recording that can be converted to a script.
+func inputRecording(inputs inputEvents) inputscript.Recording {
+	out := inputscript.Recording{}
+	value_of := inputEvent{} // Keep track of most recent state.
+	for _, input := range inputs {
+		for k, v := range input {
+			value_of[k] = v
+		}
+		if _, ok := input[kOrientation]; ok {
+			out.Orientation = input[kOrientation]
+		}
+		if _, ok := input[kMaxX]; ok {
+			out.MinX, out.MaxX = input[kMinX], input[kMaxX]
+			out.MinY, out.MaxY = input[kMinY], input[kMaxY]
+		}
+		if pressed, ok := input[kPressed]; ok {
+			out.Touches = append(out.Touches, inputscript.RecordedTouch{
+				Time:    time.Duration(value_of[kTime]) * time.Millisecond,
+				Frame:   value_of[kFrame],
+				X:       input[kX],
+				Y:       input[kY],
+				Pressed: pressed != 0,
+			})
+		}
+		if end, ok := input[kEnd]; ok && end == 1 {
+			out.End = time.Duration(value_of[kTime]) * time.Millisecond
+		}
+	}
+	return out
+}
+
+// Load the given input script and start playing it.
+// The capture is stopped if the script reaches an 'end' step.
+func startInputScript(ctx context.Context, d adb.Device, filename string, stop task.CancelFunc) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	script, err := inputscript.Parse(f)
+	if err != nil {
+		return log.Errf(ctx, err, "Parsing input script '%v'", filename)
+	}
+
+	// Stream the logcat messages to the script for its wait conditions. The
+	// script compares their timestamps with the local clock.
+	offset := deviceClockOffset(ctx, d)
+	logcat := make(chan android.LogcatMessage, 256)
+	reader, writer := io.Pipe()
+	crash.Go(func() {
+		d.Command("logcat", "-v", "epoch", "-T", "1").Capture(writer, nil).Run(ctx)
+		writer.Close()
+	})
+	crash.Go(func() {
+		defer close(logcat)
+		defer reader.Close()
+		for lines := bufio.NewScanner(reader); lines.Scan(); {
+			select {
+			case logcat <- epochLogcatMessage(lines.Text(), offset):
+			case <-task.ShouldStop(ctx):
+				return
+			}
+		}
+	})
+	crash.Go(func() {
+		ctx := log.Enter(ctx, "Inputs")
+		end, err := inputscript.Run(ctx, d, logcat, script)
+		switch {
+		case task.Stopped(ctx):
+		case err != nil:
+			log.E(ctx, "Input script failed: %v", err)
+		case end:
+			stop()
+		}
+	})
+	return nil
+}
+
+// deviceClockOffset returns how far the clock of the device is ahead of the
+// local clock, or 0 if the device clock cannot be read.
+func deviceClockOffset(ctx context.Context, d adb.Device) time.Duration {
+	before := time.Now()
+	out, err := d.Shell("date", "+%s.%N").Call(ctx)
+	now := before.Add(time.Since(before) / 2)
+	if err != nil {
+		log.W(ctx, "Failed to read the device clock, assuming it matches the local clock: %v", err)
+		return 0
+	}
+	t, ok := parseEpoch(strings.TrimSpace(out))
+	if !ok {
+		log.W(ctx, "Failed to parse the device clock '%v', assuming it matches the local clock", out)
+		return 0
+	}
+	return t.Sub(now)
+}
+
+// epochLogcatMessage returns the logcat line printed with '-v epoch' as a
+// message with its timestamp moved from the device clock to the local clock.
+// Lines without a timestamp get the zero time.
+func epochLogcatMessage(line string, offset time.Duration) android.LogcatMessage {
+	m := android.LogcatMessage{Message: line}
+	if fields := strings.Fields(line); len(fields) > 0 {
+		if t, ok := parseEpoch(fields[0]); ok {
+			m.Timestamp = t.Add(-offset)
+		}
+	}
+	return m
+}
+
+// parseEpoch parses a time in seconds since the Unix epoch, with an optional
+// decimal fraction, such as 1500000000.123. Non-numeric fractions, as printed
+// by date implementations without %N, are ignored.
+func parseEpoch(s string) (time.Time, bool) {
+	parts := strings.SplitN(s, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	nanos := int64(0)
+	if len(parts) == 2 && len(parts[1]) > 0 {
+		frac := parts[1]
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if n, err := strconv.ParseInt(frac, 10, 64); err == nil {
+			for i := len(frac); i < 9; i++ {
+				n *= 10
+			}
+			nanos = n
+		}
+	}
+	return time.Unix(seconds, nanos), true
+}
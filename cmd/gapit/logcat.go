@@ -0,0 +1,109 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
+	"github.com/google/gapid/gapis/capture"
+)
+
+// logcatRecorder collects the logcat messages logged while tracing, so that
+// they can be appended to the capture once tracing has finished.
+type logcatRecorder struct {
+	mutex    sync.Mutex
+	file     *os.File
+	appender *pack.Appender
+	messages []*capture.LogcatMessage
+}
+
+// wrap returns a writer that passes the capture stream through to file,
+// tracking its position so that messages can be correlated with the traced
+// commands.
+func (r *logcatRecorder) wrap(file *os.File) io.Writer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.file, r.appender = file, pack.NewAppender(file)
+	return lockedWriter{&r.mutex, r.appender}
+}
+
+// add records the logcat message at the current position of the capture.
+func (r *logcatRecorder) add(m android.LogcatMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	pos := uint64(0)
+	if r.appender != nil {
+		pos = r.appender.Objects()
+	}
+	r.messages = append(r.messages, &capture.LogcatMessage{
+		Timestamp: m.Timestamp.UnixNano(),
+		Priority:  int32(m.Priority),
+		Tag:       m.Tag,
+		ProcessId: int32(m.ProcessID),
+		ThreadId:  int32(m.ThreadID),
+		Message:   m.Message,
+		Position:  pos,
+	})
+}
+
+// flush appends all the recorded messages to the capture. If the capture was
+// interrupted part way through a chunk, the partial chunk is dropped first.
+func (r *logcatRecorder) flush(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.appender == nil || len(r.messages) == 0 {
+		return nil
+	}
+	if partial := r.appender.Partial(); partial > 0 {
+		end, err := r.file.Seek(-partial, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if err := r.file.Truncate(end); err != nil {
+			return err
+		}
+		r.appender.DiscardPartial()
+		log.W(ctx, "Dropped a partial chunk of %d bytes from the end of the capture", partial)
+	}
+	w, err := r.appender.Writer()
+	if err != nil {
+		return err
+	}
+	for _, m := range r.messages {
+		if err := w.Object(ctx, m); err != nil {
+			return err
+		}
+	}
+	log.I(ctx, "Stored %d logcat messages in the capture", len(r.messages))
+	r.messages = nil
+	return nil
+}
+
+type lockedWriter struct {
+	mutex *sync.Mutex
+	to    io.Writer
+}
+
+func (w lockedWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.to.Write(data)
+}
@@ -0,0 +1,229 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+
+	// Register the proto types of the APIs, so their commands can be rewritten.
+	_ "github.com/google/gapid/gapis/api/all"
+)
+
+type packFilterVerb struct{ PackFilterFlags }
+type packExtractVerb struct{ PackExtractFlags }
+type packConcatVerb struct{ PackConcatFlags }
+type packConvertVerb struct{ PackConvertFlags }
+
+func init() {
+	packVerb := app.AddVerb(&app.Verb{
+		Name:      "pack",
+		ShortHelp: "Rewrites protopack files, or their text form",
+	})
+	packVerb.Add(&app.Verb{
+		Name:       "filter",
+		ShortHelp:  "Drops groups and objects of the given types",
+		ShortUsage: "<file>",
+		Action:     &packFilterVerb{},
+	})
+	packVerb.Add(&app.Verb{
+		Name:       "extract",
+		ShortHelp:  "Extracts the groups with the given identifiers",
+		ShortUsage: "<file>",
+		Action:     &packExtractVerb{},
+	})
+	packVerb.Add(&app.Verb{
+		Name:       "concat",
+		ShortHelp:  "Concatenates files",
+		ShortUsage: "<file> [<file>...]",
+		Action:     &packConcatVerb{},
+	})
+	packVerb.Add(&app.Verb{
+		Name:       "convert",
+		ShortHelp:  "Converts a file to and from the text form",
+		ShortUsage: "<file>",
+		Action:     &packConvertVerb{},
+	})
+}
+
+func (verb *packFilterVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one file expected, got %d", flags.NArg())
+		return nil
+	}
+	if len(verb.Drop) == 0 {
+		app.Usage(ctx, "At least one type to drop expected")
+		return nil
+	}
+	return rewritePack(ctx, verb.PackOutputFlags, flags.Args(), func(e pack.Events) pack.Events {
+		return pack.DropTypes(e, verb.Drop...)
+	})
+}
+
+func (verb *packExtractVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one file expected, got %d", flags.NArg())
+		return nil
+	}
+	if len(verb.Groups) == 0 {
+		app.Usage(ctx, "At least one group identifier expected")
+		return nil
+	}
+	return rewritePack(ctx, verb.PackOutputFlags, flags.Args(), func(e pack.Events) pack.Events {
+		return pack.Subtrees(e, verb.Groups, verb.Objects)
+	})
+}
+
+func (verb *packConcatVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() < 1 {
+		app.Usage(ctx, "At least one file expected")
+		return nil
+	}
+	headers := &captureHeaders{}
+	return rewritePack(ctx, verb.PackOutputFlags, flags.Args(), func(e pack.Events) pack.Events {
+		headers.to = e
+		headers.inputs++
+		return headers
+	})
+}
+
+func (verb *packConvertVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one file expected, got %d", flags.NArg())
+		return nil
+	}
+	return rewritePack(ctx, verb.PackOutputFlags, flags.Args(), nil)
+}
+
+// rewritePack writes the events of all the input files, passed through the
+// optional filter, to the output file.
+func rewritePack(ctx context.Context, out PackOutputFlags, inputs []string, filter func(pack.Events) pack.Events) error {
+	if out.Out == "" {
+		app.Usage(ctx, "An output file is required")
+		return nil
+	}
+	f, err := os.Create(out.Out)
+	if err != nil {
+		return log.Errf(ctx, err, "Creating %v", out.Out)
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+
+	var events pack.Events
+	var copier *pack.Copier
+	var writer *pack.Writer
+	if out.Text {
+		events = pack.NewTextWriter(buf)
+	} else {
+		opts := pack.Options{Compress: out.Compress, Index: out.Index}
+		if writer, err = pack.NewWriterWithOptions(buf, opts); err != nil {
+			return err
+		}
+		copier = pack.NewCopier(writer)
+		events = copier
+	}
+
+	for _, in := range inputs {
+		e := events
+		if filter != nil {
+			e = filter(e)
+		}
+		if err := readPackOrText(ctx, in, e); err != nil {
+			return log.Errf(ctx, err, "Rewriting %v", in)
+		}
+		if copier != nil {
+			copier.Reset()
+		}
+	}
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// readPackOrText reads the protopack file, or its text form, at path.
+func readPackOrText(ctx context.Context, path string, events pack.Events) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(9); strings.EqualFold(string(magic), "protopack") {
+		return pack.Read(ctx, r, events, false)
+	}
+	return pack.ReadText(ctx, r, events)
+}
+
+// captureHeaders is a pack.Events implementation that passes the events
+// through to another, merging the capture headers of the concatenated files.
+// Files with different headers cannot be concatenated, as the commands of
+// one capture cannot be replayed with the device or ABI of another.
+// Only the first file may hold resources or an initial state: the resource
+// indices of the following files would clash with those of the first, and a
+// capture has a single initial state.
+type captureHeaders struct {
+	to     pack.Events
+	header proto.Message
+	inputs int // The number of files read so far, including the current one.
+}
+
+func (h *captureHeaders) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	if h.inputs > 1 && pack.TypeName(msg) == proto.MessageName(&capture.GlobalState{}) {
+		return log.Err(ctx, nil, "Only the first capture may have an initial state")
+	}
+	return h.to.BeginGroup(ctx, msg, id)
+}
+
+func (h *captureHeaders) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	return h.to.BeginChildGroup(ctx, msg, id, parentID)
+}
+
+func (h *captureHeaders) EndGroup(ctx context.Context, id uint64) error {
+	return h.to.EndGroup(ctx, id)
+}
+
+func (h *captureHeaders) Object(ctx context.Context, msg proto.Message) error {
+	if h.inputs > 1 && pack.TypeName(msg) == proto.MessageName(&capture.Resource{}) {
+		return log.Err(ctx, nil, "Only the first capture may have resources")
+	}
+	if pack.TypeName(msg) == proto.MessageName(&capture.Header{}) {
+		switch {
+		case h.header == nil:
+			h.header = msg
+		case proto.Equal(h.header, msg):
+			return nil // Only keep the first of identical headers.
+		default:
+			return log.Errf(ctx, nil, "Capture headers differ: {%v} and {%v}",
+				proto.CompactTextString(h.header), proto.CompactTextString(msg))
+		}
+	}
+	return h.to.Object(ctx, msg)
+}
+
+func (h *captureHeaders) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	return h.to.ChildObject(ctx, msg, parentID)
+}
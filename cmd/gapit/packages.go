@@ -51,6 +51,9 @@ func (verb *packagesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	if verb.ADB != "" {
 		adb.ADB = file.Abs(verb.ADB)
 	}
+	if verb.ADBServer != "" {
+		adb.Server = adb.NewClient(verb.ADBServer)
+	}
 
 	d, err := getADBDevice(ctx, verb.Device)
 	if err != nil {
@@ -62,6 +65,12 @@ func (verb *packagesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		return log.Err(ctx, err, "getting package list")
 	}
 
+	if verb.Labels {
+		if err := gapidapk.ResolveLabels(ctx, d, pkgs, verb.Icons); err != nil {
+			return log.Err(ctx, err, "resolving package labels")
+		}
+	}
+
 	w := os.Stdout
 	if verb.Out != "" {
 		f, err := os.OpenFile(verb.Out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
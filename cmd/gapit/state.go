@@ -27,6 +27,7 @@ import (
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/client"
 	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/box"
 	"github.com/google/gapid/gapis/service/path"
 )
 
@@ -35,9 +36,10 @@ type stateVerb struct{ StateFlags }
 func init() {
 	verb := &stateVerb{
 		StateFlags{
-			At:     flags.U64Slice{},
-			Depth:  -1,
-			Filter: flags.StringSlice{},
+			At:       flags.U64Slice{},
+			DiffFrom: flags.U64Slice{},
+			Depth:    -1,
+			Filter:   flags.StringSlice{},
 		},
 	}
 
@@ -79,7 +81,14 @@ func (verb *stateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 		verb.At = []uint64{uint64(boxedCapture.(*service.Capture).NumCommands) - 1}
 	}
 
-	boxedTree, err := client.Get(ctx, c.Command(uint64(verb.At[0]), verb.At[1:]...).StateAfter().Tree().Path())
+	state := c.Command(uint64(verb.At[0]), verb.At[1:]...).StateAfter()
+	treePath := state.Tree().Path()
+	if len(verb.DiffFrom) > 0 {
+		from := c.Command(uint64(verb.DiffFrom[0]), verb.DiffFrom[1:]...).StateAfter()
+		treePath = state.TreeDiff(from).Path()
+	}
+
+	boxedTree, err := client.Get(ctx, treePath)
 	if err != nil {
 		return log.Err(ctx, err, "Failed to load the command tree")
 	}
@@ -87,15 +96,19 @@ func (verb *stateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	tree := boxedTree.(*service.StateTree)
 
 	return traverseStateTree(ctx, client, tree.Root, verb.Depth, verb.Filter, func(n *service.StateTreeNode, prefix string) error {
-		name := n.Name + ":"
+		name := stateChangePrefix(n.Change) + n.Name + ":"
 		if n.Preview != nil {
-			v := n.Preview.Get()
+			var constants *service.ConstantSet
 			if n.Constants != nil {
-				constants, err := getConstantSet(ctx, client, n.Constants)
+				cs, err := getConstantSet(ctx, client, n.Constants)
 				if err != nil {
 					return log.Err(ctx, err, "Couldn't fetch constant set")
 				}
-				v = constants.Sprint(v)
+				constants = cs
+			}
+			v := statePreview(n.Preview, constants)
+			if n.FromPreview != nil && n.PreviewIsValue {
+				v = fmt.Sprint(statePreview(n.FromPreview, constants), " → ", v)
 			}
 			fmt.Fprintln(os.Stdout, prefix, name, v)
 		} else {
@@ -105,6 +118,31 @@ func (verb *stateVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	}, "", true)
 }
 
+// stateChangePrefix returns the prefix used to print a node of a state tree
+// diff.
+func stateChangePrefix(c service.StateTreeNodeChange) string {
+	switch c {
+	case service.StateTreeNodeChange_Added:
+		return "+"
+	case service.StateTreeNodeChange_Removed:
+		return "-"
+	case service.StateTreeNodeChange_Modified:
+		return "~"
+	default:
+		return ""
+	}
+}
+
+// statePreview returns the printable preview value, using the constant names
+// if constants is not nil.
+func statePreview(preview *box.Value, constants *service.ConstantSet) interface{} {
+	v := preview.Get()
+	if constants != nil {
+		v = constants.Sprint(v)
+	}
+	return v
+}
+
 func traverseStateTree(
 	ctx context.Context,
 	c client.Client,
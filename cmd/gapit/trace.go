@@ -19,6 +19,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -29,6 +30,7 @@ import (
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/app/analytics"
 	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/context/keys"
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android"
@@ -88,6 +90,9 @@ func (verb *traceVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 	if verb.ADB != "" {
 		adb.ADB = file.Abs(verb.ADB)
 	}
+	if verb.ADBServer != "" {
+		adb.Server = adb.NewClient(verb.ADBServer)
+	}
 
 	switch verb.API {
 	case "vulkan":
@@ -185,7 +190,7 @@ func (verb *traceVerb) captureLocal(ctx context.Context, flags flag.FlagSet, por
 		output = "capture.gfxtrace"
 	}
 	process := &client.Process{Port: port, Device: device, Options: options.Options}
-	return doCapture(ctx, process, output, start, verb.For)
+	return doCapture(ctx, process, output, start, verb.For, nil)
 }
 
 func (verb *traceVerb) captureADB(ctx context.Context, flags flag.FlagSet, start task.Signal, options traceOptions) error {
@@ -198,13 +203,16 @@ func (verb *traceVerb) captureADB(ctx context.Context, flags flag.FlagSet, start
 		analytics.TargetDevice(d.Instance().GetConfiguration()),
 	)
 
+	var logcat *logcatRecorder
 	if options.monitorLogcat {
+		logcat = &logcatRecorder{}
 		c := make(chan android.LogcatMessage, 32)
 		// this is to prevent logcat messages from triggering failures in robot
 		ctx := log.PutHandler(ctx, log.Channel(app.Flags.Log.Style.Handler(log.Stdout()), 32))
 		go func() {
 			for m := range c {
 				m.Log(ctx)
+				logcat.add(m)
 			}
 		}()
 		go d.Logcat(ctx, c)
@@ -356,12 +364,17 @@ func (verb *traceVerb) captureADB(ctx context.Context, flags flag.FlagSet, start
 		if err := startReplayingInputs(ctx, d, inputFile, stop); err != nil {
 			return err
 		}
+	} else if verb.Input.Script != "" {
+		log.I(ctx, "Starting input script")
+		if err := startInputScript(ctx, d, verb.Input.Script, stop); err != nil {
+			return err
+		}
 	}
 
-	return doCapture(ctx, process, output, start, verb.For)
+	return doCapture(ctx, process, output, start, verb.For, logcat)
 }
 
-func doCapture(ctx context.Context, process *client.Process, out string, start task.Signal, duration time.Duration) error {
+func doCapture(ctx context.Context, process *client.Process, out string, start task.Signal, duration time.Duration, logcat *logcatRecorder) error {
 	log.I(ctx, "Creating file '%v'", out)
 	os.MkdirAll(filepath.Dir(out), 0755)
 	file, err := os.Create(out)
@@ -370,14 +383,28 @@ func doCapture(ctx context.Context, process *client.Process, out string, start t
 	}
 	defer file.Close()
 
+	captureCtx := ctx
 	if duration > 0 {
-		ctx, _ = task.WithTimeout(ctx, duration)
+		captureCtx, _ = task.WithTimeout(ctx, duration)
+	}
+
+	var w io.Writer = file
+	if logcat != nil {
+		w = logcat.wrap(file)
 	}
 
-	_, err = process.Capture(ctx, start, file)
+	_, err = process.Capture(captureCtx, start, w)
 	if err != nil {
 		return err
 	}
+
+	if logcat != nil {
+		// The capture may have been stopped by cancelling its context, but
+		// the messages still need to be written.
+		if err := logcat.flush(keys.Clone(context.Background(), ctx)); err != nil {
+			log.W(ctx, "Failed to store the logcat messages in the capture: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -28,12 +28,12 @@ import (
 )
 
 var (
-	jarSignCmd     = flag.String("jarsigner", "jarsigner", "path to jarsigner")
-	zipAlignCmd    = flag.String("zipalign", "zipalign", "path to zipalign")
 	keyPass        = flag.String("keypass", "android", "key passphrase")
 	keyAlias       = flag.String("keyalias", "androiddebugkey", "key alias")
 	storePass      = flag.String("storepass", "android", "key store passphrase")
 	keyStore       = flag.String("keystore", "~/.android/debug.keystore", "key store location")
+	keyPath        = flag.String("key", "", "PEM private key location, used instead of the key store")
+	certPath       = flag.String("cert", "", "PEM certificate location for -key")
 	forceOverwrite = flag.Bool("y", false, "overwrite existing destination")
 )
 
@@ -65,11 +65,11 @@ func run(ctx context.Context) error {
 	}
 
 	return apk.ApkDebugifier{
-		JarSignCmd:   *jarSignCmd,
-		ZipAlignCmd:  *zipAlignCmd,
 		KeyPass:      *keyPass,
 		KeyAlias:     *keyAlias,
 		StorePass:    *storePass,
 		KeyStorePath: *keyStore,
+		KeyPath:      *keyPath,
+		CertPath:     *certPath,
 	}.Run(ctx, src, dst)
 }
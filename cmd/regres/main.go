@@ -23,15 +23,18 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"os/user"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/benchmark/regress"
 	"github.com/google/gapid/core/git"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/shell"
@@ -46,6 +49,11 @@ var (
 	atSHA     = flag.String("at", "", "The SHA or branch of the first changelist to profile")
 	count     = flag.Int("count", 2, "The number of changelists to profile since HEAD")
 	tracePath = flag.String("trace", "", "Path to a .gfxtrace used for report timing")
+
+	bench      = flag.String("bench", "", "Space separated Go packages to benchmark at HEAD and compare against the stored baseline, instead of profiling the changelists")
+	benchCount = flag.Int("bench-count", 5, "The number of times to run each benchmark")
+	benchStore = flag.String("bench-store", "", "Path to the directory of stored benchmark results. Defaults to ~/.gapid/regres")
+	benchDepth = flag.Int("bench-depth", 50, "The number of changelists before HEAD to search for baseline benchmark results")
 )
 
 func main() {
@@ -99,6 +107,10 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("Local changes found. Please submit any changes and run again")
 	}
 
+	if *bench != "" {
+		return runBenchmarks(ctx, g)
+	}
+
 	branch, err := g.CurrentBranch(ctx)
 	if err != nil {
 		return err
@@ -253,6 +265,72 @@ func run(ctx context.Context) error {
 	return nil
 }
 
+// runBenchmarks runs the Go benchmarks at HEAD, stores the results and
+// compares them against the results of the nearest ancestor changelist.
+func runBenchmarks(ctx context.Context, g git.Git) error {
+	dir := *benchStore
+	if dir == "" {
+		u, err := user.Current()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(u.HomeDir, ".gapid", "regres")
+	}
+	store, err := regress.NewStore(dir)
+	if err != nil {
+		return err
+	}
+
+	head, err := g.HeadCL(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	log.I(ctx, "Running benchmarks at %v: %v", head.SHA.String()[:6], head.Subject)
+	args := []string{"test", "-run", "^$", "-bench", ".", "-count", strconv.Itoa(*benchCount)}
+	args = append(args, strings.Fields(*bench)...)
+	cmd := shell.Cmd{
+		Name:      "go",
+		Args:      args,
+		Verbosity: *verbose,
+		Dir:       *root,
+	}
+	output, err := cmd.Call(ctx)
+	if err != nil {
+		return log.Err(ctx, err, "Benchmarks failed")
+	}
+	results, err := regress.ParseGoBenchmarks(strings.NewReader(output))
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("No benchmark results found")
+	}
+
+	run := &regress.Run{SHA: head.SHA, Subject: head.Subject, Results: results}
+	if err := store.Add(ctx, run); err != nil {
+		return err
+	}
+
+	base, err := store.Baseline(ctx, g, head.SHA, *benchDepth)
+	if err == regress.ErrNoBaseline {
+		log.W(ctx, "No baseline results found in the last %d changelists", *benchDepth)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cmps := regress.Compare(base, run, regress.DefaultOptions)
+	if err := regress.WriteReport(os.Stdout, base, run, cmps); err != nil {
+		return err
+	}
+	if n := regress.Regressions(cmps); n > 0 {
+		return fmt.Errorf("%d benchmarks regressed", n)
+	}
+	return nil
+}
+
 func withTouchedGLES(ctx context.Context, r *rand.Rand, f func() error) error {
 	glesAPIPath := filepath.Join(*root, "gapis", "api", "gles", "gles.api")
 	fi, err := os.Stat(glesAPIPath)
@@ -64,7 +64,7 @@ func (s Samples) Analyse() Fit {
 	for _, c := range complexities {
 		fit, err := c.Fit(s)
 		if err < bestErr {
-			best = fit
+			best, bestErr = fit, err
 		}
 	}
 	return best
@@ -81,4 +81,5 @@ func (s Samples) String() string {
 
 var complexities = []Complexity{
 	linearTime{},
+	quadraticTime{},
 }
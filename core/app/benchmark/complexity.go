@@ -42,8 +42,34 @@ func (linearTime) String() string { return "O(n)" }
 //   https://en.wikipedia.org/wiki/Covariance
 //   https://en.wikipedia.org/wiki/Variance
 func (linearTime) Fit(samples Samples) (fit Fit, err float64) {
+	α, β, err := regression(samples, func(x float64) float64 { return x })
+	if err == math.MaxFloat64 {
+		return nil, err
+	}
+	return LinearFit{time.Duration(α), time.Duration(β)}, err
+}
+
+// QuadraticTime represents an algorithmic complexity of O(n²).
+var QuadraticTime quadraticTime
+
+type quadraticTime struct{}
+
+func (quadraticTime) String() string { return "O(n²)" }
+
+// Fit calculates simple linear regression against the square of the index.
+func (quadraticTime) Fit(samples Samples) (fit Fit, err float64) {
+	α, β, err := regression(samples, func(x float64) float64 { return x * x })
+	if err == math.MaxFloat64 {
+		return nil, err
+	}
+	return QuadraticFit{time.Duration(α), time.Duration(β)}, err
+}
+
+// regression fits y = α + β·f(x) to the samples, returning the coefficients
+// and the mean squared error of the fit.
+func regression(samples Samples, f func(x float64) float64) (α, β, err float64) {
 	if len(samples) < 2 {
-		return nil, math.MaxFloat64
+		return 0, 0, math.MaxFloat64
 	}
 
 	n := len(samples)
@@ -56,17 +82,16 @@ func (linearTime) Fit(samples Samples) (fit Fit, err float64) {
 		}
 		return sum / float64(n)
 	}
-	x := func(i int) float64 { return float64(samples[i].Index) }
+	x := func(i int) float64 { return f(float64(samples[i].Index)) }
 	y := func(i int) float64 { return float64(samples[i].Time) }
 	E_x := E(x)
 	E_y := E(y)
 	Cov := E(func(i int) float64 { return (x(i) - E_x) * (y(i) - E_y) })
 	Var := E(func(i int) float64 { return Sqr(x(i) - E_x) })
-	β := Cov / Var
-	α := E_y - β*E_x
+	β = Cov / Var
+	α = E_y - β*E_x
 	err = E(func(i int) float64 { return Sqr(α + β*x(i) - y(i)) })
-
-	return LinearFit{time.Duration(α), time.Duration(β)}, 0
+	return α, β, err
 }
 
 // LinearFit is a linear time fitting (y = α + βx).
@@ -82,3 +107,17 @@ func NewLinearFit(α, β time.Duration) LinearFit {
 func (f LinearFit) String() string {
 	return fmt.Sprintf("%v + %v per sample", f.α, f.β)
 }
+
+// QuadraticFit is a quadratic time fitting (y = α + βx²).
+type QuadraticFit struct {
+	α time.Duration // Fixed systemic cost
+	β time.Duration // Cost per sample squared
+}
+
+func NewQuadraticFit(α, β time.Duration) QuadraticFit {
+	return QuadraticFit{α, β}
+}
+
+func (f QuadraticFit) String() string {
+	return fmt.Sprintf("%v + %v per sample²", f.α, f.β)
+}
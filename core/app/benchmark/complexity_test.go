@@ -29,6 +29,7 @@ func TestConstantTime(t *testing.T) {
 	}{
 		{benchmark.Samples{{5, 100}, {7, 100}, {10, 100}}, benchmark.NewLinearFit(100, 0)},
 		{benchmark.Samples{{5, 100}, {7, 102}, {10, 105}}, benchmark.NewLinearFit(95, 1)},
+		{benchmark.Samples{{2, 120}, {4, 180}, {8, 420}}, benchmark.NewQuadraticFit(100, 5)},
 	} {
 		fit := test.samples.Analyse()
 		assert.For(ctx, "fit").That(fit).Equals(test.fit)
@@ -33,6 +33,17 @@ func Duration(name string) *DurationCounter {
 	return GlobalCounters.Duration(name)
 }
 
+// Gauge is a convenience function for calling GlobalCounters.Gauge(name).
+func Gauge(name string) *GaugeCounter {
+	return GlobalCounters.Gauge(name)
+}
+
+// Histogram is a convenience function for calling
+// GlobalCounters.Histogram(name, bounds).
+func Histogram(name string, bounds []float64) *HistogramCounter {
+	return GlobalCounters.Histogram(name, bounds)
+}
+
 // Counters represents a collection of named performance counters.
 //
 // Individual counters are created on retrieve if they do not exist.
@@ -73,6 +84,22 @@ func (m *Counters) Duration(name string) *DurationCounter {
 	return m.getOrAllocate(name, newDurationCounter).(*DurationCounter)
 }
 
+// Gauge returns the GaugeCounter with the given name,
+// instantiating a new one if necessary.
+func (m *Counters) Gauge(name string) *GaugeCounter {
+	return m.getOrAllocate(name, newGaugeCounter).(*GaugeCounter)
+}
+
+// Histogram returns the HistogramCounter with the given name,
+// instantiating a new one with the bucket upper bounds if necessary.
+// The bounds must be sorted in increasing order, and are ignored if the
+// counter already exists.
+func (m *Counters) Histogram(name string, bounds []float64) *HistogramCounter {
+	return m.getOrAllocate(name, func() interface{} {
+		return newHistogramCounter(bounds)
+	}).(*HistogramCounter)
+}
+
 // All retrieves a copy of the counters keyed by name.
 func (m *Counters) All() map[string]interface{} {
 	m.mutex.Lock()
@@ -0,0 +1,54 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// GaugeCounter is a Counter that holds a float64 value that can go up and
+// down, such as a queue depth or a cache size.
+type GaugeCounter uint64
+
+func newGaugeCounter() interface{} {
+	return new(GaugeCounter)
+}
+
+// Get retrieves the value of this counter.
+func (c *GaugeCounter) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64((*uint64)(c)))
+}
+
+// Set assigns v to the counter.
+func (c *GaugeCounter) Set(v float64) {
+	atomic.StoreUint64((*uint64)(c), math.Float64bits(v))
+}
+
+// Add adds the given value to the value of this counter.
+func (c *GaugeCounter) Add(v float64) {
+	for {
+		old := atomic.LoadUint64((*uint64)(c))
+		new := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64((*uint64)(c), old, new) {
+			return
+		}
+	}
+}
+
+// Reset resets the counter to 0.
+func (c *GaugeCounter) Reset() {
+	c.Set(0)
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are histogram bucket upper bounds, in seconds, suitable for
+// request latencies from 1ms to about 30s.
+var LatencyBuckets = ExponentialBuckets(0.001, 2, 16)
+
+// ExponentialBuckets returns count histogram bucket upper bounds, the first
+// being start and each subsequent bound being factor times the previous.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	out := make([]float64, count)
+	for i := range out {
+		out[i] = start
+		start *= factor
+	}
+	return out
+}
+
+// HistogramCounter is a Counter that counts observed values in buckets.
+type HistogramCounter struct {
+	bounds []float64
+	counts []uint64 // The last bucket counts values above all bounds.
+	count  uint64
+	sum    uint64 // float64 bits
+}
+
+// HistogramSnapshot is a copy of the state of a HistogramCounter.
+type HistogramSnapshot struct {
+	// Bounds are the upper bounds of the buckets.
+	Bounds []float64
+	// Counts are the number of observed values less than or equal to the
+	// bound of the same index. Counts is one longer than Bounds, the last
+	// entry being the total number of observations.
+	Counts []uint64
+	// Sum is the sum of all the observed values.
+	Sum float64
+}
+
+func newHistogramCounter(bounds []float64) interface{} {
+	return &HistogramCounter{
+		bounds: append([]float64{}, bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds v to the histogram.
+func (c *HistogramCounter) Observe(v float64) {
+	i := sort.SearchFloat64s(c.bounds, v)
+	atomic.AddUint64(&c.counts[i], 1)
+	atomic.AddUint64(&c.count, 1)
+	for {
+		old := atomic.LoadUint64(&c.sum)
+		new := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&c.sum, old, new) {
+			return
+		}
+	}
+}
+
+// ObserveDuration adds the duration d to the histogram, in seconds.
+func (c *HistogramCounter) ObserveDuration(d time.Duration) {
+	c.Observe(d.Seconds())
+}
+
+// Start returns the current time. It's meant to be used with Stop()
+// as a way to time blocks of code and observe their durations.
+func (c *HistogramCounter) Start() time.Time {
+	return time.Now()
+}
+
+// Stop observes the Duration elapsed since the time.Time received as
+// argument.
+func (c *HistogramCounter) Stop(startTime time.Time) {
+	c.ObserveDuration(time.Since(startTime))
+}
+
+// Count returns the number of observed values.
+func (c *HistogramCounter) Count() uint64 {
+	return atomic.LoadUint64(&c.count)
+}
+
+// Snapshot returns a copy of the histogram's buckets, with the counts made
+// cumulative.
+func (c *HistogramCounter) Snapshot() HistogramSnapshot {
+	out := HistogramSnapshot{
+		Bounds: c.bounds,
+		Counts: make([]uint64, len(c.counts)),
+		Sum:    math.Float64frombits(atomic.LoadUint64(&c.sum)),
+	}
+	total := uint64(0)
+	for i := range c.counts {
+		total += atomic.LoadUint64(&c.counts[i])
+		out.Counts[i] = total
+	}
+	return out
+}
+
+// Reset clears all the observed values.
+func (c *HistogramCounter) Reset() {
+	for i := range c.counts {
+		atomic.StoreUint64(&c.counts[i], 0)
+	}
+	atomic.StoreUint64(&c.count, 0)
+	atomic.StoreUint64(&c.sum, 0)
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Labels is a set of name-value pairs that distinguish counters of the same
+// family, such as the device of a replay latency.
+type Labels map[string]string
+
+// Name returns the name of the counter of the family with the given labels,
+// in the form family{label="value",...} with the labels sorted by name.
+// Label values are escaped as in the Prometheus text format.
+func Name(family string, l Labels) string {
+	if len(l) == 0 {
+		return family
+	}
+	names := make([]string, 0, len(l))
+	for n := range l {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	b := bytes.Buffer{}
+	b.WriteString(family)
+	b.WriteRune('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteRune(',')
+		}
+		b.WriteString(sanitize(n))
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(l[n]))
+		b.WriteRune('"')
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// splitName splits a counter name into its family and its labels. The labels
+// are returned without the enclosing braces, in the form built by Name.
+func splitName(name string) (family, labels string) {
+	if i := strings.IndexRune(name, '{'); i >= 0 && strings.HasSuffix(name, "}") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// sanitize returns s with every character that is not valid in a Prometheus
+// metric or label name replaced with an underscore.
+func sanitize(s string) string {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			return r
+		case r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// PrometheusContentType is the content type of the Prometheus text format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes all the counters to w in the Prometheus text
+// exposition format.
+//
+// Counter families are converted to metric names by replacing each character
+// that is not valid in a metric name with an underscore. Integer counters are
+// written as untyped metrics, gauges as gauges and histograms as histograms.
+// Duration counters are written as counters in seconds, with a _seconds
+// suffix.
+// Counters of a family with a different kind to the first counter of that
+// family are skipped.
+func (m *Counters) WritePrometheus(w io.Writer) error {
+	type series struct {
+		labels  string
+		counter interface{}
+	}
+	type family struct {
+		name   string
+		kind   string
+		series []series
+	}
+	families := map[string]*family{}
+	for name, counter := range m.All() {
+		f, labels := splitName(name)
+		f = sanitize(f)
+		kind := ""
+		switch counter.(type) {
+		case *IntegerCounter:
+			kind = "untyped"
+		case *DurationCounter:
+			kind, f = "counter", f+"_seconds"
+		case *GaugeCounter:
+			kind = "gauge"
+		case *HistogramCounter:
+			kind = "histogram"
+		default:
+			continue
+		}
+		fam, ok := families[f]
+		if !ok {
+			fam = &family{name: f, kind: kind}
+			families[f] = fam
+		}
+		if fam.kind == kind {
+			fam.series = append(fam.series, series{labels, counter})
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		sort.Slice(f.series, func(i, j int) bool { return f.series[i].labels < f.series[j].labels })
+		fmt.Fprintf(out, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range f.series {
+			switch c := s.counter.(type) {
+			case *IntegerCounter:
+				writeSample(out, f.name, s.labels, float64(c.Get()))
+			case *DurationCounter:
+				writeSample(out, f.name, s.labels, c.Get().Seconds())
+			case *GaugeCounter:
+				writeSample(out, f.name, s.labels, c.Get())
+			case *HistogramCounter:
+				snapshot := c.Snapshot()
+				for i, count := range snapshot.Counts {
+					le := math.Inf(1)
+					if i < len(snapshot.Bounds) {
+						le = snapshot.Bounds[i]
+					}
+					labels := withLabel(s.labels, "le", formatFloat(le))
+					writeSample(out, f.name+"_bucket", labels, float64(count))
+				}
+				writeSample(out, f.name+"_sum", s.labels, snapshot.Sum)
+				writeSample(out, f.name+"_count", s.labels, float64(snapshot.Counts[len(snapshot.Counts)-1]))
+			}
+		}
+	}
+	return out.Flush()
+}
+
+// PrometheusHandler returns an http.Handler that serves the counters in the
+// Prometheus text exposition format.
+func PrometheusHandler(m *Counters) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", PrometheusContentType)
+		m.WritePrometheus(w)
+	})
+}
+
+func writeSample(w io.Writer, name, labels string, v float64) {
+	if labels == "" {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+	} else {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(v))
+	}
+}
+
+func withLabel(labels, name, value string) string {
+	l := name + `="` + labelEscaper.Replace(value) + `"`
+	if labels == "" {
+		return l
+	}
+	return labels + "," + l
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/assert"
+)
+
+func TestGaugeCounter(t *testing.T) {
+	ctx := assert.Context(t)
+
+	g := benchmark.NewCounters().Gauge("g")
+	g.Set(2.5)
+	g.Add(-1)
+	assert.With(ctx).That(g.Get()).Equals(1.5)
+	g.Reset()
+	assert.With(ctx).That(g.Get()).Equals(0.0)
+}
+
+func TestHistogramCounter(t *testing.T) {
+	ctx := assert.Context(t)
+
+	h := benchmark.NewCounters().Histogram("h", []float64{1, 2, 4})
+	for _, v := range []float64{0.5, 1, 1.5, 3, 10} {
+		h.Observe(v)
+	}
+	h.ObserveDuration(2 * time.Second)
+
+	s := h.Snapshot()
+	assert.With(ctx).ThatSlice(s.Bounds).Equals([]float64{1, 2, 4})
+	assert.With(ctx).ThatSlice(s.Counts).Equals([]uint64{2, 4, 5, 6})
+	assert.With(ctx).That(s.Sum).Equals(18.0)
+	assert.With(ctx).That(h.Count()).Equals(uint64(6))
+
+	h.Reset()
+	assert.With(ctx).ThatSlice(h.Snapshot().Counts).Equals([]uint64{0, 0, 0, 0})
+}
+
+func TestName(t *testing.T) {
+	ctx := assert.Context(t)
+
+	assert.With(ctx).That(benchmark.Name("a.b", nil)).Equals("a.b")
+	assert.With(ctx).That(benchmark.Name("a.b", benchmark.Labels{
+		"zone":   "x",
+		"device": `my "phone"`,
+	})).Equals(`a.b{device="my \"phone\"",zone="x"}`)
+}
+
+const expectedPrometheus = `# TYPE cache_size gauge
+cache_size 12.5
+# TYPE replay_latency histogram
+replay_latency_bucket{device="a",le="0.1"} 1
+replay_latency_bucket{device="a",le="1"} 2
+replay_latency_bucket{device="a",le="+Inf"} 3
+replay_latency_sum{device="a"} 3.55
+replay_latency_count{device="a"} 3
+replay_latency_bucket{device="b",le="0.1"} 0
+replay_latency_bucket{device="b",le="1"} 0
+replay_latency_bucket{device="b",le="+Inf"} 0
+replay_latency_sum{device="b"} 0
+replay_latency_count{device="b"} 0
+# TYPE resolve untyped
+resolve{result="hit"} 3
+resolve{result="miss"} 1
+# TYPE resolve_time_seconds counter
+resolve_time_seconds 1.5
+`
+
+func newTestCounters() *benchmark.Counters {
+	m := benchmark.NewCounters()
+	m.Integer(benchmark.Name("resolve", benchmark.Labels{"result": "hit"})).Add(3)
+	m.Integer(benchmark.Name("resolve", benchmark.Labels{"result": "miss"})).Add(1)
+	m.Duration("resolve.time").Add(1500 * time.Millisecond)
+	m.Gauge("cache.size").Set(12.5)
+	h := m.Histogram(benchmark.Name("replay.latency", benchmark.Labels{"device": "a"}), []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+	m.Histogram(benchmark.Name("replay.latency", benchmark.Labels{"device": "b"}), []float64{0.1, 1})
+	return m
+}
+
+func TestWritePrometheus(t *testing.T) {
+	ctx := assert.Context(t)
+
+	buf := &bytes.Buffer{}
+	err := newTestCounters().WritePrometheus(buf)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.With(ctx).ThatString(buf.String()).Equals(expectedPrometheus)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	ctx := assert.Context(t)
+
+	server := httptest.NewServer(benchmark.PrometheusHandler(newTestCounters()))
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL)
+	assert.With(ctx).ThatError(err).Succeeded()
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.With(ctx).That(res.Header.Get("Content-Type")).Equals(benchmark.PrometheusContentType)
+	assert.With(ctx).ThatString(string(body)).Equals(expectedPrometheus)
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/core/app/benchmark"
+)
+
+// Verdict is the outcome of a comparison of a benchmark against its baseline.
+type Verdict int
+
+const (
+	// Unchanged indicates that there was no significant change.
+	Unchanged = Verdict(iota)
+	// Improved indicates that the benchmark got significantly faster.
+	Improved
+	// Regressed indicates that the benchmark got significantly slower.
+	Regressed
+	// Added indicates that the benchmark has no baseline results.
+	Added
+	// Removed indicates that the benchmark only has baseline results.
+	Removed
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Improved:
+		return "improved"
+	case Regressed:
+		return "REGRESSED"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	default:
+		return ""
+	}
+}
+
+// Options controls when a change in a benchmark is significant.
+type Options struct {
+	// Alpha is the largest p-value of a significant change.
+	Alpha float64
+	// MinChange is the smallest relative change in the mean that is
+	// considered significant, regardless of the p-value.
+	MinChange float64
+	// FitRatio is the smallest ratio between the errors of two complexity
+	// fits of the same samples for the better fit to be significant. A change
+	// in complexity is only a regression if it is significant in both the
+	// baseline and the new samples.
+	FitRatio float64
+}
+
+// DefaultOptions are the Options used by the regres tool by default.
+var DefaultOptions = Options{Alpha: 0.05, MinChange: 0.05, FitRatio: 10}
+
+// Comparison is the comparison of a benchmark against its baseline.
+//
+// For benchmarks with Times, the statistics are of the durations in
+// nanoseconds. For benchmarks with Samples, the statistics are of the
+// durations divided by the input sizes, so that a change in the algorithmic
+// cost shows as a change in the mean even when the input sizes differ.
+type Comparison struct {
+	Name    string
+	Base    Stats
+	New     Stats
+	Change  float64 // The relative change in the mean.
+	P       float64 // The p-value of the change in the mean.
+	Verdict Verdict
+	// Sized is true if the benchmark was measured with Samples.
+	Sized bool
+	// BaseFit and NewFit are the fitted complexities of benchmarks with
+	// Samples, or nil for those without.
+	BaseFit benchmark.Fit
+	NewFit  benchmark.Fit
+	// Note describes a change that is reported without affecting the
+	// verdict, such as a change in fitted complexity that is not significant.
+	Note string
+}
+
+// Compare compares each of the results of run against the results of the same
+// name in base, returning the comparisons sorted by name.
+func Compare(base, run *Run, opts Options) []Comparison {
+	names := map[string]bool{}
+	for _, r := range base.Results {
+		names[r.Name] = true
+	}
+	for _, r := range run.Results {
+		names[r.Name] = true
+	}
+	out := make([]Comparison, 0, len(names))
+	for name := range names {
+		out = append(out, compare(name, base.Result(name), run.Result(name), opts))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Regressions returns the number of comparisons that regressed.
+func Regressions(cmps []Comparison) int {
+	count := 0
+	for _, c := range cmps {
+		if c.Verdict == Regressed {
+			count++
+		}
+	}
+	return count
+}
+
+func compare(name string, base, run *Result, opts Options) Comparison {
+	out := Comparison{Name: name}
+	out.Sized = (base != nil && len(base.Samples) > 0) || (run != nil && len(run.Samples) > 0)
+	if base != nil {
+		out.Base, out.BaseFit = measure(base)
+	}
+	if run != nil {
+		out.New, out.NewFit = measure(run)
+	}
+	switch {
+	case out.Base.N == 0:
+		out.Verdict = Added
+		return out
+	case out.New.N == 0:
+		out.Verdict = Removed
+		return out
+	}
+	if out.Base.Mean != 0 {
+		out.Change = (out.New.Mean - out.Base.Mean) / out.Base.Mean
+	}
+	out.P = welch(out.Base, out.New)
+	if out.P <= opts.Alpha && abs(out.Change) >= opts.MinChange {
+		if out.Change > 0 {
+			out.Verdict = Regressed
+		} else {
+			out.Verdict = Improved
+		}
+	}
+	baseC, newC := complexity(out.BaseFit), complexity(out.NewFit)
+	if baseC != nil && newC != nil && baseC != newC {
+		significant := clearFit(run.Samples, newC, baseC, opts.FitRatio) &&
+			clearFit(base.Samples, baseC, newC, opts.FitRatio)
+		if significant && order(newC) > order(baseC) {
+			// The cost grows faster with the input size than it used to.
+			// This is a regression even if the sampled sizes don't show it
+			// in the mean yet.
+			out.Verdict = Regressed
+		} else {
+			out.Note = fmt.Sprintf("complexity changed from %v to %v", baseC, newC)
+		}
+	}
+	return out
+}
+
+// complexity returns the complexity of the fit f, or nil if f is nil or of an
+// unknown type.
+func complexity(f benchmark.Fit) benchmark.Complexity {
+	switch f.(type) {
+	case benchmark.LinearFit:
+		return benchmark.LinearTime
+	case benchmark.QuadraticFit:
+		return benchmark.QuadraticTime
+	default:
+		return nil
+	}
+}
+
+// order returns the rank of the complexity c, where a higher rank grows faster
+// with the input size.
+func order(c benchmark.Complexity) int {
+	switch c {
+	case benchmark.LinearTime:
+		return 1
+	case benchmark.QuadraticTime:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// clearFit returns true if the samples fit the complexity better than other,
+// with an error at least ratio times smaller.
+func clearFit(samples benchmark.Samples, better, other benchmark.Complexity, ratio float64) bool {
+	s := append(benchmark.Samples{}, samples...)
+	_, betterErr := better.Fit(s)
+	_, otherErr := other.Fit(s)
+	return otherErr > betterErr*ratio
+}
+
+func measure(r *Result) (Stats, benchmark.Fit) {
+	if len(r.Samples) > 0 {
+		values := make([]float64, 0, len(r.Samples))
+		for _, s := range r.Samples {
+			if s.Index > 0 {
+				values = append(values, float64(s.Time)/float64(s.Index))
+			}
+		}
+		samples := append(benchmark.Samples{}, r.Samples...)
+		return newStats(values), samples.Analyse()
+	}
+	values := make([]float64, len(r.Times))
+	for i, t := range r.Times {
+		values[i] = float64(t)
+	}
+	return newStats(values), nil
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/app/benchmark/regress"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/git"
+	"github.com/google/gapid/core/log"
+)
+
+const goBenchOutput = `goos: linux
+goarch: amd64
+pkg: github.com/google/gapid/gapis/api/transform
+BenchmarkDCE-8             	     100	  12000000 ns/op	  1024 B/op	  10 allocs/op
+BenchmarkDCE-8             	     100	  13000000 ns/op	  1024 B/op	  10 allocs/op
+BenchmarkDCESize/n=100-8   	    1000	    100000 ns/op
+BenchmarkDCESize/n=1000-8  	     100	   1000000 ns/op
+BenchmarkDCEMode/fast-8    	    1000	      2500 ns/op
+PASS
+ok  	github.com/google/gapid/gapis/api/transform	5.123s
+pkg: github.com/google/gapid/gapis/resolve
+BenchmarkResolve	    1000	      1500 ns/op
+PASS
+`
+
+func TestParseGoBenchmarks(t *testing.T) {
+	ctx := log.Testing(t)
+	got, err := regress.ParseGoBenchmarks(strings.NewReader(goBenchOutput))
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "results").ThatSlice(got).DeepEquals([]*regress.Result{
+		{
+			Name:  "github.com/google/gapid/gapis/api/transform.BenchmarkDCE",
+			Times: []time.Duration{12 * time.Millisecond, 13 * time.Millisecond},
+		}, {
+			Name:  "github.com/google/gapid/gapis/api/transform.BenchmarkDCEMode/fast",
+			Times: []time.Duration{2500},
+		}, {
+			Name:    "github.com/google/gapid/gapis/api/transform.BenchmarkDCESize",
+			Samples: benchmark.Samples{{Index: 100, Time: 100 * time.Microsecond}, {Index: 1000, Time: time.Millisecond}},
+		}, {
+			Name:  "github.com/google/gapid/gapis/resolve.BenchmarkResolve",
+			Times: []time.Duration{1500},
+		},
+	})
+}
+
+func ms(values ...float64) []time.Duration {
+	out := make([]time.Duration, len(values))
+	for i, v := range values {
+		out[i] = time.Duration(v * float64(time.Millisecond))
+	}
+	return out
+}
+
+// sizes returns the samples of alternating index and millisecond values.
+func sizes(values ...float64) benchmark.Samples {
+	out := make(benchmark.Samples, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		out = append(out, benchmark.Sample{
+			Index: int(values[i]),
+			Time:  time.Duration(values[i+1] * float64(time.Millisecond)),
+		})
+	}
+	return out
+}
+
+func TestCompare(t *testing.T) {
+	ctx := log.Testing(t)
+	base := &regress.Run{Results: []*regress.Result{
+		{Name: "Flip", Samples: sizes(10, 10, 20, 20, 40, 40)},
+		{Name: "Noisy", Times: ms(10, 20, 10, 20, 15)},
+		{Name: "Quadratic", Samples: sizes(10, 10, 20, 20, 40, 40)},
+		{Name: "Removed", Times: ms(1)},
+		{Name: "Size", Samples: sizes(10, 10, 20, 20, 40, 40)},
+		{Name: "Slower", Times: ms(10, 11, 12, 13, 14)},
+		{Name: "Tiny", Times: ms(100, 100.1, 100.2, 100.1, 100)},
+	}}
+	run := &regress.Run{Results: []*regress.Result{
+		{Name: "Added", Times: ms(1)},
+		// Fits quadratic better, but not by enough to be significant.
+		{Name: "Flip", Samples: sizes(10, 10, 20, 18, 40, 41)},
+		{Name: "Noisy", Times: ms(12, 22, 9, 21, 18)},
+		// The same mean cost per sample, but growing with the square of the size.
+		{Name: "Quadratic", Samples: sizes(10, 30.0/7, 20, 120.0/7, 40, 480.0/7)},
+		{Name: "Size", Samples: sizes(10, 100, 20, 210, 40, 400)},
+		{Name: "Slower", Times: ms(15, 16, 17, 18, 19)},
+		{Name: "Tiny", Times: ms(101, 101.1, 101.2, 101.1, 101)},
+	}}
+
+	cmps := regress.Compare(base, run, regress.DefaultOptions)
+	verdicts := map[string]regress.Verdict{}
+	for _, c := range cmps {
+		verdicts[c.Name] = c.Verdict
+	}
+	assert.For(ctx, "verdicts").That(verdicts).DeepEquals(map[string]regress.Verdict{
+		"Added":     regress.Added,
+		"Flip":      regress.Unchanged,
+		"Noisy":     regress.Unchanged,
+		"Quadratic": regress.Regressed,
+		"Removed":   regress.Removed,
+		"Size":      regress.Regressed,
+		"Slower":    regress.Regressed,
+		"Tiny":      regress.Unchanged, // Significant, but below MinChange.
+	})
+	assert.For(ctx, "regressions").That(regress.Regressions(cmps)).Equals(3)
+
+	flip := cmps[1]
+	assert.For(ctx, "flip note").That(flip.Note).Equals("complexity changed from O(n) to O(n²)")
+	slower := cmps[6]
+	assert.For(ctx, "name").That(slower.Name).Equals("Slower")
+	// t = -5 with 8 degrees of freedom.
+	assert.For(ctx, "p").That(math.Abs(slower.P-0.001053) < 1e-5).Equals(true)
+	assert.For(ctx, "change").That(math.Abs(slower.Change-5.0/12) < 1e-9).Equals(true)
+
+	buf := &bytes.Buffer{}
+	err := regress.WriteReport(buf, base, run, cmps)
+	assert.For(ctx, "report").ThatError(err).Succeeded()
+	report := buf.String()
+	for _, s := range []string{"REGRESSED", "added", "removed", "3 regressed, 0 improved, 8 compared", "note"} {
+		assert.For(ctx, "report contains %v", s).That(strings.Contains(report, s)).Equals(true)
+	}
+}
+
+type history []git.ChangeList
+
+func (h history) LogFrom(ctx context.Context, at string, count int) ([]git.ChangeList, error) {
+	for i, cl := range h {
+		if cl.SHA.String() == at {
+			h = h[i:]
+			break
+		}
+	}
+	if len(h) > count {
+		h = h[:count]
+	}
+	return h, nil
+}
+
+func sha(b byte) git.SHA {
+	out := git.SHA{}
+	out[0] = b
+	return out
+}
+
+func TestStore(t *testing.T) {
+	ctx := log.Testing(t)
+	dir, err := ioutil.TempDir("", "regress")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	s, err := regress.NewStore(dir)
+	assert.For(ctx, "NewStore").ThatError(err).Succeeded()
+
+	h := history{
+		{SHA: sha(4), Subject: "four"},
+		{SHA: sha(3), Subject: "three"},
+		{SHA: sha(2), Subject: "two"},
+		{SHA: sha(1), Subject: "one"},
+	}
+
+	_, err = s.Baseline(ctx, h, sha(4), 3)
+	assert.For(ctx, "empty baseline").ThatError(err).Equals(regress.ErrNoBaseline)
+
+	err = s.Add(ctx, &regress.Run{SHA: sha(2), Results: []*regress.Result{{Name: "A", Times: ms(1)}}})
+	assert.For(ctx, "Add").ThatError(err).Succeeded()
+	err = s.Add(ctx, &regress.Run{SHA: sha(2), Results: []*regress.Result{
+		{Name: "A", Times: ms(2)},
+		{Name: "B", Times: ms(3)},
+	}})
+	assert.For(ctx, "Add").ThatError(err).Succeeded()
+	err = s.Add(ctx, &regress.Run{SHA: sha(4), Subject: "four"})
+	assert.For(ctx, "Add").ThatError(err).Succeeded()
+
+	got, err := s.Get(ctx, sha(2))
+	assert.For(ctx, "Get").ThatError(err).Succeeded()
+	assert.For(ctx, "merged").That(got).DeepEquals(&regress.Run{SHA: sha(2), Results: []*regress.Result{
+		{Name: "A", Times: ms(1, 2)},
+		{Name: "B", Times: ms(3)},
+	}})
+
+	missing, err := s.Get(ctx, sha(3))
+	assert.For(ctx, "Get missing").ThatError(err).Succeeded()
+	assert.For(ctx, "missing").That(missing == nil).Equals(true)
+
+	shas, err := s.SHAs()
+	assert.For(ctx, "SHAs").ThatError(err).Succeeded()
+	assert.For(ctx, "SHAs").ThatSlice(shas).Equals([]git.SHA{sha(2), sha(4)})
+
+	base, err := s.Baseline(ctx, h, sha(4), 3)
+	assert.For(ctx, "Baseline").ThatError(err).Succeeded()
+	assert.For(ctx, "baseline SHA").That(base.SHA).Equals(sha(2))
+	assert.For(ctx, "baseline subject").That(base.Subject).Equals("two")
+
+	_, err = s.Baseline(ctx, h, sha(4), 1)
+	assert.For(ctx, "shallow baseline").ThatError(err).Equals(regress.ErrNoBaseline)
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteReport writes a human readable report of the comparisons of run
+// against base to w.
+func WriteReport(w io.Writer, base, run *Run, cmps []Comparison) error {
+	fmt.Fprintf(w, "Comparing %v %v\n", short(run), run.Subject)
+	fmt.Fprintf(w, "   with baseline %v %v\n\n", short(base), base.Subject)
+
+	tw := tabwriter.NewWriter(w, 1, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tbaseline\tnew\tchange\tp\t")
+	improved := 0
+	for _, c := range cmps {
+		change, p := "", ""
+		if c.Verdict != Added && c.Verdict != Removed {
+			change, p = fmt.Sprintf("%+.1f%%", c.Change*100), fmt.Sprintf("%.3f", c.P)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			c.Name, formatStats(c.Base, c.Sized), formatStats(c.New, c.Sized),
+			change, p, c.Verdict)
+		if c.Sized {
+			fmt.Fprintf(tw, "  fit\t%v\t%v\t\t\t\n", formatFit(c.BaseFit), formatFit(c.NewFit))
+		}
+		if c.Note != "" {
+			fmt.Fprintf(tw, "  note\t%v\t\t\t\t\n", c.Note)
+		}
+		if c.Verdict == Improved {
+			improved++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d regressed, %d improved, %d compared\n", Regressions(cmps), improved, len(cmps))
+	return err
+}
+
+func short(r *Run) string {
+	return r.SHA.String()[:8]
+}
+
+func formatStats(s Stats, perSample bool) string {
+	if s.N == 0 {
+		return "-"
+	}
+	out := formatNanoseconds(s.Mean)
+	if perSample {
+		out += "/n"
+	}
+	if s.N > 1 && s.Mean != 0 {
+		out += fmt.Sprintf(" ±%.0f%%", 100*s.StdDev/s.Mean)
+	}
+	return out
+}
+
+func formatNanoseconds(ns float64) string {
+	switch {
+	case ns < 1e3:
+		return fmt.Sprintf("%.3gns", ns)
+	case ns < 1e6:
+		return fmt.Sprintf("%.3gµs", ns/1e3)
+	case ns < 1e9:
+		return fmt.Sprintf("%.3gms", ns/1e6)
+	default:
+		return fmt.Sprintf("%.3gs", ns/1e9)
+	}
+}
+
+func formatFit(f interface{ String() string }) string {
+	if f == nil {
+		return "-"
+	}
+	return f.String()
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/git"
+)
+
+// Result holds the measurements of a single benchmark.
+type Result struct {
+	// Name is the name of the benchmark.
+	Name string `json:"name"`
+	// Times are the durations of each repeated run of the benchmark.
+	Times []time.Duration `json:"times,omitempty"`
+	// Samples are the durations of runs of the benchmark over inputs of
+	// different sizes, used to measure the algorithmic cost.
+	Samples benchmark.Samples `json:"samples,omitempty"`
+}
+
+// Run holds the results of the benchmarks of a single changelist.
+type Run struct {
+	// SHA is the changelist that was benchmarked.
+	SHA git.SHA `json:"sha"`
+	// Subject is the subject line of the changelist.
+	Subject string `json:"subject,omitempty"`
+	// Results are the benchmark results, sorted by name.
+	Results []*Result `json:"results"`
+}
+
+// Result returns the result with the given name, or nil if there is none.
+func (r *Run) Result(name string) *Result {
+	i := sort.Search(len(r.Results), func(i int) bool { return r.Results[i].Name >= name })
+	if i < len(r.Results) && r.Results[i].Name == name {
+		return r.Results[i]
+	}
+	return nil
+}
+
+// Merge adds the measurements of the results to the run.
+func (r *Run) Merge(results ...*Result) {
+	for _, n := range results {
+		if o := r.Result(n.Name); o != nil {
+			o.Times = append(o.Times, n.Times...)
+			o.Samples = append(o.Samples, n.Samples...)
+			continue
+		}
+		c := *n
+		r.Results = append(r.Results, &c)
+		sort.Slice(r.Results, func(i, j int) bool { return r.Results[i].Name < r.Results[j].Name })
+	}
+}
+
+var (
+	benchLineRE = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([0-9.e+]+) ns/op`)
+	sizeRE      = regexp.MustCompile(`^(?:n=)?(\d+)$`)
+)
+
+// ParseGoBenchmarks parses the output of 'go test -bench', returning the
+// results sorted by name.
+//
+// Benchmark names are prefixed by their package. Benchmarks that are run
+// multiple times, with -count, have a time for each run. Sub-benchmarks whose
+// last name element is an input size, either 'n=<size>' or just '<size>', are
+// added as samples to the result of their parent benchmark.
+func ParseGoBenchmarks(r io.Reader) ([]*Result, error) {
+	run := Run{}
+	pkg := ""
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimSpace(line[len("pkg: "):])
+			continue
+		}
+		m := benchLineRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		ns, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		name, t := m[1], time.Duration(ns)
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		res := &Result{Name: name}
+		if i := strings.LastIndex(name, "/"); i > 0 {
+			if size := sizeRE.FindStringSubmatch(name[i+1:]); size != nil {
+				n, err := strconv.Atoi(size[1])
+				if err == nil {
+					res.Name = name[:i]
+					res.Samples.Add(n, t)
+				}
+			}
+		}
+		if len(res.Samples) == 0 {
+			res.Times = []time.Duration{t}
+		}
+		run.Merge(res)
+	}
+	return run.Results, s.Err()
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress
+
+import "math"
+
+// Stats summarizes a set of measurements.
+type Stats struct {
+	N      int     // The number of measurements.
+	Mean   float64 // The mean of the measurements.
+	StdDev float64 // The sample standard deviation of the measurements.
+}
+
+func newStats(values []float64) Stats {
+	out := Stats{N: len(values)}
+	if out.N == 0 {
+		return out
+	}
+	for _, v := range values {
+		out.Mean += v
+	}
+	out.Mean /= float64(out.N)
+	if out.N > 1 {
+		sum := 0.0
+		for _, v := range values {
+			sum += (v - out.Mean) * (v - out.Mean)
+		}
+		out.StdDev = math.Sqrt(sum / float64(out.N-1))
+	}
+	return out
+}
+
+// welch returns the two-tailed p-value of Welch's t-test for the hypothesis
+// that the measurements summarized by a and b have the same mean.
+// See https://en.wikipedia.org/wiki/Welch%27s_t-test
+func welch(a, b Stats) float64 {
+	if a.N < 2 || b.N < 2 {
+		return 1
+	}
+	va, vb := a.StdDev*a.StdDev/float64(a.N), b.StdDev*b.StdDev/float64(b.N)
+	if va+vb == 0 {
+		if a.Mean == b.Mean {
+			return 1
+		}
+		return 0
+	}
+	t := (a.Mean - b.Mean) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+	// The two-tailed p-value of Student's t-distribution.
+	return betaInc(df/2, 0.5, df/(df+t*t))
+}
+
+// betaInc returns the regularized incomplete beta function Iₓ(a, b).
+// See Numerical Recipes, section 6.4.
+func betaInc(a, b, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF evaluates the continued fraction for the incomplete beta function
+// using the modified Lentz's method.
+func betaCF(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		m := float64(m)
+		// Even step.
+		n := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + n*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + n/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// Odd step.
+		n = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + n*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + n/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
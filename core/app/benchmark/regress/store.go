@@ -0,0 +1,146 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/git"
+	"github.com/google/gapid/core/log"
+)
+
+// ErrNoBaseline is returned by Store.Baseline when none of the searched
+// changelists have stored results.
+var ErrNoBaseline = errors.New("No baseline results found")
+
+// Store is a local file store of benchmark runs, holding one JSON file per
+// changelist.
+type Store struct {
+	dir string
+}
+
+// History is the interface to the changelist history used to find baselines.
+// It is implemented by git.Git.
+type History interface {
+	LogFrom(ctx context.Context, at string, count int) ([]git.ChangeList, error)
+}
+
+// NewStore returns a Store that keeps its files in dir, creating the directory
+// if it does not exist.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir}, nil
+}
+
+func (s *Store) path(sha git.SHA) string {
+	return filepath.Join(s.dir, sha.String()+".json")
+}
+
+// Get returns the stored run for the changelist sha, or nil if there is none.
+func (s *Store) Get(ctx context.Context, sha git.SHA) (*Run, error) {
+	data, err := ioutil.ReadFile(s.path(sha))
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	run := &Run{}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, log.Errf(ctx, err, "Couldn't parse results for %v", sha)
+	}
+	return run, nil
+}
+
+// Add stores the run, merging its results with any already stored for the
+// same changelist.
+func (s *Store) Add(ctx context.Context, run *Run) error {
+	existing, err := s.Get(ctx, run.SHA)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		existing = &Run{SHA: run.SHA}
+	}
+	if run.Subject != "" {
+		existing.Subject = run.Subject
+	}
+	existing.Merge(run.Results...)
+	data, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path(run.SHA) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(run.SHA))
+}
+
+// SHAs returns the changelists that have stored runs, sorted by SHA.
+func (s *Store) SHAs() ([]git.SHA, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := []git.SHA{}
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		sha := git.SHA{}
+		if err := sha.Parse(strings.TrimSuffix(name, ".json")); err == nil {
+			out = append(out, sha)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out, nil
+}
+
+// Baseline returns the stored run of the most recent ancestor of the
+// changelist at, searching up to depth changelists back. If none of them
+// have stored results then ErrNoBaseline is returned.
+func (s *Store) Baseline(ctx context.Context, h History, at git.SHA, depth int) (*Run, error) {
+	cls, err := h.LogFrom(ctx, at.String(), depth+1)
+	if err != nil {
+		return nil, err
+	}
+	for _, cl := range cls {
+		if cl.SHA == at {
+			continue
+		}
+		run, err := s.Get(ctx, cl.SHA)
+		if err != nil {
+			return nil, err
+		}
+		if run != nil {
+			if run.Subject == "" {
+				run.Subject = cl.Subject
+			}
+			return run, nil
+		}
+	}
+	return nil, ErrNoBaseline
+}
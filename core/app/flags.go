@@ -33,7 +33,8 @@ type (
 		File   string       `help:"_The file to store the logs in"`
 	}
 	ProfileFlags struct {
-		CPU string `help:"_write cpu profile to file"`
-		Mem string `help:"_write mem profile to file"`
+		CPU   string `help:"_write cpu profile to file"`
+		Mem   string `help:"_write mem profile to file"`
+		Trace string `help:"_write a chrome trace of log spans to file"`
 	}
 )
@@ -23,7 +23,7 @@ import (
 	"github.com/google/gapid/core/log"
 )
 
-func applyProfiler(ctx context.Context, flags *ProfileFlags) func() {
+func applyProfiler(ctx context.Context, flags *ProfileFlags) (context.Context, func()) {
 	closers := []func(){}
 	if flags.CPU != "" {
 		log.I(ctx, "CPU profiling enabled")
@@ -53,7 +53,23 @@ func applyProfiler(ctx context.Context, flags *ProfileFlags) func() {
 			log.I(ctx, "Mem profile written")
 		})
 	}
-	return func() {
+	if flags.Trace != "" {
+		log.I(ctx, "Span tracing enabled")
+		f, err := os.Create(flags.Trace)
+		if err != nil {
+			log.F(ctx, true, "Span tracing failed to start.\nError: %v", err)
+		}
+		tracer := log.NewChromeTracer(f)
+		ctx = log.PutSpanHandler(ctx, tracer)
+		closers = append(closers, func() {
+			if err := tracer.Close(); err != nil {
+				log.E(ctx, "Failed to write span trace: %v", err)
+			}
+			f.Close()
+			log.I(ctx, "Span trace written")
+		})
+	}
+	return ctx, func() {
 		for _, closer := range closers {
 			closer()
 		}
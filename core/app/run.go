@@ -156,7 +156,7 @@ func Run(main task.Task) {
 		return
 	}
 
-	endProfile := applyProfiler(rootCtx, &Flags.Profile)
+	rootCtx, endProfile := applyProfiler(rootCtx, &Flags.Profile)
 
 	ctx, cancel := task.WithCancel(rootCtx)
 
@@ -15,6 +15,7 @@
 package compare
 
 import (
+	"math"
 	"reflect"
 	"unicode"
 	"unicode/utf8"
@@ -26,6 +27,8 @@ type Comparator struct {
 	Handler Handler
 	seen    seen
 	custom  *Custom
+	// tolerance is the absolute difference permitted between two floats.
+	tolerance float64
 }
 
 type seen map[seenKey]struct{}
@@ -190,6 +193,10 @@ func (t Comparator) compareValues(v1, v2 reflect.Value, ptr bool) {
 			// cant actually compare functions, so any non nil is considered a difference
 			t.Handler(t.Path.Diff(toValue(v1, ptr), toValue(v2, ptr)))
 		}
+	case reflect.Float32, reflect.Float64:
+		if f1, f2 := v1.Float(), v2.Float(); f1 != f2 && !(math.Abs(f1-f2) <= t.tolerance) {
+			t.Handler(t.Path.Diff(toValue(v1, false), toValue(v2, false)))
+		}
 	default:
 		// Normal equality suffices
 		if toValue(v1, false) != toValue(v2, false) {
@@ -53,10 +53,10 @@ func Register(f interface{}) {
 // Compare delivers all the differences it finds to the specified Handler.
 // If the reference and value are equal, the handler will never be invoked.
 func Compare(reference, value interface{}, handler Handler) {
-	compare(reference, value, handler, globalCustom)
+	compare(reference, value, handler, globalCustom, 0)
 }
 
-func compare(reference, value interface{}, handler Handler, custom *Custom) {
+func compare(reference, value interface{}, handler Handler, custom *Custom, tolerance float64) {
 	defer func() {
 		if err := recover(); err != nil {
 			if _, isLimit := err.(diffLimit); !isLimit {
@@ -65,7 +65,7 @@ func compare(reference, value interface{}, handler Handler, custom *Custom) {
 			}
 		}
 	}()
-	t := Comparator{Path: Path{}, Handler: handler, seen: seen{}, custom: custom}
+	t := Comparator{Path: Path{}, Handler: handler, seen: seen{}, custom: custom, tolerance: tolerance}
 	t.Compare(reference, value)
 }
 
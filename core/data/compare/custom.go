@@ -88,7 +88,7 @@ func (c *Custom) Register(f interface{}) {
 // when no custom comparison function has been registered with this custom.
 // If the reference and value are equal, the handler will never be invoked.
 func (c *Custom) Compare(reference, value interface{}, handler Handler) {
-	compare(reference, value, handler, c)
+	compare(reference, value, handler, c, 0)
 }
 
 // Diff returns the differences between the reference and the value.
@@ -14,4 +14,6 @@
 
 // Package compare has utilities for comparing values.
 // The DeepEquals and Diff functions are the main entry points.
+// DiffTree returns the differences as a structured tree that can be written
+// as a JSON Patch, as unified or side-by-side text, or as an HTML page.
 package compare
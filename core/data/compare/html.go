@@ -0,0 +1,77 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// WriteHTML writes the tree to w as a standalone HTML page with the given
+// title. Each node is a collapsible section holding a table of its
+// differences.
+func (t *Tree) WriteHTML(w io.Writer, title string) error {
+	return htmlTemplate.Execute(w, struct {
+		Title string
+		Count int
+		Root  *Node
+	}{title, t.Count, t.Root})
+}
+
+var htmlTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"step":    func(op interface{}) string { return fmt.Sprint(op) },
+	"value":   formatValue,
+	"label":   differenceLabel,
+	"added":   func(c Change) bool { return c == Added },
+	"removed": func(c Change) bool { return c == Removed },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: monospace; }
+details { margin-left: 1.5em; }
+table { border-collapse: collapse; margin-left: 1.5em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; vertical-align: top; }
+.reference { background: #fdd; }
+.value { background: #dfd; }
+.added .reference, .removed .value { background: #eee; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Count}} difference(s)</p>
+{{template "node" .Root}}
+</body>
+</html>
+{{define "node"}}
+{{- if .Differences}}
+<table>
+<tr><th>Change</th><th>Reference</th><th>Value</th></tr>
+{{- range .Differences}}
+<tr class="{{.Change}}"><td>{{.Change}}</td>
+<td class="reference">{{if not (added .Change)}}{{label .}}{{value .Reference}}{{end}}</td>
+<td class="value">{{if not (removed .Change)}}{{label .}}{{value .Value}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Children}}
+<details open><summary>{{step .Operation}}</summary>
+{{- template "node" .}}
+</details>
+{{- end}}
+{{end}}`))
@@ -0,0 +1,161 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Options holds the optional settings for a comparison made with
+// Options.Compare or DiffTree.
+type Options struct {
+	// Ignore is a list of path patterns for values that should not be compared.
+	// Patterns are matched against the Pointer form of a path, with each
+	// '/' separated element matched using path.Match. An element of "**"
+	// matches any number of path elements. A pattern that matches a path also
+	// ignores everything below it.
+	// For example "/Devices/*/Name" or "/**/Timestamp".
+	Ignore []string
+	// FloatTolerance is the largest absolute difference between two floating
+	// point values that are still considered equal.
+	FloatTolerance float64
+	// Limit is the maximum number of differences to gather. 0 means no limit.
+	Limit int
+	// Custom is the set of custom comparison functions to use.
+	// If nil then only the globally registered functions are used.
+	Custom *Custom
+}
+
+// Compare delivers all the differences it finds that are not ignored by o
+// to the specified Handler.
+// If the reference and value are equal, the handler will never be invoked.
+func (o Options) Compare(reference, value interface{}, handler Handler) {
+	custom := o.Custom
+	if custom == nil {
+		custom = globalCustom
+	}
+	if len(o.Ignore) > 0 {
+		ignore := make([][]string, len(o.Ignore))
+		for i, p := range o.Ignore {
+			ignore[i] = splitPointer(p)
+		}
+		next := handler
+		handler = func(p Path) {
+			tokens := p.tokens()
+			for _, pattern := range ignore {
+				if matchPattern(pattern, tokens) {
+					return
+				}
+			}
+			next(p)
+		}
+	}
+	compare(reference, value, handler, custom, o.FloatTolerance)
+}
+
+// Diff returns the differences between the reference and the value that are
+// not ignored by o.
+// If they compare equal, the length of the returned slice will be 0.
+func (o Options) Diff(reference, value interface{}) []Path {
+	if o.Limit <= 0 {
+		var diffs []Path
+		o.Compare(reference, value, func(p Path) { diffs = append(diffs, p) })
+		return diffs
+	}
+	diffs := make(collect, 0, o.Limit)
+	o.Compare(reference, value, diffs.add)
+	return ([]Path)(diffs)
+}
+
+// Pointer returns the path to the value holding the difference as a JSON
+// pointer (RFC 6901), for example "/Children/0/Value".
+// Only member, index and entry fragments contribute to the pointer.
+func (p Path) Pointer() string {
+	tokens := p.tokens()
+	if len(tokens) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for i, t := range tokens {
+		tokens[i] = escaper.Replace(t)
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+// tokens returns the unescaped pointer elements of the path.
+func (p Path) tokens() []string {
+	tokens := make([]string, 0, len(p))
+	for _, f := range p {
+		if t, ok := token(f.Operation); ok {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// token returns the pointer element for the path operation op, and whether op
+// steps into a child value.
+func token(op interface{}) (string, bool) {
+	switch op := op.(type) {
+	case MemberOp:
+		return string(op), true
+	case IndexOp:
+		return strconv.Itoa(int(op)), true
+	case EntryOp:
+		return fmt.Sprint(op.Key), true
+	default:
+		return "", false
+	}
+}
+
+// splitPointer splits the JSON pointer or pattern s into its unescaped
+// elements.
+func splitPointer(s string) []string {
+	s = strings.TrimPrefix(s, "/")
+	if s == "" {
+		return nil
+	}
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	parts := strings.Split(s, "/")
+	for i, p := range parts {
+		parts[i] = unescaper.Replace(p)
+	}
+	return parts
+}
+
+// matchPattern returns true if pattern matches tokens or any of its prefixes.
+func matchPattern(pattern, tokens []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(tokens); i++ {
+			if matchPattern(pattern[1:], tokens[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], tokens[0]); !ok {
+		return false
+	}
+	return matchPattern(pattern[1:], tokens[1:])
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PatchOperation is a single JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Patch returns the list of JSON Patch operations that transform the
+// reference into the value.
+// Length changes are implied by the add and remove operations, so are not
+// included. Removals are ordered last-to-first so that array indices remain
+// valid as the patch is applied.
+func (t *Tree) Patch() []PatchOperation {
+	replaces, removes, adds := []PatchOperation{}, []PatchOperation{}, []PatchOperation{}
+	t.Walk(func(ops []interface{}, n *Node) {
+		for _, d := range n.Differences {
+			path := d.Path.Pointer()
+			switch d.Change {
+			case Added:
+				adds = append(adds, PatchOperation{"add", path, patchValue(d.Value)})
+			case Removed:
+				removes = append(removes, PatchOperation{"remove", path, nil})
+			case Changed:
+				replaces = append(replaces, PatchOperation{"replace", path, patchValue(d.Value)})
+			case Retyped:
+				// The differing types are held in d, the value is one fragment up.
+				v := d.Path[len(d.Path)-2].Value
+				replaces = append(replaces, PatchOperation{"replace", path, patchValue(v)})
+			}
+		}
+	})
+	for i, j := 0, len(removes)-1; i < j; i, j = i+1, j-1 {
+		removes[i], removes[j] = removes[j], removes[i]
+	}
+	return append(append(replaces, removes...), adds...)
+}
+
+// WriteJSONPatch writes the tree to w as a JSON Patch (RFC 6902) document.
+func (t *Tree) WriteJSONPatch(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(t.Patch())
+}
+
+// patchValue returns v in a form that can be encoded as JSON.
+func patchValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil:
+		return json.RawMessage("null")
+	case Hidden, invalid:
+		return fmt.Sprintf("%+v", v)
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return v
+}
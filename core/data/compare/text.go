@@ -0,0 +1,100 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteUnified writes the tree to w as an indented unified diff, with the
+// reference lines prefixed with '-' and the value lines prefixed with '+'.
+func (t *Tree) WriteUnified(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	t.Walk(func(ops []interface{}, n *Node) {
+		indent := strings.Repeat("  ", len(ops))
+		if len(ops) > 0 {
+			fmt.Fprintf(b, " %s%v\n", indent[2:], n.Operation)
+		}
+		for _, d := range n.Differences {
+			label := differenceLabel(d)
+			if d.Change != Added {
+				fmt.Fprintf(b, "-%s%s%v\n", indent, label, formatValue(d.Reference))
+			}
+			if d.Change != Removed {
+				fmt.Fprintf(b, "+%s%s%v\n", indent, label, formatValue(d.Value))
+			}
+		}
+	})
+	return b.Flush()
+}
+
+// WriteSideBySide writes the tree to w as a table of differences, with the
+// path, reference and value in aligned columns.
+// Values longer than width are truncated, unless width is 0.
+func (t *Tree) WriteSideBySide(w io.Writer, width int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tCHANGE\tREFERENCE\tVALUE")
+	t.Walk(func(ops []interface{}, n *Node) {
+		for _, d := range n.Differences {
+			path := d.Path.Pointer()
+			if path == "" {
+				path = "/"
+			}
+			ref, val := "", ""
+			if d.Change != Added {
+				ref = truncate(differenceLabel(d)+formatValue(d.Reference), width)
+			}
+			if d.Change != Removed {
+				val = truncate(differenceLabel(d)+formatValue(d.Value), width)
+			}
+			fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", path, d.Change, ref, val)
+		}
+	})
+	return tw.Flush()
+}
+
+// differenceLabel returns the prefix used to describe what d compares.
+func differenceLabel(d Difference) string {
+	switch d.Change {
+	case Resized:
+		return "len "
+	case Retyped:
+		return "type "
+	default:
+		return ""
+	}
+}
+
+// formatValue returns the single line display string for v.
+func formatValue(v interface{}) string {
+	s := fmt.Sprintf("%+v", v)
+	return strings.NewReplacer("\n", "⏎", "\t", " ").Replace(s)
+}
+
+// truncate shortens s to width runes, unless width is 0.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(r[:width-1]) + "…"
+}
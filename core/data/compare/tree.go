@@ -0,0 +1,170 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change is the kind of a single difference in a Tree.
+type Change int
+
+const (
+	// Changed is a value that differs between the reference and the value.
+	Changed Change = iota
+	// Added is a container element only present in the value.
+	Added
+	// Removed is a container element only present in the reference.
+	Removed
+	// Resized is a container whose length differs.
+	Resized
+	// Retyped is a value whose type differs.
+	Retyped
+)
+
+func (c Change) String() string {
+	switch c {
+	case Changed:
+		return "changed"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Resized:
+		return "resized"
+	case Retyped:
+		return "retyped"
+	default:
+		return fmt.Sprintf("Change(%d)", int(c))
+	}
+}
+
+// Difference is a single difference held by a Node.
+type Difference struct {
+	// Change is the kind of difference.
+	Change Change
+	// Reference is the value in the reference, or Missing if Added.
+	Reference interface{}
+	// Value is the value in the compared value, or Missing if Removed.
+	Value interface{}
+	// Path is the full path of the difference, as delivered to a Handler.
+	Path Path
+}
+
+// Node is a single value in a Tree.
+type Node struct {
+	// Operation is the path operation that leads to this node from its parent.
+	// It is one of MemberOp, IndexOp or EntryOp, or nil for the root.
+	Operation interface{}
+	// Differences holds the differences found directly on this node's value.
+	Differences []Difference
+	// Children holds the nodes for the child values that contain differences.
+	Children []*Node
+}
+
+// Tree is a structured form of the differences between two values, where
+// each node is a value that has differences either directly or in its
+// children.
+type Tree struct {
+	// Root is the node for the top level values.
+	Root *Node
+	// Count is the total number of differences in the tree.
+	Count int
+}
+
+// DiffTree compares the value against the reference using the options o, and
+// returns the differences as a Tree.
+// If they compare equal, the Tree will be Empty.
+func DiffTree(reference, value interface{}, o Options) *Tree {
+	t := &Tree{Root: &Node{}}
+	for _, p := range o.Diff(reference, value) {
+		t.Add(p)
+	}
+	t.Root.sort()
+	return t
+}
+
+// Empty returns true if the tree holds no differences.
+func (t *Tree) Empty() bool { return t.Count == 0 }
+
+// Add inserts the difference p into the tree.
+func (t *Tree) Add(p Path) {
+	if len(p) == 0 {
+		return
+	}
+	n := t.Root
+	for _, f := range p[:len(p)-1] {
+		if _, ok := token(f.Operation); ok {
+			n = n.child(f.Operation)
+		}
+	}
+	n.Differences = append(n.Differences, newDifference(p))
+	t.Count++
+}
+
+// Walk calls f for every node in the tree in depth first order, passing the
+// path of operations from the root to the node.
+func (t *Tree) Walk(f func(ops []interface{}, n *Node)) {
+	t.Root.walk(nil, f)
+}
+
+func (n *Node) walk(ops []interface{}, f func([]interface{}, *Node)) {
+	f(ops, n)
+	for _, c := range n.Children {
+		n := len(ops)
+		c.walk(append(ops[:n:n], c.Operation), f)
+	}
+}
+
+func (n *Node) child(op interface{}) *Node {
+	for _, c := range n.Children {
+		if c.Operation == op {
+			return c
+		}
+	}
+	c := &Node{Operation: op}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort orders map entries by key so that trees are stable, as maps are
+// compared in random order. Members and indices keep the comparison order.
+func (n *Node) sort() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, aok := n.Children[i].Operation.(EntryOp)
+		b, bok := n.Children[j].Operation.(EntryOp)
+		return aok && bok && fmt.Sprint(a.Key) < fmt.Sprint(b.Key)
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+func newDifference(p Path) Difference {
+	last := p[len(p)-1]
+	d := Difference{Change: Changed, Reference: last.Reference, Value: last.Value, Path: p}
+	switch {
+	case last.Operation == Key && last.Reference == Missing:
+		d.Change = Added
+	case last.Operation == Key:
+		d.Change = Removed
+	case len(p) > 1 && p[len(p)-2].Operation == Length:
+		d.Change = Resized
+	case len(p) > 1 && p[len(p)-2].Operation == Type:
+		d.Change = Retyped
+	}
+	return d
+}
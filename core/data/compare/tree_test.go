@@ -0,0 +1,204 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/compare"
+)
+
+type Device struct {
+	Name     string
+	Scale    float64
+	Layers   []string
+	Settings map[string]int
+}
+
+var (
+	deviceBase = Device{
+		Name:     "phone",
+		Scale:    1.0,
+		Layers:   []string{"a", "b", "c"},
+		Settings: map[string]int{"x": 1, "y": 2},
+	}
+	deviceOther = Device{
+		Name:     "tablet",
+		Scale:    1.0000001,
+		Layers:   []string{"a"},
+		Settings: map[string]int{"x": 3, "z": 4},
+	}
+)
+
+func TestDiffTree(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(deviceBase, deviceOther, compare.Options{})
+	assert.For("count").That(tree.Count).Equals(8)
+
+	got := []string{}
+	tree.Walk(func(ops []interface{}, n *compare.Node) {
+		for _, d := range n.Differences {
+			got = append(got, d.Path.Pointer()+" "+d.Change.String())
+		}
+	})
+	assert.For("differences").ThatSlice(got).Equals([]string{
+		"/Name changed",
+		"/Scale changed",
+		"/Layers resized",
+		"/Layers/1 removed",
+		"/Layers/2 removed",
+		"/Settings/x changed",
+		"/Settings/y removed",
+		"/Settings/z added",
+	})
+	assert.For("equal").That(compare.DiffTree(deviceBase, deviceBase, compare.Options{}).Empty()).Equals(true)
+}
+
+func TestDiffTreeOptions(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(deviceBase, deviceOther, compare.Options{
+		Ignore:         []string{"/Name", "/Settings/*", "/**/1"},
+		FloatTolerance: 1e-3,
+	})
+	got := []string{}
+	tree.Walk(func(ops []interface{}, n *compare.Node) {
+		for _, d := range n.Differences {
+			got = append(got, d.Path.Pointer())
+		}
+	})
+	assert.For("differences").ThatSlice(got).Equals([]string{"/Layers", "/Layers/2"})
+
+	tree = compare.DiffTree(1.0, 1.5, compare.Options{FloatTolerance: 0.1})
+	assert.For("outside tolerance").That(tree.Count).Equals(1)
+	tree = compare.DiffTree(float32(1.0), float32(1.05), compare.Options{FloatTolerance: 0.1})
+	assert.For("float32 tolerance").That(tree.Empty()).Equals(true)
+
+	diffs := compare.Options{Limit: 2}.Diff(deviceBase, deviceOther)
+	assert.For("limit").That(len(diffs)).Equals(2)
+}
+
+func TestPointer(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		path   compare.Path
+		expect string
+	}{
+		{root.Diff(0, 1), ""},
+		{root.Member("A", 0, 0).Index(2, 0, 0).Diff(0, 1), "/A/2"},
+		{root.Entry("a/b~c", 0, 0).Length(0, 0).Diff(0, 1), "/a~1b~0c"},
+		{root.Entry(7, 0, 0).Missing(compare.Missing, 1), "/7"},
+	} {
+		assert.For("%v", test.path).That(test.path.Pointer()).Equals(test.expect)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(deviceBase, deviceOther, compare.Options{})
+	buf := &bytes.Buffer{}
+	assert.For("err").ThatError(tree.WriteJSONPatch(buf)).Succeeded()
+	assert.For("patch").ThatString(buf.String()).Equals(`[
+  {
+    "op": "replace",
+    "path": "/Name",
+    "value": "tablet"
+  },
+  {
+    "op": "replace",
+    "path": "/Scale",
+    "value": 1.0000001
+  },
+  {
+    "op": "replace",
+    "path": "/Settings/x",
+    "value": 3
+  },
+  {
+    "op": "remove",
+    "path": "/Settings/y"
+  },
+  {
+    "op": "remove",
+    "path": "/Layers/2"
+  },
+  {
+    "op": "remove",
+    "path": "/Layers/1"
+  },
+  {
+    "op": "add",
+    "path": "/Settings/z",
+    "value": 4
+  }
+]
+`)
+	patch := compare.DiffTree([]int{1}, nil, compare.Options{}).Patch()
+	assert.For("nil").ThatSlice(patch).DeepEquals([]compare.PatchOperation{
+		{Op: "replace", Path: "", Value: patch[0].Value},
+	})
+}
+
+func TestWriteUnified(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(objectBase, objectDifferent, compare.Options{})
+	buf := &bytes.Buffer{}
+	assert.For("err").ThatError(tree.WriteUnified(buf)).Succeeded()
+	assert.For("unified").ThatString(buf.String()).Equals(` .Children
+   [0]
+     .Value
+-      0
++      2
+`)
+	buf.Reset()
+	tree = compare.DiffTree(sliceBase, sliceLonger, compare.Options{})
+	assert.For("err").ThatError(tree.WriteUnified(buf)).Succeeded()
+	assert.For("unified").ThatString(buf.String()).Equals(`-len 3
++len 4
+ [3]
++  4
+`)
+}
+
+func TestWriteSideBySide(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(deviceBase, deviceOther, compare.Options{Ignore: []string{"/Settings", "/Layers"}})
+	buf := &bytes.Buffer{}
+	assert.For("err").ThatError(tree.WriteSideBySide(buf, 5)).Succeeded()
+	assert.For("side by side").ThatString(buf.String()).Equals(
+		"PATH    CHANGE   REFERENCE  VALUE\n" +
+			"/Name   changed  phone      tabl…\n" +
+			"/Scale  changed  1          1.00…\n")
+}
+
+func TestWriteHTML(t *testing.T) {
+	assert := assert.To(t)
+	tree := compare.DiffTree(deviceBase, deviceOther, compare.Options{})
+	buf := &bytes.Buffer{}
+	assert.For("err").ThatError(tree.WriteHTML(buf, "<devices>")).Succeeded()
+	html := buf.String()
+	for _, expect := range []string{
+		"<title>&lt;devices&gt;</title>",
+		"<p>8 difference(s)</p>",
+		"<summary>.Layers</summary>",
+		"<summary>[z]</summary>",
+		`<tr class="added"><td>added</td>`,
+		`<td class="value">tablet</td>`,
+	} {
+		assert.For("%s", expect).That(strings.Contains(html, expect)).Equals(true)
+	}
+}
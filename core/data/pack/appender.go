@@ -0,0 +1,228 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/fault"
+)
+
+// ErrPartialChunk is the error returned by Appender.Writer when the stream
+// does not end on a chunk boundary.
+const ErrPartialChunk = fault.Const("Pack stream ends with a partial chunk")
+
+// Appender is an io.Writer that passes a pack stream through to another
+// writer, tracking the chunks of the stream so that more objects can be
+// appended to it once the stream is complete.
+type Appender struct {
+	to      io.Writer
+	header  []byte   // bytes of the header passed through so far.
+	records bool     // true if the stream version has records.
+	varint  []byte   // bytes of the current varint.
+	fields  []uint64 // varints of the current chunk size or record prefix.
+	body    int64    // bytes of the current chunk body still to be passed through.
+	isType  bool     // true if the current chunk is a type definition.
+	record  uint64   // kind of the current record, if not a chunk.
+	block   []byte   // data of the current compressed block.
+	err     error    // error decompressing a block.
+	partial int64    // bytes written since the end of the last complete chunk.
+	chunks  uint64
+	types   uint64
+	objects uint64
+}
+
+// NewAppender returns a new Appender that writes to the supplied output
+// stream. All the data written to the Appender is passed through unmodified.
+func NewAppender(to io.Writer) *Appender {
+	return &Appender{to: to}
+}
+
+// Write passes the data through to the output stream, tracking its chunks.
+func (a *Appender) Write(data []byte) (int, error) {
+	n, err := a.to.Write(data)
+	a.track(data[:n])
+	return n, err
+}
+
+func (a *Appender) track(data []byte) {
+	for len(data) > 0 {
+		switch {
+		case len(a.header) < len(header):
+			n := len(header) - len(a.header)
+			if n > len(data) {
+				n = len(data)
+			}
+			a.header, data = append(a.header, data[:n]...), data[n:]
+			a.partial += int64(n)
+			if len(a.header) == len(header) {
+				a.partial = 0
+				version, _ := parseHeader(string(a.header))
+				a.records = version.Major >= 3
+			}
+		case a.body > 0:
+			n := len(data)
+			if int64(n) > a.body {
+				n = int(a.body)
+			}
+			if a.record == recordBlock {
+				a.block = append(a.block, data[:n]...)
+			}
+			a.body, data = a.body-int64(n), data[n:]
+			a.partial += int64(n)
+			if a.body == 0 {
+				a.endChunk()
+			}
+		default:
+			a.varint, data = append(a.varint, data[0]), data[1:]
+			a.partial++
+			if a.varint[len(a.varint)-1]&0x80 != 0 {
+				continue // More bytes of the varint to come.
+			}
+			v, _ := proto.DecodeVarint(a.varint)
+			a.varint = a.varint[:0]
+			a.fields = append(a.fields, v)
+			a.beginChunk()
+		}
+	}
+}
+
+// beginChunk starts the chunk or record once all the varints that prefix it
+// have been read.
+func (a *Appender) beginChunk() {
+	if a.fields[0] != 0 || !a.records {
+		v := a.fields[0]
+		size := int64(v>>1) ^ -int64(v&1) // Decode zig-zag encoding
+		a.body, a.isType, a.record = size, size < 0, 0
+		if a.isType {
+			a.body = -size
+		}
+	} else {
+		switch {
+		case len(a.fields) < 2:
+			return // Record kind to come.
+		case a.fields[1] == recordEnd:
+			a.fields = a.fields[:0]
+			a.partial = 0
+			return
+		case len(a.fields) < 3:
+			return // Record size to come.
+		}
+		a.record, a.body = a.fields[1], int64(a.fields[2])
+	}
+	a.fields = a.fields[:0]
+	if a.body == 0 {
+		a.endChunk()
+	}
+}
+
+func (a *Appender) endChunk() {
+	switch {
+	case a.record == recordBlock:
+		a.endBlock()
+	case a.record != 0:
+	case a.isType:
+		a.chunks++
+		a.types++
+	default:
+		a.chunks++
+		a.objects++
+	}
+	a.record = 0
+	a.partial = 0
+}
+
+// endBlock counts the chunks of the compressed block.
+func (a *Appender) endBlock() {
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(a.block)))
+	a.block = a.block[:0]
+	if err != nil {
+		a.err = err
+		return
+	}
+	for len(data) > 0 {
+		v, n := proto.DecodeVarint(data)
+		if n == 0 {
+			a.err = ErrPartialChunk
+			return
+		}
+		size := int64(v>>1) ^ -int64(v&1) // Decode zig-zag encoding
+		if size < 0 {
+			a.types++
+			size = -size
+		} else {
+			a.objects++
+		}
+		a.chunks++
+		if int64(len(data)-n) < size {
+			a.err = ErrPartialChunk
+			return
+		}
+		data = data[int64(n)+size:]
+	}
+}
+
+// Objects returns the number of complete object chunks written to the
+// Appender so far.
+func (a *Appender) Objects() uint64 {
+	return a.objects
+}
+
+// Partial returns the number of bytes written to the Appender since the end of
+// the last complete chunk of the stream.
+func (a *Appender) Partial() int64 {
+	return a.partial
+}
+
+// DiscardPartial drops the partial chunk at the end of the stream, so that
+// Writer can append to the complete chunks before it. The caller must remove
+// the last Partial bytes from the output stream before calling DiscardPartial.
+func (a *Appender) DiscardPartial() {
+	if len(a.header) < len(header) {
+		a.header = a.header[:0]
+	}
+	a.varint, a.fields, a.block = a.varint[:0], a.fields[:0], a.block[:0]
+	a.body, a.isType, a.record, a.partial = 0, false, 0, 0
+}
+
+// Writer returns a Writer that appends to the stream. It returns
+// ErrPartialChunk if the stream written so far ends part way through a chunk,
+// and so cannot be appended to. The returned Writer redeclares any types it
+// uses, and its object count continues from the objects of the stream.
+func (a *Appender) Writer() (*Writer, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if len(a.header) < len(header) || a.body > 0 || len(a.varint) > 0 || len(a.fields) > 0 {
+		return nil, ErrPartialChunk
+	}
+	w := &Writer{
+		types:   newTypes(false),
+		id:      a.chunks,
+		objects: a.objects,
+		buf:     proto.NewBuffer(make([]byte, 0, initalBufferSize)),
+		sizebuf: proto.NewBuffer(make([]byte, 0, maxVarintSize)),
+		to:      a.to,
+	}
+	// The types of the stream are not known, but reserve their indices.
+	for i := uint64(0); i < a.types; i++ {
+		w.types.entries = append(w.types.entries, nil)
+	}
+	return w, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/data/protoutil/testprotos"
+	"github.com/google/gapid/core/log"
+)
+
+func TestAppender(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, opts := range []pack.Options{
+		{},
+		{Compress: true, Index: true},
+	} {
+		testAppender(ctx, opts)
+	}
+}
+
+func testAppender(ctx context.Context, opts pack.Options) {
+	ctx = log.V{"options": opts}.Bind(ctx)
+	var id0 uint64
+
+	stream := events{
+		eventObject{&testprotos.MsgA{F32: 1, U32: 2, S32: 3, Str: "four"}},
+		eventBeginGroup{&testprotos.MsgB{F64: 6, U64: 7, S64: 11, Bool: false}, &id0},
+		eventChildObject{&testprotos.MsgA{F32: 7, U32: 8, S32: 12, Str: "thirteen"}, &id0},
+		eventEndGroup{&id0},
+	}
+	appended := events{
+		eventObject{&testprotos.MsgC{Entries: []*testprotos.MsgC_Entry{{Value: 1}}}},
+		eventObject{&testprotos.MsgA{F32: 3, U32: 4, S32: 5, Str: "six"}},
+	}
+
+	data := &bytes.Buffer{}
+	w, err := pack.NewWriterWithOptions(data, opts)
+	assert.For(ctx, "NewWriter").ThatError(err).Succeeded()
+	for _, e := range stream {
+		e.write(ctx, w)
+	}
+	assert.For(ctx, "Close").ThatError(w.Close()).Succeeded()
+
+	// Pass the stream through the appender in small writes.
+	out := &bytes.Buffer{}
+	a := pack.NewAppender(out)
+	for b := data.Bytes(); len(b) > 0; {
+		n := 3
+		if n > len(b) {
+			n = len(b)
+		}
+		a.Write(b[:n])
+		b = b[n:]
+	}
+	assert.For(ctx, "passed through").ThatSlice(out.Bytes()).Equals(data.Bytes())
+	assert.For(ctx, "Objects").That(a.Objects()).Equals(w.Objects())
+
+	partial := pack.NewAppender(&bytes.Buffer{})
+	partial.Write(data.Bytes()[:data.Len()-1])
+	_, err = partial.Writer()
+	assert.For(ctx, "Writer on partial chunk").ThatError(err).Equals(pack.ErrPartialChunk)
+	testDiscardPartial(ctx, data.Bytes()[:data.Len()-1], appended)
+	testDiscardPartial(ctx, data.Bytes()[:data.Len()/2], appended)
+
+	aw, err := a.Writer()
+	if !assert.For(ctx, "Writer").ThatError(err).Succeeded() {
+		return
+	}
+	for _, e := range appended {
+		e.write(ctx, aw)
+	}
+	assert.For(ctx, "appended objects").That(aw.Objects()).Equals(w.Objects() + 2)
+
+	// The appended chunks follow the index, so it can no longer be found.
+	_, err = pack.ReadIndex(ctx, bytes.NewReader(out.Bytes()))
+	assert.For(ctx, "ReadIndex").ThatError(err).Equals(pack.ErrNoIndex)
+
+	got := events{}
+	err = pack.Read(ctx, bytes.NewBuffer(out.Bytes()), &got, false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(append(stream, appended...))
+}
+
+// testDiscardPartial checks that the partial chunk at the end of data can be
+// dropped, and the appended events written after the complete chunks.
+func testDiscardPartial(ctx context.Context, data []byte, appended events) {
+	out := &bytes.Buffer{}
+	a := pack.NewAppender(out)
+	a.Write(data)
+	assert.For(ctx, "Partial").That(a.Partial() > 0).Equals(true)
+
+	out.Truncate(out.Len() - int(a.Partial()))
+	a.DiscardPartial()
+	assert.For(ctx, "Partial after discard").That(a.Partial()).Equals(int64(0))
+	aw, err := a.Writer()
+	if !assert.For(ctx, "Writer after discard").ThatError(err).Succeeded() {
+		return
+	}
+	for _, e := range appended {
+		e.write(ctx, aw)
+	}
+
+	got := events{}
+	err = pack.Read(ctx, bytes.NewBuffer(out.Bytes()), &got, false)
+	assert.For(ctx, "Read after discard").ThatError(err).Succeeded()
+	if assert.For(ctx, "events after discard").That(len(got) >= len(appended)).Equals(true) {
+		assert.For(ctx, "appended after discard").ThatSlice(got[len(got)-len(appended):]).DeepEquals(appended)
+	}
+}
@@ -220,7 +220,7 @@ func (d *Dynamic) unpack(data interface{}, f *descriptor.FieldDescriptorProto) (
 		return (int64)(decodeZigzag(data.(uint64))), nil
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
 		tyname := strings.TrimLeft(f.GetTypeName(), ".")
-		if subty, ok := d.types.byName[tyname]; ok {
+		if subty, ok := d.types.get(tyname); ok {
 			msg := subty.create()
 			if err := proto.Unmarshal(data.([]byte), msg); err != nil {
 				return nil, err
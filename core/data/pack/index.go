@@ -0,0 +1,183 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/fault"
+)
+
+// ErrNoIndex is the error returned by ReadIndex when the stream has no index.
+const ErrNoIndex = fault.Const("Pack stream has no index")
+
+// footerSize is the size of the stream offset of the index record, which is
+// stored in the last bytes of an indexed stream.
+const footerSize = 8
+
+// Index is the index of the root groups of a pack stream, written to the end
+// of the stream by a Writer with the Index option enabled.
+type Index struct {
+	// Groups holds the root groups of the stream in stream order.
+	Groups []IndexEntry
+}
+
+// IndexEntry locates a root group in a pack stream.
+type IndexEntry struct {
+	// ID is the identifier of the group, as passed to Events.BeginGroup.
+	ID uint64
+	// Type is the proto type name of the group's message.
+	Type string
+	// Offset is the offset in the stream from which the group can be read.
+	Offset uint64
+	// Chunk is the identifier of the first chunk at Offset.
+	Chunk uint64
+	// Types is the number of types declared before Offset.
+	Types int
+}
+
+// ReadIndex reads the index from the end of the pack stream. It returns
+// ErrNoIndex if the stream was written without an index.
+func ReadIndex(ctx context.Context, from io.ReadSeeker) (*Index, error) {
+	if _, err := from.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	version, err := newReader(from, nil, nil).readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if version.Major < 3 {
+		return nil, ErrNoIndex
+	} else if version.Major > MaxMajorVersion {
+		return nil, ErrUnsupportedVersion{Version: version}
+	}
+
+	end, err := from.Seek(-footerSize, io.SeekEnd)
+	if err != nil {
+		return nil, ErrNoIndex
+	}
+	footer := make([]byte, footerSize)
+	if _, err := io.ReadFull(from, footer); err != nil {
+		return nil, err
+	}
+	offset := int64(binary.LittleEndian.Uint64(footer))
+	if offset < int64(len(header)) || offset >= end {
+		return nil, ErrNoIndex
+	}
+	if _, err := from.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, end+footerSize-offset)
+	if _, err := io.ReadFull(from, data); err != nil {
+		return nil, err
+	}
+
+	var record [3]uint64 // The zero chunk size, record kind and record size.
+	for f := range record {
+		v, n := proto.DecodeVarint(data)
+		if n == 0 {
+			return nil, ErrNoIndex
+		}
+		record[f], data = v, data[n:]
+	}
+	if record[0] != 0 || record[1] != recordIndex || record[2] != uint64(len(data)) {
+		return nil, ErrNoIndex
+	}
+
+	pb := proto.NewBuffer(data)
+	i := &Index{}
+	count, err := pb.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, count)
+	for t := range names {
+		if names[t], err = pb.DecodeStringBytes(); err != nil {
+			return nil, err
+		}
+		// The type descriptor is only needed to decode the chunks.
+		if _, err := pb.DecodeRawBytes(false); err != nil {
+			return nil, err
+		}
+	}
+	if count, err = pb.DecodeVarint(); err != nil {
+		return nil, err
+	}
+	i.Groups = make([]IndexEntry, count)
+	for g := range i.Groups {
+		e := &i.Groups[g]
+		var ty, types uint64
+		for _, v := range []*uint64{&e.ID, &ty, &e.Offset, &e.Chunk, &types} {
+			if *v, err = pb.DecodeVarint(); err != nil {
+				return nil, err
+			}
+		}
+		if ty == 0 || ty > uint64(len(names)) || types > uint64(len(names)) {
+			return nil, ErrNoIndex
+		}
+		e.Type, e.Types = names[ty-1], int(types)
+	}
+	return i, nil
+}
+
+// indexGroup adds the root group about to be written to the index.
+func (w *Writer) indexGroup(ty *ty) {
+	e := IndexEntry{
+		ID:     w.id,
+		Type:   ty.name,
+		Offset: w.offset,
+		Chunk:  w.id,
+		Types:  int(w.types.count()) - 1,
+	}
+	if w.block != nil && w.block.Len() > 0 {
+		// The group will be written in the middle of the pending block.
+		e.Chunk, e.Types = w.blockID, w.blockTypes
+	}
+	w.index.Groups = append(w.index.Groups, e)
+}
+
+// writeIndex writes the index record, followed by its offset in the stream.
+func (w *Writer) writeIndex() error {
+	pb := proto.NewBuffer(nil)
+	types := w.types.entries[1:]
+	pb.EncodeVarint(uint64(len(types)))
+	for _, t := range types {
+		pb.EncodeStringBytes(t.name)
+		desc := []byte{}
+		if t.desc != nil {
+			var err error
+			if desc, err = proto.Marshal(t.desc); err != nil {
+				return err
+			}
+		}
+		pb.EncodeRawBytes(desc)
+	}
+	pb.EncodeVarint(uint64(len(w.index.Groups)))
+	for _, e := range w.index.Groups {
+		pb.EncodeVarint(e.ID)
+		pb.EncodeVarint(w.types.byName[e.Type].index)
+		pb.EncodeVarint(e.Offset)
+		pb.EncodeVarint(e.Chunk)
+		pb.EncodeVarint(uint64(e.Types))
+	}
+	offset := w.offset
+	data := pb.Bytes()
+	data = append(data, make([]byte, footerSize)...)
+	binary.LittleEndian.PutUint64(data[len(data)-footerSize:], offset)
+	return w.writeRecord(recordIndex, data)
+}
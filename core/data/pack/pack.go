@@ -26,6 +26,17 @@ const (
 
 	initalBufferSize = 4096
 	maxVarintSize    = 10
+
+	// blockSize is the number of uncompressed chunk bytes that are gathered
+	// before a compressed block is written.
+	blockSize = 64 * 1024
+)
+
+// Kinds of the records that follow a zero chunk size in a version 3 stream.
+const (
+	recordEnd   = 0
+	recordBlock = 1
+	recordIndex = 2
 )
 
 var (
@@ -33,10 +44,15 @@ var (
 	MinMajorVersion = 2
 
 	// MaxMajorVersion is the current maximum supported major version of pack files.
-	MaxMajorVersion = 2
+	MaxMajorVersion = 3
+
+	// header is the header written by this package for streams that use
+	// records, including the version.
+	header = []byte("ProtoPack\r\n3.0\n\x00")
 
-	// header is the header written by this package including the version.
-	header = []byte("ProtoPack\r\n2.0\n\x00")
+	// headerV2 is the header written by this package for streams that do not
+	// use records, which can also be read by version 2 readers.
+	headerV2 = []byte("ProtoPack\r\n2.0\n\x00")
 )
 
 type Version struct {
@@ -16,13 +16,17 @@ package pack_test
 
 import (
 	"bytes"
+	"compress/flate"
 	"context"
+	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/gapid/core/assert"
 	"github.com/google/gapid/core/data/pack"
 	"github.com/google/gapid/core/data/protoutil/testprotos"
+	"github.com/google/gapid/core/fault"
 	"github.com/google/gapid/core/log"
 )
 
@@ -107,15 +111,15 @@ func (e *events) ChildObject(ctx context.Context, msg proto.Message, parentID ui
 	return nil
 }
 
-func TestReaderWriter(t *testing.T) {
-	ctx := log.Testing(t)
-	buf := &bytes.Buffer{}
-
+// testEvents returns a list of events to write, and the IDs of the groups
+// that will be assigned when they are written.
+func testEvents() (events, *[4]uint64) {
 	// Serialization Begin* methods return IDs for the written chunks.
 	// Store them here so that *Child* methods can read them.
-	var id0, id1, id2, id3 uint64
+	ids := &[4]uint64{}
+	id0, id1, id2, id3 := &ids[0], &ids[1], &ids[2], &ids[3]
 
-	expected := events{
+	return events{
 		eventObject{&testprotos.MsgA{F32: 1, U32: 2, S32: 3, Str: "four"}},
 		eventObject{&testprotos.MsgB{F64: 2, U64: 3, S64: 4, Bool: false}},
 		eventObject{&testprotos.MsgA{F32: 3, U32: 4, S32: 5, Str: "six"}},
@@ -124,27 +128,182 @@ func TestReaderWriter(t *testing.T) {
 			&testprotos.MsgC_Entry{Value: 1},
 		}}},
 
-		eventBeginGroup{&testprotos.MsgA{F32: 5, U32: 6, S32: 10, Str: "eleven"}, &id0},
-		eventBeginGroup{&testprotos.MsgB{F64: 6, U64: 7, S64: 11, Bool: false}, &id1},
-		eventChildObject{&testprotos.MsgA{F32: 7, U32: 8, S32: 12, Str: "thirteen"}, &id0},
-		eventBeginChildGroup{&testprotos.MsgB{F64: 8, U64: 9, S64: 13, Bool: true}, &id2, &id0},
-		eventEndGroup{&id0},
-		eventBeginChildGroup{&testprotos.MsgA{F32: 9, U32: 10, S32: 11, Str: "twelve"}, &id3, &id1},
-		eventEndGroup{&id1},
-	}
+		eventBeginGroup{&testprotos.MsgA{F32: 5, U32: 6, S32: 10, Str: "eleven"}, id0},
+		eventBeginGroup{&testprotos.MsgB{F64: 6, U64: 7, S64: 11, Bool: false}, id1},
+		eventChildObject{&testprotos.MsgA{F32: 7, U32: 8, S32: 12, Str: "thirteen"}, id0},
+		eventBeginChildGroup{&testprotos.MsgB{F64: 8, U64: 9, S64: 13, Bool: true}, id2, id0},
+		eventEndGroup{id0},
+		eventBeginChildGroup{&testprotos.MsgA{F32: 9, U32: 10, S32: 11, Str: "twelve"}, id3, id1},
+		eventEndGroup{id1},
+	}, ids
+}
 
-	w, err := pack.NewWriter(buf)
+func write(ctx context.Context, opts pack.Options, expected events) []byte {
+	buf := &bytes.Buffer{}
+	w, err := pack.NewWriterWithOptions(buf, opts)
 	assert.For(ctx, "NewWriter").ThatError(err).Succeeded()
 	for _, e := range expected {
 		e.write(ctx, w)
 	}
+	assert.For(ctx, "Close").ThatError(w.Close()).Succeeded()
+	return buf.Bytes()
+}
+
+func TestReaderWriter(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, opts := range []pack.Options{
+		{},
+		{Compress: true},
+		{Index: true},
+		{Compress: true, Index: true},
+	} {
+		ctx := log.V{"options": opts}.Bind(ctx)
+		expected, _ := testEvents()
+		data := write(ctx, opts, expected)
+
+		got := events{}
+		err := pack.Read(ctx, bytes.NewBuffer(data), &got, false)
+		assert.For(ctx, "Read").ThatError(err).Succeeded()
+
+		assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+
+		err = pack.Read(ctx, bytes.NewBuffer(data), &got, true)
+		assert.For(ctx, "Read (force-dynamic)").ThatError(err).Succeeded()
+	}
+}
+
+func TestWriteVersion(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		opts     pack.Options
+		expected string
+	}{
+		{pack.Options{}, "ProtoPack\r\n2.0\n\x00"},
+		{pack.Options{Compress: true}, "ProtoPack\r\n3.0\n\x00"},
+		{pack.Options{Index: true}, "ProtoPack\r\n3.0\n\x00"},
+		{pack.Options{Compress: true, Index: true}, "ProtoPack\r\n3.0\n\x00"},
+	} {
+		ctx := log.V{"options": test.opts}.Bind(ctx)
+		expected, _ := testEvents()
+		data := write(ctx, test.opts, expected)
+		assert.For(ctx, "header").That(string(data[:16])).Equals(test.expected)
+
+		got := events{}
+		err := pack.Read(ctx, bytes.NewBuffer(data), &got, false)
+		assert.For(ctx, "Read").ThatError(err).Succeeded()
+		assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ctx := log.Testing(t)
+
+	written, _ := testEvents()
+	_, err := pack.ReadIndex(ctx, bytes.NewReader(write(ctx, pack.Options{}, written)))
+	assert.For(ctx, "ReadIndex (no index)").ThatError(err).Equals(pack.ErrNoIndex)
+
+	for _, opts := range []pack.Options{
+		{Index: true},
+		{Compress: true, Index: true},
+	} {
+		ctx := log.V{"options": opts}.Bind(ctx)
+		written, ids := testEvents()
+		data := write(ctx, opts, written)
+
+		index, err := pack.ReadIndex(ctx, bytes.NewReader(data))
+		if !assert.For(ctx, "ReadIndex").ThatError(err).Succeeded() {
+			continue
+		}
+		assert.For(ctx, "groups").That(len(index.Groups)).Equals(2)
+		assert.For(ctx, "group 0").That(index.Groups[0].ID).Equals(ids[0])
+		assert.For(ctx, "group 0 type").That(index.Groups[0].Type).Equals("testprotos.MsgA")
+		assert.For(ctx, "group 1").That(index.Groups[1].ID).Equals(ids[1])
+		assert.For(ctx, "group 1 type").That(index.Groups[1].Type).Equals("testprotos.MsgB")
+
+		// Groups in the same compressed block share the block's offset.
+		assert.For(ctx, "group 1 offset").That(index.Groups[1].Offset >= index.Groups[0].Offset).Equals(true)
+		assert.For(ctx, "group 1 offset").That(index.Groups[1].Offset < uint64(len(data))).Equals(true)
+	}
+}
+
+func TestIndexBlocks(t *testing.T) {
+	ctx := log.Testing(t)
+	const count = 1000
+	padding := string(make([]byte, 200))
+
+	written := events{}
+	ids := make([]uint64, count)
+	for i := range ids {
+		written = append(written,
+			eventBeginGroup{&testprotos.MsgA{F32: float32(i)}, &ids[i]},
+			eventChildObject{&testprotos.MsgA{Str: padding}, &ids[i]},
+			eventEndGroup{&ids[i]})
+	}
+	plain := write(ctx, pack.Options{Index: true}, written)
+	for i := range ids {
+		ids[i] = 0
+	}
+	data := write(ctx, pack.Options{Compress: true, Index: true}, written)
+	assert.For(ctx, "compressed size").That(len(data) < len(plain)/4).Equals(true)
+
+	index, err := pack.ReadIndex(ctx, bytes.NewReader(data))
+	if !assert.For(ctx, "ReadIndex").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "groups").That(len(index.Groups)).Equals(count)
+
+	assert.For(ctx, "group 700").That(index.Groups[700].ID).Equals(ids[700])
 
 	got := events{}
-	err = pack.Read(ctx, bytes.NewBuffer(buf.Bytes()), &got, false)
+	err = pack.Read(ctx, bytes.NewReader(data), &got, false)
 	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(written)
+}
+
+func TestReadTruncatedBlock(t *testing.T) {
+	ctx := log.Testing(t)
+	written, _ := testEvents()
+	data := write(ctx, pack.Options{Compress: true}, written)
+
+	// Replace the DEFLATE data of the first block with data that stops before
+	// the end of the stream, and so before the final DEFLATE block.
+	header, record := data[:16], data[16:]
+	assert.For(ctx, "record").That(record[:2]).DeepEquals([]byte{0, 1})
+	size, n := proto.DecodeVarint(record[2:])
+	block, rest := record[2+n:2+n+int(size)], record[2+n+int(size):]
+	chunks, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(block)))
+	if !assert.For(ctx, "decompress").ThatError(err).Succeeded() {
+		return
+	}
+	truncated := &bytes.Buffer{}
+	fw, _ := flate.NewWriter(truncated, flate.DefaultCompression)
+	fw.Write(chunks)
+	fw.Flush()
 
-	assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+	corrupt := append([]byte{}, header...)
+	corrupt = append(corrupt, 0, 1)
+	corrupt = append(corrupt, proto.EncodeVarint(uint64(truncated.Len()))...)
+	corrupt = append(corrupt, truncated.Bytes()...)
+	corrupt = append(corrupt, rest...)
 
-	err = pack.Read(ctx, bytes.NewBuffer(buf.Bytes()), &got, true)
-	assert.For(ctx, "Read (force-dynamic)").ThatError(err).Succeeded()
+	err = pack.Read(ctx, bytes.NewReader(corrupt), &events{}, false)
+	assert.For(ctx, "Read").ThatError(err).Failed()
+	err = pack.ReadParallel(ctx, bytes.NewReader(corrupt), &events{}, false, 0)
+	assert.For(ctx, "ReadParallel").ThatError(err).Failed()
+}
+
+// errReader is an io.Reader that always fails with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestReadRecordError(t *testing.T) {
+	ctx := log.Testing(t)
+	const errRead = fault.Const("read failed")
+	// The stream fails to be read while reading ahead of the record kind.
+	from := io.MultiReader(
+		bytes.NewReader([]byte("ProtoPack\r\n3.0\n\x00\x00\x01\x08partial")),
+		errReader{errRead})
+	err := pack.Read(ctx, from, &events{}, false)
+	assert.For(ctx, "Read").ThatError(err).Equals(errRead)
 }
@@ -0,0 +1,212 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"context"
+	"io"
+	"runtime"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/pkg/errors"
+)
+
+// errTruncated is returned by dispatchBatches when a chunk holds a truncated
+// message, which ends the stream.
+const errTruncated = fault.Const("Truncated message")
+
+const (
+	// batchChunks is the maximum number of chunks in a batch.
+	batchChunks = 256
+	// slabSize is the maximum size of the buffers that batches copy chunk data
+	// into. Buffers start small and double in size up to slabSize.
+	slabSize = 256 * 1024
+)
+
+// ReadParallel reads the pack file from the supplied stream like Read, but
+// unmarshals the proto messages of the chunks on workers goroutines.
+// The stream is read on another goroutine, and the events are called on the
+// calling goroutine in stream order.
+// If workers is 0, then runtime.GOMAXPROCS(0) workers are used.
+func ReadParallel(ctx context.Context, from io.Reader, events Events, forceDynamic bool, workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	r := newReader(from, newTypes(forceDynamic), events)
+	version, err := r.readHeader()
+	if err != nil {
+		return err
+	} else if !(MinMajorVersion <= version.Major && version.Major <= MaxMajorVersion) {
+		return ErrUnsupportedVersion{Version: version}
+	}
+	r.version = version
+	return r.readParallel(ctx, workers)
+}
+
+// batch is a sequence of chunks that are unmarshalled by a single worker.
+type batch struct {
+	chunks []chunk
+	slab   []byte
+	bytes  int // total size of the slabs.
+	err    error
+	done   chan struct{}
+}
+
+func newBatch() *batch {
+	return &batch{
+		chunks: make([]chunk, 0, batchChunks),
+		done:   make(chan struct{}),
+	}
+}
+
+// add adds the chunk to the batch, copying its data.
+func (b *batch) add(c chunk) {
+	if len(c.data) > cap(b.slab)-len(b.slab) {
+		size := cap(b.slab) * 2
+		switch {
+		case size < initalBufferSize:
+			size = initalBufferSize
+		case size > slabSize:
+			size = slabSize
+		}
+		if len(c.data) > size {
+			size = len(c.data)
+		}
+		b.slab = make([]byte, 0, size)
+		b.bytes += size
+	}
+	start := len(b.slab)
+	b.slab = append(b.slab, c.data...)
+	c.data = b.slab[start:len(b.slab):len(b.slab)]
+	b.chunks = append(b.chunks, c)
+}
+
+func (b *batch) unmarshal() {
+	defer close(b.done)
+	for i := range b.chunks {
+		if b.err = b.chunks[i].unmarshal(); b.err != nil {
+			b.chunks = b.chunks[:i] // Only report the chunks before the error.
+			return
+		}
+	}
+}
+
+// readParallel reads the stream as a pipeline: this goroutine dispatches the
+// events of the batches in order, as they are produced by the stream reading
+// goroutine and unmarshalled by the worker goroutines.
+func (r *reader) readParallel(ctx context.Context, workers int) error {
+	readCtx, cancel := task.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan *batch, workers)
+	ordered := make(chan *batch, workers*2)
+	var readErr error
+	go func() {
+		defer close(work)
+		defer close(ordered)
+		readErr = r.batches(readCtx, work, ordered)
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for b := range work {
+				b.unmarshal()
+			}
+		}()
+	}
+
+	err := r.dispatchBatches(ctx, ordered)
+	cancel()
+	for range ordered {
+		// Drain the batches until the reading goroutine stops.
+	}
+	switch err {
+	case nil:
+		return readErr
+	case errTruncated:
+		return nil // The reading goroutine was stopped early.
+	default:
+		return err
+	}
+}
+
+// batches reads the chunks of the stream, sending them in batches to both the
+// work and ordered channels.
+func (r *reader) batches(ctx context.Context, work, ordered chan<- *batch) error {
+	b := newBatch()
+	send := func() error {
+		select {
+		case ordered <- b:
+		case <-ctx.Done():
+			return task.StopReason(ctx)
+		}
+		select {
+		case work <- b:
+		case <-ctx.Done():
+			return task.StopReason(ctx)
+		}
+		b = newBatch()
+		return nil
+	}
+	for !task.Stopped(ctx) {
+		c, err := r.next()
+		if err != nil {
+			if sendErr := send(); sendErr != nil {
+				return sendErr
+			}
+			cause := errors.Cause(err)
+			if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		b.add(c)
+		if len(b.chunks) == batchChunks || b.bytes >= slabSize {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+	return task.StopReason(ctx)
+}
+
+// dispatchBatches reports the chunks of the ordered batches to the events,
+// once they have been unmarshalled.
+func (r *reader) dispatchBatches(ctx context.Context, ordered <-chan *batch) error {
+	for b := range ordered {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return task.StopReason(ctx)
+		}
+		for i := range b.chunks {
+			if err := r.dispatch(ctx, &b.chunks[i]); err != nil {
+				return err
+			}
+		}
+		if b.err != nil {
+			// As with Read, a truncated message ends the stream.
+			cause := errors.Cause(b.err)
+			if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+				return errTruncated
+			}
+			return b.err
+		}
+		if task.Stopped(ctx) {
+			return task.StopReason(ctx)
+		}
+	}
+	return nil
+}
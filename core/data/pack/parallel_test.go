@@ -0,0 +1,197 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/data/protoutil/testprotos"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+// largeEvents returns count root groups, each with a child group and object.
+func largeEvents(count int) events {
+	out := events{}
+	ids := make([]uint64, count*2)
+	for i := 0; i < count; i++ {
+		group, child := &ids[i*2], &ids[i*2+1]
+		out = append(out,
+			eventBeginGroup{&testprotos.MsgA{F32: float32(i), Str: fmt.Sprint("group ", i)}, group},
+			eventBeginChildGroup{&testprotos.MsgB{U64: uint64(i)}, child, group},
+			eventChildObject{&testprotos.MsgC{Entries: []*testprotos.MsgC_Entry{{Value: int32(i)}}}, child},
+			eventEndGroup{child},
+			eventEndGroup{group})
+	}
+	return out
+}
+
+func TestReadParallel(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name   string
+		events func() events
+	}{
+		{"small", func() events { e, _ := testEvents(); return e }},
+		{"large", func() events { return largeEvents(5000) }},
+	} {
+		for _, opts := range []pack.Options{{}, {Compress: true, Index: true}} {
+			expected := test.events()
+			data := write(ctx, opts, expected)
+			for _, workers := range []int{0, 1, 3} {
+				ctx := log.V{"test": test.name, "options": opts, "workers": workers}.Bind(ctx)
+				got := events{}
+				err := pack.ReadParallel(ctx, bytes.NewReader(data), &got, false, workers)
+				assert.For(ctx, "ReadParallel").ThatError(err).Succeeded()
+				assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+
+				dynamic := &counter{}
+				err = pack.ReadParallel(ctx, bytes.NewReader(data), dynamic, true, workers)
+				assert.For(ctx, "ReadParallel (force-dynamic)").ThatError(err).Succeeded()
+				assert.For(ctx, "events (force-dynamic)").That(dynamic.count).Equals(len(expected))
+			}
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, opts := range []pack.Options{{}, {Compress: true}} {
+		written, _ := testEvents()
+		data := write(ctx, opts, written)
+		for size := 16; size < len(data); size++ {
+			ctx := log.V{"options": opts, "size": size}.Bind(ctx)
+			truncated := data[:size]
+
+			// A truncated stream ends cleanly after its last complete chunk.
+			expected := events{}
+			err := pack.Read(ctx, bytes.NewReader(truncated), &expected, false)
+			assert.For(ctx, "Read").ThatError(err).Succeeded()
+
+			got := events{}
+			err = pack.ReadParallel(ctx, bytes.NewReader(truncated), &got, false, 2)
+			assert.For(ctx, "ReadParallel").ThatError(err).Succeeded()
+			assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+		}
+	}
+
+	// Shorten the size of the last chunk, so that its message is truncated.
+	msg := &testprotos.MsgA{Str: "truncated"}
+	data := write(ctx, pack.Options{}, events{eventObject{msg}, eventObject{msg}})
+	last := len(data) - 1
+	for int(data[last]) != 2*(len(data)-last-1) { // Single byte zigzag size.
+		last--
+	}
+	truncated := append(append([]byte{}, data[:last]...), data[last]-2)
+	truncated = append(truncated, data[last+1:len(data)-1]...)
+
+	expected := events{eventObject{msg}}
+	got := events{}
+	err := pack.Read(ctx, bytes.NewReader(truncated), &got, false)
+	assert.For(ctx, "Read (message)").ThatError(err).Succeeded()
+	assert.For(ctx, "events (message)").ThatSlice(got).DeepEquals(expected)
+
+	got = events{}
+	err = pack.ReadParallel(ctx, bytes.NewReader(truncated), &got, false, 2)
+	assert.For(ctx, "ReadParallel (message)").ThatError(err).Succeeded()
+	assert.For(ctx, "events (message)").ThatSlice(got).DeepEquals(expected)
+}
+
+func TestReadParallelEventError(t *testing.T) {
+	ctx := log.Testing(t)
+	const errFail = fault.Const("fail")
+	data := write(ctx, pack.Options{Compress: true}, largeEvents(1000))
+	events := &counter{fail: 1234, err: errFail}
+	err := pack.ReadParallel(ctx, bytes.NewReader(data), events, false, 4)
+	assert.For(ctx, "err").ThatError(err).Equals(errFail)
+	assert.For(ctx, "events").That(events.count).Equals(1234)
+}
+
+func TestReadParallelCancel(t *testing.T) {
+	ctx := log.Testing(t)
+	data := write(ctx, pack.Options{}, largeEvents(1000))
+	ctx, cancel := task.WithCancel(ctx)
+	events := &counter{fail: 100, cancel: cancel}
+	err := pack.ReadParallel(ctx, bytes.NewReader(data), events, false, 4)
+	assert.For(ctx, "err").ThatError(err).Equals(context.Canceled)
+}
+
+func BenchmarkRead(b *testing.B) {
+	ctx := context.Background()
+	data := write(ctx, pack.Options{Compress: true}, largeEvents(10000))
+	for _, workers := range []int{0, 1, 2, 4, 8, 16, 32} {
+		name := fmt.Sprint("parallel-", workers)
+		if workers == 0 {
+			name = "sequential"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var err error
+				if workers == 0 {
+					err = pack.Read(ctx, bytes.NewReader(data), &counter{}, false)
+				} else {
+					err = pack.ReadParallel(ctx, bytes.NewReader(data), &counter{}, false, workers)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// counter is an Events implementation that counts the events, and which can
+// fail or cancel the read once fail events have been counted.
+type counter struct {
+	count  int
+	fail   int
+	err    error
+	cancel task.CancelFunc
+}
+
+func (c *counter) event() error {
+	if c.fail > 0 && c.count == c.fail {
+		if c.cancel != nil {
+			c.cancel()
+			return nil
+		}
+		return c.err
+	}
+	c.count++
+	return nil
+}
+
+func (c *counter) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	return c.event()
+}
+func (c *counter) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	return c.event()
+}
+func (c *counter) EndGroup(ctx context.Context, id uint64) error {
+	return c.event()
+}
+func (c *counter) Object(ctx context.Context, msg proto.Message) error {
+	return c.event()
+}
+func (c *counter) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	return c.event()
+}
@@ -15,6 +15,8 @@
 package pack
 
 import (
+	"bytes"
+	"compress/flate"
 	"context"
 	"fmt"
 	"io"
@@ -22,6 +24,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
 	"github.com/google/gapid/core/math/sint"
 	"github.com/pkg/errors"
 )
@@ -32,36 +35,27 @@ type ErrUnknownType struct{ TypeName string }
 
 func (e ErrUnknownType) Error() string { return fmt.Sprintf("Unknown proto type '%s'", e.TypeName) }
 
+// errCorruptBlock is returned when a compressed block ends in the middle of its
+// data or of one of its chunks.
+const errCorruptBlock = fault.Const("Corrupt compressed block")
+
 // Read reads the pack file from the supplied stream.
 // This function will read the header from the stream, adjusting it's position.
 // It may read extra bytes from the stream into an internal buffer.
 func Read(ctx context.Context, from io.Reader, events Events, forceDynamic bool) error {
-	r := &reader{
-		types:  newTypes(forceDynamic),
-		from:   from,
-		buf:    make([]byte, 0, initalBufferSize),
-		events: events,
-	}
-	r.pb = proto.NewBuffer(r.buf)
-	if version, err := r.readHeader(); err != nil {
+	r := newReader(from, newTypes(forceDynamic), events)
+	version, err := r.readHeader()
+	if err != nil {
 		return err
 	} else if !(MinMajorVersion <= version.Major && version.Major <= MaxMajorVersion) {
 		return ErrUnsupportedVersion{Version: version}
 	}
-	for ; !task.Stopped(ctx); r.id++ {
-		if err := r.unmarshal(ctx); err != nil {
-			cause := errors.Cause(err)
-			if cause == io.EOF || cause == io.ErrUnexpectedEOF {
-				return nil
-			}
-			return err
-		}
-	}
-	return task.StopReason(ctx)
+	r.version = version
+	return r.read(ctx)
 }
 
 // reader is the type for a pack file reader.
-// They should only be constructed by NewReader.
+// They should only be constructed by newReader.
 type reader struct {
 	types     *types
 	events    Events
@@ -70,85 +64,164 @@ type reader struct {
 	bufOffset int
 	pb        *proto.Buffer
 	from      io.Reader
+	version   Version
+	// block is the reader of the compressed block being read.
+	block *reader
 }
 
-func (r *reader) unmarshal(ctx context.Context) (err error) {
-	size, err := r.readChunk()
-	if err != nil {
-		return err
+func newReader(from io.Reader, types *types, events Events) *reader {
+	r := &reader{
+		types:  types,
+		from:   from,
+		buf:    make([]byte, 0, initalBufferSize),
+		events: events,
 	}
+	r.pb = proto.NewBuffer(r.buf)
+	return r
+}
 
-	// Negated size means this is type definition chunk.
-	if size < 0 {
-		name, err := r.pb.DecodeStringBytes()
-		if err != nil {
-			return err
+// read reads chunks until the end of the stream.
+func (r *reader) read(ctx context.Context) error {
+	for !task.Stopped(ctx) {
+		c, err := r.next()
+		if err == nil {
+			err = c.unmarshal()
+		}
+		if err == nil {
+			err = r.dispatch(ctx, &c)
 		}
-		desc := &descriptor.DescriptorProto{}
-		if err = r.pb.Unmarshal(desc); err != nil {
+		if err != nil {
+			cause := errors.Cause(err)
+			if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+				return nil
+			}
 			return err
 		}
-		r.types.add(name, desc)
-		return nil
 	}
+	return task.StopReason(ctx)
+}
 
-	// Read first two fields of object instance. If missing, they are implicitly set to 0.
-	// NB: Protobuf library returns the signed zig-zag-encoded integers as uint64!
-	parent, err := r.pb.DecodeZigzag64()
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return err
-	}
-	tyIdx, err := r.pb.DecodeZigzag64()
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return err
+// chunk is an object chunk read from the stream.
+type chunk struct {
+	id       uint64
+	parent   uint64 // The parent field, relative to id if negative.
+	ty       *ty    // The object type, nil for children list terminators.
+	children bool   // True if the object may have children.
+	data     []byte // The proto message data.
+	msg      proto.Message
+}
+
+// unmarshal unmarshals the chunk's proto message.
+func (c *chunk) unmarshal() error {
+	if c.ty == nil {
+		return nil
 	}
-	hasParent := int64(parent) < 0
-	hasChildren := int64(tyIdx) < 0
+	c.msg = c.ty.create()
+	return proto.UnmarshalMerge(c.data, c.msg)
+}
 
-	if tyIdx == 0 { // Null-terminator
-		if hasParent {
-			if err := r.events.EndGroup(ctx, r.id+parent); err != nil {
-				return err
+// next reads the next object chunk of the stream to be reported to the
+// events. Type definitions and records are handled as they are read.
+// The data of the returned chunk is only valid until the next call.
+func (r *reader) next() (chunk, error) {
+	for {
+		if r.block != nil {
+			c, err := r.block.next()
+			r.id = r.block.id
+			if err == nil {
+				return c, nil
+			}
+			switch errors.Cause(err) {
+			case io.EOF:
+			case io.ErrUnexpectedEOF:
+				// The whole block was read, so the block is corrupt, not truncated.
+				return chunk{}, errCorruptBlock
+			default:
+				return chunk{}, err
 			}
+			r.block = nil
+			continue
 		}
-	} else { // New object instance
-		if int64(tyIdx) < 0 {
-			tyIdx = -tyIdx // Absolute value.
+
+		size, err := r.readChunk()
+		if err != nil {
+			return chunk{}, err
 		}
-		if tyIdx >= r.types.count() {
-			return fmt.Errorf("Unknown type index: %v. Type count: %v.", tyIdx, r.types.count())
+		if size == 0 {
+			if err := r.readRecord(); err != nil {
+				return chunk{}, err
+			}
+			continue
 		}
-		ty := *r.types.entries[tyIdx]
-		msg := ty.create()
-		if err := r.pb.Unmarshal(msg); err != nil {
-			return err
+		id := r.id
+		r.id++
+
+		// Negated size means this is type definition chunk.
+		if size < 0 {
+			name, err := r.pb.DecodeStringBytes()
+			if err != nil {
+				return chunk{}, err
+			}
+			desc := &descriptor.DescriptorProto{}
+			if err = r.pb.Unmarshal(desc); err != nil {
+				return chunk{}, err
+			}
+			r.types.add(name, desc)
+			continue
 		}
-		if !hasParent {
-			if hasChildren {
-				err = r.events.BeginGroup(ctx, msg, r.id)
-			} else {
-				err = r.events.Object(ctx, msg)
+
+		// Read first two fields of object instance. If missing, they are implicitly set to 0.
+		data := r.pb.Bytes()
+		parent, n := proto.DecodeVarint(data)
+		data = data[n:]
+		tyIdx, n := proto.DecodeVarint(data)
+		data = data[n:]
+		parent, tyIdx = decodeZigzag(parent), decodeZigzag(tyIdx)
+
+		c := chunk{id: id, parent: parent, children: int64(tyIdx) < 0}
+		if tyIdx != 0 {
+			if c.children {
+				tyIdx = -tyIdx // Absolute value.
 			}
-		} else {
-			parentID := r.id + parent
-			if hasChildren {
-				err = r.events.BeginChildGroup(ctx, msg, r.id, parentID)
-			} else {
-				err = r.events.ChildObject(ctx, msg, parentID)
+			if tyIdx >= r.types.count() {
+				return chunk{}, fmt.Errorf("Unknown type index: %v. Type count: %v.", tyIdx, r.types.count())
 			}
+			c.ty, c.data = r.types.entries[tyIdx], data
 		}
-		if err != nil {
-			return err
+		return c, nil
+	}
+}
+
+// dispatch reports the unmarshalled chunk to the events.
+func (r *reader) dispatch(ctx context.Context, c *chunk) error {
+	hasParent := int64(c.parent) < 0
+	parentID := c.id + c.parent
+	switch {
+	case c.ty == nil: // Null-terminator
+		if hasParent {
+			return r.events.EndGroup(ctx, parentID)
 		}
+		return nil
+	case !hasParent && c.children:
+		return r.events.BeginGroup(ctx, c.msg, c.id)
+	case !hasParent:
+		return r.events.Object(ctx, c.msg)
+	case c.children:
+		return r.events.BeginChildGroup(ctx, c.msg, c.id, parentID)
+	default:
+		return r.events.ChildObject(ctx, c.msg, parentID)
 	}
-	return nil
 }
 
 func (r *reader) readHeader() (Version, error) {
 	if err := r.readN(16); err != nil {
 		return Version{}, err
 	}
-	str := string(r.pb.Bytes())
+	return parseHeader(string(r.pb.Bytes()))
+}
+
+// parseHeader returns the version of the 16 byte stream header.
+func parseHeader(str string) (Version, error) {
 	if str[0:9] == "protopack" {
 		return Version{1, 0}, nil
 	}
@@ -164,6 +237,22 @@ func (r *reader) readHeader() (Version, error) {
 }
 
 func (r *reader) readChunk() (chunkSize int64, err error) {
+	size, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		if r.version.Major < 3 {
+			return 0, io.EOF
+		}
+		return 0, nil // Record
+	}
+	size = decodeZigzag(size)
+	return int64(size), r.readN(sint.Abs(int(size)))
+}
+
+// readVarint reads a single varint from the stream.
+func (r *reader) readVarint() (uint64, error) {
 	// Make sure we have enough bytes for the maxiumum a varint could be, but don't
 	// fail if the eof is within that range
 	if err := r.readN(maxVarintSize); err != nil {
@@ -172,13 +261,55 @@ func (r *reader) readChunk() (chunkSize int64, err error) {
 		}
 	}
 	data := r.pb.Bytes()
-	size, n := proto.DecodeVarint(data)
+	v, n := proto.DecodeVarint(data)
 	r.bufOffset -= len(data) - n
-	if n == 0 || size == 0 {
+	if n == 0 {
 		return 0, io.EOF
 	}
-	size = (size >> 1) ^ uint64((int64(size&1)<<63)>>63) // Decode zig-zag encoding
-	return int64(size), r.readN(sint.Abs(int(size)))
+	return v, nil
+}
+
+// readRecord reads the record that follows a zero chunk size.
+func (r *reader) readRecord() error {
+	kind, err := r.readVarint()
+	if err != nil {
+		return err
+	}
+	if kind == recordEnd {
+		return io.EOF
+	}
+	size, err := r.readVarint()
+	if err != nil {
+		return err
+	}
+	if err := r.readN(int(size)); err != nil {
+		return err
+	}
+	switch kind {
+	case recordBlock:
+		// The block data remains valid until the block has been read.
+		r.block = newReader(blockReader{flate.NewReader(bytes.NewReader(r.pb.Bytes()))}, r.types, r.events)
+		r.block.id = r.id
+		r.block.version = Version{2, 0} // Blocks hold chunks, not records.
+		return nil
+	case recordIndex:
+		return nil // Only used for seeking.
+	default:
+		return fmt.Errorf("Unknown record kind: %v", kind)
+	}
+}
+
+// blockReader reads the decompressed data of a block. A truncated DEFLATE
+// stream is reported as errCorruptBlock, as the reader would otherwise take it
+// for the end of the block.
+type blockReader struct{ io.Reader }
+
+func (b blockReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.ErrUnexpectedEOF {
+		err = errCorruptBlock
+	}
+	return n, err
 }
 
 // readN makes sure there is size bytes available in the buffer if possible
@@ -0,0 +1,253 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrUnknownGroup is the error returned when an event refers to a group that
+// has not begun, or has already ended.
+type ErrUnknownGroup struct{ ID uint64 }
+
+func (e ErrUnknownGroup) Error() string { return fmt.Sprintf("Unknown group: %v", e.ID) }
+
+// TypeName returns the proto type name of the message, which may be a Dynamic
+// message.
+func TypeName(msg proto.Message) string {
+	if d, ok := msg.(*Dynamic); ok {
+		return d.Desc.GetName()
+	}
+	return proto.MessageName(msg)
+}
+
+// Copier is an Events implementation that writes the events to a Writer,
+// mapping the group identifiers of the events to those of the Writer.
+// Streams can be concatenated by copying each one in turn, calling Reset
+// between them.
+type Copier struct {
+	w   *Writer
+	ids map[uint64]uint64
+}
+
+// NewCopier returns a new Copier that writes to w.
+func NewCopier(w *Writer) *Copier {
+	return &Copier{w: w, ids: map[uint64]uint64{}}
+}
+
+// Reset forgets the group identifiers of the events copied so far, so that
+// another stream can be copied.
+func (c *Copier) Reset() {
+	c.ids = map[uint64]uint64{}
+}
+
+// BeginGroup implements Events.
+func (c *Copier) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	if err := c.check(msg); err != nil {
+		return err
+	}
+	newID, err := c.w.BeginGroup(ctx, msg)
+	if err != nil {
+		return err
+	}
+	c.ids[id] = newID
+	return nil
+}
+
+// BeginChildGroup implements Events.
+func (c *Copier) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	parent, err := c.parent(msg, parentID)
+	if err != nil {
+		return err
+	}
+	newID, err := c.w.BeginChildGroup(ctx, msg, parent)
+	if err != nil {
+		return err
+	}
+	c.ids[id] = newID
+	return nil
+}
+
+// EndGroup implements Events.
+func (c *Copier) EndGroup(ctx context.Context, id uint64) error {
+	newID, ok := c.ids[id]
+	if !ok {
+		return ErrUnknownGroup{id}
+	}
+	delete(c.ids, id)
+	return c.w.EndGroup(ctx, newID)
+}
+
+// Object implements Events.
+func (c *Copier) Object(ctx context.Context, msg proto.Message) error {
+	if err := c.check(msg); err != nil {
+		return err
+	}
+	return c.w.Object(ctx, msg)
+}
+
+// ChildObject implements Events.
+func (c *Copier) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	parent, err := c.parent(msg, parentID)
+	if err != nil {
+		return err
+	}
+	return c.w.ChildObject(ctx, msg, parent)
+}
+
+func (c *Copier) parent(msg proto.Message, id uint64) (uint64, error) {
+	if err := c.check(msg); err != nil {
+		return 0, err
+	}
+	newID, ok := c.ids[id]
+	if !ok {
+		return 0, ErrUnknownGroup{id}
+	}
+	return newID, nil
+}
+
+// check returns an error if the message cannot be written.
+func (c *Copier) check(msg proto.Message) error {
+	if d, ok := msg.(*Dynamic); ok {
+		return fmt.Errorf("Cannot write message of unregistered type '%v'", d.Desc.GetName())
+	}
+	return nil
+}
+
+// DropTypes returns an Events implementation that passes the events through
+// to events, except for the objects and groups with any of the given proto
+// type names. The descendants of dropped groups are also dropped.
+func DropTypes(events Events, names ...string) Events {
+	drop := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		drop[n] = struct{}{}
+	}
+	return &typeDropper{events, drop, map[uint64]struct{}{}}
+}
+
+type typeDropper struct {
+	to      Events
+	drop    map[string]struct{}
+	dropped map[uint64]struct{}
+}
+
+func (d *typeDropper) drops(msg proto.Message, parentID *uint64) bool {
+	if parentID != nil {
+		if _, ok := d.dropped[*parentID]; ok {
+			return true
+		}
+	}
+	_, ok := d.drop[TypeName(msg)]
+	return ok
+}
+
+func (d *typeDropper) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	if d.drops(msg, nil) {
+		d.dropped[id] = struct{}{}
+		return nil
+	}
+	return d.to.BeginGroup(ctx, msg, id)
+}
+
+func (d *typeDropper) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	if d.drops(msg, &parentID) {
+		d.dropped[id] = struct{}{}
+		return nil
+	}
+	return d.to.BeginChildGroup(ctx, msg, id, parentID)
+}
+
+func (d *typeDropper) EndGroup(ctx context.Context, id uint64) error {
+	if _, ok := d.dropped[id]; ok {
+		delete(d.dropped, id)
+		return nil
+	}
+	return d.to.EndGroup(ctx, id)
+}
+
+func (d *typeDropper) Object(ctx context.Context, msg proto.Message) error {
+	if d.drops(msg, nil) {
+		return nil
+	}
+	return d.to.Object(ctx, msg)
+}
+
+func (d *typeDropper) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	if d.drops(msg, &parentID) {
+		return nil
+	}
+	return d.to.ChildObject(ctx, msg, parentID)
+}
+
+// Subtrees returns an Events implementation that only passes the groups with
+// the given identifiers, and their descendants, through to events. Selected
+// groups that are not descendants of other selected groups become root
+// groups. If objects is true, objects outside of any group are also passed
+// through.
+func Subtrees(events Events, ids []uint64, objects bool) Events {
+	selected := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		selected[id] = struct{}{}
+	}
+	return &subtrees{events, selected, objects, map[uint64]struct{}{}}
+}
+
+type subtrees struct {
+	to       Events
+	selected map[uint64]struct{}
+	objects  bool
+	kept     map[uint64]struct{}
+}
+
+func (s *subtrees) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	if _, ok := s.selected[id]; !ok {
+		return nil
+	}
+	s.kept[id] = struct{}{}
+	return s.to.BeginGroup(ctx, msg, id)
+}
+
+func (s *subtrees) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	if _, ok := s.kept[parentID]; ok {
+		s.kept[id] = struct{}{}
+		return s.to.BeginChildGroup(ctx, msg, id, parentID)
+	}
+	return s.BeginGroup(ctx, msg, id)
+}
+
+func (s *subtrees) EndGroup(ctx context.Context, id uint64) error {
+	if _, ok := s.kept[id]; !ok {
+		return nil
+	}
+	delete(s.kept, id)
+	return s.to.EndGroup(ctx, id)
+}
+
+func (s *subtrees) Object(ctx context.Context, msg proto.Message) error {
+	if !s.objects {
+		return nil
+	}
+	return s.to.Object(ctx, msg)
+}
+
+func (s *subtrees) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	if _, ok := s.kept[parentID]; !ok {
+		return nil
+	}
+	return s.to.ChildObject(ctx, msg, parentID)
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/log"
+)
+
+func TestCopier(t *testing.T) {
+	ctx := log.Testing(t)
+	expected, _ := testEvents()
+	data := write(ctx, pack.Options{}, expected)
+
+	buf := &bytes.Buffer{}
+	w, err := pack.NewWriter(buf)
+	assert.For(ctx, "NewWriter").ThatError(err).Succeeded()
+	c := pack.NewCopier(w)
+	for i := 0; i < 2; i++ {
+		err := pack.Read(ctx, bytes.NewReader(data), c, false)
+		assert.For(ctx, "Read").ThatError(err).Succeeded()
+		c.Reset()
+	}
+
+	got := events{}
+	err = pack.Read(ctx, bytes.NewReader(buf.Bytes()), &got, false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	if assert.For(ctx, "events").That(len(got)).Equals(len(expected) * 2) {
+		assert.For(ctx, "first copy").ThatSlice(got[:len(expected)]).DeepEquals(expected)
+	}
+}
+
+func TestDropTypes(t *testing.T) {
+	ctx := log.Testing(t)
+	written, _ := testEvents()
+	data := write(ctx, pack.Options{}, written)
+
+	got := events{}
+	err := pack.Read(ctx, bytes.NewReader(data), pack.DropTypes(&got, "testprotos.MsgB"), false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(events{
+		written[0], // Object MsgA
+		written[2], // Object MsgA
+		written[4], // Object MsgC
+		written[5], // BeginGroup id0 MsgA
+		written[7], // ChildObject MsgA of id0
+		written[9], // EndGroup id0
+	})
+}
+
+func TestSubtrees(t *testing.T) {
+	ctx := log.Testing(t)
+	written, ids := testEvents()
+	data := write(ctx, pack.Options{}, written)
+
+	got := events{}
+	subtrees := pack.Subtrees(&got, []uint64{ids[1], ids[2]}, false)
+	err := pack.Read(ctx, bytes.NewReader(data), subtrees, false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	child := written[8].(eventBeginChildGroup)
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(events{
+		written[6], // BeginGroup id1
+		eventBeginGroup{child.Msg, child.ID},
+		written[10], // BeginChildGroup id3 of id1
+		written[11], // EndGroup id1
+	})
+
+	got = events{}
+	subtrees = pack.Subtrees(&got, []uint64{ids[0]}, true)
+	err = pack.Read(ctx, bytes.NewReader(data), subtrees, false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	assert.For(ctx, "events (objects)").ThatSlice(got).DeepEquals(append(
+		append(events{}, written[0:6]...),
+		written[7], // ChildObject MsgA of id0
+		written[8], // BeginChildGroup id2 of id0
+		written[9], // EndGroup id0
+	))
+}
@@ -0,0 +1,286 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/event/task"
+)
+
+// TextWriter is an Events implementation that writes the events in the text
+// form of a pack stream, which can be edited by hand and read with ReadText.
+//
+// Each event is written as an entry, with messages in the proto text format:
+//
+//	object testprotos.MsgA {
+//	  str: "a root object"
+//	}
+//	group 1 testprotos.MsgB {
+//	}
+//	group 2 in 1 testprotos.MsgB {
+//	}
+//	object in 2 testprotos.MsgA {
+//	}
+//	end 2
+//	end 1
+//
+// Group identifiers only need to be unique among the groups that have not
+// ended. Lines starting with '#' are comments.
+type TextWriter struct {
+	to io.Writer
+}
+
+// NewTextWriter returns a new TextWriter that writes to the supplied stream.
+func NewTextWriter(to io.Writer) *TextWriter {
+	return &TextWriter{to: to}
+}
+
+// BeginGroup implements Events.
+func (t *TextWriter) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	return t.write(fmt.Sprintf("group %d", id), msg)
+}
+
+// BeginChildGroup implements Events.
+func (t *TextWriter) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	return t.write(fmt.Sprintf("group %d in %d", id, parentID), msg)
+}
+
+// EndGroup implements Events.
+func (t *TextWriter) EndGroup(ctx context.Context, id uint64) error {
+	_, err := fmt.Fprintf(t.to, "end %d\n", id)
+	return err
+}
+
+// Object implements Events.
+func (t *TextWriter) Object(ctx context.Context, msg proto.Message) error {
+	return t.write("object", msg)
+}
+
+// ChildObject implements Events.
+func (t *TextWriter) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	return t.write(fmt.Sprintf("object in %d", parentID), msg)
+}
+
+func (t *TextWriter) write(entry string, msg proto.Message) error {
+	if d, ok := msg.(*Dynamic); ok {
+		return fmt.Errorf("Cannot write message of unregistered type '%v'", d.Desc.GetName())
+	}
+	text := (&proto.TextMarshaler{ExpandAny: true}).Text(msg)
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s {\n", entry, TypeName(msg))
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line != "" {
+			buf.WriteString("  ")
+			buf.WriteString(line)
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := t.to.Write(buf.Bytes())
+	return err
+}
+
+// ReadText reads the text form of a pack stream, as written by TextWriter,
+// from the supplied stream, calling events for each of its entries.
+func ReadText(ctx context.Context, from io.Reader, events Events) error {
+	data, err := ioutil.ReadAll(from)
+	if err != nil {
+		return err
+	}
+	p := &textParser{text: string(data), line: 1}
+	open := map[uint64]struct{}{}
+	for !task.Stopped(ctx) {
+		kind := p.word()
+		switch kind {
+		case "":
+			if p.pos < len(p.text) {
+				return p.errorf("Unexpected '%c'", p.text[p.pos])
+			}
+			return nil
+		case "object", "group":
+			var id uint64
+			if kind == "group" {
+				if id, err = p.number(); err != nil {
+					return err
+				}
+				if _, ok := open[id]; ok {
+					return p.errorf("Group %v has already begun", id)
+				}
+			}
+			parentID, hasParent, err := p.parent(open)
+			if err != nil {
+				return err
+			}
+			msg, err := p.message()
+			if err != nil {
+				return err
+			}
+			switch {
+			case kind == "object" && !hasParent:
+				err = events.Object(ctx, msg)
+			case kind == "object":
+				err = events.ChildObject(ctx, msg, parentID)
+			case !hasParent:
+				err = events.BeginGroup(ctx, msg, id)
+			default:
+				err = events.BeginChildGroup(ctx, msg, id, parentID)
+			}
+			if err != nil {
+				return err
+			}
+			if kind == "group" {
+				open[id] = struct{}{}
+			}
+		case "end":
+			id, err := p.number()
+			if err != nil {
+				return err
+			}
+			if _, ok := open[id]; !ok {
+				return p.errorf("%v", ErrUnknownGroup{id})
+			}
+			delete(open, id)
+			if err := events.EndGroup(ctx, id); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("Unexpected '%v'", kind)
+		}
+	}
+	return task.StopReason(ctx)
+}
+
+// textParser parses the text form of a pack stream.
+type textParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (p *textParser) errorf(msg string, args ...interface{}) error {
+	return fmt.Errorf("Line %d: %s", p.line, fmt.Sprintf(msg, args...))
+}
+
+// skip skips whitespace and comments.
+func (p *textParser) skip() {
+	for p.pos < len(p.text) {
+		switch c := p.text[p.pos]; {
+		case c == '\n':
+			p.line++
+		case c == '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		case c != ' ' && c != '\t' && c != '\r':
+			return
+		}
+		p.pos++
+	}
+}
+
+// word returns the next word, or an empty string if there are none.
+func (p *textParser) word() string {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n#{}", p.text[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *textParser) number() (uint64, error) {
+	word := p.word()
+	n, err := strconv.ParseUint(word, 10, 64)
+	if err != nil {
+		return 0, p.errorf("Expected a group identifier, got '%v'", word)
+	}
+	return n, nil
+}
+
+// parent parses the optional parent group of an entry.
+func (p *textParser) parent(open map[uint64]struct{}) (uint64, bool, error) {
+	pos, line := p.pos, p.line
+	if p.word() != "in" {
+		p.pos, p.line = pos, line
+		return 0, false, nil
+	}
+	id, err := p.number()
+	if err != nil {
+		return 0, false, err
+	}
+	if _, ok := open[id]; !ok {
+		return 0, false, p.errorf("%v", ErrUnknownGroup{id})
+	}
+	return id, true, nil
+}
+
+// message parses a proto type name followed by a message in braces.
+func (p *textParser) message() (proto.Message, error) {
+	name := p.word()
+	ty := proto.MessageType(name)
+	if ty == nil {
+		return nil, p.errorf("%v", ErrUnknownType{name})
+	}
+	p.skip()
+	if p.pos >= len(p.text) || p.text[p.pos] != '{' {
+		return nil, p.errorf("Expected '{' after %v", name)
+	}
+	line := p.line
+	p.pos++
+	start := p.pos
+	var quote byte
+	for depth := 1; depth > 0; p.pos++ {
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("Line %d: Unterminated %v message", line, name)
+		}
+		switch c := p.text[p.pos]; {
+		case c == '\n':
+			p.line++
+		case quote != 0:
+			if c == '\\' {
+				p.pos++
+				if p.pos < len(p.text) && p.text[p.pos] == '\n' {
+					p.line++ // Escaped line break.
+				}
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			for p.pos+1 < len(p.text) && p.text[p.pos+1] != '\n' {
+				p.pos++
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		}
+	}
+	msg := reflect.New(ty.Elem()).Interface().(proto.Message)
+	if err := proto.UnmarshalText(p.text[start:p.pos-1], msg); err != nil {
+		return nil, fmt.Errorf("Line %d: %v", line, err)
+	}
+	return msg, nil
+}
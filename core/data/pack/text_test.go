@@ -0,0 +1,91 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/data/protoutil/testprotos"
+	"github.com/google/gapid/core/log"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	ctx := log.Testing(t)
+	expected, _ := testEvents()
+	data := write(ctx, pack.Options{}, expected)
+
+	text := &bytes.Buffer{}
+	err := pack.Read(ctx, bytes.NewReader(data), pack.NewTextWriter(text), false)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+
+	got := events{}
+	err = pack.ReadText(ctx, bytes.NewReader(text.Bytes()), &got)
+	assert.For(ctx, "ReadText").ThatError(err).Succeeded()
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(expected)
+
+	err = pack.ReadText(ctx, strings.NewReader(text.String()+"end 100\n"), &events{})
+	assert.For(ctx, "ReadText (trailing end)").ThatError(err).Failed()
+}
+
+func TestReadText(t *testing.T) {
+	ctx := log.Testing(t)
+	id, child := uint64(7), uint64(3)
+	text := `
+# A hand written stream.
+object testprotos.MsgA {
+  str: "braces { and # in strings }"  # and comments { }
+}
+group 7 testprotos.MsgC {
+  entries: < value: 1 >
+  entries { value: 2 }
+}
+group 3 in 7 testprotos.MsgB { u64: 9 }
+object in 3 testprotos.MsgA { str: 'single \' quoted' }
+end 3
+end 7
+`
+	got := events{}
+	err := pack.ReadText(ctx, strings.NewReader(text), &got)
+	assert.For(ctx, "ReadText").ThatError(err).Succeeded()
+	assert.For(ctx, "events").ThatSlice(got).DeepEquals(events{
+		eventObject{&testprotos.MsgA{Str: "braces { and # in strings }"}},
+		eventBeginGroup{&testprotos.MsgC{Entries: []*testprotos.MsgC_Entry{{Value: 1}, {Value: 2}}}, &id},
+		eventBeginChildGroup{&testprotos.MsgB{U64: 9}, &child, &id},
+		eventChildObject{&testprotos.MsgA{Str: "single ' quoted"}, &child},
+		eventEndGroup{&child},
+		eventEndGroup{&id},
+	})
+
+	for _, test := range []struct {
+		text string
+		err  string
+	}{
+		{"object in 1 testprotos.MsgA {}", "Line 1: Unknown group: 1"},
+		{"group 1 testprotos.MsgA {}\ngroup 1 testprotos.MsgA {}", "Line 2: Group 1 has already begun"},
+		{"\nend 2", "Line 2: Unknown group: 2"},
+		{"object testprotos.Missing {}", "Line 1: Unknown proto type 'testprotos.Missing'"},
+		{"object testprotos.MsgA {\n str: \"}\"\n", "Line 1: Unterminated testprotos.MsgA message"},
+		{"object testprotos.MsgA", "Line 1: Expected '{' after testprotos.MsgA"},
+		{"objects", "Line 1: Unexpected 'objects'"},
+		{"}", "Line 1: Unexpected '}'"},
+	} {
+		err := pack.ReadText(ctx, strings.NewReader(test.text), &events{})
+		assert.For(ctx, "err for %q", test.text).ThatError(err).HasMessage(test.err)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
@@ -45,6 +46,9 @@ type types struct {
 	entries      []*ty
 	byName       map[string]*ty
 	forceDynamic bool
+	// mutex guards byName for dynamic messages that are unmarshalled while
+	// types are being added.
+	mutex sync.RWMutex
 }
 
 // newTypes constructs a new empty type registry.
@@ -124,7 +128,17 @@ func (t *types) add(name string, desc *descriptor.DescriptorProto) *ty {
 		create: create,
 		desc:   desc,
 	}
+	t.mutex.Lock()
 	t.entries = append(t.entries, entry)
 	t.byName[name] = entry
+	t.mutex.Unlock()
 	return entry
 }
+
+// get returns the type with the given name.
+func (t *types) get(name string) (*ty, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	ty, ok := t.byName[name]
+	return ty, ok
+}
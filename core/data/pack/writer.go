@@ -15,6 +15,8 @@
 package pack
 
 import (
+	"bytes"
+	"compress/flate"
 	"context"
 	"fmt"
 	"io"
@@ -23,13 +25,33 @@ import (
 )
 
 // Writer is the type for a pack file writer.
-// They should only be constructed by NewWriter.
+// They should only be constructed by NewWriter or NewWriterWithOptions.
 type Writer struct {
 	types   *types
 	id      uint64
+	objects uint64
 	buf     *proto.Buffer
 	sizebuf *proto.Buffer
 	to      io.Writer
+	offset  uint64 // number of bytes written to to.
+
+	// The following are only used when compression is enabled.
+	block      *bytes.Buffer // uncompressed chunks of the pending block.
+	blockID    uint64        // identifier of the first chunk in block.
+	blockTypes int           // number of types declared before block.
+	compressor *flate.Writer
+
+	// index is nil if the index is not being written.
+	index *Index
+}
+
+// Options holds the optional features of a Writer.
+type Options struct {
+	// Compress enables DEFLATE compression of the chunks.
+	Compress bool
+	// Index enables writing an index of the root groups to the end of the
+	// stream when the Writer is closed.
+	Index bool
 }
 
 // NewWriter constructs and returns a new Writer that writes to the supplied
@@ -37,13 +59,38 @@ type Writer struct {
 // This method will write the packfile magic and header to the underlying
 // stream.
 func NewWriter(to io.Writer) (*Writer, error) {
+	return NewWriterWithOptions(to, Options{})
+}
+
+// NewWriterWithOptions constructs and returns a new Writer that writes to the
+// supplied output stream using the optional features of opts.
+// The stream is written in the version 3 format if any of the features are
+// enabled, otherwise in the version 2 format.
+// This method will write the packfile magic and header to the underlying
+// stream. Close must be called once all the objects have been written.
+func NewWriterWithOptions(to io.Writer, opts Options) (*Writer, error) {
 	w := &Writer{
 		types:   newTypes(false),
 		buf:     proto.NewBuffer(make([]byte, 0, initalBufferSize)),
 		sizebuf: proto.NewBuffer(make([]byte, 0, maxVarintSize)),
 		to:      to,
 	}
-	if _, err := w.to.Write(header); err != nil {
+	if opts.Compress {
+		compressor, err := flate.NewWriter(nil, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		w.block = &bytes.Buffer{}
+		w.compressor = compressor
+	}
+	if opts.Index {
+		w.index = &Index{}
+	}
+	hdr := headerV2
+	if opts.Compress || opts.Index {
+		hdr = header
+	}
+	if err := w.write(hdr); err != nil {
 		return nil, err
 	}
 	return w, nil
@@ -84,6 +131,25 @@ func (w *Writer) ChildObject(ctx context.Context, msg proto.Message, parentID ui
 	return err
 }
 
+// Objects returns the number of object chunks written to the stream,
+// including group terminators. Each object chunk corresponds to one call to
+// the Events of a reader of the stream.
+func (w *Writer) Objects() uint64 {
+	return w.objects
+}
+
+// Close flushes any pending compressed chunks and writes the index, if
+// enabled, to the end of the stream. Nothing can be written after Close.
+func (w *Writer) Close() error {
+	if err := w.flushBlock(); err != nil {
+		return err
+	}
+	if w.index == nil {
+		return nil
+	}
+	return w.writeIndex()
+}
+
 func (w *Writer) writeMessage(ctx context.Context, msg proto.Message, isGroup bool, parentID *uint64) (id uint64, err error) {
 
 	ty, err := w.types.addForMessage(ctx, msg, func(t *ty) error { return w.writeType(t) })
@@ -92,6 +158,9 @@ func (w *Writer) writeMessage(ctx context.Context, msg proto.Message, isGroup bo
 	}
 
 	if parentID == nil {
+		if isGroup && w.index != nil {
+			w.indexGroup(ty)
+		}
 		if err := w.buf.EncodeZigzag64(0); err != nil {
 			return 0, err
 		}
@@ -136,6 +205,12 @@ func (w *Writer) writeType(t *ty) error {
 }
 
 func (w *Writer) flushChunk(isTypeDef bool) error {
+	if w.block != nil && w.block.Len() == 0 {
+		w.blockID, w.blockTypes = w.id, int(w.types.count())-1
+		if isTypeDef {
+			w.blockTypes-- // The type being declared is already registered.
+		}
+	}
 	size := len(w.buf.Bytes())
 	if isTypeDef {
 		size = -size
@@ -143,13 +218,69 @@ func (w *Writer) flushChunk(isTypeDef bool) error {
 	if err := w.sizebuf.EncodeZigzag64(uint64(size)); err != nil {
 		return err
 	}
-	_, err := w.to.Write(w.sizebuf.Bytes())
+	err := w.writeChunk(w.sizebuf.Bytes())
 	w.sizebuf.Reset()
 	if err != nil {
 		return err
 	}
-	_, err = w.to.Write(w.buf.Bytes())
+	err = w.writeChunk(w.buf.Bytes())
 	w.buf.Reset()
 	w.id++
+	if !isTypeDef {
+		w.objects++
+	}
+	if err == nil && w.block != nil && w.block.Len() >= blockSize {
+		err = w.flushBlock()
+	}
+	return err
+}
+
+// writeChunk writes the chunk data to the pending block if compression is
+// enabled, otherwise directly to the output stream.
+func (w *Writer) writeChunk(data []byte) error {
+	if w.block != nil {
+		w.block.Write(data)
+		return nil
+	}
+	return w.write(data)
+}
+
+// flushBlock compresses the pending block and writes it to the output stream
+// as a block record.
+func (w *Writer) flushBlock() error {
+	if w.block == nil || w.block.Len() == 0 {
+		return nil
+	}
+	compressed := bytes.Buffer{}
+	w.compressor.Reset(&compressed)
+	if _, err := w.compressor.Write(w.block.Bytes()); err != nil {
+		return err
+	}
+	if err := w.compressor.Close(); err != nil {
+		return err
+	}
+	w.block.Reset()
+	return w.writeRecord(recordBlock, compressed.Bytes())
+}
+
+// writeRecord writes a record of the given kind and data to the output
+// stream. Records are introduced by a zero chunk size, followed by the kind
+// and the size of the data.
+func (w *Writer) writeRecord(kind uint64, data []byte) error {
+	w.sizebuf.EncodeVarint(0)
+	w.sizebuf.EncodeVarint(kind)
+	w.sizebuf.EncodeVarint(uint64(len(data)))
+	err := w.write(w.sizebuf.Bytes())
+	w.sizebuf.Reset()
+	if err != nil {
+		return err
+	}
+	return w.write(data)
+}
+
+// write writes the data to the output stream, tracking the stream offset.
+func (w *Writer) write(data []byte) error {
+	n, err := w.to.Write(data)
+	w.offset += uint64(n)
 	return err
 }
@@ -0,0 +1,138 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/context/keys"
+)
+
+// Priority is the scheduling class of a task run by a PriorityPool.
+// Larger values are scheduled ahead of smaller ones.
+type Priority int
+
+const (
+	// Background is the priority of work that nobody is waiting on, such as
+	// prefetching and thumbnails.
+	Background = Priority(iota)
+	// Normal is the default priority.
+	Normal
+	// Interactive is the priority of work that a user is actively waiting on.
+	Interactive
+
+	numPriorities = int(Interactive) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Background:
+		return "Background"
+	case Normal:
+		return "Normal"
+	case Interactive:
+		return "Interactive"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+type priorityKeyTy string
+type clientKeyTy string
+type poolKeyTy string
+
+const (
+	priorityKey priorityKeyTy = "task.priorityKey"
+	clientKey   clientKeyTy   = "task.clientKey"
+	poolKey     poolKeyTy     = "task.poolKey"
+)
+
+// PutPriority returns a new context with the scheduling priority p.
+func PutPriority(ctx context.Context, p Priority) context.Context {
+	return keys.WithValue(ctx, priorityKey, p)
+}
+
+// GetPriority returns the scheduling priority of ctx, or Normal if the
+// context has no priority.
+func GetPriority(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey).(Priority); ok {
+		return p
+	}
+	return Normal
+}
+
+// PutClient returns a new context with the client identifier c.
+// Pools share their workers fairly between the clients of tasks of the same
+// priority.
+func PutClient(ctx context.Context, c string) context.Context {
+	return keys.WithValue(ctx, clientKey, c)
+}
+
+// GetClient returns the client identifier of ctx.
+func GetClient(ctx context.Context) string {
+	out, _ := ctx.Value(clientKey).(string)
+	return out
+}
+
+// Inherit returns ctx with the priority, client and pool membership of from.
+// It is used when work done on behalf of from runs with an unrelated context,
+// such as a shared resolve that must outlive any single caller.
+func Inherit(ctx, from context.Context) context.Context {
+	if p, ok := from.Value(priorityKey).(Priority); ok {
+		ctx = PutPriority(ctx, p)
+	}
+	if c, ok := from.Value(clientKey).(string); ok {
+		ctx = PutClient(ctx, c)
+	}
+	if m, ok := from.Value(poolKey).(*membership); ok {
+		ctx = keys.WithValue(ctx, poolKey, m)
+	}
+	return ctx
+}
+
+// membership is a linked list of the pools running a task and the tasks that
+// submitted it.
+type membership struct {
+	pool   *PriorityPool
+	parent *membership
+}
+
+func getMembership(ctx context.Context) *membership {
+	out, _ := ctx.Value(poolKey).(*membership)
+	return out
+}
+
+// joinPool returns ctx marked as running on the pool p.
+func joinPool(ctx context.Context, p *PriorityPool) context.Context {
+	return keys.WithValue(ctx, poolKey, &membership{p, getMembership(ctx)})
+}
+
+// inPool returns true if ctx belongs to a task running on the pool p.
+func inPool(ctx context.Context, p *PriorityPool) bool {
+	for m := getMembership(ctx); m != nil; m = m.parent {
+		if m.pool == p {
+			return true
+		}
+	}
+	return false
+}
+
+// InPool returns true if ctx belongs to a task running on any PriorityPool.
+// Such a task holds one of the pool's workers, so it must not block waiting
+// for work that may still be queued behind it.
+func InPool(ctx context.Context) bool {
+	return getMembership(ctx) != nil
+}
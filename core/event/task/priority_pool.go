@@ -0,0 +1,297 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/app/crash"
+)
+
+// ErrPoolShutdown is the result of tasks submitted to a PriorityPool after it
+// has been shut down.
+var ErrPoolShutdown = errors.New("Task pool has been shut down")
+
+// PriorityPool is an executor that runs tasks on a fixed number of goroutines,
+// choosing the next task to run by its priority and client.
+//
+// Tasks of a higher Priority are always started before tasks of a lower
+// Priority. Within a Priority, the clients of the queued tasks take turns, so
+// that a client submitting many tasks cannot starve another. Tasks of the same
+// client and Priority are started in submission order.
+//
+// A task whose context is cancelled while queued is removed from the queue and
+// its handle is fired with the context's StopReason. The context of a running
+// task is the context it was submitted with, so cancellation propagates to the
+// task as usual.
+//
+// Tasks submitted with the context of a task already running on the pool are
+// started immediately on a new goroutine instead of being queued. This
+// prevents a task that waits on its own sub-tasks from deadlocking the pool.
+type PriorityPool struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	classes  [numPriorities]class
+	queued   int
+	shutdown bool
+	done     sync.WaitGroup
+	counters poolCounters
+}
+
+// class is the queue of tasks of a single priority.
+type class struct {
+	clients map[string]*clientQueue
+	ring    []*clientQueue
+	next    int
+}
+
+// clientQueue is the FIFO of tasks of a single client in a class.
+type clientQueue struct {
+	name  string
+	items []*poolItem
+}
+
+// poolItem is a task queued on a PriorityPool.
+type poolItem struct {
+	ctx      context.Context
+	runner   Runner
+	priority Priority
+	queued   time.Time
+	removed  bool
+	dequeued chan struct{}
+}
+
+type poolCounters struct {
+	queued    *benchmark.IntegerCounter
+	byClass   [numPriorities]*benchmark.IntegerCounter
+	running   *benchmark.IntegerCounter
+	executed  *benchmark.IntegerCounter
+	cancelled *benchmark.IntegerCounter
+	wait      *benchmark.DurationCounter
+}
+
+// NewPriorityPool returns a new PriorityPool that runs up to parallel tasks at
+// once, and which must be greater than 0.
+// The pool's queue depths and task counts are exposed as counters in c with
+// the given name as a prefix. If c is nil the counters are not shared.
+func NewPriorityPool(parallel int, c *benchmark.Counters, name string) *PriorityPool {
+	if c == nil {
+		c = benchmark.NewCounters()
+	}
+	p := &PriorityPool{
+		counters: poolCounters{
+			queued:    c.Integer(name + ".queued"),
+			running:   c.Integer(name + ".running"),
+			executed:  c.Integer(name + ".executed"),
+			cancelled: c.Integer(name + ".cancelled"),
+			wait:      c.Duration(name + ".wait"),
+		},
+	}
+	for i := range p.classes {
+		p.classes[i].clients = map[string]*clientQueue{}
+		p.counters.byClass[i] = c.Integer(benchmark.Name(name+".queued", benchmark.Labels{
+			"priority": Priority(i).String(),
+		}))
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	p.done.Add(parallel)
+	for i := 0; i < parallel; i++ {
+		crash.Go(p.work)
+	}
+	return p
+}
+
+// Executor returns the pool as an Executor.
+func (p *PriorityPool) Executor() Executor { return p.Execute }
+
+// Execute queues task to be run on the pool with the priority and client of
+// ctx. Execute implements the Executor signature.
+func (p *PriorityPool) Execute(ctx context.Context, task Task) Handle {
+	if inPool(ctx, p) {
+		return Go(ctx, task)
+	}
+
+	h, r := Prepare(joinPool(ctx, p), task)
+	priority := GetPriority(ctx)
+	if priority < 0 {
+		priority = 0
+	} else if int(priority) >= numPriorities {
+		priority = Priority(numPriorities - 1)
+	}
+	item := &poolItem{
+		ctx:      ctx,
+		runner:   r,
+		priority: priority,
+		queued:   time.Now(),
+		dequeued: make(chan struct{}),
+	}
+
+	p.mutex.Lock()
+	if p.shutdown {
+		p.mutex.Unlock()
+		return Direct(ctx, func(context.Context) error { return ErrPoolShutdown })
+	}
+	p.classes[priority].push(GetClient(ctx), item)
+	p.queued++
+	p.counters.queued.Increment()
+	p.counters.byClass[priority].Increment()
+	p.cond.Signal()
+	p.mutex.Unlock()
+
+	if stop := ShouldStop(ctx); stop != nil {
+		crash.Go(func() {
+			select {
+			case <-stop:
+				p.cancel(item)
+			case <-item.dequeued:
+			}
+		})
+	}
+	return h
+}
+
+// Shutdown stops the pool from accepting new tasks and waits for all the
+// queued and running tasks to finish, or ctx to be cancelled.
+// Shutdown implements the Task signature.
+func (p *PriorityPool) Shutdown(ctx context.Context) error {
+	p.mutex.Lock()
+	p.shutdown = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+
+	done, fire := NewSignal()
+	crash.Go(func() {
+		p.done.Wait()
+		fire(ctx)
+	})
+	if !done.Wait(ctx) {
+		return StopReason(ctx)
+	}
+	return nil
+}
+
+// Queued returns the number of tasks waiting to be run.
+func (p *PriorityPool) Queued() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.queued
+}
+
+// cancel removes the item from the queue if it has not yet been started, and
+// fires its handle.
+func (p *PriorityPool) cancel(item *poolItem) {
+	p.mutex.Lock()
+	if item.removed {
+		p.mutex.Unlock()
+		return
+	}
+	p.remove(item)
+	p.mutex.Unlock()
+
+	p.counters.cancelled.Increment()
+	item.runner() // Fires the handle with the context's StopReason.
+}
+
+// remove marks the item as no longer queued. It must be called with the mutex
+// locked. Removed items are dropped from the client queues by pop.
+func (p *PriorityPool) remove(item *poolItem) {
+	item.removed = true
+	close(item.dequeued)
+	p.queued--
+	p.counters.queued.Add(-1)
+	p.counters.byClass[item.priority].Add(-1)
+}
+
+// work is the body of each of the pool's goroutines.
+func (p *PriorityPool) work() {
+	defer p.done.Done()
+	for {
+		p.mutex.Lock()
+		for p.queued == 0 && !p.shutdown {
+			p.cond.Wait()
+		}
+		if p.queued == 0 {
+			p.mutex.Unlock()
+			return
+		}
+		item := p.pop()
+		p.remove(item)
+		p.mutex.Unlock()
+
+		p.counters.wait.Stop(item.queued)
+		p.counters.running.Increment()
+		item.runner()
+		p.counters.running.Add(-1)
+		p.counters.executed.Increment()
+	}
+}
+
+// pop returns the next item to run. It must be called with the mutex locked
+// and at least one item queued.
+func (p *PriorityPool) pop() *poolItem {
+	for i := numPriorities - 1; i >= 0; i-- {
+		if item := p.classes[i].pop(); item != nil {
+			return item
+		}
+	}
+	panic("PriorityPool.pop called with no queued tasks")
+}
+
+func (c *class) push(client string, item *poolItem) {
+	q, ok := c.clients[client]
+	if !ok {
+		q = &clientQueue{name: client}
+		c.clients[client] = q
+		c.ring = append(c.ring, q)
+	}
+	q.items = append(q.items, item)
+}
+
+// pop returns the next queued item of the class, taking each client in turn,
+// or nil if the class has no queued items.
+func (c *class) pop() *poolItem {
+	for len(c.ring) > 0 {
+		if c.next >= len(c.ring) {
+			c.next = 0
+		}
+		q := c.ring[c.next]
+		var item *poolItem
+		for len(q.items) > 0 && item == nil {
+			if !q.items[0].removed {
+				item = q.items[0]
+			}
+			q.items[0] = nil
+			q.items = q.items[1:]
+		}
+		if len(q.items) == 0 {
+			// Drop the empty client queue. The next client moves into the
+			// current position of the ring.
+			copy(c.ring[c.next:], c.ring[c.next+1:])
+			c.ring[len(c.ring)-1] = nil
+			c.ring = c.ring[:len(c.ring)-1]
+			delete(c.clients, q.name)
+		} else {
+			c.next++
+		}
+		if item != nil {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+)
+
+// blockPool submits a task to the pool that blocks until the returned task is
+// called. It returns once the blocking task has started.
+func blockPool(ctx context.Context, pool *task.PriorityPool) task.Task {
+	started, start := task.NewSignal()
+	release, unblock := task.NewSignal()
+	pool.Execute(ctx, func(ctx context.Context) error {
+		start(ctx)
+		release.Wait(ctx)
+		return nil
+	})
+	started.Wait(ctx)
+	return unblock
+}
+
+type runOrder struct {
+	mutex sync.Mutex
+	order []string
+}
+
+func (r *runOrder) task(name string) task.Task {
+	return func(context.Context) error {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.order = append(r.order, name)
+		return nil
+	}
+}
+
+func TestPriorityPoolPriorities(t *testing.T) {
+	ctx := log.Testing(t)
+	pool := task.NewPriorityPool(1, nil, "pool")
+	defer pool.Shutdown(ctx)
+
+	unblock := blockPool(ctx, pool)
+	r := &runOrder{}
+	handles := []task.Handle{
+		pool.Execute(task.PutPriority(ctx, task.Background), r.task("background")),
+		pool.Execute(ctx, r.task("normal")),
+		pool.Execute(task.PutPriority(ctx, task.Interactive), r.task("interactive")),
+		pool.Execute(task.PutPriority(ctx, task.Background), r.task("background 2")),
+	}
+	assert.For(ctx, "queued").That(pool.Queued()).Equals(4)
+	unblock(ctx)
+	for _, h := range handles {
+		assert.For(ctx, "result").ThatError(h.Result(ctx)).Succeeded()
+	}
+	assert.For(ctx, "order").ThatSlice(r.order).Equals([]string{
+		"interactive", "normal", "background", "background 2",
+	})
+}
+
+func TestPriorityPoolFairness(t *testing.T) {
+	ctx := log.Testing(t)
+	pool := task.NewPriorityPool(1, nil, "pool")
+	defer pool.Shutdown(ctx)
+
+	unblock := blockPool(ctx, pool)
+	r := &runOrder{}
+	a, b := task.PutClient(ctx, "a"), task.PutClient(ctx, "b")
+	handles := []task.Handle{
+		pool.Execute(a, r.task("a1")),
+		pool.Execute(a, r.task("a2")),
+		pool.Execute(a, r.task("a3")),
+		pool.Execute(b, r.task("b1")),
+		pool.Execute(b, r.task("b2")),
+	}
+	unblock(ctx)
+	for _, h := range handles {
+		assert.For(ctx, "result").ThatError(h.Result(ctx)).Succeeded()
+	}
+	assert.For(ctx, "order").ThatSlice(r.order).Equals([]string{
+		"a1", "b1", "a2", "b2", "a3",
+	})
+}
+
+func TestPriorityPoolCancel(t *testing.T) {
+	ctx := log.Testing(t)
+	counters := benchmark.NewCounters()
+	pool := task.NewPriorityPool(1, counters, "pool")
+	defer pool.Shutdown(ctx)
+
+	unblock := blockPool(ctx, pool)
+	r := &runOrder{}
+	child, cancel := task.WithCancel(ctx)
+	cancelled := pool.Execute(child, r.task("cancelled"))
+	kept := pool.Execute(ctx, r.task("kept"))
+	assert.For(ctx, "queued").That(counters.Integer("pool.queued").Get()).Equals(int64(2))
+
+	cancel()
+	assert.For(ctx, "cancelled").ThatError(cancelled.Result(ctx)).Equals(context.Canceled)
+	assert.For(ctx, "queued").That(pool.Queued()).Equals(1)
+	assert.For(ctx, "queued").That(counters.Integer("pool.queued").Get()).Equals(int64(1))
+	assert.For(ctx, "cancelled count").That(counters.Integer("pool.cancelled").Get()).Equals(int64(1))
+
+	unblock(ctx)
+	assert.For(ctx, "kept").ThatError(kept.Result(ctx)).Succeeded()
+	assert.For(ctx, "order").ThatSlice(r.order).Equals([]string{"kept"})
+	assert.For(ctx, "queued").That(counters.Integer(`pool.queued{priority="Normal"}`).Get()).Equals(int64(0))
+}
+
+func TestPriorityPoolNested(t *testing.T) {
+	ctx := log.Testing(t)
+	pool := task.NewPriorityPool(1, nil, "pool")
+	defer pool.Shutdown(ctx)
+
+	assert.For(ctx, "in pool").That(task.InPool(ctx)).Equals(false)
+	h := pool.Execute(ctx, func(ctx context.Context) error {
+		assert.For(ctx, "in pool").That(task.InPool(ctx)).Equals(true)
+		// The pool only has one goroutine, so this would deadlock if the
+		// sub-task was queued.
+		return pool.Execute(ctx, func(context.Context) error { return nil }).Result(ctx)
+	})
+	assert.For(ctx, "result").ThatError(h.Result(ctx)).Succeeded()
+}
+
+func TestPriorityPoolShutdown(t *testing.T) {
+	ctx := log.Testing(t)
+	counters := benchmark.NewCounters()
+	pool := task.NewPriorityPool(2, counters, "pool")
+
+	unblock := blockPool(ctx, pool)
+	r := &runOrder{}
+	queued := pool.Execute(ctx, r.task("queued"))
+	shutdown := task.Go(ctx, pool.Shutdown)
+
+	unblock(ctx)
+	assert.For(ctx, "shutdown").ThatError(shutdown.Result(ctx)).Succeeded()
+	assert.For(ctx, "queued").ThatError(queued.Result(ctx)).Succeeded()
+	assert.For(ctx, "order").ThatSlice(r.order).Equals([]string{"queued"})
+	assert.For(ctx, "executed").That(counters.Integer("pool.executed").Get()).Equals(int64(2))
+
+	late := pool.Execute(ctx, r.task("late"))
+	assert.For(ctx, "late").ThatError(late.Result(ctx)).Equals(task.ErrPoolShutdown)
+}
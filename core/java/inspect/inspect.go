@@ -0,0 +1,299 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inspect takes JSON-serializable snapshots of the state of a Java
+// virtual machine using the Java Debug Wire Protocol.
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/log"
+)
+
+// Options controls what is recorded by Take.
+type Options struct {
+	// Classes filters the loaded classes that are reported by their name.
+	// If nil, then no loaded classes are reported.
+	Classes *regexp.Regexp
+	// Statics is the list of static fields to report. Each entry is either a
+	// class name, such as "android.opengl.EGL14", in which case all the static
+	// fields declared by the class are reported, or a class name followed by
+	// a '#' and a field name, such as "android.view.Choreographer#sThreadInstance".
+	Statics []string
+	// MaxFrames is the maximum number of frames reported for each thread.
+	// If 0, then all the frames are reported.
+	MaxFrames int
+}
+
+// Snapshot holds the state of a virtual machine.
+type Snapshot struct {
+	Threads []Thread `json:"threads"`
+	Classes []string `json:"classes,omitempty"`
+	Statics []Static `json:"statics,omitempty"`
+}
+
+// Thread describes a single thread and its stack.
+type Thread struct {
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Frames []Frame `json:"frames,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Frame describes a single stack frame.
+type Frame struct {
+	Class    string `json:"class"`
+	Method   string `json:"method"`
+	Location uint64 `json:"location"`
+}
+
+// Static describes the value of a single static field.
+type Static struct {
+	Class string      `json:"class"`
+	Field string      `json:"field,omitempty"`
+	Type  string      `json:"type,omitempty"`
+	Value interface{} `json:"value"`
+	Error string      `json:"error,omitempty"`
+}
+
+// Take returns a snapshot of the virtual machine connected to by conn.
+// All the threads of the virtual machine are suspended while the snapshot is
+// taken, and are resumed before Take returns.
+func Take(ctx context.Context, conn *jdwp.Connection, opts Options) (*Snapshot, error) {
+	if err := conn.SuspendAll(); err != nil {
+		return nil, log.Err(ctx, err, "Suspending threads")
+	}
+	defer conn.ResumeAll()
+
+	t := &taker{conn: conn, types: map[jdwp.ReferenceTypeID]string{}}
+	out := &Snapshot{}
+
+	threads, err := conn.GetAllThreads()
+	if err != nil {
+		return nil, log.Err(ctx, err, "Getting threads")
+	}
+	for _, id := range threads {
+		out.Threads = append(out.Threads, t.thread(id, opts.MaxFrames))
+	}
+	sort.SliceStable(out.Threads, func(i, j int) bool {
+		return out.Threads[i].Name < out.Threads[j].Name
+	})
+
+	if opts.Classes != nil {
+		classes, err := conn.GetAllClasses()
+		if err != nil {
+			return nil, log.Err(ctx, err, "Getting loaded classes")
+		}
+		for _, c := range classes {
+			if name := TypeName(c.Signature); opts.Classes.MatchString(name) {
+				out.Classes = append(out.Classes, name)
+			}
+		}
+		sort.Strings(out.Classes)
+	}
+
+	for _, s := range opts.Statics {
+		out.Statics = append(out.Statics, t.statics(s)...)
+	}
+
+	return out, nil
+}
+
+type taker struct {
+	conn  *jdwp.Connection
+	types map[jdwp.ReferenceTypeID]string
+}
+
+func (t *taker) thread(id jdwp.ThreadID, maxFrames int) Thread {
+	out := Thread{Name: fmt.Sprint(id)}
+	name, err := t.conn.GetThreadName(id)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Name = name
+	status, _, err := t.conn.GetThreadStatus(id)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Status = status.String()
+	count, err := t.conn.GetFrameCount(id)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	if maxFrames > 0 && count > maxFrames {
+		count = maxFrames
+	}
+	frames, err := t.conn.GetFrames(id, 0, count)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	for _, f := range frames {
+		method, err := t.conn.GetLocationMethodName(f.Location)
+		if err != nil {
+			method = fmt.Sprint(f.Location.Method)
+		}
+		out.Frames = append(out.Frames, Frame{
+			Class:    t.typeName(jdwp.ReferenceTypeID(f.Location.Class)),
+			Method:   method,
+			Location: f.Location.Location,
+		})
+	}
+	return out
+}
+
+func (t *taker) statics(spec string) []Static {
+	class, field := spec, ""
+	if i := strings.IndexRune(spec, '#'); i >= 0 {
+		class, field = spec[:i], spec[i+1:]
+	}
+	fail := func(err error) []Static {
+		return []Static{{Class: class, Field: field, Error: err.Error()}}
+	}
+
+	classes, err := t.conn.GetClassesBySignature(Signature(class))
+	if err != nil {
+		return fail(err)
+	}
+	if len(classes) == 0 {
+		return fail(fmt.Errorf("Class '%v' is not loaded", class))
+	}
+
+	out := []Static{}
+	for _, c := range classes {
+		fields, err := t.conn.GetFields(c.TypeID)
+		if err != nil {
+			return fail(err)
+		}
+		selected := jdwp.Fields{}
+		for _, f := range fields {
+			if f.ModBits&jdwp.ModStatic != 0 && (field == "" || f.Name == field) {
+				selected = append(selected, f)
+			}
+		}
+		if field != "" && len(selected) == 0 {
+			return fail(fmt.Errorf("Class '%v' has no static field '%v'", class, field))
+		}
+		ids := make([]jdwp.FieldID, len(selected))
+		for i, f := range selected {
+			ids[i] = f.ID
+		}
+		values, err := t.conn.GetStaticFieldValues(c.TypeID, ids...)
+		if err != nil {
+			return fail(err)
+		}
+		for i, f := range selected {
+			s := Static{Class: class, Field: f.Name, Type: TypeName(f.Signature)}
+			if i < len(values) {
+				s.Value = t.value(values[i])
+			}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// value returns v converted to a JSON-serializable value.
+func (t *taker) value(v jdwp.Value) interface{} {
+	switch v := v.(type) {
+	case jdwp.Char:
+		return string(rune(v))
+	case float32:
+		return t.value(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprint(v) // Not representable in JSON.
+		}
+		return v
+	case jdwp.StringID:
+		if v == 0 {
+			return nil
+		}
+		s, err := t.conn.GetString(v)
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		return s
+	case jdwp.Object:
+		id := v.ID()
+		if id == 0 {
+			return nil
+		}
+		ty, err := t.conn.GetObjectType(id)
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		return fmt.Sprintf("%v@%x", t.typeName(ty.Type), uint64(id))
+	default:
+		return v
+	}
+}
+
+func (t *taker) typeName(id jdwp.ReferenceTypeID) string {
+	if name, ok := t.types[id]; ok {
+		return name
+	}
+	name := fmt.Sprint(id)
+	if sig, err := t.conn.GetTypeSignature(id); err == nil {
+		name = TypeName(sig)
+	}
+	t.types[id] = name
+	return name
+}
+
+// Signature returns the JNI type signature of the class with the given name.
+// For example "java.lang.String" returns "Ljava/lang/String;".
+func Signature(class string) string {
+	return "L" + strings.Replace(class, ".", "/", -1) + ";"
+}
+
+// TypeName returns the Java type name of the JNI type signature.
+// For example "Ljava/lang/String;" returns "java.lang.String" and "[I" returns
+// "int[]".
+func TypeName(sig string) string {
+	switch {
+	case sig == "":
+		return sig
+	case sig[0] == '[':
+		return TypeName(sig[1:]) + "[]"
+	case sig[0] == 'L' && strings.HasSuffix(sig, ";"):
+		return strings.Replace(sig[1:len(sig)-1], "/", ".", -1)
+	}
+	if name, ok := primitives[sig]; ok {
+		return name
+	}
+	return sig
+}
+
+var primitives = map[string]string{
+	"V": "void",
+	"Z": "boolean",
+	"B": "byte",
+	"C": "char",
+	"S": "short",
+	"I": "int",
+	"J": "long",
+	"F": "float",
+	"D": "double",
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inspect_test
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/inspect"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/java/jdwp/test"
+	"github.com/google/gapid/core/log"
+)
+
+func TestTypeName(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		sig      string
+		expected string
+	}{
+		{"", ""},
+		{"I", "int"},
+		{"Z", "boolean"},
+		{"V", "void"},
+		{"[I", "int[]"},
+		{"[[D", "double[][]"},
+		{"Ljava/lang/String;", "java.lang.String"},
+		{"[Landroid/view/View;", "android.view.View[]"},
+		{"Lcom/example/Outer$Inner;", "com.example.Outer$Inner"},
+		{"Q", "Q"},
+	} {
+		assert.For(ctx, "TypeName(%q)", test.sig).That(inspect.TypeName(test.sig)).Equals(test.expected)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		class    string
+		expected string
+	}{
+		{"Main", "LMain;"},
+		{"java.lang.String", "Ljava/lang/String;"},
+		{"com.example.Outer$Inner", "Lcom/example/Outer$Inner;"},
+	} {
+		got := inspect.Signature(test.class)
+		assert.For(ctx, "Signature(%q)", test.class).That(got).Equals(test.expected)
+		assert.For(ctx, "TypeName(Signature(%q))", test.class).That(inspect.TypeName(got)).Equals(test.class)
+	}
+}
+
+// vm returns a fake virtual machine with two threads and a class holding
+// static fields of various types.
+func vm() *test.VM {
+	return &test.VM{
+		Classes: []*test.Class{
+			{
+				Signature: "Lcom/example/Game;",
+				Fields: []*test.Field{
+					{Name: "level", Signature: "I", ModBits: jdwp.ModStatic, Value: 3},
+					{Name: "title", Signature: "Ljava/lang/String;", ModBits: jdwp.ModStatic, Value: "Tetris"},
+					{Name: "grade", Signature: "C", ModBits: jdwp.ModStatic, Value: jdwp.Char('A')},
+					{Name: "speed", Signature: "F", ModBits: jdwp.ModStatic, Value: float32(1.5)},
+					{Name: "ratio", Signature: "D", ModBits: jdwp.ModStatic, Value: math.NaN()},
+					{Name: "player", Signature: "Lcom/example/Player;", ModBits: jdwp.ModStatic, Value: &test.Object{Class: "Lcom/example/Player;"}},
+					{Name: "enemy", Signature: "Lcom/example/Player;", ModBits: jdwp.ModStatic},
+					{Name: "score", Signature: "J", ModBits: jdwp.ModPrivate},
+				},
+				Methods: []*test.Method{
+					{Name: "run", Signature: "()V"},
+					{Name: "draw", Signature: "()V"},
+				},
+			},
+			{Signature: "Lcom/example/Player;"},
+		},
+		Threads: []*test.Thread{
+			{
+				Name:   "render",
+				Status: jdwp.ThreadWait,
+				Frames: []*test.Frame{
+					{Class: "Lcom/example/Game;", Method: "draw", Location: 7},
+					{Class: "Lcom/example/Game;", Method: "run", Location: 12},
+				},
+			},
+			{Name: "main", Status: jdwp.ThreadRunning},
+		},
+	}
+}
+
+func TestTake(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, c := range []struct {
+		name     string
+		opts     inspect.Options
+		expected inspect.Snapshot
+	}{
+		{
+			name: "threads",
+			expected: inspect.Snapshot{Threads: []inspect.Thread{
+				{Name: "main", Status: jdwp.ThreadRunning.String()},
+				{Name: "render", Status: jdwp.ThreadWait.String(), Frames: []inspect.Frame{
+					{Class: "com.example.Game", Method: "draw", Location: 7},
+					{Class: "com.example.Game", Method: "run", Location: 12},
+				}},
+			}},
+		}, {
+			name: "max frames",
+			opts: inspect.Options{MaxFrames: 1},
+			expected: inspect.Snapshot{Threads: []inspect.Thread{
+				{Name: "main", Status: jdwp.ThreadRunning.String()},
+				{Name: "render", Status: jdwp.ThreadWait.String(), Frames: []inspect.Frame{
+					{Class: "com.example.Game", Method: "draw", Location: 7},
+				}},
+			}},
+		}, {
+			name: "classes",
+			opts: inspect.Options{Classes: regexp.MustCompile(`^com\.example\.`), MaxFrames: 1},
+			expected: inspect.Snapshot{
+				Threads: []inspect.Thread{
+					{Name: "main", Status: jdwp.ThreadRunning.String()},
+					{Name: "render", Status: jdwp.ThreadWait.String(), Frames: []inspect.Frame{
+						{Class: "com.example.Game", Method: "draw", Location: 7},
+					}},
+				},
+				Classes: []string{"com.example.Game", "com.example.Player"},
+			},
+		},
+	} {
+		ctx, cancel := task.WithCancel(ctx)
+		conn, err := test.Connect(ctx, vm())
+		if assert.For(ctx, "%v connect", c.name).ThatError(err).Succeeded() {
+			got, err := inspect.Take(ctx, conn, c.opts)
+			if assert.For(ctx, "%v err", c.name).ThatError(err).Succeeded() {
+				assert.For(ctx, "%v", c.name).That(*got).DeepEquals(c.expected)
+			}
+		}
+		cancel()
+	}
+}
+
+func TestTakeStatics(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+	conn, err := test.Connect(ctx, vm())
+	if !assert.For(ctx, "connect").ThatError(err).Succeeded() {
+		return
+	}
+	got, err := inspect.Take(ctx, conn, inspect.Options{Statics: []string{
+		"com.example.Game",
+		"com.example.Game#title",
+		"com.example.Game#score",
+		"com.example.Missing",
+	}})
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+
+	// The object value holds its object identifier.
+	for i, s := range got.Statics {
+		if s.Field == "player" {
+			v, _ := s.Value.(string)
+			assert.For(ctx, "player").That(strings.HasPrefix(v, "com.example.Player@")).Equals(true)
+			got.Statics[i].Value = "com.example.Player@…"
+		}
+	}
+
+	const game = "com.example.Game"
+	assert.For(ctx, "statics").That(got.Statics).DeepEquals([]inspect.Static{
+		{Class: game, Field: "level", Type: "int", Value: 3},
+		{Class: game, Field: "title", Type: "java.lang.String", Value: "Tetris"},
+		{Class: game, Field: "grade", Type: "char", Value: "A"},
+		{Class: game, Field: "speed", Type: "float", Value: 1.5},
+		{Class: game, Field: "ratio", Type: "double", Value: "NaN"},
+		{Class: game, Field: "player", Type: "com.example.Player", Value: "com.example.Player@…"},
+		{Class: game, Field: "enemy", Type: "com.example.Player", Value: nil},
+		{Class: game, Field: "title", Type: "java.lang.String", Value: "Tetris"},
+		{Class: game, Field: "score", Error: "Class 'com.example.Game' has no static field 'score'"},
+		{Class: "com.example.Missing", Error: "Class 'com.example.Missing' is not loaded"},
+	})
+}
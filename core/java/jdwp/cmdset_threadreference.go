@@ -57,6 +57,17 @@ func (c *Connection) GetSuspendCount(id ThreadID) (int, error) {
 	return count, nil
 }
 
+// GetFrameCount returns the number of frames on the thread's stack.
+// The thread must be suspended.
+func (c *Connection) GetFrameCount(id ThreadID) (int, error) {
+	var count int
+	err := c.get(cmdThreadReferenceFrameCount, id, &count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // FrameInfo describes a single stack frame.
 type FrameInfo struct {
 	Frame    FrameID
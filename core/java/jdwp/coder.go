@@ -254,6 +254,10 @@ func (c *Connection) decode(r binary.Reader, v reflect.Value) error {
 			v.Set(reflect.ValueOf(r.Int32()).Convert(t))
 		case reflect.Int64:
 			v.Set(reflect.ValueOf(r.Int64()).Convert(t))
+		case reflect.Float32:
+			v.Set(reflect.ValueOf(r.Float32()).Convert(t))
+		case reflect.Float64:
+			v.Set(reflect.ValueOf(r.Float64()).Convert(t))
 		case reflect.Struct:
 			for i, count := 0, v.NumField(); i < count; i++ {
 				c.decode(r, v.Field(i))
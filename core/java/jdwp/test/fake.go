@@ -0,0 +1,688 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/jdwp"
+)
+
+// VM is a declarative description of a fake Java virtual machine.
+// Connect serves the VM over an in-process JDWP connection, so that code using
+// the jdwp package can be tested without Java installed.
+//
+// Values held by the fake VM use the following Go types:
+// • nil for the null object  • bool            • int8          • jdwp.Char
+// • int16                     • int or int32    • int64         • float32
+// • float64                   • string for java.lang.String instances
+// • *Object                   • *Array          • *Thread       • *Class
+type VM struct {
+	// Classes are the classes loaded by the VM. The java.lang classes used by
+	// the jdbg package are added if they are not declared.
+	Classes []*Class
+	// Threads are the threads of the VM. A thread named "main" is added if no
+	// threads are declared.
+	Threads []*Thread
+	// Events are raised in order, each one once an event request matching it
+	// has been set and the VM is resumed.
+	Events []*Event
+}
+
+// Class describes a class or interface loaded by the fake VM.
+type Class struct {
+	// Signature is the JNI signature of the class, such as "Lcom/foo/Bar;".
+	Signature string
+	// Super is the signature of the super class.
+	// If empty, then the super class is java.lang.Object.
+	Super string
+	// Interfaces are the signatures of the interfaces directly implemented by
+	// the class.
+	Interfaces []string
+	// Interface is true if the type is an interface.
+	Interface bool
+	// Fields are the fields declared by the class.
+	Fields []*Field
+	// Methods are the methods declared by the class.
+	Methods []*Method
+}
+
+// Field describes a field declared by a class.
+type Field struct {
+	Name      string
+	Signature string
+	ModBits   jdwp.ModBits
+	// Value is the initial value of a static field.
+	Value interface{}
+}
+
+// Method describes a method declared by a class.
+type Method struct {
+	Name      string
+	Signature string
+	ModBits   jdwp.ModBits
+	// Variables is the variable table of the method.
+	Variables []jdwp.FrameVariable
+	// Invoke is called when the method is invoked, or for constructors, when
+	// a new instance is created. If Invoke is nil, then the method returns the
+	// zero value of its return type. If Invoke returns a jdwp.Error, then the
+	// invoke request fails with that error, otherwise other errors are thrown
+	// as java.lang.RuntimeException instances.
+	Invoke func(*Call) (interface{}, error)
+}
+
+// Call holds the arguments of a method invocation.
+type Call struct {
+	// This is the object the method was invoked on, or the newly created
+	// object for constructors. This is nil for static methods.
+	This *Object
+	// Args are the arguments passed to the method.
+	Args []interface{}
+	// Thread is the thread the method was invoked on.
+	Thread *Thread
+}
+
+// Object is an instance of a class.
+type Object struct {
+	// Class is the signature of the object's class.
+	Class string
+	// Fields holds the values of the object's instance fields by name.
+	Fields map[string]interface{}
+}
+
+// Array is an instance of an array type.
+type Array struct {
+	// Class is the signature of the array type, such as "[I".
+	Class string
+	// Elements holds the values of the array elements.
+	Elements []interface{}
+}
+
+// Thread describes a thread of the fake VM.
+type Thread struct {
+	Name   string
+	Status jdwp.ThreadStatus
+	// Frames are the frames of the thread's stack, innermost first.
+	Frames []*Frame
+}
+
+// Frame describes a single stack frame.
+type Frame struct {
+	// Class is the signature of the class declaring the frame's method.
+	Class string
+	// Method is the name of the frame's method.
+	Method   string
+	Location uint64
+	// This is the object the method was invoked on, or nil for static methods.
+	This *Object
+	// Locals holds the values of the frame's local variables by slot.
+	Locals map[int]interface{}
+}
+
+// Event describes an event raised by the fake VM.
+type Event struct {
+	// Kind is one of jdwp.ClassPrepare, jdwp.MethodEntry, jdwp.MethodExit,
+	// jdwp.Breakpoint, jdwp.ThreadStart or jdwp.ThreadDeath.
+	Kind jdwp.EventKind
+	// Thread is the name of the thread that raises the event.
+	// If empty, then the first thread raises the event.
+	Thread string
+	// Class is the signature of the class the event relates to.
+	Class string
+	// Method is the name of the method, for method and breakpoint events.
+	Method   string
+	Location uint64
+}
+
+// Connect starts serving the fake VM and returns a JDWP connection to it.
+// The VM is served until ctx is stopped.
+func Connect(ctx context.Context, vm *VM) (*jdwp.Connection, error) {
+	s, err := newServer(vm)
+	if err != nil {
+		return nil, err
+	}
+	client, server := net.Pipe()
+	go func() {
+		<-task.ShouldStop(ctx)
+		client.Close()
+		server.Close()
+	}()
+	go s.serve(server)
+	return jdwp.Open(ctx, client)
+}
+
+type server struct {
+	sync.Mutex
+	nextID     uint64
+	classes    []*class
+	classBySig map[string]*class
+	methods    map[uint64]*method
+	fields     map[uint64]*field
+	threads    []*thread
+	frames     map[uint64]*frame
+	objects    map[uint64]interface{}
+	ids        map[interface{}]uint64
+	pending    []*Event
+	requests   []*request
+	nextReq    int
+	suspended  int
+	resumed    bool
+	send       func(set, id uint8, data []byte) error
+}
+
+type class struct {
+	*Class
+	id         uint64
+	kind       jdwp.TypeTag
+	super      *class
+	interfaces []*class
+	fields     []*field
+	methods    []*method
+}
+
+type field struct {
+	*Field
+	id    uint64
+	class *class
+	value interface{}
+}
+
+type method struct {
+	*Method
+	id    uint64
+	class *class
+}
+
+type thread struct {
+	*Thread
+	id        uint64
+	suspended int
+	frames    []*frame
+}
+
+type frame struct {
+	*Frame
+	id     uint64
+	class  *class
+	method *method
+}
+
+type request struct {
+	id        int
+	kind      jdwp.EventKind
+	policy    jdwp.SuspendPolicy
+	modifiers []jdwp.EventModifier
+	count     int
+}
+
+const (
+	sigObject    = "Ljava/lang/Object;"
+	sigString    = "Ljava/lang/String;"
+	sigClass     = "Ljava/lang/Class;"
+	sigThread    = "Ljava/lang/Thread;"
+	sigException = "Ljava/lang/RuntimeException;"
+)
+
+func newServer(vm *VM) (*server, error) {
+	s := &server{
+		classBySig: map[string]*class{},
+		methods:    map[uint64]*method{},
+		fields:     map[uint64]*field{},
+		frames:     map[uint64]*frame{},
+		objects:    map[uint64]interface{}{},
+		ids:        map[interface{}]uint64{},
+		pending:    append([]*Event{}, vm.Events...),
+		nextReq:    1,
+	}
+
+	for _, c := range append(append([]*Class{}, vm.Classes...), builtins()...) {
+		if _, dup := s.classBySig[c.Signature]; dup {
+			continue // Declared classes replace the builtins.
+		}
+		s.addClass(c)
+	}
+	for _, c := range s.classes {
+		super := c.Super
+		if super == "" && c.Signature != sigObject && !c.Interface {
+			super = sigObject
+		}
+		if super != "" {
+			if c.super = s.classBySig[super]; c.super == nil {
+				return nil, fmt.Errorf("Super class '%v' of '%v' not found", super, c.Signature)
+			}
+		}
+		for _, sig := range c.Interfaces {
+			i := s.classBySig[sig]
+			if i == nil {
+				return nil, fmt.Errorf("Interface '%v' of '%v' not found", sig, c.Signature)
+			}
+			c.interfaces = append(c.interfaces, i)
+		}
+	}
+
+	threads := vm.Threads
+	if len(threads) == 0 {
+		threads = []*Thread{{Name: "main", Status: jdwp.ThreadRunning}}
+	}
+	for _, t := range threads {
+		th := &thread{Thread: t, id: s.newID(t)}
+		for _, f := range t.Frames {
+			c := s.classBySig[f.Class]
+			if c == nil {
+				return nil, fmt.Errorf("Class '%v' of frame in thread '%v' not found", f.Class, t.Name)
+			}
+			m := c.method(f.Method)
+			if m == nil {
+				return nil, fmt.Errorf("Method '%v' of frame in thread '%v' not found", f.Method, t.Name)
+			}
+			fr := &frame{Frame: f, id: s.newID(nil), class: c, method: m}
+			s.frames[fr.id] = fr
+			th.frames = append(th.frames, fr)
+		}
+		s.threads = append(s.threads, th)
+	}
+
+	for _, e := range s.pending {
+		if e.Thread != "" && s.thread(e.Thread) == nil {
+			return nil, fmt.Errorf("Thread '%v' of %v event not found", e.Thread, e.Kind)
+		}
+		switch e.Kind {
+		case jdwp.ThreadStart, jdwp.ThreadDeath:
+		case jdwp.ClassPrepare, jdwp.MethodEntry, jdwp.MethodExit, jdwp.Breakpoint:
+			c := s.classBySig[e.Class]
+			if c == nil {
+				return nil, fmt.Errorf("Class '%v' of %v event not found", e.Class, e.Kind)
+			}
+			if e.Kind != jdwp.ClassPrepare && c.method(e.Method) == nil {
+				return nil, fmt.Errorf("Method '%v' of %v event not found", e.Method, e.Kind)
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported event kind %v", e.Kind)
+		}
+	}
+	return s, nil
+}
+
+func (s *server) newID(o interface{}) uint64 {
+	s.nextID++
+	if o != nil {
+		s.objects[s.nextID] = o
+		s.ids[o] = s.nextID
+	}
+	return s.nextID
+}
+
+func (s *server) addClass(c *Class) *class {
+	out := &class{Class: c, kind: jdwp.Class}
+	out.id = s.newID(c)
+	switch {
+	case c.Interface:
+		out.kind = jdwp.Interface
+	case strings.HasPrefix(c.Signature, "["):
+		out.kind = jdwp.Array
+	}
+	for _, f := range c.Fields {
+		out.fields = append(out.fields, &field{Field: f, id: s.newID(nil), class: out, value: f.Value})
+		s.fields[out.fields[len(out.fields)-1].id] = out.fields[len(out.fields)-1]
+	}
+	for _, m := range c.Methods {
+		out.methods = append(out.methods, &method{Method: m, id: s.newID(nil), class: out})
+		s.methods[out.methods[len(out.methods)-1].id] = out.methods[len(out.methods)-1]
+	}
+	s.classes = append(s.classes, out)
+	s.classBySig[c.Signature] = out
+	return out
+}
+
+// class returns the class with the given signature. Array types are created
+// on demand.
+func (s *server) class(sig string) *class {
+	if c, ok := s.classBySig[sig]; ok {
+		return c
+	}
+	if !strings.HasPrefix(sig, "[") || !s.validType(sig[1:]) {
+		return nil
+	}
+	c := s.addClass(&Class{Signature: sig})
+	c.super = s.classBySig[sigObject]
+	return c
+}
+
+func (s *server) validType(sig string) bool {
+	switch {
+	case sig == "":
+		return false
+	case len(sig) == 1:
+		return strings.Contains("ZBCSIJFD", sig)
+	default:
+		return s.class(sig) != nil
+	}
+}
+
+func (c *class) method(name string) *method {
+	for _, m := range c.methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func (c *class) field(id uint64) *field {
+	for t := c; t != nil; t = t.super {
+		for _, f := range t.fields {
+			if f.id == id {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func (c *class) isA(o *class) bool {
+	if c == o {
+		return true
+	}
+	for _, i := range c.interfaces {
+		if i.isA(o) {
+			return true
+		}
+	}
+	return c.super != nil && c.super.isA(o)
+}
+
+func (s *server) thread(name string) *thread {
+	for _, t := range s.threads {
+		if name == "" || t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *server) threadByID(id uint64) *thread {
+	for _, t := range s.threads {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// id returns the object identifier for the value, allocating a new identifier
+// if the value has not been seen before.
+func (s *server) id(v interface{}) uint64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case jdwp.Object:
+		return uint64(v.ID())
+	}
+	if id, ok := s.ids[v]; ok {
+		return id
+	}
+	return s.newID(v)
+}
+
+// typeOf returns the class of the object with the given identifier.
+func (s *server) typeOf(id uint64) *class {
+	switch o := s.objects[id].(type) {
+	case string:
+		return s.class(sigString)
+	case *Object:
+		return s.class(o.Class)
+	case *Array:
+		return s.class(o.Class)
+	case *Thread:
+		return s.class(sigThread)
+	case *Class:
+		return s.class(sigClass)
+	}
+	return nil
+}
+
+// signature returns the type signature of the value. If the value is nil, then
+// def is returned.
+func (s *server) signature(v interface{}, def string) string {
+	switch v := v.(type) {
+	case bool:
+		return "Z"
+	case int8, uint8:
+		return "B"
+	case jdwp.Char:
+		return "C"
+	case int16:
+		return "S"
+	case int, int32:
+		return "I"
+	case int64:
+		return "J"
+	case float32:
+		return "F"
+	case float64:
+		return "D"
+	case nil:
+		return def
+	default:
+		if c := s.typeOf(s.id(v)); c != nil {
+			return c.Signature
+		}
+		return def
+	}
+}
+
+// tag returns the JDWP tag of a value of the given type.
+func (s *server) tag(v interface{}, sig string) jdwp.Tag {
+	switch sig[0] {
+	case 'L':
+		switch v.(type) {
+		case string:
+			return jdwp.TagString
+		case *Thread:
+			return jdwp.TagThread
+		case *Class:
+			return jdwp.TagClassObject
+		case *Array:
+			return jdwp.TagArray
+		}
+		return jdwp.TagObject
+	case '[':
+		return jdwp.TagArray
+	}
+	return jdwp.Tag(sig[0])
+}
+
+// writeValue writes the value v of the type sig, prefixed with its tag.
+func (s *server) writeValue(w binary.Writer, v interface{}, sig string) {
+	if sig == "" || sig[0] == 'L' || sig[0] == '[' {
+		// Use the runtime type of the object for the tag.
+		sig = s.signature(v, sig)
+		if sig == "" {
+			sig = sigObject
+		}
+	}
+	w.Uint8(uint8(s.tag(v, sig)))
+	s.writeUntagged(w, v, sig)
+}
+
+// writeUntagged writes the value v of the type sig.
+func (s *server) writeUntagged(w binary.Writer, v interface{}, sig string) {
+	r := reflect.ValueOf(v)
+	i, f := int64(0), float64(0)
+	switch r.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, f = r.Int(), float64(r.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, f = int64(r.Uint()), float64(r.Uint())
+	case reflect.Float32, reflect.Float64:
+		i, f = int64(r.Float()), r.Float()
+	case reflect.Bool:
+		if r.Bool() {
+			i = 1
+		}
+	}
+	switch sig[0] {
+	case 'V':
+	case 'Z':
+		w.Bool(i != 0)
+	case 'B':
+		w.Int8(int8(i))
+	case 'C':
+		w.Uint16(uint16(i))
+	case 'S':
+		w.Int16(int16(i))
+	case 'I':
+		w.Int32(int32(i))
+	case 'J':
+		w.Int64(i)
+	case 'F':
+		w.Float32(float32(f))
+	case 'D':
+		w.Float64(f)
+	default:
+		w.Uint64(s.id(v))
+	}
+}
+
+// readValue reads a value prefixed with its tag.
+func (s *server) readValue(r binary.Reader) interface{} {
+	return s.readUntagged(r, string(rune(r.Uint8())))
+}
+
+// readUntagged reads a value of the type sig, or with the tag sig.
+func (s *server) readUntagged(r binary.Reader, sig string) interface{} {
+	switch sig[0] {
+	case 'V':
+		return nil
+	case 'Z':
+		return r.Bool()
+	case 'B':
+		return r.Int8()
+	case 'C':
+		return jdwp.Char(r.Int16())
+	case 'S':
+		return r.Int16()
+	case 'I':
+		return int(r.Int32())
+	case 'J':
+		return r.Int64()
+	case 'F':
+		return r.Float32()
+	case 'D':
+		return r.Float64()
+	default:
+		return s.objects[r.Uint64()]
+	}
+}
+
+// zero returns the zero value of the given type.
+func zero(sig string) interface{} {
+	switch sig[0] {
+	case 'Z':
+		return false
+	case 'B':
+		return int8(0)
+	case 'C':
+		return jdwp.Char(0)
+	case 'S':
+		return int16(0)
+	case 'I':
+		return 0
+	case 'J':
+		return int64(0)
+	case 'F':
+		return float32(0)
+	case 'D':
+		return float64(0)
+	}
+	return nil
+}
+
+// returnType returns the return type of the method signature.
+func returnType(sig string) string {
+	return sig[strings.LastIndex(sig, ")")+1:]
+}
+
+// builtins returns the java.lang classes used by the jdbg package.
+func builtins() []*Class {
+	toString := &Method{
+		Name:      "toString",
+		Signature: "()Ljava/lang/String;",
+		ModBits:   jdwp.ModPublic,
+		Invoke: func(c *Call) (interface{}, error) {
+			if c.This == nil {
+				return "", nil
+			}
+			if msg, ok := c.This.Fields["message"]; ok {
+				return fmt.Sprintf("%v: %v", typeName(c.This.Class), msg), nil
+			}
+			return typeName(c.This.Class), nil
+		},
+	}
+	out := []*Class{
+		{Signature: sigObject, Methods: []*Method{constructor(), toString}},
+		{Signature: sigString, Methods: []*Method{constructor()}},
+		{Signature: sigClass, Methods: []*Method{constructor()}},
+		{Signature: sigThread, Methods: []*Method{constructor()}},
+		{
+			Signature: sigException,
+			Fields:    []*Field{{Name: "message", Signature: sigString, ModBits: jdwp.ModPrivate}},
+			Methods:   []*Method{constructor()},
+		},
+		{Signature: "Ljava/lang/Number;", Methods: []*Method{constructor()}},
+	}
+	for _, b := range []struct{ name, super, sig string }{
+		{"Boolean", sigObject, "Z"},
+		{"Byte", "Ljava/lang/Number;", "B"},
+		{"Character", sigObject, "C"},
+		{"Short", "Ljava/lang/Number;", "S"},
+		{"Integer", "Ljava/lang/Number;", "I"},
+		{"Long", "Ljava/lang/Number;", "J"},
+		{"Float", "Ljava/lang/Number;", "F"},
+		{"Double", "Ljava/lang/Number;", "D"},
+	} {
+		out = append(out, &Class{
+			Signature: "Ljava/lang/" + b.name + ";",
+			Super:     b.super,
+			Fields:    []*Field{{Name: "value", Signature: b.sig, ModBits: jdwp.ModPrivate | jdwp.ModFinal}},
+			Methods: []*Method{{
+				Name:      "<init>",
+				Signature: "(" + b.sig + ")V",
+				ModBits:   jdwp.ModPublic,
+				Invoke: func(c *Call) (interface{}, error) {
+					c.This.Fields["value"] = c.Args[0]
+					return nil, nil
+				},
+			}},
+		})
+	}
+	return out
+}
+
+func constructor() *Method {
+	return &Method{Name: "<init>", Signature: "()V", ModBits: jdwp.ModPublic}
+}
+
+// typeName returns the Java type name for the class signature.
+func typeName(sig string) string {
+	return strings.Replace(strings.TrimSuffix(strings.TrimPrefix(sig, "L"), ";"), "/", ".", -1)
+}
@@ -0,0 +1,999 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/os/device"
+)
+
+const (
+	packetIsReply   = 0x80
+	classStatus     = jdwp.StatusVerified | jdwp.StatusPrepared | jdwp.StatusInitialized
+	cmdSetEvent     = 64
+	cmdCompositeEvt = 100
+)
+
+var handshake = []byte("JDWP-Handshake")
+
+type handler func(s *server, r binary.Reader, w binary.Writer) jdwp.Error
+
+// handlers are the command handlers by command set and command.
+// See https://docs.oracle.com/javase/8/docs/platform/jpda/jdwp/jdwp-protocol.html
+var handlers = map[[2]uint8]handler{
+	{1, 1}:   (*server).vmVersion,
+	{1, 2}:   (*server).vmClassesBySignature,
+	{1, 3}:   (*server).vmAllClasses,
+	{1, 4}:   (*server).vmAllThreads,
+	{1, 7}:   (*server).vmIDSizes,
+	{1, 8}:   (*server).vmSuspend,
+	{1, 9}:   (*server).vmResume,
+	{1, 11}:  (*server).vmCreateString,
+	{2, 1}:   (*server).refTypeSignature,
+	{2, 4}:   (*server).refTypeFields,
+	{2, 5}:   (*server).refTypeMethods,
+	{2, 6}:   (*server).refTypeGetValues,
+	{2, 10}:  (*server).refTypeInterfaces,
+	{3, 1}:   (*server).classTypeSuperclass,
+	{3, 2}:   (*server).classTypeSetValues,
+	{3, 3}:   (*server).classTypeInvokeMethod,
+	{3, 4}:   (*server).classTypeNewInstance,
+	{4, 1}:   (*server).arrayTypeNewInstance,
+	{6, 2}:   (*server).methodVariableTable,
+	{9, 1}:   (*server).objectReferenceType,
+	{9, 2}:   (*server).objectGetValues,
+	{9, 3}:   (*server).objectSetValues,
+	{9, 6}:   (*server).objectInvokeMethod,
+	{9, 7}:   (*server).objectCollection,
+	{9, 8}:   (*server).objectCollection,
+	{10, 1}:  (*server).stringValue,
+	{11, 1}:  (*server).threadName,
+	{11, 2}:  (*server).threadSuspend,
+	{11, 3}:  (*server).threadResume,
+	{11, 4}:  (*server).threadStatus,
+	{11, 6}:  (*server).threadFrames,
+	{11, 7}:  (*server).threadFrameCount,
+	{11, 12}: (*server).threadSuspendCount,
+	{13, 1}:  (*server).arrayLength,
+	{13, 2}:  (*server).arrayGetValues,
+	{13, 3}:  (*server).arraySetValues,
+	{15, 1}:  (*server).eventRequestSet,
+	{15, 2}:  (*server).eventRequestClear,
+	{16, 1}:  (*server).frameGetValues,
+	{16, 2}:  (*server).frameSetValues,
+	{16, 3}:  (*server).frameThisObject,
+	{17, 1}:  (*server).classObjectReflectedType,
+}
+
+// serve answers the JDWP requests read from conn until conn is closed.
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	got := make([]byte, len(handshake))
+	if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, handshake) {
+		return
+	}
+	if _, err := conn.Write(handshake); err != nil {
+		return
+	}
+
+	mutex, nextID := sync.Mutex{}, uint32(0)
+	write := func(id uint32, flags uint8, header []byte, data []byte) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		buf := &bytes.Buffer{}
+		w := endian.Writer(buf, device.BigEndian)
+		w.Uint32(uint32(9 + len(header) + len(data)))
+		w.Uint32(id)
+		w.Uint8(flags)
+		w.Data(header)
+		w.Data(data)
+		_, err := conn.Write(buf.Bytes())
+		return err
+	}
+	s.send = func(set, cmd uint8, data []byte) error {
+		nextID++
+		return write(nextID|0x80000000, 0, []byte{set, cmd}, data)
+	}
+
+	r := endian.Reader(conn, device.BigEndian)
+	for {
+		length := r.Uint32()
+		id := r.Uint32()
+		r.Uint8() // flags
+		set, cmd := r.Uint8(), r.Uint8()
+		if r.Error() != nil || length < 11 {
+			return
+		}
+		data := make([]byte, length-11)
+		r.Data(data)
+		if r.Error() != nil {
+			return
+		}
+
+		reply, err, resumed := s.handle(set, cmd, data)
+		if write(id, packetIsReply, []byte{uint8(err >> 8), uint8(err)}, reply) != nil {
+			return
+		}
+		if resumed {
+			s.Lock()
+			s.fire()
+			s.Unlock()
+		}
+	}
+}
+
+// handle handles a single command, returning the reply data, the error code
+// and whether any threads were resumed by the command.
+func (s *server) handle(set, cmd uint8, data []byte) ([]byte, jdwp.Error, bool) {
+	h, ok := handlers[[2]uint8{set, cmd}]
+	if !ok {
+		return nil, jdwp.ErrNotImplemented, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	r := endian.Reader(bytes.NewReader(data), device.BigEndian)
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.BigEndian)
+	s.resumed = false
+	if err := h(s, r, w); err != jdwp.ErrNone {
+		return nil, err, false
+	}
+	if r.Error() != nil {
+		return nil, jdwp.ErrIllegalArgument, false
+	}
+	return buf.Bytes(), jdwp.ErrNone, s.resumed
+}
+
+func readString(r binary.Reader) string {
+	data := make([]byte, r.Uint32())
+	r.Data(data)
+	return string(data)
+}
+
+func writeString(w binary.Writer, s string) {
+	w.Uint32(uint32(len(s)))
+	w.Data([]byte(s))
+}
+
+func (s *server) writeLocation(w binary.Writer, c *class, m *method, index uint64) {
+	w.Uint8(uint8(c.kind))
+	w.Uint64(c.id)
+	w.Uint64(m.id)
+	w.Uint64(index)
+}
+
+func (s *server) readRefType(r binary.Reader) *class {
+	if c, ok := s.objects[r.Uint64()].(*Class); ok {
+		return s.classBySig[c.Signature]
+	}
+	return nil
+}
+
+func (s *server) readThread(r binary.Reader) *thread {
+	return s.threadByID(r.Uint64())
+}
+
+func (s *server) readArgs(r binary.Reader) []interface{} {
+	args := make([]interface{}, r.Int32())
+	for i := range args {
+		args[i] = s.readValue(r)
+	}
+	return args
+}
+
+func (s *server) vmVersion(r binary.Reader, w binary.Writer) jdwp.Error {
+	writeString(w, "Fake Java virtual machine")
+	w.Int32(1)
+	w.Int32(8)
+	writeString(w, "1.8.0")
+	writeString(w, "Fake VM")
+	return jdwp.ErrNone
+}
+
+func (s *server) vmClassesBySignature(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.class(readString(r))
+	if c == nil {
+		w.Int32(0)
+		return jdwp.ErrNone
+	}
+	w.Int32(1)
+	w.Uint8(uint8(c.kind))
+	w.Uint64(c.id)
+	w.Int32(int32(classStatus))
+	return jdwp.ErrNone
+}
+
+func (s *server) vmAllClasses(r binary.Reader, w binary.Writer) jdwp.Error {
+	w.Int32(int32(len(s.classes)))
+	for _, c := range s.classes {
+		w.Uint8(uint8(c.kind))
+		w.Uint64(c.id)
+		writeString(w, c.Signature)
+		w.Int32(int32(classStatus))
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) vmAllThreads(r binary.Reader, w binary.Writer) jdwp.Error {
+	w.Int32(int32(len(s.threads)))
+	for _, t := range s.threads {
+		w.Uint64(t.id)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) vmIDSizes(r binary.Reader, w binary.Writer) jdwp.Error {
+	for i := 0; i < 5; i++ {
+		w.Int32(8)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) vmSuspend(r binary.Reader, w binary.Writer) jdwp.Error {
+	s.suspended++
+	return jdwp.ErrNone
+}
+
+func (s *server) vmResume(r binary.Reader, w binary.Writer) jdwp.Error {
+	if s.suspended > 0 {
+		s.suspended--
+	}
+	s.resumed = true
+	return jdwp.ErrNone
+}
+
+func (s *server) vmCreateString(r binary.Reader, w binary.Writer) jdwp.Error {
+	w.Uint64(s.id(readString(r)))
+	return jdwp.ErrNone
+}
+
+func (s *server) refTypeSignature(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	writeString(w, c.Signature)
+	return jdwp.ErrNone
+}
+
+func (s *server) refTypeFields(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	w.Int32(int32(len(c.fields)))
+	for _, f := range c.fields {
+		w.Uint64(f.id)
+		writeString(w, f.Name)
+		writeString(w, f.Signature)
+		w.Int32(int32(f.ModBits))
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) refTypeMethods(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	w.Int32(int32(len(c.methods)))
+	for _, m := range c.methods {
+		w.Uint64(m.id)
+		writeString(w, m.Name)
+		writeString(w, m.Signature)
+		w.Int32(int32(m.ModBits))
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) refTypeGetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	fields := make([]*field, r.Int32())
+	for i := range fields {
+		if fields[i] = c.field(r.Uint64()); fields[i] == nil {
+			return jdwp.ErrInvalidFieldID
+		}
+	}
+	w.Int32(int32(len(fields)))
+	for _, f := range fields {
+		s.writeValue(w, f.value, f.Signature)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) refTypeInterfaces(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	w.Int32(int32(len(c.interfaces)))
+	for _, i := range c.interfaces {
+		w.Uint64(i.id)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) classTypeSuperclass(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	if c.super != nil {
+		w.Uint64(c.super.id)
+	} else {
+		w.Uint64(0)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) classTypeSetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	for i, n := 0, int(r.Int32()); i < n; i++ {
+		f := c.field(r.Uint64())
+		if f == nil {
+			return jdwp.ErrInvalidFieldID
+		}
+		f.value = s.readUntagged(r, f.Signature)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) classTypeInvokeMethod(r binary.Reader, w binary.Writer) jdwp.Error {
+	c, t, m := s.readRefType(r), s.readThread(r), s.methods[r.Uint64()]
+	args := s.readArgs(r)
+	r.Int32() // options
+	switch {
+	case c == nil:
+		return jdwp.ErrInvalidClass
+	case t == nil:
+		return jdwp.ErrInvalidThread
+	case m == nil || !c.isA(m.class):
+		return jdwp.ErrInvalidMethodID
+	}
+	return s.writeInvoke(w, t, m, nil, args)
+}
+
+func (s *server) classTypeNewInstance(r binary.Reader, w binary.Writer) jdwp.Error {
+	c, t, m := s.readRefType(r), s.readThread(r), s.methods[r.Uint64()]
+	args := s.readArgs(r)
+	r.Int32() // options
+	switch {
+	case c == nil:
+		return jdwp.ErrInvalidClass
+	case t == nil:
+		return jdwp.ErrInvalidThread
+	case m == nil || m.class != c || m.Name != "<init>":
+		return jdwp.ErrInvalidMethodID
+	}
+	obj := &Object{Class: c.Signature, Fields: map[string]interface{}{}}
+	for t := c; t != nil; t = t.super {
+		for _, f := range t.fields {
+			if f.ModBits&jdwp.ModStatic == 0 {
+				obj.Fields[f.Name] = zero(f.Signature)
+			}
+		}
+	}
+	_, exception, err := s.invoke(t, m, obj, args)
+	if err != jdwp.ErrNone {
+		return err
+	}
+	if exception != nil {
+		s.writeValue(w, nil, c.Signature)
+	} else {
+		s.writeValue(w, obj, c.Signature)
+	}
+	s.writeValue(w, exception, sigException)
+	return jdwp.ErrNone
+}
+
+func (s *server) arrayTypeNewInstance(r binary.Reader, w binary.Writer) jdwp.Error {
+	c, length := s.readRefType(r), int(r.Int32())
+	if c == nil || c.kind != jdwp.Array {
+		return jdwp.ErrInvalidClass
+	}
+	a := &Array{Class: c.Signature, Elements: make([]interface{}, length)}
+	for i := range a.Elements {
+		a.Elements[i] = zero(c.Signature[1:])
+	}
+	s.writeValue(w, a, c.Signature)
+	return jdwp.ErrNone
+}
+
+func (s *server) methodVariableTable(r binary.Reader, w binary.Writer) jdwp.Error {
+	c, m := s.readRefType(r), s.methods[r.Uint64()]
+	switch {
+	case c == nil:
+		return jdwp.ErrInvalidClass
+	case m == nil || m.class != c:
+		return jdwp.ErrInvalidMethodID
+	case m.Variables == nil:
+		return jdwp.ErrAbsentInformation
+	}
+	args := 0
+	for _, v := range m.Variables {
+		if v.CodeIndex == 0 && v.Name != "this" {
+			args++
+		}
+	}
+	w.Int32(int32(args))
+	w.Int32(int32(len(m.Variables)))
+	for _, v := range m.Variables {
+		w.Uint64(v.CodeIndex)
+		writeString(w, v.Name)
+		writeString(w, v.Signature)
+		w.Int32(int32(v.Length))
+		w.Int32(int32(v.Slot))
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) objectReferenceType(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.typeOf(r.Uint64())
+	if c == nil {
+		return jdwp.ErrInvalidObject
+	}
+	w.Uint8(uint8(c.kind))
+	w.Uint64(c.id)
+	return jdwp.ErrNone
+}
+
+func (s *server) objectGetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	id := r.Uint64()
+	obj, ok := s.objects[id].(*Object)
+	if !ok {
+		return jdwp.ErrInvalidObject
+	}
+	c := s.typeOf(id)
+	fields := make([]*field, r.Int32())
+	for i := range fields {
+		if fields[i] = c.field(r.Uint64()); fields[i] == nil {
+			return jdwp.ErrInvalidFieldID
+		}
+	}
+	w.Int32(int32(len(fields)))
+	for _, f := range fields {
+		if f.ModBits&jdwp.ModStatic != 0 {
+			s.writeValue(w, f.value, f.Signature)
+		} else {
+			s.writeValue(w, obj.Fields[f.Name], f.Signature)
+		}
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) objectSetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	id := r.Uint64()
+	obj, ok := s.objects[id].(*Object)
+	if !ok {
+		return jdwp.ErrInvalidObject
+	}
+	c := s.typeOf(id)
+	for i, n := 0, int(r.Int32()); i < n; i++ {
+		f := c.field(r.Uint64())
+		if f == nil {
+			return jdwp.ErrInvalidFieldID
+		}
+		if v := s.readUntagged(r, f.Signature); f.ModBits&jdwp.ModStatic != 0 {
+			f.value = v
+		} else {
+			obj.Fields[f.Name] = v
+		}
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) objectInvokeMethod(r binary.Reader, w binary.Writer) jdwp.Error {
+	id, t := r.Uint64(), s.readThread(r)
+	c, m := s.readRefType(r), s.methods[r.Uint64()]
+	args := s.readArgs(r)
+	options := jdwp.InvokeOptions(r.Int32())
+	objTy := s.typeOf(id)
+	switch {
+	case objTy == nil:
+		return jdwp.ErrInvalidObject
+	case t == nil:
+		return jdwp.ErrInvalidThread
+	case c == nil:
+		return jdwp.ErrInvalidClass
+	case m == nil || !objTy.isA(m.class):
+		return jdwp.ErrInvalidMethodID
+	}
+	if options&jdwp.InvokeNonvirtual == 0 {
+		// Find the most derived override of the method.
+	search:
+		for ty := objTy; ty != nil; ty = ty.super {
+			for _, o := range ty.methods {
+				if o.Name == m.Name && o.Signature == m.Signature {
+					m = o
+					break search
+				}
+			}
+		}
+	}
+	this, _ := s.objects[id].(*Object)
+	return s.writeInvoke(w, t, m, this, args)
+}
+
+func (s *server) objectCollection(r binary.Reader, w binary.Writer) jdwp.Error {
+	if _, ok := s.objects[r.Uint64()]; !ok {
+		return jdwp.ErrInvalidObject
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) stringValue(r binary.Reader, w binary.Writer) jdwp.Error {
+	str, ok := s.objects[r.Uint64()].(string)
+	if !ok {
+		return jdwp.ErrInvalidString
+	}
+	writeString(w, str)
+	return jdwp.ErrNone
+}
+
+func (s *server) threadName(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	if t == nil {
+		return jdwp.ErrInvalidThread
+	}
+	writeString(w, t.Name)
+	return jdwp.ErrNone
+}
+
+func (s *server) threadSuspend(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	if t == nil {
+		return jdwp.ErrInvalidThread
+	}
+	t.suspended++
+	return jdwp.ErrNone
+}
+
+func (s *server) threadResume(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	if t == nil {
+		return jdwp.ErrInvalidThread
+	}
+	if t.suspended > 0 {
+		t.suspended--
+	}
+	s.resumed = true
+	return jdwp.ErrNone
+}
+
+func (s *server) threadStatus(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	if t == nil {
+		return jdwp.ErrInvalidThread
+	}
+	w.Int32(int32(t.Status))
+	if s.isSuspended(t) {
+		w.Int32(int32(jdwp.Suspended))
+	} else {
+		w.Int32(int32(jdwp.NotSuspended))
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) threadFrames(r binary.Reader, w binary.Writer) jdwp.Error {
+	t, start, length := s.readThread(r), int(r.Int32()), int(r.Int32())
+	switch {
+	case t == nil:
+		return jdwp.ErrInvalidThread
+	case !s.isSuspended(t):
+		return jdwp.ErrThreadNotSuspended
+	case start < 0 || start > len(t.frames):
+		return jdwp.ErrInvalidIndex
+	}
+	frames := t.frames[start:]
+	if length >= 0 {
+		if length > len(frames) {
+			return jdwp.ErrInvalidLength
+		}
+		frames = frames[:length]
+	}
+	w.Int32(int32(len(frames)))
+	for _, f := range frames {
+		w.Uint64(f.id)
+		s.writeLocation(w, f.class, f.method, f.Location)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) threadFrameCount(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	switch {
+	case t == nil:
+		return jdwp.ErrInvalidThread
+	case !s.isSuspended(t):
+		return jdwp.ErrThreadNotSuspended
+	}
+	w.Int32(int32(len(t.frames)))
+	return jdwp.ErrNone
+}
+
+func (s *server) threadSuspendCount(r binary.Reader, w binary.Writer) jdwp.Error {
+	t := s.readThread(r)
+	if t == nil {
+		return jdwp.ErrInvalidThread
+	}
+	w.Int32(int32(s.suspended + t.suspended))
+	return jdwp.ErrNone
+}
+
+func (s *server) isSuspended(t *thread) bool {
+	return s.suspended+t.suspended > 0
+}
+
+func (s *server) arrayLength(r binary.Reader, w binary.Writer) jdwp.Error {
+	a, ok := s.objects[r.Uint64()].(*Array)
+	if !ok {
+		return jdwp.ErrInvalidArray
+	}
+	w.Int32(int32(len(a.Elements)))
+	return jdwp.ErrNone
+}
+
+func (s *server) arrayGetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	a, ok := s.objects[r.Uint64()].(*Array)
+	first, length := int(r.Int32()), int(r.Int32())
+	switch {
+	case !ok:
+		return jdwp.ErrInvalidArray
+	case first < 0 || length < 0 || first+length > len(a.Elements):
+		return jdwp.ErrInvalidIndex
+	}
+	elTy := a.Class[1:]
+	w.Uint8(uint8(s.tag(nil, elTy)))
+	w.Int32(int32(length))
+	for _, v := range a.Elements[first : first+length] {
+		if len(elTy) == 1 {
+			s.writeUntagged(w, v, elTy)
+		} else {
+			s.writeValue(w, v, elTy)
+		}
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) arraySetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	a, ok := s.objects[r.Uint64()].(*Array)
+	first, count := int(r.Int32()), int(r.Int32())
+	switch {
+	case !ok:
+		return jdwp.ErrInvalidArray
+	case first < 0 || count < 0 || first+count > len(a.Elements):
+		return jdwp.ErrInvalidIndex
+	}
+	for i := 0; i < count; i++ {
+		a.Elements[first+i] = s.readUntagged(r, a.Class[1:])
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) eventRequestSet(r binary.Reader, w binary.Writer) jdwp.Error {
+	req := &request{
+		id:     s.nextReq,
+		kind:   jdwp.EventKind(r.Uint8()),
+		policy: jdwp.SuspendPolicy(r.Uint8()),
+	}
+	for i, n := 0, int(r.Int32()); i < n; i++ {
+		var m jdwp.EventModifier
+		switch r.Uint8() {
+		case 1:
+			m = jdwp.CountEventModifier(r.Int32())
+			req.count = int(m.(jdwp.CountEventModifier))
+		case 2:
+			r.Int32() // Conditional. Reserved for future use.
+			continue
+		case 3:
+			m = jdwp.ThreadOnlyEventModifier(r.Uint64())
+		case 4:
+			m = jdwp.ClassOnlyEventModifier(r.Uint64())
+		case 5:
+			m = jdwp.ClassMatchEventModifier(readString(r))
+		case 6:
+			m = jdwp.ClassExcludeEventModifier(readString(r))
+		case 7:
+			m = jdwp.LocationOnlyEventModifier{
+				Type:     jdwp.TypeTag(r.Uint8()),
+				Class:    jdwp.ClassID(r.Uint64()),
+				Method:   jdwp.MethodID(r.Uint64()),
+				Location: r.Uint64(),
+			}
+		case 8:
+			m = jdwp.ExceptionOnlyEventModifier{
+				ExceptionOrNull: jdwp.ReferenceTypeID(r.Uint64()),
+				Caught:          r.Bool(),
+				Uncaught:        r.Bool(),
+			}
+		case 9:
+			m = jdwp.FieldOnlyEventModifier{
+				Type:  jdwp.ReferenceTypeID(r.Uint64()),
+				Field: jdwp.FieldID(r.Uint64()),
+			}
+		case 10:
+			m = jdwp.StepEventModifier{
+				Thread: jdwp.ThreadID(r.Uint64()),
+				Size:   int(r.Int32()),
+				Depth:  int(r.Int32()),
+			}
+		case 11:
+			m = jdwp.InstanceOnlyEventModifier(r.Uint64())
+		case 12:
+			readString(r) // SourceNameMatch. Fake classes have no source.
+			continue
+		default:
+			return jdwp.ErrIllegalArgument
+		}
+		req.modifiers = append(req.modifiers, m)
+	}
+	s.nextReq++
+	s.requests = append(s.requests, req)
+	w.Int32(int32(req.id))
+	return jdwp.ErrNone
+}
+
+func (s *server) eventRequestClear(r binary.Reader, w binary.Writer) jdwp.Error {
+	kind, id := jdwp.EventKind(r.Uint8()), int(r.Int32())
+	for i, req := range s.requests {
+		if req.id == id && req.kind == kind {
+			s.requests = append(s.requests[:i], s.requests[i+1:]...)
+			break
+		}
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) readFrame(r binary.Reader) (*thread, *frame, jdwp.Error) {
+	t, id := s.readThread(r), r.Uint64()
+	switch {
+	case t == nil:
+		return nil, nil, jdwp.ErrInvalidThread
+	case !s.isSuspended(t):
+		return nil, nil, jdwp.ErrThreadNotSuspended
+	}
+	for _, f := range t.frames {
+		if f.id == id {
+			return t, f, jdwp.ErrNone
+		}
+	}
+	return nil, nil, jdwp.ErrInvalidFrameID
+}
+
+func (s *server) frameGetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	_, f, err := s.readFrame(r)
+	if err != jdwp.ErrNone {
+		return err
+	}
+	n := int(r.Int32())
+	w.Int32(int32(n))
+	for i := 0; i < n; i++ {
+		slot, sig := int(r.Int32()), string(rune(r.Uint8()))
+		v, ok := f.Locals[slot]
+		if !ok {
+			return jdwp.ErrInvalidSlot
+		}
+		if sig == "L" || sig == "[" {
+			sig += ";" // Let writeValue use the object's type.
+		}
+		s.writeValue(w, v, sig)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) frameSetValues(r binary.Reader, w binary.Writer) jdwp.Error {
+	_, f, err := s.readFrame(r)
+	if err != jdwp.ErrNone {
+		return err
+	}
+	for i, n := 0, int(r.Int32()); i < n; i++ {
+		slot, v := int(r.Int32()), s.readValue(r)
+		if f.Locals == nil {
+			f.Locals = map[int]interface{}{}
+		}
+		f.Locals[slot] = v
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) frameThisObject(r binary.Reader, w binary.Writer) jdwp.Error {
+	_, f, err := s.readFrame(r)
+	if err != jdwp.ErrNone {
+		return err
+	}
+	if f.This == nil {
+		s.writeValue(w, nil, sigObject)
+	} else {
+		s.writeValue(w, f.This, f.This.Class)
+	}
+	return jdwp.ErrNone
+}
+
+func (s *server) classObjectReflectedType(r binary.Reader, w binary.Writer) jdwp.Error {
+	c := s.readRefType(r)
+	if c == nil {
+		return jdwp.ErrInvalidClass
+	}
+	w.Uint8(uint8(c.kind))
+	w.Uint64(c.id)
+	return jdwp.ErrNone
+}
+
+// invoke calls the method, returning the result and the thrown exception.
+func (s *server) invoke(t *thread, m *method, this *Object, args []interface{}) (interface{}, interface{}, jdwp.Error) {
+	if m.Invoke == nil {
+		return zero(returnType(m.Signature)), nil, jdwp.ErrNone
+	}
+	res, err := m.Invoke(&Call{This: this, Args: args, Thread: t.Thread})
+	if e, ok := err.(jdwp.Error); ok {
+		return nil, nil, e
+	}
+	if err != nil {
+		return zero(returnType(m.Signature)), &Object{
+			Class:  sigException,
+			Fields: map[string]interface{}{"message": err.Error()},
+		}, jdwp.ErrNone
+	}
+	return res, nil, jdwp.ErrNone
+}
+
+// writeInvoke calls the method, writing the result and the thrown exception.
+func (s *server) writeInvoke(w binary.Writer, t *thread, m *method, this *Object, args []interface{}) jdwp.Error {
+	res, exception, err := s.invoke(t, m, this, args)
+	if err != jdwp.ErrNone {
+		return err
+	}
+	s.writeValue(w, res, returnType(m.Signature))
+	s.writeValue(w, exception, sigException)
+	return jdwp.ErrNone
+}
+
+// fire raises the pending events that match an event request, in order,
+// until the VM is suspended.
+func (s *server) fire() {
+	for s.suspended == 0 {
+		fired := false
+		for i, e := range s.pending {
+			t := s.thread(e.Thread)
+			if s.isSuspended(t) {
+				continue
+			}
+			req := s.match(e, t)
+			if req == nil {
+				continue
+			}
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			if req.count > 0 {
+				if req.count--; req.count > 0 {
+					fired = true // Filtered by the count modifier.
+					break
+				}
+				s.clear(req)
+			}
+			s.raise(req, e, t)
+			fired = true
+			break
+		}
+		if !fired {
+			return
+		}
+	}
+}
+
+func (s *server) clear(req *request) {
+	for i, r := range s.requests {
+		if r == req {
+			s.requests = append(s.requests[:i], s.requests[i+1:]...)
+			return
+		}
+	}
+}
+
+// match returns the first event request that matches the event.
+func (s *server) match(e *Event, t *thread) *request {
+	c := s.classBySig[e.Class]
+	var m *method
+	if c != nil {
+		m = c.method(e.Method)
+	}
+next:
+	for _, req := range s.requests {
+		if req.kind != e.Kind {
+			continue
+		}
+		for _, mod := range req.modifiers {
+			ok := true
+			switch mod := mod.(type) {
+			case jdwp.ThreadOnlyEventModifier:
+				ok = uint64(mod) == t.id
+			case jdwp.ClassOnlyEventModifier:
+				o, _ := s.objects[uint64(mod)].(*Class)
+				ok = c != nil && o != nil && c.isA(s.classBySig[o.Signature])
+			case jdwp.ClassMatchEventModifier:
+				ok = c != nil && classMatches(string(mod), typeName(c.Signature))
+			case jdwp.ClassExcludeEventModifier:
+				ok = c == nil || !classMatches(string(mod), typeName(c.Signature))
+			case jdwp.LocationOnlyEventModifier:
+				ok = c != nil && m != nil && uint64(mod.Class) == c.id &&
+					uint64(mod.Method) == m.id && mod.Location == e.Location
+			}
+			if !ok {
+				continue next
+			}
+		}
+		return req
+	}
+	return nil
+}
+
+// classMatches returns true if the class name matches the pattern of a
+// ClassMatchEventModifier.
+func classMatches(pattern, name string) bool {
+	switch {
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(name, pattern[1:])
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	default:
+		return pattern == name
+	}
+}
+
+// raise sends the event for the request, suspending threads as required by
+// the request's suspend policy.
+func (s *server) raise(req *request, e *Event, t *thread) {
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.BigEndian)
+	w.Uint8(uint8(req.policy))
+	w.Int32(1)
+	w.Uint8(uint8(e.Kind))
+	w.Int32(int32(req.id))
+	w.Uint64(t.id)
+	c := s.classBySig[e.Class]
+	switch e.Kind {
+	case jdwp.ClassPrepare:
+		w.Uint8(uint8(c.kind))
+		w.Uint64(c.id)
+		writeString(w, c.Signature)
+		w.Int32(int32(classStatus))
+	case jdwp.MethodEntry, jdwp.MethodExit, jdwp.Breakpoint:
+		s.writeLocation(w, c, c.method(e.Method), e.Location)
+	}
+
+	switch req.policy {
+	case jdwp.SuspendAll:
+		s.suspended++
+	case jdwp.SuspendEventThread:
+		t.suspended++
+	}
+	s.send(cmdSetEvent, cmdCompositeEvt, buf.Bytes())
+}
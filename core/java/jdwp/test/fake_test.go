@@ -0,0 +1,234 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/java/jdbg"
+	"github.com/google/gapid/core/java/jdwp"
+	"github.com/google/gapid/core/java/jdwp/test"
+	"github.com/google/gapid/core/log"
+)
+
+// calculator returns the declaration of a class with overloaded static and
+// instance methods.
+func calculator() *test.Class {
+	return &test.Class{
+		Signature: "Lcom/example/Calculator;",
+		Fields: []*test.Field{
+			{Name: "value", Signature: "I", ModBits: jdwp.ModPrivate},
+			{Name: "name", Signature: "Ljava/lang/String;", ModBits: jdwp.ModStatic, Value: "calculator"},
+		},
+		Methods: []*test.Method{
+			{
+				Name:      "<init>",
+				Signature: "()V",
+				ModBits:   jdwp.ModPublic,
+			}, {
+				Name:      "Add",
+				Signature: "(II)I",
+				ModBits:   jdwp.ModPublic | jdwp.ModStatic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					return c.Args[0].(int) + c.Args[1].(int), nil
+				},
+			}, {
+				Name:      "Add",
+				Signature: "(JJ)J",
+				ModBits:   jdwp.ModPublic | jdwp.ModStatic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					return c.Args[0].(int64) + c.Args[1].(int64) + 1000, nil
+				},
+			}, {
+				Name:      "Add",
+				Signature: "(I)V",
+				ModBits:   jdwp.ModPublic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					c.This.Fields["value"] = c.This.Fields["value"].(int) + c.Args[0].(int)
+					return nil, nil
+				},
+			}, {
+				Name:      "Result",
+				Signature: "()I",
+				ModBits:   jdwp.ModPublic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					return c.This.Fields["value"], nil
+				},
+			}, {
+				Name:      "Describe",
+				Signature: "(Ljava/lang/String;)Ljava/lang/String;",
+				ModBits:   jdwp.ModPublic | jdwp.ModStatic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					return fmt.Sprintf("%v: %v", c.Args[0], c.Thread.Name), nil
+				},
+			}, {
+				Name:      "Divide",
+				Signature: "(II)I",
+				ModBits:   jdwp.ModPublic | jdwp.ModStatic,
+				Invoke: func(c *test.Call) (interface{}, error) {
+					if c.Args[1].(int) == 0 {
+						return nil, fmt.Errorf("divide by zero")
+					}
+					return c.Args[0].(int) / c.Args[1].(int), nil
+				},
+			},
+		},
+	}
+}
+
+func connect(ctx context.Context, t *testing.T, vm *test.VM) (*jdwp.Connection, task.CancelFunc) {
+	ctx, cancel := task.WithCancel(ctx)
+	conn, err := test.Connect(ctx, vm)
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		cancel()
+		t.FailNow()
+	}
+	return conn, cancel
+}
+
+func mainThread(ctx context.Context, t *testing.T, conn *jdwp.Connection) jdwp.ThreadID {
+	threads, err := conn.GetAllThreads()
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() || len(threads) == 0 {
+		t.FailNow()
+	}
+	return threads[0]
+}
+
+func TestFakeInvokeStaticMethod(t *testing.T) {
+	ctx := log.Testing(t)
+	conn, cancel := connect(ctx, t, &test.VM{Classes: []*test.Class{calculator()}})
+	defer cancel()
+
+	err := jdbg.Do(conn, mainThread(ctx, t, conn), func(j *jdbg.JDbg) error {
+		calc := j.Class("com.example.Calculator")
+		assert.For(ctx, "Add(int, int)").That(calc.Call("Add", 3, 7).Get()).Equals(10)
+		assert.For(ctx, "Add(long, long)").That(calc.Call("Add", int64(3), int64(7)).Get()).Equals(int64(1010))
+		assert.For(ctx, "Describe").That(calc.Call("Describe", "thread").Get()).Equals("thread: main")
+		assert.For(ctx, "name").That(calc.Field("name").Get()).Equals("calculator")
+		return nil
+	})
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+}
+
+func TestFakeInvokeMethod(t *testing.T) {
+	ctx := log.Testing(t)
+	conn, cancel := connect(ctx, t, &test.VM{Classes: []*test.Class{calculator()}})
+	defer cancel()
+
+	err := jdbg.Do(conn, mainThread(ctx, t, conn), func(j *jdbg.JDbg) error {
+		calc := j.Class("com.example.Calculator").New()
+		for _, i := range []int{3, 6, 8} {
+			calc.Call("Add", i)
+		}
+		assert.For(ctx, "res").That(calc.Call("Result").Get()).Equals(3 + 6 + 8)
+		return nil
+	})
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+}
+
+func TestFakeInvokeThrows(t *testing.T) {
+	ctx := log.Testing(t)
+	conn, cancel := connect(ctx, t, &test.VM{Classes: []*test.Class{calculator()}})
+	defer cancel()
+
+	err := jdbg.Do(conn, mainThread(ctx, t, conn), func(j *jdbg.JDbg) error {
+		j.Class("com.example.Calculator").Call("Divide", 1, 0)
+		return nil
+	})
+	assert.For(ctx, "err").ThatError(err).HasMessage(
+		"Exception raised calling: public static int com.example.Calculator.Divide(int, int)\n" +
+			"java.lang.RuntimeException: divide by zero")
+}
+
+func TestFakeEvents(t *testing.T) {
+	ctx := log.Testing(t)
+	vm := &test.VM{
+		Classes: []*test.Class{calculator()},
+		Threads: []*test.Thread{
+			{Name: "main", Status: jdwp.ThreadRunning},
+			{Name: "worker", Status: jdwp.ThreadWait},
+		},
+		Events: []*test.Event{
+			{Kind: jdwp.ClassPrepare, Thread: "worker", Class: "Lcom/example/Calculator;"},
+			{Kind: jdwp.MethodEntry, Class: "Lcom/example/Calculator;", Method: "<init>"},
+			{Kind: jdwp.MethodEntry, Class: "Lcom/example/Calculator;", Method: "Result", Location: 4},
+		},
+	}
+	conn, cancel := connect(ctx, t, vm)
+	defer cancel()
+
+	thread, err := conn.WaitForClassPrepare(ctx, "*.Calculator")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	name, err := conn.GetThreadName(thread)
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "thread").That(name).Equals("worker")
+
+	class, err := conn.GetClassBySignature("Lcom/example/Calculator;")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	method, err := conn.GetClassMethod(class.ClassID(), "Result", "()I")
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+
+	entry, err := conn.WaitForMethodEntry(ctx, class.ClassID(), method.ID)
+	if assert.For(ctx, "err").ThatError(err).Succeeded() {
+		assert.For(ctx, "location").That(entry.Location.Location).Equals(uint64(4))
+		name, err := conn.GetLocationMethodName(entry.Location)
+		assert.For(ctx, "err").ThatError(err).Succeeded()
+		assert.For(ctx, "method").That(name).Equals("Result")
+	}
+}
+
+func TestFakeStackFrames(t *testing.T) {
+	ctx := log.Testing(t)
+	this := &test.Object{
+		Class:  "Lcom/example/Calculator;",
+		Fields: map[string]interface{}{"value": 5},
+	}
+	vm := &test.VM{
+		Classes: []*test.Class{calculator()},
+		Threads: []*test.Thread{{
+			Name:   "main",
+			Status: jdwp.ThreadRunning,
+			Frames: []*test.Frame{{
+				Class:    "Lcom/example/Calculator;",
+				Method:   "Result",
+				Location: 2,
+				This:     this,
+				Locals:   map[int]interface{}{1: "forty-two"},
+			}},
+		}},
+	}
+	vm.Classes[0].Methods[4].Variables = []jdwp.FrameVariable{
+		{CodeIndex: 0, Name: "this", Signature: "Lcom/example/Calculator;", Length: 8, Slot: 0},
+		{CodeIndex: 1, Name: "answer", Signature: "Ljava/lang/String;", Length: 7, Slot: 1},
+	}
+	conn, cancel := connect(ctx, t, vm)
+	defer cancel()
+
+	thread := mainThread(ctx, t, conn)
+	_, err := conn.GetFrames(thread, 0, -1)
+	assert.For(ctx, "err").ThatError(err).Failed()
+
+	assert.For(ctx, "err").ThatError(conn.SuspendAll()).Succeeded()
+	err = jdbg.Do(conn, thread, func(j *jdbg.JDbg) error {
+		assert.For(ctx, "result").That(j.This().Call("Result").Get()).Equals(5)
+		assert.For(ctx, "answer").That(j.GetStackObject("answer").Get()).Equals("forty-two")
+		return nil
+	})
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langsvr
+
+import "github.com/google/gapid/core/langsvr/protocol"
+
+// CallHierarchyItem represents a callable programming construct, such as a
+// function or a command, in a call hierarchy.
+type CallHierarchyItem struct {
+	// The name of the item.
+	Name string
+
+	// The kind of the item.
+	Kind SymbolKind
+
+	// Additional detail for the item, such as its signature.
+	Detail string
+
+	// The location of the entire item declaration.
+	Location Location
+
+	// The range of the item's name within Location.
+	SelectionRange Range
+}
+
+func callHierarchyItem(p protocol.CallHierarchyItem) CallHierarchyItem {
+	out := CallHierarchyItem{
+		Name:           p.Name,
+		Kind:           SymbolKind(p.Kind),
+		Location:       Location{URI: p.URI, Range: rng(p.Range)},
+		SelectionRange: rng(p.SelectionRange),
+	}
+	if p.Detail != nil {
+		out.Detail = *p.Detail
+	}
+	return out
+}
+
+func (i CallHierarchyItem) toProtocol() protocol.CallHierarchyItem {
+	out := protocol.CallHierarchyItem{
+		Name:           i.Name,
+		Kind:           protocol.SymbolKind(i.Kind),
+		URI:            i.Location.URI,
+		Range:          i.Location.Range.toProtocol(),
+		SelectionRange: i.SelectionRange.toProtocol(),
+	}
+	if i.Detail != "" {
+		out.Detail = &i.Detail
+	}
+	return out
+}
+
+// IncomingCall represents a caller of a call hierarchy item.
+type IncomingCall struct {
+	// The calling item.
+	From CallHierarchyItem
+
+	// The ranges of the calls within the caller.
+	Ranges []Range
+}
+
+func (c IncomingCall) toProtocol() protocol.CallHierarchyIncomingCall {
+	out := protocol.CallHierarchyIncomingCall{
+		From:       c.From.toProtocol(),
+		FromRanges: make([]protocol.Range, len(c.Ranges)),
+	}
+	for i, r := range c.Ranges {
+		out.FromRanges[i] = r.toProtocol()
+	}
+	return out
+}
+
+// OutgoingCall represents a callee of a call hierarchy item.
+type OutgoingCall struct {
+	// The called item.
+	To CallHierarchyItem
+
+	// The ranges of the calls within the calling item.
+	Ranges []Range
+}
+
+func (c OutgoingCall) toProtocol() protocol.CallHierarchyOutgoingCall {
+	out := protocol.CallHierarchyOutgoingCall{
+		To:         c.To.toProtocol(),
+		FromRanges: make([]protocol.Range, len(c.Ranges)),
+	}
+	for i, r := range c.Ranges {
+		out.FromRanges[i] = r.toProtocol()
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langsvr
+
+import "github.com/google/gapid/core/langsvr/protocol"
+
+// FoldingRange is a span of lines in a document that can be collapsed by the
+// client.
+type FoldingRange struct {
+	// The range of the fold. Only the lines are used by most clients.
+	Range Range
+
+	// The kind of the fold. Can be left empty.
+	Kind FoldingRangeKind
+}
+
+// FoldingRangeList is a list of folding ranges.
+type FoldingRangeList []FoldingRange
+
+// Add appends a new folding range to the list.
+func (l *FoldingRangeList) Add(rng Range, kind FoldingRangeKind) {
+	*l = append(*l, FoldingRange{rng, kind})
+}
+
+func (f FoldingRange) toProtocol() protocol.FoldingRange {
+	r := f.Range.toProtocol()
+	out := protocol.FoldingRange{
+		StartLine:      r.Start.Line,
+		StartCharacter: &r.Start.Column,
+		EndLine:        r.End.Line,
+		EndCharacter:   &r.End.Column,
+	}
+	if f.Kind != "" {
+		kind := protocol.FoldingRangeKind(f.Kind)
+		out.Kind = &kind
+	}
+	return out
+}
+
+func (l FoldingRangeList) toProtocol() []protocol.FoldingRange {
+	out := make([]protocol.FoldingRange, 0, len(l))
+	for _, f := range l {
+		if f.Range.End.Line > f.Range.Start.Line {
+			out = append(out, f.toProtocol())
+		}
+	}
+	return out
+}
+
+// FoldingRangeKind is an enumerator of folding range kinds.
+type FoldingRangeKind string
+
+const (
+	// CommentFolding is the folding range for a comment.
+	CommentFolding = FoldingRangeKind(protocol.CommentFolding)
+
+	// ImportsFolding is the folding range for a set of imports.
+	ImportsFolding = FoldingRangeKind(protocol.ImportsFolding)
+
+	// RegionFolding is the folding range for a region.
+	RegionFolding = FoldingRangeKind(protocol.RegionFolding)
+)
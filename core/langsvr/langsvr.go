@@ -179,6 +179,36 @@ type RenameProvider interface {
 	Rename(ctx context.Context, doc *Document, pos Position, newName string) (WorkspaceEdit, error)
 }
 
+// SemanticTokensProvider is the interface implemented by servers that support
+// semantic highlighting.
+type SemanticTokensProvider interface {
+	// SemanticTokens returns the list of semantic tokens for the specified
+	// document.
+	SemanticTokens(context.Context, *Document) (SemanticTokenList, error)
+}
+
+// FoldingRangeProvider is the interface implemented by servers that support
+// code folding.
+type FoldingRangeProvider interface {
+	// FoldingRanges returns the list of foldable ranges for the specified
+	// document.
+	FoldingRanges(context.Context, *Document) (FoldingRangeList, error)
+}
+
+// CallHierarchyProvider is the interface implemented by servers that support
+// call hierarchy information.
+type CallHierarchyProvider interface {
+	// PrepareCallHierarchy returns the call hierarchy items for the callable
+	// symbol in the specified document at position.
+	PrepareCallHierarchy(context.Context, *Document, Position) ([]CallHierarchyItem, error)
+
+	// IncomingCalls returns the list of callers of the given item.
+	IncomingCalls(context.Context, CallHierarchyItem) ([]IncomingCall, error)
+
+	// OutgoingCalls returns the list of callees of the given item.
+	OutgoingCalls(context.Context, CallHierarchyItem) ([]OutgoingCall, error)
+}
+
 // Connect creates a connection to between server and the client (code editor)
 // communicating on stream.
 func Connect(ctx context.Context, stream io.ReadWriter, server Server) error {
@@ -274,6 +304,8 @@ func (s *langsvr) Initialize(ctx context.Context, processID int, rootPath string
 	_, caps.DocumentFormattingProvider = s.server.(FormatProvider)
 	_, caps.DocumentRangeFormattingProvider = s.server.(FormatRangeProvider)
 	_, caps.RenameProvider = s.server.(RenameProvider)
+	_, caps.FoldingRangeProvider = s.server.(FoldingRangeProvider)
+	_, caps.CallHierarchyProvider = s.server.(CallHierarchyProvider)
 	if _, ok := s.server.(CompletionProvider); ok {
 		caps.CompletionProvider = protocol.CompletionOptions{
 			ResolveProvider:   true,
@@ -290,6 +322,12 @@ func (s *langsvr) Initialize(ctx context.Context, processID int, rootPath string
 			ResolveProvider: true,
 		}
 	}
+	if _, ok := s.server.(SemanticTokensProvider); ok {
+		caps.SemanticTokensProvider = &protocol.SemanticTokensOptions{
+			Legend: semanticTokensLegend(),
+			Full:   true,
+		}
+	}
 	if _, ok := s.server.(FormatOnTypeProvider); ok {
 		caps.DocumentOnTypeFormattingProvider = &protocol.DocumentOnTypeFormattingOptions{
 			FirstTriggerCharacter: "",
@@ -586,6 +624,95 @@ func (s langsvr) Rename(ctx context.Context, item protocol.TextDocumentIdentifie
 	return edits.toProtocol(), nil
 }
 
+func (s langsvr) SemanticTokens(ctx context.Context, docID protocol.TextDocumentIdentifier) (protocol.SemanticTokens, error) {
+	ctx = log.Enter(ctx, "SemanticTokens")
+	sp, ok := s.server.(SemanticTokensProvider)
+	if !ok {
+		return protocol.SemanticTokens{Data: []int{}}, nil
+	}
+	doc, err := s.getDoc(docID.URI)
+	if err != nil {
+		return protocol.SemanticTokens{}, err
+	}
+	tokens, err := sp.SemanticTokens(ctx, doc)
+	if err != nil {
+		return protocol.SemanticTokens{}, err
+	}
+	return tokens.toProtocol(), nil
+}
+
+func (s langsvr) FoldingRanges(ctx context.Context, docID protocol.TextDocumentIdentifier) ([]protocol.FoldingRange, error) {
+	ctx = log.Enter(ctx, "FoldingRanges")
+	fp, ok := s.server.(FoldingRangeProvider)
+	if !ok {
+		return []protocol.FoldingRange{}, nil
+	}
+	doc, err := s.getDoc(docID.URI)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := fp.FoldingRanges(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return ranges.toProtocol(), nil
+}
+
+func (s langsvr) PrepareCallHierarchy(ctx context.Context, docID protocol.TextDocumentIdentifier, position protocol.Position) ([]protocol.CallHierarchyItem, error) {
+	ctx = log.Enter(ctx, "PrepareCallHierarchy")
+	cp, ok := s.server.(CallHierarchyProvider)
+	if !ok {
+		return []protocol.CallHierarchyItem{}, nil
+	}
+	doc, err := s.getDoc(docID.URI)
+	if err != nil {
+		return nil, err
+	}
+	items, err := cp.PrepareCallHierarchy(ctx, doc, pos(position))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyItem, len(items))
+	for i, item := range items {
+		out[i] = item.toProtocol()
+	}
+	return out, nil
+}
+
+func (s langsvr) IncomingCalls(ctx context.Context, item protocol.CallHierarchyItem) ([]protocol.CallHierarchyIncomingCall, error) {
+	ctx = log.Enter(ctx, "IncomingCalls")
+	cp, ok := s.server.(CallHierarchyProvider)
+	if !ok {
+		return []protocol.CallHierarchyIncomingCall{}, nil
+	}
+	calls, err := cp.IncomingCalls(ctx, callHierarchyItem(item))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyIncomingCall, len(calls))
+	for i, c := range calls {
+		out[i] = c.toProtocol()
+	}
+	return out, nil
+}
+
+func (s langsvr) OutgoingCalls(ctx context.Context, item protocol.CallHierarchyItem) ([]protocol.CallHierarchyOutgoingCall, error) {
+	ctx = log.Enter(ctx, "OutgoingCalls")
+	cp, ok := s.server.(CallHierarchyProvider)
+	if !ok {
+		return []protocol.CallHierarchyOutgoingCall{}, nil
+	}
+	calls, err := cp.OutgoingCalls(ctx, callHierarchyItem(item))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]protocol.CallHierarchyOutgoingCall, len(calls))
+	for i, c := range calls {
+		out[i] = c.toProtocol()
+	}
+	return out, nil
+}
+
 func (s langsvr) OnExit(ctx context.Context) error {
 	ctx = log.Enter(ctx, "OnExit")
 	s.terminate()
@@ -36,25 +36,30 @@ const (
 
 var methods = map[string]reflect.Type{
 	// requests
-	"initialize":                     reflect.TypeOf(InitializeRequest{}),
-	"shutdown":                       reflect.TypeOf(ShutdownRequest{}),
-	"window/showMessageRequest":      reflect.TypeOf(ShowMessageRequest{}),
-	"textDocument/completion":        reflect.TypeOf(CompletionRequest{}),
-	"completionItem/resolve":         reflect.TypeOf(CompletionItemResolveRequest{}),
-	"textDocument/hover":             reflect.TypeOf(HoverRequest{}),
-	"textDocument/signatureHelp":     reflect.TypeOf(SignatureHelpRequest{}),
-	"textDocument/definition":        reflect.TypeOf(GotoDefinitionRequest{}),
-	"textDocument/references":        reflect.TypeOf(FindReferencesRequest{}),
-	"textDocument/documentHighlight": reflect.TypeOf(DocumentHighlightRequest{}),
-	"textDocument/documentSymbol":    reflect.TypeOf(DocumentSymbolRequest{}),
-	"workspace/symbol":               reflect.TypeOf(WorkspaceSymbolRequest{}),
-	"textDocument/codeAction":        reflect.TypeOf(CodeActionRequest{}),
-	"textDocument/codeLens":          reflect.TypeOf(CodeLensRequest{}),
-	"codeLens/resolve":               reflect.TypeOf(CodeLensResolveRequest{}),
-	"textDocument/formatting":        reflect.TypeOf(DocumentFormattingRequest{}),
-	"textDocument/rangeFormatting":   reflect.TypeOf(DocumentRangeFormattingRequest{}),
-	"textDocument/onTypeFormatting":  reflect.TypeOf(DocumentOnTypeFormattingRequest{}),
-	"textDocument/rename":            reflect.TypeOf(RenameRequest{}),
+	"initialize":                        reflect.TypeOf(InitializeRequest{}),
+	"shutdown":                          reflect.TypeOf(ShutdownRequest{}),
+	"window/showMessageRequest":         reflect.TypeOf(ShowMessageRequest{}),
+	"textDocument/completion":           reflect.TypeOf(CompletionRequest{}),
+	"completionItem/resolve":            reflect.TypeOf(CompletionItemResolveRequest{}),
+	"textDocument/hover":                reflect.TypeOf(HoverRequest{}),
+	"textDocument/signatureHelp":        reflect.TypeOf(SignatureHelpRequest{}),
+	"textDocument/definition":           reflect.TypeOf(GotoDefinitionRequest{}),
+	"textDocument/references":           reflect.TypeOf(FindReferencesRequest{}),
+	"textDocument/documentHighlight":    reflect.TypeOf(DocumentHighlightRequest{}),
+	"textDocument/documentSymbol":       reflect.TypeOf(DocumentSymbolRequest{}),
+	"workspace/symbol":                  reflect.TypeOf(WorkspaceSymbolRequest{}),
+	"textDocument/codeAction":           reflect.TypeOf(CodeActionRequest{}),
+	"textDocument/codeLens":             reflect.TypeOf(CodeLensRequest{}),
+	"codeLens/resolve":                  reflect.TypeOf(CodeLensResolveRequest{}),
+	"textDocument/formatting":           reflect.TypeOf(DocumentFormattingRequest{}),
+	"textDocument/rangeFormatting":      reflect.TypeOf(DocumentRangeFormattingRequest{}),
+	"textDocument/onTypeFormatting":     reflect.TypeOf(DocumentOnTypeFormattingRequest{}),
+	"textDocument/rename":               reflect.TypeOf(RenameRequest{}),
+	"textDocument/semanticTokens/full":  reflect.TypeOf(SemanticTokensRequest{}),
+	"textDocument/foldingRange":         reflect.TypeOf(FoldingRangeRequest{}),
+	"textDocument/prepareCallHierarchy": reflect.TypeOf(CallHierarchyPrepareRequest{}),
+	"callHierarchy/incomingCalls":       reflect.TypeOf(CallHierarchyIncomingCallsRequest{}),
+	"callHierarchy/outgoingCalls":       reflect.TypeOf(CallHierarchyOutgoingCallsRequest{}),
 
 	// notifications
 	"exit":                             reflect.TypeOf(ExitNotification{}),
@@ -210,6 +215,30 @@ type Server interface {
 	// newName is the new name of the symbol.
 	Rename(ctx context.Context, doc TextDocumentIdentifier, pos Position, newName string) (WorkspaceEdit, error)
 
+	// SemanticTokens is a request to compute the semantic tokens for the
+	// entire document.
+	// doc is the document to compute semantic tokens for.
+	SemanticTokens(ctx context.Context, doc TextDocumentIdentifier) (SemanticTokens, error)
+
+	// FoldingRanges is a request to list all the folding ranges for the given
+	// document.
+	// doc is the document to compute folding ranges for.
+	FoldingRanges(ctx context.Context, doc TextDocumentIdentifier) ([]FoldingRange, error)
+
+	// PrepareCallHierarchy is a request to resolve the call hierarchy item(s)
+	// for the symbol at the given position.
+	// doc is the document identifier.
+	// pos is the position in the document of the symbol.
+	PrepareCallHierarchy(ctx context.Context, doc TextDocumentIdentifier, pos Position) ([]CallHierarchyItem, error)
+
+	// IncomingCalls is a request to resolve all the callers of the given
+	// call hierarchy item.
+	IncomingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyIncomingCall, error)
+
+	// OutgoingCalls is a request to resolve all the callees of the given
+	// call hierarchy item.
+	OutgoingCalls(ctx context.Context, item CallHierarchyItem) ([]CallHierarchyOutgoingCall, error)
+
 	// OnExit is a request for the server to exit its process.
 	OnExit(ctx context.Context) error
 
@@ -665,6 +694,61 @@ func (c *Connection) dispatch(ctx context.Context, msg interface{}, server Serve
 		}
 		return c.send(res)
 
+	case *SemanticTokensRequest:
+		tokens, err := server.SemanticTokens(ctx, msg.Params.TextDocument)
+		res := SemanticTokensResponse{}
+		if err != nil {
+			initResponseErr(&res, msg.ID, err)
+		} else {
+			initResponseRes(&res, msg.ID)
+			res.Result = &tokens
+		}
+		return c.send(res)
+
+	case *FoldingRangeRequest:
+		ranges, err := server.FoldingRanges(ctx, msg.Params.TextDocument)
+		res := FoldingRangeResponse{}
+		if err != nil {
+			initResponseErr(&res, msg.ID, err)
+		} else {
+			initResponseRes(&res, msg.ID)
+			res.Result = ranges
+		}
+		return c.send(res)
+
+	case *CallHierarchyPrepareRequest:
+		items, err := server.PrepareCallHierarchy(ctx, msg.Params.Document, msg.Params.Position)
+		res := CallHierarchyPrepareResponse{}
+		if err != nil {
+			initResponseErr(&res, msg.ID, err)
+		} else {
+			initResponseRes(&res, msg.ID)
+			res.Result = items
+		}
+		return c.send(res)
+
+	case *CallHierarchyIncomingCallsRequest:
+		calls, err := server.IncomingCalls(ctx, msg.Params.Item)
+		res := CallHierarchyIncomingCallsResponse{}
+		if err != nil {
+			initResponseErr(&res, msg.ID, err)
+		} else {
+			initResponseRes(&res, msg.ID)
+			res.Result = calls
+		}
+		return c.send(res)
+
+	case *CallHierarchyOutgoingCallsRequest:
+		calls, err := server.OutgoingCalls(ctx, msg.Params.Item)
+		res := CallHierarchyOutgoingCallsResponse{}
+		if err != nil {
+			initResponseErr(&res, msg.ID, err)
+		} else {
+			initResponseRes(&res, msg.ID)
+			res.Result = calls
+		}
+		return c.send(res)
+
 	case *ExitNotification:
 		server.OnExit(ctx)
 		return nil
@@ -521,3 +521,110 @@ type RenameResponse struct {
 	// Code and message set in case an exception happens during the request.
 	Error *ResponseErrorHeader `json:"error,omitempty"`
 }
+
+// SemanticTokensRequest is a request sent from the client to the server to
+// compute the semantic tokens for a whole document.
+type SemanticTokensRequest struct {
+	RequestMessageHeader
+
+	Params struct {
+		// The text document.
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	} `json:"params"`
+}
+
+// SemanticTokensResponse is the response to a semantic tokens request.
+type SemanticTokensResponse struct {
+	ResponseMessageHeader
+
+	Result *SemanticTokens `json:"result"`
+
+	// Code and message set in case an exception happens during the request.
+	Error *ResponseErrorHeader `json:"error,omitempty"`
+}
+
+// FoldingRangeRequest is a request sent from the client to the server to
+// return all folding ranges found in a given text document.
+type FoldingRangeRequest struct {
+	RequestMessageHeader
+
+	Params struct {
+		// The text document.
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	} `json:"params"`
+}
+
+// FoldingRangeResponse is the response to a folding range request.
+type FoldingRangeResponse struct {
+	ResponseMessageHeader
+
+	Result []FoldingRange `json:"result"`
+
+	// Code and message set in case an exception happens during the request.
+	Error *ResponseErrorHeader `json:"error,omitempty"`
+}
+
+// CallHierarchyPrepareRequest is a request sent from the client to the server
+// to return the call hierarchy item for the symbol at the given text document
+// position. The returned item is then used in the incoming and outgoing calls
+// requests.
+type CallHierarchyPrepareRequest struct {
+	RequestMessageHeader
+
+	Params TextDocumentPositionParams `json:"params"`
+}
+
+// CallHierarchyPrepareResponse is the response to a call hierarchy prepare
+// request.
+type CallHierarchyPrepareResponse struct {
+	ResponseMessageHeader
+
+	Result []CallHierarchyItem `json:"result"`
+
+	// Code and message set in case an exception happens during the request.
+	Error *ResponseErrorHeader `json:"error,omitempty"`
+}
+
+// CallHierarchyIncomingCallsRequest is a request sent from the client to the
+// server to resolve the incoming calls for a given call hierarchy item.
+type CallHierarchyIncomingCallsRequest struct {
+	RequestMessageHeader
+
+	Params struct {
+		// The item returned by a call hierarchy prepare request.
+		Item CallHierarchyItem `json:"item"`
+	} `json:"params"`
+}
+
+// CallHierarchyIncomingCallsResponse is the response to a call hierarchy
+// incoming calls request.
+type CallHierarchyIncomingCallsResponse struct {
+	ResponseMessageHeader
+
+	Result []CallHierarchyIncomingCall `json:"result"`
+
+	// Code and message set in case an exception happens during the request.
+	Error *ResponseErrorHeader `json:"error,omitempty"`
+}
+
+// CallHierarchyOutgoingCallsRequest is a request sent from the client to the
+// server to resolve the outgoing calls for a given call hierarchy item.
+type CallHierarchyOutgoingCallsRequest struct {
+	RequestMessageHeader
+
+	Params struct {
+		// The item returned by a call hierarchy prepare request.
+		Item CallHierarchyItem `json:"item"`
+	} `json:"params"`
+}
+
+// CallHierarchyOutgoingCallsResponse is the response to a call hierarchy
+// outgoing calls request.
+type CallHierarchyOutgoingCallsResponse struct {
+	ResponseMessageHeader
+
+	Result []CallHierarchyOutgoingCall `json:"result"`
+
+	// Code and message set in case an exception happens during the request.
+	Error *ResponseErrorHeader `json:"error,omitempty"`
+}
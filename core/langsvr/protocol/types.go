@@ -263,6 +263,15 @@ type ServerCapabilities struct {
 
 	// The server provides rename support.
 	RenameProvider bool `json:"renameProvider"`
+
+	// The server provides semantic tokens support.
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	// The server provides folding range support.
+	FoldingRangeProvider bool `json:"foldingRangeProvider,omitempty"`
+
+	// The server provides call hierarchy support.
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
 }
 
 // MessageType is an enumerator of message types that can be shown to the user.
@@ -529,3 +538,117 @@ type FormattingOptions struct {
 	// Signature for further properties.
 	// [key: string]: boolean | number | string;
 }
+
+// SemanticTokensLegend describes the token types and modifiers used to encode
+// SemanticTokens. Token types and modifiers are referenced by index into these
+// lists.
+type SemanticTokensLegend struct {
+	// The token types a server uses.
+	TokenTypes []string `json:"tokenTypes"`
+
+	// The token modifiers a server uses.
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions describes the semantic token support of the server.
+type SemanticTokensOptions struct {
+	// The legend used by the server.
+	Legend SemanticTokensLegend `json:"legend"`
+
+	// Server supports providing semantic tokens for a full document.
+	Full bool `json:"full"`
+}
+
+// SemanticTokens holds the encoded semantic tokens of a document.
+type SemanticTokens struct {
+	// An optional result id used for delta requests.
+	ResultID *string `json:"resultId,omitempty"`
+
+	// The encoded token data. Each token is represented by 5 integers:
+	// deltaLine, deltaStartChar, length, tokenType, tokenModifiers.
+	// deltaLine is relative to the previous token's line and deltaStartChar
+	// is relative to the previous token's start if they share a line.
+	Data []int `json:"data"`
+}
+
+// FoldingRange represents a folding range in a document.
+type FoldingRange struct {
+	// The zero-based line number from where the folded range starts.
+	StartLine int `json:"startLine"`
+
+	// The zero-based character offset from where the folded range starts.
+	// If not defined, defaults to the length of the start line.
+	StartCharacter *int `json:"startCharacter,omitempty"`
+
+	// The zero-based line number where the folded range ends.
+	EndLine int `json:"endLine"`
+
+	// The zero-based character offset before the folded range ends.
+	// If not defined, defaults to the length of the end line.
+	EndCharacter *int `json:"endCharacter,omitempty"`
+
+	// Describes the kind of the folding range.
+	Kind *FoldingRangeKind `json:"kind,omitempty"`
+}
+
+// FoldingRangeKind is an enumerator of folding range kinds.
+type FoldingRangeKind string
+
+const (
+	// CommentFolding is the folding range for a comment.
+	CommentFolding = FoldingRangeKind("comment")
+
+	// ImportsFolding is the folding range for a set of imports.
+	ImportsFolding = FoldingRangeKind("imports")
+
+	// RegionFolding is the folding range for a region.
+	RegionFolding = FoldingRangeKind("region")
+)
+
+// CallHierarchyItem represents a programming construct like a function or
+// command in the context of a call hierarchy.
+type CallHierarchyItem struct {
+	// The name of this item.
+	Name string `json:"name"`
+
+	// The kind of this item.
+	Kind SymbolKind `json:"kind"`
+
+	// More detail for this item, e.g. the signature of a function.
+	Detail *string `json:"detail,omitempty"`
+
+	// The resource identifier of this item.
+	URI string `json:"uri"`
+
+	// The range enclosing this symbol not including leading/trailing
+	// whitespace but everything else, e.g. comments and code.
+	Range Range `json:"range"`
+
+	// The range that should be selected and revealed when this symbol is being
+	// picked, e.g. the name of a function. Must be contained by Range.
+	SelectionRange Range `json:"selectionRange"`
+
+	// A data entry field that is preserved between a call hierarchy prepare
+	// and incoming calls or outgoing calls requests.
+	Data interface{} `json:"data,omitempty"`
+}
+
+// CallHierarchyIncomingCall represents a caller of a call hierarchy item.
+type CallHierarchyIncomingCall struct {
+	// The item that makes the call.
+	From CallHierarchyItem `json:"from"`
+
+	// The ranges at which the calls appear. This is relative to the caller
+	// denoted by From.
+	FromRanges []Range `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCall represents a callee of a call hierarchy item.
+type CallHierarchyOutgoingCall struct {
+	// The item that is called.
+	To CallHierarchyItem `json:"to"`
+
+	// The ranges at which this item is called. This is relative to the caller
+	// of the outgoing calls request.
+	FromRanges []Range `json:"fromRanges"`
+}
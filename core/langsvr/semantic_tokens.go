@@ -0,0 +1,238 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langsvr
+
+import (
+	"sort"
+
+	"github.com/google/gapid/core/langsvr/protocol"
+)
+
+// SemanticToken is a classification of a span of text in a document, used by
+// the client to colorize source based on meaning rather than grammar.
+type SemanticToken struct {
+	// The range of the token. Must span a single line.
+	Range Range
+
+	// The type of the token.
+	Type SemanticTokenType
+
+	// The modifiers of the token.
+	Modifiers SemanticTokenModifiers
+}
+
+// SemanticTokenList is a list of semantic tokens.
+type SemanticTokenList []SemanticToken
+
+// Add appends a new semantic token to the list.
+func (l *SemanticTokenList) Add(rng Range, ty SemanticTokenType, mods SemanticTokenModifiers) {
+	*l = append(*l, SemanticToken{rng, ty, mods})
+}
+
+// toProtocol returns the list encoded as relative token data.
+// Tokens that span multiple lines are dropped.
+func (l SemanticTokenList) toProtocol() protocol.SemanticTokens {
+	sorted := make(SemanticTokenList, 0, len(l))
+	for _, t := range l {
+		if t.Range.Start.Line == t.Range.End.Line && t.Range.End.Column > t.Range.Start.Column {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Range.Start, sorted[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	data := make([]int, 0, len(sorted)*5)
+	prev := Position{Line: 1, Column: 1}
+	for _, t := range sorted {
+		start := t.Range.Start
+		deltaLine, deltaColumn := start.Line-prev.Line, start.Column-1
+		if deltaLine == 0 {
+			deltaColumn = start.Column - prev.Column
+		}
+		data = append(data,
+			deltaLine,
+			deltaColumn,
+			t.Range.End.Column-start.Column,
+			int(t.Type),
+			int(t.Modifiers))
+		prev = start
+	}
+	return protocol.SemanticTokens{Data: data}
+}
+
+// SemanticTokenType is an enumerator of semantic token types.
+type SemanticTokenType int
+
+const (
+	// NamespaceToken is the token type for a namespace, module or package.
+	NamespaceToken = SemanticTokenType(iota)
+
+	// TypeToken is the token type for a type that is not covered by a more specific token type.
+	TypeToken
+
+	// ClassToken is the token type for a class type.
+	ClassToken
+
+	// EnumToken is the token type for an enumeration type.
+	EnumToken
+
+	// InterfaceToken is the token type for an interface type.
+	InterfaceToken
+
+	// StructToken is the token type for a structure type.
+	StructToken
+
+	// TypeParameterToken is the token type for a type parameter.
+	TypeParameterToken
+
+	// ParameterToken is the token type for a function or command parameter.
+	ParameterToken
+
+	// VariableToken is the token type for a local or global variable.
+	VariableToken
+
+	// PropertyToken is the token type for a member field or property.
+	PropertyToken
+
+	// EnumMemberToken is the token type for an enumeration value.
+	EnumMemberToken
+
+	// EventToken is the token type for an event.
+	EventToken
+
+	// FunctionToken is the token type for a function.
+	FunctionToken
+
+	// MethodToken is the token type for a member function or method.
+	MethodToken
+
+	// MacroToken is the token type for a macro.
+	MacroToken
+
+	// KeywordToken is the token type for a language keyword.
+	KeywordToken
+
+	// ModifierToken is the token type for a modifier keyword.
+	ModifierToken
+
+	// CommentToken is the token type for a comment.
+	CommentToken
+
+	// StringToken is the token type for a string literal.
+	StringToken
+
+	// NumberToken is the token type for a number literal.
+	NumberToken
+
+	// RegexpToken is the token type for a regular expression literal.
+	RegexpToken
+
+	// OperatorToken is the token type for an operator.
+	OperatorToken
+)
+
+var semanticTokenTypeNames = []string{
+	"namespace",
+	"type",
+	"class",
+	"enum",
+	"interface",
+	"struct",
+	"typeParameter",
+	"parameter",
+	"variable",
+	"property",
+	"enumMember",
+	"event",
+	"function",
+	"method",
+	"macro",
+	"keyword",
+	"modifier",
+	"comment",
+	"string",
+	"number",
+	"regexp",
+	"operator",
+}
+
+// String returns the protocol name of the token type.
+func (t SemanticTokenType) String() string {
+	if int(t) < len(semanticTokenTypeNames) {
+		return semanticTokenTypeNames[t]
+	}
+	return "unknown"
+}
+
+// SemanticTokenModifiers is a bitfield of semantic token modifiers.
+type SemanticTokenModifiers int
+
+const (
+	// DeclarationModifier is the token modifier for the declaration of a symbol.
+	DeclarationModifier = SemanticTokenModifiers(1 << iota)
+
+	// DefinitionModifier is the token modifier for the definition of a symbol.
+	DefinitionModifier
+
+	// ReadonlyModifier is the token modifier for a read-only variable or member.
+	ReadonlyModifier
+
+	// StaticModifier is the token modifier for a static member.
+	StaticModifier
+
+	// DeprecatedModifier is the token modifier for a symbol that should no longer be used.
+	DeprecatedModifier
+
+	// AbstractModifier is the token modifier for an abstract type or member.
+	AbstractModifier
+
+	// AsyncModifier is the token modifier for a function marked async.
+	AsyncModifier
+
+	// ModificationModifier is the token modifier for a variable reference where the variable is assigned to.
+	ModificationModifier
+
+	// DocumentationModifier is the token modifier for a token inside documentation.
+	DocumentationModifier
+
+	// DefaultLibraryModifier is the token modifier for a symbol that is part of the standard library.
+	DefaultLibraryModifier
+)
+
+var semanticTokenModifierNames = []string{
+	"declaration",
+	"definition",
+	"readonly",
+	"static",
+	"deprecated",
+	"abstract",
+	"async",
+	"modification",
+	"documentation",
+	"defaultLibrary",
+}
+
+// semanticTokensLegend returns the legend describing the token types and
+// modifiers used by SemanticTokenList encoding.
+func semanticTokensLegend() protocol.SemanticTokensLegend {
+	return protocol.SemanticTokensLegend{
+		TokenTypes:     append([]string{}, semanticTokenTypeNames...),
+		TokenModifiers: append([]string{}, semanticTokenModifierNames...),
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langsvr
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestSemanticTokensEncoding(t *testing.T) {
+	assert := assert.To(t)
+	at := func(line, start, end int) Range {
+		return Range{Position{line, start}, Position{line, end}}
+	}
+	l := SemanticTokenList{}
+	l.Add(at(3, 5, 9), FunctionToken, DeclarationModifier)
+	l.Add(at(1, 1, 4), KeywordToken, 0)
+	l.Add(at(3, 12, 13), ParameterToken, ReadonlyModifier|DefinitionModifier)
+	l.Add(Range{Position{4, 1}, Position{5, 3}}, CommentToken, 0) // multi-line, dropped
+	l.Add(at(6, 2, 4), VariableToken, 0)
+
+	assert.For("data").ThatSlice(l.toProtocol().Data).Equals([]int{
+		0, 0, 3, int(KeywordToken), 0,
+		2, 4, 4, int(FunctionToken), int(DeclarationModifier),
+		0, 7, 1, int(ParameterToken), int(ReadonlyModifier | DefinitionModifier),
+		3, 1, 2, int(VariableToken), 0,
+	})
+
+	legend := semanticTokensLegend()
+	assert.For("types").That(len(legend.TokenTypes)).Equals(int(OperatorToken) + 1)
+	assert.For("function").That(legend.TokenTypes[FunctionToken]).Equals("function")
+	assert.For("modifiers").That(len(legend.TokenModifiers)).Equals(10)
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// ChromeTracer is a SpanHandler that writes each span as a complete event in
+// the Chrome trace event JSON format. The output can be loaded into
+// chrome://tracing or Perfetto, with one track per goroutine.
+type ChromeTracer struct {
+	mutex     sync.Mutex
+	to        io.Writer
+	pid       int
+	processes map[string]bool
+	count     int
+	closed    bool
+	err       error
+}
+
+// chromeEvent is a single event in the Chrome trace event format.
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`
+	TS    float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	PID   int                    `json:"pid"`
+	TID   uint64                 `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// NewChromeTracer returns a new ChromeTracer that writes to to.
+// Close must be called once all spans have ended to terminate the JSON array.
+func NewChromeTracer(to io.Writer) *ChromeTracer {
+	return &ChromeTracer{
+		to:        to,
+		pid:       os.Getpid(),
+		processes: map[string]bool{},
+	}
+}
+
+// HandleSpan writes the span s as a complete event. Spans that end after
+// Close has been called are dropped.
+func (t *ChromeTracer) HandleSpan(s *Span) {
+	args := make(map[string]interface{}, len(s.Values))
+	for _, v := range s.Values {
+		args[v.Name] = jsonValue(v.Value)
+	}
+	ev := chromeEvent{
+		Name:  s.Name,
+		Cat:   s.Process,
+		Phase: "X",
+		TS:    float64(s.Start.UnixNano()) / 1e3,
+		Dur:   float64(s.Duration().Nanoseconds()) / 1e3,
+		PID:   t.pid,
+		TID:   s.Goroutine,
+		Args:  args,
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	if s.Process != "" && !t.processes[s.Process] {
+		t.processes[s.Process] = true
+		t.write(chromeEvent{
+			Name:  "process_name",
+			Phase: "M",
+			PID:   t.pid,
+			Args:  map[string]interface{}{"name": s.Process},
+		})
+	}
+	t.write(ev)
+}
+
+// Close terminates the JSON array of events, returning the first error
+// encountered writing the trace.
+func (t *ChromeTracer) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return t.err
+	}
+	t.closed = true
+	if t.count == 0 {
+		t.print("[")
+	}
+	t.print("\n]\n")
+	return t.err
+}
+
+func (t *ChromeTracer) write(ev chromeEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	if t.count == 0 {
+		t.print("[\n")
+	} else {
+		t.print(",\n")
+	}
+	t.count++
+	if t.err == nil {
+		_, err := t.to.Write(data)
+		t.fail(err)
+	}
+}
+
+func (t *ChromeTracer) print(s string) {
+	if t.err == nil {
+		_, err := io.WriteString(t.to, s)
+		t.fail(err)
+	}
+}
+
+func (t *ChromeTracer) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonMessage is the JSON encoding of a single Message.
+type jsonMessage struct {
+	Time        string                 `json:"time"`
+	Severity    string                 `json:"severity"`
+	Text        string                 `json:"text"`
+	Tag         string                 `json:"tag,omitempty"`
+	Process     string                 `json:"process,omitempty"`
+	StopProcess bool                   `json:"stop_process,omitempty"`
+	Trace       []string               `json:"trace,omitempty"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+	Causes      []jsonCause            `json:"causes,omitempty"`
+}
+
+// jsonCause is the JSON encoding of the chain of causes of an error value.
+type jsonCause struct {
+	Value    string   `json:"value"`
+	Messages []string `json:"messages"`
+}
+
+// causer is the interface implemented by errors that wrap another error.
+type causer interface {
+	Cause() error
+}
+
+// JSON returns a Handler that writes each message to to as a single line
+// holding a JSON object. Values that are errors are written as their error
+// strings, and the chain of each error's causes is written to the causes list.
+// Closing the handler closes to if it implements io.Closer.
+func JSON(to io.Writer) Handler {
+	mutex := sync.Mutex{}
+	handle := func(m *Message) {
+		data, err := json.Marshal(jsonFrom(m))
+		if err != nil {
+			data, _ = json.Marshal(jsonMessage{
+				Time:     m.Time.Format(time.RFC3339Nano),
+				Severity: Error.String(),
+				Text:     fmt.Sprintf("Failed to encode log message %q: %v", m.Text, err),
+			})
+		}
+		data = append(data, '\n')
+		mutex.Lock()
+		defer mutex.Unlock()
+		to.Write(data)
+	}
+	close := func() {
+		if c, ok := to.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return NewHandler(handle, close)
+}
+
+func jsonFrom(m *Message) *jsonMessage {
+	out := &jsonMessage{
+		Time:        m.Time.Format(time.RFC3339Nano),
+		Severity:    m.Severity.String(),
+		Text:        m.Text,
+		Tag:         m.Tag,
+		Process:     m.Process,
+		StopProcess: m.StopProcess,
+		Trace:       m.Trace,
+	}
+	if len(m.Values) > 0 {
+		out.Values = make(map[string]interface{}, len(m.Values))
+	}
+	for _, v := range m.Values {
+		if _, shadowed := out.Values[v.Name]; shadowed {
+			continue
+		}
+		switch value := v.Value.(type) {
+		case error:
+			out.Values[v.Name] = value.Error()
+			if cause := causes(value); len(cause) > 0 {
+				out.Causes = append(out.Causes, jsonCause{v.Name, cause})
+			}
+		default:
+			out.Values[v.Name] = jsonValue(value)
+		}
+	}
+	return out
+}
+
+// causes returns the messages of the errors that err wraps, outermost first.
+func causes(err error) []string {
+	var out []string
+	for {
+		c, ok := err.(causer)
+		if !ok {
+			return out
+		}
+		if err = c.Cause(); err == nil {
+			return out
+		}
+		out = append(out, err.Error())
+	}
+}
+
+// jsonValue returns v in a form that can be encoded as a JSON value.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return v
+	case float32, float64:
+		if s := fmt.Sprint(v); s == "NaN" || s == "+Inf" || s == "-Inf" {
+			return s
+		}
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+type jsonRecord struct {
+	Time        string                 `json:"time"`
+	Severity    string                 `json:"severity"`
+	Text        string                 `json:"text"`
+	Tag         string                 `json:"tag"`
+	Process     string                 `json:"process"`
+	StopProcess bool                   `json:"stop_process"`
+	Trace       []string               `json:"trace"`
+	Values      map[string]interface{} `json:"values"`
+	Causes      []struct {
+		Value    string   `json:"value"`
+		Messages []string `json:"messages"`
+	} `json:"causes"`
+}
+
+func TestJSON(t *testing.T) {
+	assertCtx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	h := log.JSON(buf)
+
+	ctx := context.Background()
+	ctx = log.PutHandler(ctx, h)
+	ctx = log.PutClock(ctx, testClock)
+	ctx = log.PutTag(ctx, "tag")
+	ctx = log.PutProcess(ctx, "gapis")
+	ctx = log.Enter(ctx, "outer")
+	ctx = log.Enter(ctx, "inner")
+
+	cause := log.Err(ctx, errors.New("disk on fire"), "Failed to read")
+	log.W(ctx, "plain warning")
+	log.Bind(ctx, log.V{"count": 3, "name": "meow", "err": cause}).E("with values")
+	h.Close()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.For(assertCtx, "lines").That(len(lines)).Equals(2)
+
+	records := make([]jsonRecord, len(lines))
+	for i, l := range lines {
+		assert.For(assertCtx, "unmarshal").ThatError(json.Unmarshal([]byte(l), &records[i])).Succeeded()
+	}
+
+	r := records[0]
+	assert.For(assertCtx, "time").That(r.Time).Equals("2000-01-22T12:34:56.789Z")
+	assert.For(assertCtx, "severity").That(r.Severity).Equals("Warning")
+	assert.For(assertCtx, "text").That(r.Text).Equals("plain warning")
+	assert.For(assertCtx, "tag").That(r.Tag).Equals("tag")
+	assert.For(assertCtx, "process").That(r.Process).Equals("gapis")
+	assert.For(assertCtx, "trace").ThatSlice(r.Trace).Equals([]string{"inner", "outer"})
+	assert.For(assertCtx, "values").That(len(r.Values)).Equals(0)
+	assert.For(assertCtx, "causes").That(len(r.Causes)).Equals(0)
+
+	r = records[1]
+	assert.For(assertCtx, "severity").That(r.Severity).Equals("Error")
+	assert.For(assertCtx, "count").That(r.Values["count"]).Equals(3.0)
+	assert.For(assertCtx, "name").That(r.Values["name"]).Equals("meow")
+	assert.For(assertCtx, "err").That(r.Values["err"]).Equals(cause.Error())
+	assert.For(assertCtx, "causes").That(len(r.Causes)).Equals(1)
+	assert.For(assertCtx, "cause value").That(r.Causes[0].Value).Equals("err")
+	assert.For(assertCtx, "cause messages").ThatSlice(r.Causes[0].Messages).Equals([]string{"disk on fire"})
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RotateOptions controls when a RotatingFile starts a new file.
+type RotateOptions struct {
+	// MaxSize is the size in bytes after which the file is rotated.
+	// A value of 0 disables size-based rotation.
+	MaxSize int64
+
+	// MaxAge is the length of time after which the file is rotated.
+	// A value of 0 disables age-based rotation.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files to keep, named with the
+	// suffixes .1 (newest) to .MaxBackups (oldest). Older files are deleted.
+	// A value of 0 keeps no rotated files.
+	MaxBackups int
+}
+
+// RotatingFile is an io.WriteCloser that writes to a file, renaming it and
+// starting a new file once it grows past a maximum size or age.
+// Writes are never split across files.
+type RotatingFile struct {
+	mutex   sync.Mutex
+	path    string
+	opts    RotateOptions
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// NewRotatingFile creates or truncates the file at path and returns a
+// RotatingFile that writes to it.
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(os.O_TRUNC); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes data to the current file, rotating first if the file has
+// reached its maximum size or age.
+func (f *RotatingFile) Write(data []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(data))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) shouldRotate(size int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+size > f.opts.MaxSize {
+		return true
+	}
+	if f.opts.MaxAge > 0 && time.Since(f.created) >= f.opts.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.opts.MaxBackups <= 0 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(f.backup(f.opts.MaxBackups))
+		for i := f.opts.MaxBackups - 1; i > 0; i-- {
+			os.Rename(f.backup(i), f.backup(i+1))
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			// Keep writing to the old file rather than losing messages, and
+			// only retry once it has grown by another MaxSize or MaxAge has
+			// passed again.
+			if err := f.open(os.O_APPEND); err != nil {
+				return err
+			}
+			f.size = 0
+			return nil
+		}
+	}
+	return f.open(os.O_TRUNC)
+}
+
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+func (f *RotatingFile) open(flag int) error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|flag, 0666)
+	if err != nil {
+		return err
+	}
+	size := int64(0)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	f.file, f.size, f.created = file, size, time.Now()
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func readFiles(paths ...string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			out[i] = "<missing>"
+			continue
+		}
+		out[i] = string(data)
+	}
+	return out
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	ctx := log.Testing(t)
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "gapis.log")
+	f, err := log.NewRotatingFile(path, log.RotateOptions{MaxSize: 8, MaxBackups: 2})
+	assert.For(ctx, "NewRotatingFile").ThatError(err).Succeeded()
+	for _, s := range []string{"aaaa\n", "bb\n", "cccc\n", "dddddddddd\n", "e\n"} {
+		_, err := f.Write([]byte(s))
+		assert.For(ctx, "Write").ThatError(err).Succeeded()
+	}
+	assert.For(ctx, "Close").ThatError(f.Close()).Succeeded()
+
+	got := readFiles(path, path+".1", path+".2", path+".3")
+	assert.For(ctx, "files").ThatSlice(got).Equals([]string{
+		"e\n",
+		"dddddddddd\n",
+		"cccc\n",
+		"<missing>",
+	})
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	ctx := log.Testing(t)
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gapis.log")
+	f, err := log.NewRotatingFile(path, log.RotateOptions{MaxAge: 10 * time.Millisecond})
+	assert.For(ctx, "NewRotatingFile").ThatError(err).Succeeded()
+	f.Write([]byte("old\n"))
+	time.Sleep(20 * time.Millisecond)
+	f.Write([]byte("new\n"))
+	assert.For(ctx, "Close").ThatError(f.Close()).Succeeded()
+
+	got := readFiles(path, path+".1")
+	assert.For(ctx, "files").ThatSlice(got).Equals([]string{"new\n", "<missing>"})
+
+	_, err = f.Write([]byte("closed\n"))
+	assert.For(ctx, "Write after Close").ThatError(err).Failed()
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	ctx := log.Testing(t)
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(dir)
+
+	// A non-empty directory in the way of the backup cannot be replaced.
+	path := filepath.Join(dir, "gapis.log")
+	err = os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755)
+	assert.For(ctx, "MkdirAll").ThatError(err).Succeeded()
+
+	f, err := log.NewRotatingFile(path, log.RotateOptions{MaxSize: 8, MaxBackups: 1})
+	assert.For(ctx, "NewRotatingFile").ThatError(err).Succeeded()
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		n, err := f.Write([]byte(s))
+		assert.For(ctx, "Write").ThatError(err).Succeeded()
+		assert.For(ctx, "Write n").That(n).Equals(len(s))
+	}
+	assert.For(ctx, "Close").ThatError(f.Close()).Succeeded()
+
+	got := readFiles(path)
+	assert.For(ctx, "files").ThatSlice(got).Equals([]string{"aaaa\nbbbb\ncccc\n"})
+}
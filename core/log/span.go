@@ -0,0 +1,171 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gapid/core/context/keys"
+)
+
+// Span is a named, timed region of execution started with StartSpan.
+type Span struct {
+	// The name of the span.
+	Name string
+
+	// The unique identifier of the span.
+	ID uint64
+
+	// The identifier of the enclosing span, or 0 if this is a root span.
+	Parent uint64
+
+	// The time the span was started.
+	Start time.Time
+
+	// The time the span was ended.
+	End time.Time
+
+	// The identifier of the goroutine that started the span.
+	Goroutine uint64
+
+	// The name of the process that started the span.
+	Process string
+
+	// The stack of enter() calls at the time the span was started, including
+	// the span itself.
+	Trace Trace
+
+	// The key-value pairs bound to the context when the span was started.
+	Values Values
+}
+
+// Duration returns the length of time between the start and end of the span.
+func (s *Span) Duration() time.Duration { return s.End.Sub(s.Start) }
+
+// SpanHandler is the interface implemented by types that collect completed
+// spans.
+type SpanHandler interface {
+	// HandleSpan is called when the span s has ended.
+	// HandleSpan may be called concurrently from different goroutines.
+	HandleSpan(s *Span)
+}
+
+// SpanHandlerFunc is a function that implements the SpanHandler interface.
+type SpanHandlerFunc func(*Span)
+
+// HandleSpan calls f(s).
+func (f SpanHandlerFunc) HandleSpan(s *Span) { f(s) }
+
+type spanHandlerKeyTy string
+type spanKeyTy string
+
+const (
+	spanHandlerKey spanHandlerKeyTy = "log.spanHandlerKey"
+	spanKey        spanKeyTy        = "log.spanKey"
+)
+
+// PutSpanHandler returns a new context with the SpanHandler assigned to w.
+// Spans are only recorded when the context holds a SpanHandler.
+func PutSpanHandler(ctx context.Context, w SpanHandler) context.Context {
+	return keys.WithValue(ctx, spanHandlerKey, w)
+}
+
+// GetSpanHandler returns the SpanHandler assigned to ctx.
+func GetSpanHandler(ctx context.Context) SpanHandler {
+	out, _ := ctx.Value(spanHandlerKey).(SpanHandler)
+	return out
+}
+
+var nextSpanID uint64
+
+// StartSpan returns a new context with the trace-stack pushed by name, as
+// Enter does, and starts a span of the same name.
+// The returned function ends the span, passing it to the context's
+// SpanHandler. It must be called once, typically with defer:
+//
+//	ctx, end := log.StartSpan(ctx, "Resolve")
+//	defer end()
+//
+// If the context has no SpanHandler then StartSpan is equivalent to Enter and
+// the returned function does nothing.
+func StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	ctx = Enter(ctx, name)
+	h := GetSpanHandler(ctx)
+	if h == nil {
+		return ctx, func() {}
+	}
+	s := &Span{
+		Name:      name,
+		ID:        atomic.AddUint64(&nextSpanID, 1),
+		Goroutine: goroutineID(),
+		Process:   GetProcess(ctx),
+		Trace:     GetTrace(ctx),
+		Values:    spanValues(ctx),
+	}
+	if parent, ok := ctx.Value(spanKey).(*Span); ok {
+		s.Parent = parent.ID
+	}
+	clock := GetClock(ctx)
+	s.Start = now(clock)
+	return keys.WithValue(ctx, spanKey, s), func() {
+		s.End = now(clock)
+		h.HandleSpan(s)
+	}
+}
+
+func now(c Clock) time.Time {
+	if c != nil {
+		return c.Time()
+	}
+	return time.Now()
+}
+
+// spanValues returns the values bound to ctx, with values bound closer to ctx
+// shadowing those of the same name further up the chain.
+func spanValues(ctx context.Context) Values {
+	var out Values
+	seen := map[string]bool{}
+	for n := getValues(ctx); n != nil; n = n.parent {
+		for name, value := range n.v {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, &Value{Name: name, Value: value})
+			}
+		}
+	}
+	sort.Sort(out)
+	return out
+}
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineID returns the identifier of the calling goroutine, as printed at
+// the top of its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, goroutinePrefix)
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+type spanRecorder struct {
+	mutex sync.Mutex
+	spans []*log.Span
+}
+
+func (r *spanRecorder) HandleSpan(s *log.Span) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+type stepClock struct {
+	mutex sync.Mutex
+	t     time.Time
+}
+
+func (c *stepClock) Time() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = c.t.Add(time.Millisecond)
+	return c.t
+}
+
+func TestSpans(t *testing.T) {
+	ctx := log.Testing(t)
+	r := &spanRecorder{}
+	ctx = log.PutSpanHandler(ctx, r)
+	ctx = log.PutClock(ctx, &stepClock{t: time.Unix(1000, 0)})
+	ctx = log.V{"capture": "foo", "cmd": 1}.Bind(ctx)
+
+	outer, endOuter := log.StartSpan(ctx, "outer")
+	inner, endInner := log.StartSpan(log.V{"cmd": 2}.Bind(outer), "inner")
+	assert.For(ctx, "trace").ThatSlice(log.GetTrace(inner)).Equals([]string{"inner", "outer"})
+	endInner()
+	endOuter()
+
+	assert.For(ctx, "spans").That(len(r.spans)).Equals(2)
+	in, out := r.spans[0], r.spans[1]
+	assert.For(ctx, "inner name").That(in.Name).Equals("inner")
+	assert.For(ctx, "outer name").That(out.Name).Equals("outer")
+	assert.For(ctx, "outer parent").That(out.Parent).Equals(uint64(0))
+	assert.For(ctx, "inner parent").That(in.Parent).Equals(out.ID)
+	assert.For(ctx, "outer duration").That(out.Duration()).Equals(3 * time.Millisecond)
+	assert.For(ctx, "inner duration").That(in.Duration()).Equals(time.Millisecond)
+	assert.For(ctx, "goroutine").That(in.Goroutine).Equals(out.Goroutine)
+	assert.For(ctx, "goroutine").That(in.Goroutine).NotEquals(uint64(0))
+	assert.For(ctx, "values").ThatSlice(in.Values).DeepEquals(log.Values{
+		{Name: "capture", Value: "foo"},
+		{Name: "cmd", Value: 2},
+	})
+}
+
+func TestSpansWithoutHandler(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx, end := log.StartSpan(ctx, "span")
+	end()
+	assert.For(ctx, "trace").ThatSlice(log.GetTrace(ctx)).Equals([]string{"span"})
+}
+
+func TestChromeTracer(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	tracer := log.NewChromeTracer(buf)
+	ctx = log.PutSpanHandler(ctx, tracer)
+	ctx = log.PutProcess(ctx, "gapis")
+	ctx = log.PutClock(ctx, &stepClock{t: time.Unix(1000, 0)})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, end := log.StartSpan(log.V{"index": i}.Bind(ctx), "work")
+			_, endChild := log.StartSpan(ctx, "child")
+			endChild()
+			end()
+		}(i)
+	}
+	wg.Wait()
+	assert.For(ctx, "close").ThatError(tracer.Close()).Succeeded()
+
+	events := []struct {
+		Name  string                 `json:"name"`
+		Phase string                 `json:"ph"`
+		TS    float64                `json:"ts"`
+		Dur   float64                `json:"dur"`
+		TID   uint64                 `json:"tid"`
+		Args  map[string]interface{} `json:"args"`
+	}{}
+	err := json.Unmarshal(buf.Bytes(), &events)
+	assert.For(ctx, "unmarshal").ThatError(err).Succeeded()
+	assert.For(ctx, "events").That(len(events)).Equals(7)
+
+	assert.For(ctx, "metadata").That(events[0].Phase).Equals("M")
+	assert.For(ctx, "process name").That(events[0].Args["name"]).Equals("gapis")
+	indices := map[float64]bool{}
+	for _, ev := range events[1:] {
+		assert.For(ctx, "phase").That(ev.Phase).Equals("X")
+		assert.For(ctx, "duration").That(ev.Dur > 0).Equals(true)
+		assert.For(ctx, "tid").That(ev.TID).NotEquals(uint64(0))
+		if ev.Name == "work" {
+			indices[ev.Args["index"].(float64)] = true
+		}
+	}
+	assert.For(ctx, "indices").That(len(indices)).Equals(3)
+}
+
+func TestChromeTracerEmpty(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	assert.For(ctx, "close").ThatError(log.NewChromeTracer(buf).Close()).Succeeded()
+	events := []interface{}{}
+	assert.For(ctx, "unmarshal").ThatError(json.Unmarshal(buf.Bytes(), &events)).Succeeded()
+	assert.For(ctx, "events").That(len(events)).Equals(0)
+}
+
+func TestChromeTracerLateSpan(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	tracer := log.NewChromeTracer(buf)
+	ctx = log.PutSpanHandler(ctx, tracer)
+
+	_, end := log.StartSpan(ctx, "late")
+	assert.For(ctx, "close").ThatError(tracer.Close()).Succeeded()
+	end()
+	assert.For(ctx, "close again").ThatError(tracer.Close()).Succeeded()
+
+	events := []interface{}{}
+	assert.For(ctx, "unmarshal").ThatError(json.Unmarshal(buf.Bytes(), &events)).Succeeded()
+	assert.For(ctx, "events").That(len(events)).Equals(0)
+}
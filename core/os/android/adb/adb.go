@@ -15,9 +15,11 @@
 package adb
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/gapid/core/os/file"
 	"github.com/google/gapid/core/os/shell"
@@ -86,9 +88,32 @@ func (b *binding) prepareADBCommand(cmd shell.Cmd, useShell bool) (shell.Process
 	return shell.LocalTarget.Start(cmd)
 }
 
+// startServerCommand emulates the adb command using the adb server client.
+// Only the commands that map directly onto a device service are supported,
+// the rest of the binding calls the client directly.
+func (b *binding) startServerCommand(ctx context.Context, cmd shell.Cmd) (shell.Process, error) {
+	switch cmd.Name {
+	case "shell":
+		return b.client.startShell(ctx, b.To.Serial, strings.Join(cmd.Args, " "), cmd)
+	case "logcat":
+		return b.client.startShell(ctx, b.To.Serial, shellCommandLine(cmd), cmd)
+	case "root":
+		return b.client.startService(ctx, b.To.Serial, "root:", cmd, false)
+	default:
+		return nil, fmt.Errorf("adb %v is not supported by the adb server client", cmd.Name)
+	}
+}
+
 type deviceTarget struct{ b *binding }
 
 func (t deviceTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	return t.StartContext(context.Background(), cmd)
+}
+
+func (t deviceTarget) StartContext(ctx context.Context, cmd shell.Cmd) (shell.Process, error) {
+	if t.b.client != nil {
+		return t.b.startServerCommand(ctx, cmd)
+	}
 	return t.b.prepareADBCommand(cmd, false)
 }
 
@@ -99,6 +124,13 @@ func (t deviceTarget) String() string {
 type shellTarget struct{ b *binding }
 
 func (t shellTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	return t.StartContext(context.Background(), cmd)
+}
+
+func (t shellTarget) StartContext(ctx context.Context, cmd shell.Cmd) (shell.Process, error) {
+	if t.b.client != nil {
+		return t.b.client.startShell(ctx, t.b.To.Serial, shellCommandLine(cmd), cmd)
+	}
 	return t.b.prepareADBCommand(cmd, true)
 }
 
@@ -187,6 +187,16 @@ untagSocket(48) failed with errno -22
 
 [ 03-29 15:16:32.219 31608:31608 F/Finsky   ]
 [1] PackageVerificationReceiver.onReceive: Verification requested, id = 331
+`),
+
+		// System properties query.
+		stub.RespondTo(adbPath.System()+` -s production_device shell getprop`, `
+[dalvik.vm.heapsize]: [512m]
+[ro.build.fingerprint]: [google/hammerhead/hammerhead:6.0.1/MMB29Q/2480792:user/release-keys]
+[ro.product.model]: [Nexus 5]
+[persist.sys.multiline]: [first
+second]
+[ro.empty]: []
 `),
 
 		// Common responses to all devices
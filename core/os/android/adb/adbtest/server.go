@@ -0,0 +1,460 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adbtest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is the adb protocol version reported by the fake server.
+const Version = 39
+
+// ShellHandler runs the shell command line on a fake device, returning the
+// exit status.
+type ShellHandler func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// Respond returns a ShellHandler that prints the response mapped to each
+// command line. Unknown commands fail with the status 127.
+func Respond(responses map[string]string) ShellHandler {
+	return func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+		res, ok := responses[cmd]
+		if !ok {
+			name, _ := split(cmd, " ")
+			fmt.Fprintf(stderr, "/system/bin/sh: %v: not found\n", name)
+			return 127
+		}
+		io.WriteString(stdout, res)
+		return 0
+	}
+}
+
+// Device is a fake device attached to a Server.
+type Device struct {
+	// Serial is the serial of the device.
+	Serial string
+	// State is the state reported by host:devices. Defaults to "device".
+	State string
+	// Features is the list of features supported by the device.
+	Features []string
+	// Shell handles the shell commands run on the device.
+	Shell ShellHandler
+	// Root is the output of the root: service.
+	Root string
+	// Files is the content of the files on the device, keyed by path.
+	Files map[string][]byte
+
+	mutex sync.Mutex
+}
+
+// File returns the content of the file at path on the device.
+func (d *Device) File(path string) ([]byte, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	data, ok := d.Files[path]
+	return data, ok
+}
+
+// mode returns the sync mode of the path, or 0 if it does not exist.
+func (d *Device) mode(path string) uint32 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.Files[path]; ok {
+		return 0100644
+	}
+	dir := strings.TrimSuffix(path, "/") + "/"
+	for f := range d.Files {
+		if strings.HasPrefix(f, dir) {
+			return 0040755
+		}
+	}
+	return 0
+}
+
+// Server is a fake adb server that serves its Devices over the adb
+// host-server protocol.
+type Server struct {
+	// Devices is the list of devices attached to the server.
+	Devices []*Device
+
+	listener net.Listener
+	mutex    sync.Mutex
+	forwards map[string]string // local -> "serial remote"
+	requests []string
+}
+
+// NewServer starts a new fake adb server listening on a free local port.
+func NewServer(devices ...*Device) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Devices: devices, listener: l, forwards: map[string]string{}}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Requests returns all the service requests the server has received.
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Forwards returns the active port forwards, formatted as
+// "serial local remote".
+func (s *Server) Forwards() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out := []string{}
+	for local, to := range s.forwards {
+		serial, remote := split(to, " ")
+		out = append(out, fmt.Sprintf("%v %v %v", serial, local, remote))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *Server) device(serial string) *Device {
+	for _, d := range s.Devices {
+		if d.Serial == serial {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			s.handle(&conn{Conn: c, r: bufio.NewReader(c)})
+		}()
+	}
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *conn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+func (c *conn) okay() { io.WriteString(c, "OKAY") }
+
+func (c *conn) fail(msg string) {
+	fmt.Fprintf(c, "FAIL%04x%s", len(msg), msg)
+}
+
+func (c *conn) reply(msg string) {
+	fmt.Fprintf(c, "OKAY%04x%s", len(msg), msg)
+}
+
+func (c *conn) readRequest() (string, error) {
+	hex := make([]byte, 4)
+	if _, err := io.ReadFull(c, hex); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(hex), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func split(s, sep string) (string, string) {
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// handle serves the requests of a single connection.
+func (s *Server) handle(c *conn) {
+	var device *Device
+	for {
+		req, err := c.readRequest()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.requests = append(s.requests, req)
+		s.mutex.Unlock()
+
+		if device != nil {
+			s.handleDevice(c, device, req)
+			return
+		}
+
+		switch {
+		case req == "host:version":
+			c.reply(fmt.Sprintf("%04x", Version))
+			return
+		case req == "host:devices":
+			list := ""
+			for _, d := range s.Devices {
+				state := d.State
+				if state == "" {
+					state = "device"
+				}
+				list += fmt.Sprintf("%v\t%v\n", d.Serial, state)
+			}
+			c.reply(list)
+			return
+		case strings.HasPrefix(req, "host:transport:"):
+			serial := strings.TrimPrefix(req, "host:transport:")
+			if device = s.device(serial); device == nil {
+				c.fail(fmt.Sprintf("device '%v' not found", serial))
+				return
+			}
+			c.okay()
+		case strings.HasPrefix(req, "host-serial:"):
+			serial, service := split(strings.TrimPrefix(req, "host-serial:"), ":")
+			d := s.device(serial)
+			if d == nil {
+				c.fail(fmt.Sprintf("device '%v' not found", serial))
+				return
+			}
+			s.handleHostSerial(c, d, service)
+			return
+		default:
+			c.fail("unknown host service")
+			return
+		}
+	}
+}
+
+// handleHostSerial serves a host request for a specific device.
+func (s *Server) handleHostSerial(c *conn, d *Device, service string) {
+	switch {
+	case service == "features":
+		c.reply(strings.Join(d.Features, ","))
+	case strings.HasPrefix(service, "forward:"):
+		local, remote := split(strings.TrimPrefix(service, "forward:"), ";")
+		s.mutex.Lock()
+		s.forwards[local] = d.Serial + " " + remote
+		s.mutex.Unlock()
+		c.okay()
+		c.okay()
+	case strings.HasPrefix(service, "killforward:"):
+		local := strings.TrimPrefix(service, "killforward:")
+		s.mutex.Lock()
+		_, found := s.forwards[local]
+		delete(s.forwards, local)
+		s.mutex.Unlock()
+		c.okay()
+		if !found {
+			c.fail(fmt.Sprintf("listener '%v' not found", local))
+			return
+		}
+		c.okay()
+	default:
+		c.fail("unknown host service")
+	}
+}
+
+// handleDevice serves a device service request.
+func (s *Server) handleDevice(c *conn, d *Device, req string) {
+	service, arg := split(req, ":")
+	switch service {
+	case "shell":
+		c.okay()
+		if d.Shell != nil {
+			d.Shell(arg, c, c, c)
+		}
+	case "shell,v2,raw":
+		c.okay()
+		shellV2(c, d, arg)
+	case "root":
+		c.okay()
+		io.WriteString(c, d.Root)
+	case "sync":
+		c.okay()
+		syncService(c, d)
+	default:
+		c.fail("unknown device service")
+	}
+}
+
+// packetWriter writes shell protocol v2 packets with the given id.
+type packetWriter struct {
+	c     *conn
+	id    byte
+	mutex *sync.Mutex
+}
+
+func (w packetWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	header := [5]byte{w.id}
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := w.c.Write(header[:]); err != nil {
+		return 0, err
+	}
+	return w.c.Write(data)
+}
+
+func shellV2(c *conn, d *Device, cmd string) {
+	stdin, stdinW := io.Pipe()
+	go func() {
+		defer stdinW.Close()
+		header := [5]byte{}
+		for {
+			if _, err := io.ReadFull(c, header[:]); err != nil {
+				return
+			}
+			data := io.LimitReader(c, int64(binary.LittleEndian.Uint32(header[1:])))
+			switch header[0] {
+			case 0: // stdin
+				io.Copy(stdinW, data)
+			case 4: // close stdin
+				return
+			default:
+				io.Copy(ioutil.Discard, data)
+			}
+		}
+	}()
+	mutex := &sync.Mutex{}
+	status := 0
+	if d.Shell != nil {
+		status = d.Shell(cmd, stdin, packetWriter{c, 1, mutex}, packetWriter{c, 2, mutex})
+	}
+	packetWriter{c, 3, mutex}.Write([]byte{byte(status)})
+}
+
+func syncService(c *conn, d *Device) {
+	for {
+		id, n, err := readSync(c)
+		if err != nil {
+			return
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(c, data); err != nil {
+			return
+		}
+		switch id {
+		case "STAT":
+			content, _ := d.File(string(data))
+			writeSync(c, "STAT", d.mode(string(data)))
+			binary.Write(c, binary.LittleEndian, [2]uint32{uint32(len(content)), 0})
+		case "SEND":
+			path, _ := split(string(data), ",")
+			content, err := recvFile(c)
+			if err != nil {
+				return
+			}
+			d.mutex.Lock()
+			if d.Files == nil {
+				d.Files = map[string][]byte{}
+			}
+			d.Files[path] = content
+			d.mutex.Unlock()
+			writeSync(c, "OKAY", 0)
+		case "RECV":
+			content, ok := d.File(string(data))
+			if !ok {
+				msg := "No such file or directory"
+				writeSync(c, "FAIL", uint32(len(msg)))
+				io.WriteString(c, msg)
+				continue
+			}
+			for len(content) > 0 {
+				chunk := content
+				if len(chunk) > 64*1024 {
+					chunk = chunk[:64*1024]
+				}
+				writeSync(c, "DATA", uint32(len(chunk)))
+				c.Write(chunk)
+				content = content[len(chunk):]
+			}
+			writeSync(c, "DONE", 0)
+		case "QUIT":
+			return
+		default:
+			return
+		}
+	}
+}
+
+// recvFile reads the DATA requests of a SEND until DONE.
+func recvFile(c *conn) ([]byte, error) {
+	content := []byte{}
+	for {
+		id, n, err := readSync(c)
+		if err != nil {
+			return nil, err
+		}
+		switch id {
+		case "DATA":
+			data := make([]byte, n)
+			if _, err := io.ReadFull(c, data); err != nil {
+				return nil, err
+			}
+			content = append(content, data...)
+		case "DONE":
+			return content, nil
+		default:
+			return nil, fmt.Errorf("Unexpected sync request %q", id)
+		}
+	}
+}
+
+func readSync(c *conn) (string, uint32, error) {
+	var h struct {
+		ID     [4]byte
+		Length uint32
+	}
+	if err := binary.Read(c, binary.LittleEndian, &h); err != nil {
+		return "", 0, err
+	}
+	return string(h.ID[:]), h.Length, nil
+}
+
+func writeSync(c *conn, id string, value uint32) {
+	var h struct {
+		ID     [4]byte
+		Length uint32
+	}
+	copy(h.ID[:], id)
+	h.Length = value
+	binary.Write(c, binary.LittleEndian, &h)
+}
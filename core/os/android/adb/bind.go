@@ -53,6 +53,9 @@ func (l DeviceList) FindBySerial(serial string) Device {
 // binding represents an attached Android device.
 type binding struct {
 	bind.Simple
+	// client is the adb server client used to talk to the device, or nil if
+	// the adb binary is used.
+	client *Client
 }
 
 // verify that binding implements Device
@@ -0,0 +1,262 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/os/device/bind"
+)
+
+const (
+	// DefaultServerPort is the port the adb server listens on unless
+	// overridden with the ANDROID_ADB_SERVER_PORT environment variable.
+	DefaultServerPort = 5037
+
+	statusOkay = "OKAY"
+	statusFail = "FAIL"
+)
+
+// Server, if not nil, is used to communicate with devices using the adb
+// host-server protocol instead of spawning the adb binary for each command.
+// It only affects devices found by subsequent device scans.
+var Server *Client
+
+// Client talks to a running adb server using the adb host-server protocol.
+// See: https://android.googlesource.com/platform/system/core/+/master/adb/OVERVIEW.TXT
+type Client struct {
+	// Addr is the TCP address of the adb server.
+	Addr string
+
+	featuresMutex sync.Mutex
+	features      map[string][]string // device serial -> features
+}
+
+// NewClient returns a new Client that talks to the adb server at addr.
+func NewClient(addr string) *Client {
+	return &Client{Addr: addr, features: map[string][]string{}}
+}
+
+// DefaultServerAddr returns the address of the local adb server.
+func DefaultServerAddr() string {
+	port := DefaultServerPort
+	if p, err := strconv.Atoi(os.Getenv("ANDROID_ADB_SERVER_PORT")); err == nil {
+		port = p
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// ServerError is returned when the adb server or the device rejects a request.
+type ServerError struct {
+	// Request is the request that failed.
+	Request string
+	// Message is the failure reason reported by the server.
+	Message string
+}
+
+func (e ServerError) Error() string {
+	return fmt.Sprintf("adb server rejected '%v': %v", e.Request, e.Message)
+}
+
+// conn is a single connection to the adb server.
+type conn struct {
+	net.Conn
+}
+
+// closeOnStop closes the connection if ctx is stopped before the returned
+// function is called.
+func (c *conn) closeOnStop(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-task.ShouldStop(ctx):
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// dial opens a new connection to the adb server.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{}
+	nc, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc}, nil
+}
+
+// request sends the service request and waits for the server to accept it.
+func (c *conn) request(service string) error {
+	if _, err := fmt.Fprintf(c, "%04x%s", len(service), service); err != nil {
+		return err
+	}
+	return c.status(service)
+}
+
+// status reads an OKAY or FAIL status from the server, returning a
+// ServerError for FAIL.
+func (c *conn) status(request string) error {
+	status := make([]byte, 4)
+	if _, err := io.ReadFull(c, status); err != nil {
+		return err
+	}
+	switch string(status) {
+	case statusOkay:
+		return nil
+	case statusFail:
+		msg, err := c.readString()
+		if err != nil {
+			return err
+		}
+		return ServerError{Request: request, Message: msg}
+	default:
+		return fmt.Errorf("Unexpected adb server status %q for '%v'", status, request)
+	}
+}
+
+// readString reads a hex length-prefixed string.
+func (c *conn) readString() (string, error) {
+	hex := make([]byte, 4)
+	if _, err := io.ReadFull(c, hex); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(hex), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// query sends the host request and returns its length-prefixed reply.
+func (c *Client) query(ctx context.Context, request string) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.request(request); err != nil {
+		return "", err
+	}
+	return conn.readString()
+}
+
+// command sends the host request that replies with a second status once the
+// request has been carried out.
+func (c *Client) command(ctx context.Context, request string) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.request(request); err != nil {
+		return err
+	}
+	return conn.status(request)
+}
+
+// open connects to the service on the device with the given serial.
+func (c *Client) open(ctx context.Context, serial, service string) (*conn, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.request("host:transport:" + serial); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.request(service); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Version returns the protocol version of the adb server.
+func (c *Client) Version(ctx context.Context) (int, error) {
+	res, err := c.query(ctx, "host:version")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(res, 16, 32)
+	return int(v), err
+}
+
+// Devices returns the serials and statuses of the devices attached to the
+// adb server.
+func (c *Client) Devices(ctx context.Context) (map[string]bind.Status, error) {
+	res, err := c.query(ctx, "host:devices")
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceLines(ctx, strings.Split(res, "\n"))
+}
+
+// Features returns the features supported by the device with the given serial.
+func (c *Client) Features(ctx context.Context, serial string) ([]string, error) {
+	c.featuresMutex.Lock()
+	defer c.featuresMutex.Unlock()
+	if f, ok := c.features[serial]; ok {
+		return f, nil
+	}
+	res, err := c.query(ctx, "host-serial:"+serial+":features")
+	if err != nil {
+		return nil, err
+	}
+	f := strings.Split(strings.TrimSpace(res), ",")
+	c.features[serial] = f
+	return f, nil
+}
+
+// hasFeature returns true if the device with the given serial supports the
+// named feature.
+func (c *Client) hasFeature(ctx context.Context, serial, name string) bool {
+	features, err := c.Features(ctx, serial)
+	if err != nil {
+		return false
+	}
+	for _, f := range features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Forward forwards connections to the local port to the device port of the
+// device with the given serial.
+func (c *Client) Forward(ctx context.Context, serial string, local, device Port) error {
+	return c.command(ctx, fmt.Sprintf("host-serial:%v:forward:%v;%v",
+		serial, local.adbForwardString(), device.adbForwardString()))
+}
+
+// RemoveForward removes a port forward made by Forward.
+func (c *Client) RemoveForward(ctx context.Context, serial string, local Port) error {
+	return c.command(ctx, fmt.Sprintf("host-serial:%v:killforward:%v",
+		serial, local.adbForwardString()))
+}
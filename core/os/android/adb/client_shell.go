@@ -0,0 +1,196 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/os/shell"
+)
+
+// Shell protocol v2 packet identifiers.
+const (
+	shellStdin      = 0
+	shellStdout     = 1
+	shellStderr     = 2
+	shellExit       = 3
+	shellCloseStdin = 4
+)
+
+// ExitError is returned when a command run through the adb server exits with
+// a non-zero status. Devices without the shell_v2 feature do not report exit
+// statuses.
+type ExitError struct {
+	Status int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// shellProcess is a shell.Process for a command running on a device through
+// the adb server.
+type shellProcess struct {
+	conn *conn
+	done chan error
+}
+
+// startShell starts the command line on the device with the given serial,
+// using the shell protocol v2 if the device supports it.
+func (c *Client) startShell(ctx context.Context, serial, command string, cmd shell.Cmd) (shell.Process, error) {
+	if c.hasFeature(ctx, serial, "shell_v2") {
+		return c.startService(ctx, serial, "shell,v2,raw:"+command, cmd, true)
+	}
+	return c.startService(ctx, serial, "shell:"+command, cmd, false)
+}
+
+// startService opens the service on the device with the given serial and
+// streams it to and from the standard streams of cmd. If v2 is true, the
+// stream uses the shell protocol v2 packets, otherwise it is raw.
+func (c *Client) startService(ctx context.Context, serial, service string, cmd shell.Cmd, v2 bool) (shell.Process, error) {
+	conn, err := c.open(ctx, serial, service)
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	p := &shellProcess{conn: conn, done: make(chan error, 1)}
+	if v2 {
+		go p.writeStdinV2(cmd.Stdin)
+		go func() { p.done <- p.readV2(stdout, stderr) }()
+		return p, nil
+	}
+	if cmd.Stdin != nil {
+		go func() {
+			io.Copy(conn, cmd.Stdin)
+			if tcp, ok := conn.Conn.(*net.TCPConn); ok {
+				tcp.CloseWrite()
+			}
+		}()
+	}
+	go func() {
+		_, err := io.Copy(stdout, conn)
+		p.done <- err
+	}()
+	return p, nil
+}
+
+// writeStdinV2 forwards stdin to the device as shell protocol packets.
+func (p *shellProcess) writeStdinV2(stdin io.Reader) {
+	if stdin != nil {
+		buf := make([]byte, syncMaxData)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				if p.writePacket(shellStdin, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	p.writePacket(shellCloseStdin, nil)
+}
+
+func (p *shellProcess) writePacket(id byte, data []byte) error {
+	header := [5]byte{id}
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := p.conn.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := p.conn.Write(data)
+	return err
+}
+
+// readV2 demultiplexes the shell protocol packets from the device until the
+// command exits.
+func (p *shellProcess) readV2(stdout, stderr io.Writer) error {
+	defer p.conn.Close()
+	header := [5]byte{}
+	for {
+		if _, err := io.ReadFull(p.conn, header[:]); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("Shell connection closed before the command exited")
+			}
+			return err
+		}
+		data := io.LimitReader(p.conn, int64(binary.LittleEndian.Uint32(header[1:])))
+		switch header[0] {
+		case shellStdout:
+			if _, err := io.Copy(stdout, data); err != nil {
+				return err
+			}
+		case shellStderr:
+			if _, err := io.Copy(stderr, data); err != nil {
+				return err
+			}
+		case shellExit:
+			status, err := ioutil.ReadAll(data)
+			if err != nil {
+				return err
+			}
+			if len(status) > 0 && status[0] != 0 {
+				return ExitError{Status: int(status[0])}
+			}
+			return nil
+		default:
+			if _, err := io.Copy(ioutil.Discard, data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *shellProcess) Wait(ctx context.Context) error {
+	select {
+	case err := <-p.done:
+		p.conn.Close()
+		return err
+	case <-task.ShouldStop(ctx):
+		p.Kill()
+		return task.StopReason(ctx)
+	}
+}
+
+func (p *shellProcess) Kill() error {
+	p.conn.Close()
+	return nil
+}
+
+// shellCommandLine returns the command line for cmd, joining the arguments
+// with spaces like 'adb shell' does.
+func shellCommandLine(cmd shell.Cmd) string {
+	return strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+}
+
+// shellQuote returns s quoted so that the device shell treats it as a single
+// literal argument.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
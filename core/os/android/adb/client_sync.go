@@ -0,0 +1,209 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const (
+	syncMaxData  = 64 * 1024
+	syncModeDir  = 0040000
+	syncModeFile = 0100000
+)
+
+// syncHeader is the header of a sync service request or response.
+type syncHeader struct {
+	ID     [4]byte
+	Length uint32
+}
+
+// writeSync writes a sync request with the given id and data.
+func (c *conn) writeSync(id string, data []byte) error {
+	if err := c.writeSyncHeader(id, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := c.Write(data)
+	return err
+}
+
+// writeSyncHeader writes a sync request header with the given id and value.
+func (c *conn) writeSyncHeader(id string, value uint32) error {
+	h := syncHeader{Length: value}
+	copy(h.ID[:], id)
+	return binary.Write(c, binary.LittleEndian, &h)
+}
+
+// readSync reads a sync response header, returning its id and value.
+func (c *conn) readSync() (string, uint32, error) {
+	h := syncHeader{}
+	if err := binary.Read(c, binary.LittleEndian, &h); err != nil {
+		return "", 0, err
+	}
+	return string(h.ID[:]), h.Length, nil
+}
+
+// readSyncFail reads the message of a FAIL sync response of length n.
+func (c *conn) readSyncFail(request string, n uint32) error {
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(c, msg); err != nil {
+		return err
+	}
+	return ServerError{Request: request, Message: string(msg)}
+}
+
+// statMode returns the file mode of the remote path, or 0 if it does not
+// exist.
+func (c *conn) statMode(remote string) (uint32, error) {
+	if err := c.writeSync("STAT", []byte(remote)); err != nil {
+		return 0, err
+	}
+	id, mode, err := c.readSync()
+	if err != nil {
+		return 0, err
+	}
+	if id != "STAT" {
+		return 0, fmt.Errorf("Unexpected sync response %q to STAT", id)
+	}
+	var sizeAndTime [8]byte
+	if _, err := io.ReadFull(c, sizeAndTime[:]); err != nil {
+		return 0, err
+	}
+	return mode, nil
+}
+
+// Push copies the local file to the remote path on the device with the given
+// serial. If remote is a directory the file keeps its name.
+func (c *Client) Push(ctx context.Context, serial, local, remote string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.open(ctx, serial, "sync:")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer conn.closeOnStop(ctx)()
+
+	mode, err := conn.statMode(remote)
+	if err != nil {
+		return err
+	}
+	if mode&syncModeDir != 0 {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	spec := fmt.Sprintf("%v,%d", remote, syncModeFile|uint32(info.Mode().Perm()))
+	if err := conn.writeSync("SEND", []byte(spec)); err != nil {
+		return err
+	}
+	buf := make([]byte, syncMaxData)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := conn.writeSync("DATA", buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := conn.writeSyncHeader("DONE", uint32(info.ModTime().Unix())); err != nil {
+		return err
+	}
+
+	id, n, err := conn.readSync()
+	switch {
+	case err != nil:
+		return err
+	case id == "FAIL":
+		return conn.readSyncFail("push "+remote, n)
+	case id != "OKAY":
+		return fmt.Errorf("Unexpected sync response %q to SEND", id)
+	}
+	return conn.writeSyncHeader("QUIT", 0)
+}
+
+// Pull copies the remote file on the device with the given serial to the
+// local path. If local is a directory the file keeps its name.
+func (c *Client) Pull(ctx context.Context, serial, remote, local string) error {
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+
+	conn, err := c.open(ctx, serial, "sync:")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer conn.closeOnStop(ctx)()
+
+	if err := conn.writeSync("RECV", []byte(remote)); err != nil {
+		return err
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if err := conn.recv(f, remote); err != nil {
+		f.Close()
+		os.Remove(local)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return conn.writeSyncHeader("QUIT", 0)
+}
+
+// recv copies the DATA responses of a RECV request to w.
+func (c *conn) recv(w io.Writer, remote string) error {
+	for {
+		id, n, err := c.readSync()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case "DATA":
+			if _, err := io.CopyN(w, c, int64(n)); err != nil {
+				return err
+			}
+		case "DONE":
+			return nil
+		case "FAIL":
+			return c.readSyncFail("pull "+remote, n)
+		default:
+			return fmt.Errorf("Unexpected sync response %q to RECV", id)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/android/adb/adbtest"
+	"github.com/google/gapid/core/os/device/bind"
+)
+
+func startFakeServer(ctx context.Context) *adbtest.Server {
+	srv, err := adbtest.NewServer(
+		&adbtest.Device{
+			Serial:   "fake_v2_device",
+			Features: []string{"shell_v2", "cmd"},
+			Shell: adbtest.Respond(map[string]string{
+				"echo hello":                                      "hello\n",
+				"getprop ro.build.product":                        "fake\n",
+				"getprop ro.build.version.release":                "8.0.0\n",
+				`pm install -r '/data/local/tmp/my app'\''s.apk'`: "Success\n",
+				`rm -f '/data/local/tmp/my app'\''s.apk'`:         "",
+			}),
+		},
+		&adbtest.Device{
+			Serial: "fake_v1_device",
+			Shell:  adbtest.Respond(map[string]string{"echo hello": "hello\n"}),
+		},
+		&adbtest.Device{Serial: "fake_offline_device", State: "offline"},
+	)
+	assert.For(ctx, "NewServer").ThatError(err).Succeeded()
+	return srv
+}
+
+func TestClient(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	srv := startFakeServer(ctx)
+	defer srv.Close()
+	c := adb.NewClient(srv.Addr())
+
+	version, err := c.Version(ctx)
+	assert.For(ctx, "Version err").ThatError(err).Succeeded()
+	assert.For(ctx, "Version").That(version).Equals(adbtest.Version)
+
+	devices, err := c.Devices(ctx)
+	assert.For(ctx, "Devices err").ThatError(err).Succeeded()
+	assert.For(ctx, "Devices").That(devices).DeepEquals(map[string]bind.Status{
+		"fake_v2_device":      bind.Status_Online,
+		"fake_v1_device":      bind.Status_Online,
+		"fake_offline_device": bind.Status_Offline,
+	})
+
+	features, err := c.Features(ctx, "fake_v2_device")
+	assert.For(ctx, "Features err").ThatError(err).Succeeded()
+	assert.For(ctx, "Features").ThatSlice(features).Equals([]string{"shell_v2", "cmd"})
+
+	err = c.Forward(ctx, "fake_v1_device", adb.TCPPort(1234), adb.NamedAbstractSocket("sock"))
+	assert.For(ctx, "Forward").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").ThatSlice(srv.Forwards()).Equals([]string{"fake_v1_device tcp:1234 localabstract:sock"})
+	err = c.RemoveForward(ctx, "fake_v1_device", adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").ThatSlice(srv.Forwards()).IsEmpty()
+	err = c.RemoveForward(ctx, "fake_v1_device", adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward missing").ThatError(err).Failed()
+}
+
+func TestClientShell(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	srv := startFakeServer(ctx)
+	defer srv.Close()
+	adb.Server = adb.NewClient(srv.Addr())
+	defer func() { adb.Server = nil }()
+
+	for _, serial := range []string{"fake_v2_device", "fake_v1_device"} {
+		ctx := log.V{"serial": serial}.Bind(ctx)
+		d := mustConnect(ctx, serial)
+		out, err := d.Shell("echo", "hello").Call(ctx)
+		assert.For(ctx, "Shell err").ThatError(err).Succeeded()
+		assert.For(ctx, "Shell").ThatString(out).Equals("hello")
+	}
+
+	d := mustConnect(ctx, "fake_v2_device")
+	stderr := &bytes.Buffer{}
+	err := d.Shell("missing").Capture(nil, stderr).Run(ctx)
+	assert.For(ctx, "Exit status").ThatError(err).HasCause(adb.ExitError{Status: 127})
+	assert.For(ctx, "Stderr").ThatString(stderr.String()).Equals("/system/bin/sh: missing: not found\n")
+
+	requests := map[string]bool{}
+	for _, req := range srv.Requests() {
+		requests[req] = true
+	}
+	assert.For(ctx, "Shell v1 request").That(requests["shell:echo hello"]).Equals(true)
+	assert.For(ctx, "Shell v2 request").That(requests["shell,v2,raw:echo hello"]).Equals(true)
+}
+
+func TestClientFiles(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	srv := startFakeServer(ctx)
+	defer srv.Close()
+	adb.Server = adb.NewClient(srv.Addr())
+	defer func() { adb.Server = nil }()
+
+	tmp, err := ioutil.TempDir("", "adb_client_test")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(tmp)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	// The name needs quoting by the device shell.
+	local := filepath.Join(tmp, "my app's.apk")
+	assert.For(ctx, "WriteFile").ThatError(ioutil.WriteFile(local, content, 0644)).Succeeded()
+
+	d := mustConnect(ctx, "fake_v2_device")
+	err = d.Push(ctx, local, "/sdcard/app.apk")
+	assert.For(ctx, "Push").ThatError(err).Succeeded()
+	pushed, _ := srv.Devices[0].File("/sdcard/app.apk")
+	assert.For(ctx, "Pushed").That(bytes.Equal(pushed, content)).Equals(true)
+
+	pulled := filepath.Join(tmp, "pulled.apk")
+	err = d.Pull(ctx, "/sdcard/app.apk", pulled)
+	assert.For(ctx, "Pull").ThatError(err).Succeeded()
+	got, _ := ioutil.ReadFile(pulled)
+	assert.For(ctx, "Pulled").That(bytes.Equal(got, content)).Equals(true)
+
+	err = d.Pull(ctx, "/sdcard/missing", filepath.Join(tmp, "missing"))
+	assert.For(ctx, "Pull missing").ThatError(err).Failed()
+	_, err = os.Stat(filepath.Join(tmp, "missing"))
+	assert.For(ctx, "Partial file removed").That(os.IsNotExist(err)).Equals(true)
+
+	err = d.InstallAPK(ctx, local, true, false)
+	assert.For(ctx, "InstallAPK").ThatError(err).Succeeded()
+
+	err = d.Root(ctx)
+	assert.For(ctx, "Root").ThatError(err).Succeeded()
+}
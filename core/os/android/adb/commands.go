@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -31,6 +32,9 @@ const (
 	// production build as is not 'rooted'.
 	ErrDeviceNotRooted = fault.Const("Device is not rooted")
 	ErrRootFailed      = fault.Const("Device failed to switch to root")
+	// ErrInstallFailed is returned by Device.InstallAPK when the package manager
+	// rejects the APK.
+	ErrInstallFailed = fault.Const("Failed to install APK")
 
 	maxRootAttempts = 5
 )
@@ -95,10 +99,34 @@ func (b *binding) InstallAPK(ctx context.Context, path string, reinstall bool, g
 		// during installation. Before Android 6.0, the flag did not exist.
 		args = append(args, "-g")
 	}
+	if b.client != nil {
+		return b.installViaServer(ctx, path, args)
+	}
 	args = append(args, path)
 	return b.Command("install", args...).Run(ctx)
 }
 
+// installViaServer pushes the APK to a temporary file on the device and
+// installs it with the package manager, as 'adb install' does.
+func (b *binding) installViaServer(ctx context.Context, path string, flags []string) error {
+	remote := "/data/local/tmp/" + filepath.Base(path)
+	if err := b.Push(ctx, path, remote); err != nil {
+		return err
+	}
+	// The shell command line is not quoted by the adb server.
+	quoted := shellQuote(remote)
+	defer b.Shell("rm", "-f", quoted).Run(ctx)
+	args := append(append([]string{"install"}, flags...), quoted)
+	res, err := b.Shell("pm", args...).Call(ctx)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(res, "Success") {
+		return log.Err(ctx, ErrInstallFailed, res)
+	}
+	return nil
+}
+
 // SELinuxEnforcing returns true if the device is currently in a
 // SELinux enforcing mode, or false if the device is currently in a SELinux
 // permissive mode.
@@ -164,6 +192,39 @@ func (b *binding) SystemProperty(ctx context.Context, name string) (string, erro
 	return res, nil
 }
 
+// SystemProperties returns all the system properties of the device.
+func (b *binding) SystemProperties(ctx context.Context) (map[string]string, error) {
+	res, err := b.Shell("getprop").Call(ctx)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "getprop returned error: \n%s", err.Error())
+	}
+	return parseProperties(res), nil
+}
+
+// parseProperties parses the output of getprop, which lists each property as
+// "[name]: [value]". Values may span multiple lines.
+func parseProperties(s string) map[string]string {
+	out := map[string]string{}
+	name, value, open := "", "", false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if open {
+			value += "\n" + line
+		} else {
+			parts := strings.SplitN(line, "]: [", 2)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], "[") {
+				continue
+			}
+			name, value, open = parts[0][1:], parts[1], true
+		}
+		if strings.HasSuffix(value, "]") {
+			out[name] = value[:len(value)-1]
+			open = false
+		}
+	}
+	return out
+}
+
 // SetSystemProperty sets the system property with the given string value
 func (b *binding) SetSystemProperty(ctx context.Context, name, value string) error {
 	if len(value) == 0 {
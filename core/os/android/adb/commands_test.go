@@ -93,6 +93,20 @@ func TestReinstallAPK(t_ *testing.T) {
 	expectedCommand(ctx, adbPath.System()+` -s install_device install -r thing_to_install`, err)
 }
 
+func TestSystemProperties(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	d := mustConnect(ctx, "production_device")
+	got, err := d.SystemProperties(ctx)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "properties").That(got).DeepEquals(map[string]string{
+		"dalvik.vm.heapsize":    "512m",
+		"ro.build.fingerprint":  "google/hammerhead/hammerhead:6.0.1/MMB29Q/2480792:user/release-keys",
+		"ro.product.model":      "Nexus 5",
+		"persist.sys.multiline": "first\nsecond",
+		"ro.empty":              "",
+	})
+}
+
 func TestSELinuxEnforcing(t_ *testing.T) {
 	ctx := log.Testing(t_)
 	d := mustConnect(ctx, "production_device")
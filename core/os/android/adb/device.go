@@ -100,7 +100,7 @@ func Devices(ctx context.Context) (DeviceList, error) {
 	return out, nil
 }
 
-func newDevice(ctx context.Context, serial string, status bind.Status) (*binding, error) {
+func newDevice(ctx context.Context, serial string, status bind.Status, client *Client) (*binding, error) {
 	d := &binding{
 		Simple: bind.Simple{
 			To: &device.Instance{
@@ -109,6 +109,7 @@ func newDevice(ctx context.Context, serial string, status bind.Status) (*binding
 			},
 			LastStatus: status,
 		},
+		client: client,
 	}
 
 	// Lookup the basic hardware information
@@ -177,15 +178,8 @@ func allZero(bytes []byte) bool {
 
 // scanDevices returns the list of attached Android devices.
 func scanDevices(ctx context.Context) error {
-	exe, err := adb()
-	if err != nil {
-		return log.Err(ctx, err, "")
-	}
-	stdout, err := shell.Command(exe.System(), "devices").Call(ctx)
-	if err != nil {
-		return err
-	}
-	parsed, err := parseDevices(ctx, stdout)
+	client := Server
+	parsed, err := listDevices(ctx, client)
 	if err != nil {
 		return err
 	}
@@ -195,8 +189,8 @@ func scanDevices(ctx context.Context) error {
 
 	for serial, status := range parsed {
 		cached, ok := cache[serial]
-		if !ok || status != cached.Status() {
-			device, err := newDevice(ctx, serial, status)
+		if !ok || status != cached.Status() || client != cached.client {
+			device, err := newDevice(ctx, serial, status, client)
 			if err != nil {
 				return err
 			}
@@ -219,12 +213,34 @@ func scanDevices(ctx context.Context) error {
 	return nil
 }
 
+// listDevices returns the attached devices using the adb server client, or
+// the adb binary if client is nil.
+func listDevices(ctx context.Context, client *Client) (map[string]bind.Status, error) {
+	if client != nil {
+		return client.Devices(ctx)
+	}
+	exe, err := adb()
+	if err != nil {
+		return nil, log.Err(ctx, err, "")
+	}
+	stdout, err := shell.Command(exe.System(), "devices").Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseDevices(ctx, stdout)
+}
+
 func parseDevices(ctx context.Context, out string) (map[string]bind.Status, error) {
 	a := strings.SplitAfter(out, "List of devices attached")
 	if len(a) != 2 {
 		return nil, ErrNoDeviceList
 	}
-	lines := strings.Split(a[1], "\n")
+	return parseDeviceLines(ctx, strings.Split(a[1], "\n"))
+}
+
+// parseDeviceLines parses the serial and status from each line of a device
+// list.
+func parseDeviceLines(ctx context.Context, lines []string) (map[string]bind.Status, error) {
 	devices := make(map[string]bind.Status, len(lines))
 	for _, line := range lines {
 		if strings.HasPrefix(line, "adb server version") && strings.HasSuffix(line, "killing...") {
@@ -18,10 +18,16 @@ import "context"
 
 // Pushes the local file to the remote one.
 func (b *binding) Push(ctx context.Context, local, remote string) error {
+	if b.client != nil {
+		return b.client.Push(ctx, b.To.Serial, local, remote)
+	}
 	return b.Command("push", local, remote).Run(ctx)
 }
 
 // Pulls the remote file to the local one.
 func (b *binding) Pull(ctx context.Context, remote, local string) error {
+	if b.client != nil {
+		return b.client.Pull(ctx, b.To.Serial, remote, local)
+	}
 	return b.Command("pull", remote, local).Run(ctx)
 }
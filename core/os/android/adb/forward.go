@@ -83,6 +83,9 @@ func (p Jdwp) adbForwardString() string {
 
 // Forward will forward the specified device Port to the specified local Port.
 func (b *binding) Forward(ctx context.Context, local, device Port) error {
+	if b.client != nil {
+		return b.client.Forward(ctx, b.To.Serial, local, device)
+	}
 	return b.Command("forward", local.adbForwardString(), device.adbForwardString()).Run(ctx)
 }
 
@@ -90,5 +93,8 @@ func (b *binding) Forward(ctx context.Context, local, device Port) error {
 func (b *binding) RemoveForward(ctx context.Context, local Port) error {
 	// Clone context to ignore cancellation.
 	ctx = keys.Clone(context.Background(), ctx)
+	if b.client != nil {
+		return b.client.RemoveForward(ctx, b.To.Serial, local)
+	}
 	return b.Command("forward", "--remove", local.adbForwardString()).Run(ctx)
 }
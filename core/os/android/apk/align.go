@@ -0,0 +1,143 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+)
+
+const (
+	// Alignment is the alignment of uncompressed files in the APK, allowing
+	// them to be mapped directly from the APK.
+	Alignment = 4
+	// PageAlignment is the alignment of uncompressed shared libraries, so
+	// they can be loaded directly from the APK.
+	PageAlignment = 4096
+
+	ErrMisaligned = fault.Const("Uncompressed file in APK is not aligned.")
+
+	zipLocalHeaderSig      = 0x04034b50
+	zipCentralDirectorySig = 0x02014b50
+	zipEndOfDirectorySig   = 0x06054b50
+	zipLocalHeaderSize     = 30
+	zipEndOfDirectorySize  = 22
+	zipDataDescriptorFlag  = 0x8
+)
+
+// zipEntry is a file to be written to an aligned zip archive.
+type zipEntry struct {
+	header zip.FileHeader
+	// raw is the file's content as stored in the archive.
+	raw []byte
+	// digest is the SHA-256 digest of the uncompressed content.
+	digest []byte
+}
+
+// alignmentOf returns the required alignment of the entry's data.
+func alignmentOf(h *zip.FileHeader) int {
+	switch {
+	case h.Method != zip.Store:
+		return 1
+	case strings.HasSuffix(h.Name, ".so"):
+		return PageAlignment
+	default:
+		return Alignment
+	}
+}
+
+// writeAligned writes the entries into a zip archive, padding the local
+// file headers so that uncompressed data is aligned as zipalign would do.
+// It returns the archive and the offset of the central directory.
+func writeAligned(entries []*zipEntry) ([]byte, int) {
+	out := &bytes.Buffer{}
+	le := func(v interface{}) { binary.Write(out, binary.LittleEndian, v) }
+	offsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		h := &e.header
+		offsets[i] = uint32(out.Len())
+		align := alignmentOf(h)
+		pad := (align - (out.Len()+zipLocalHeaderSize+len(h.Name))%align) % align
+		le(uint32(zipLocalHeaderSig))
+		le(versionNeeded(h))
+		le(h.Flags &^ zipDataDescriptorFlag)
+		le(h.Method)
+		le(h.ModifiedTime)
+		le(h.ModifiedDate)
+		le(h.CRC32)
+		le(uint32(len(e.raw)))
+		le(uint32(h.UncompressedSize64))
+		le(uint16(len(h.Name)))
+		le(uint16(pad))
+		out.WriteString(h.Name)
+		out.Write(make([]byte, pad))
+		out.Write(e.raw)
+	}
+	cdOffset := out.Len()
+	for i, e := range entries {
+		h := &e.header
+		le(uint32(zipCentralDirectorySig))
+		le(h.CreatorVersion&0xff00 | 20) // version made by
+		le(versionNeeded(h))
+		le(h.Flags &^ zipDataDescriptorFlag)
+		le(h.Method)
+		le(h.ModifiedTime)
+		le(h.ModifiedDate)
+		le(h.CRC32)
+		le(uint32(len(e.raw)))
+		le(uint32(h.UncompressedSize64))
+		le(uint16(len(h.Name)))
+		le([3]uint16{}) // extra, comment length and disk number
+		le(uint16(0))   // internal attributes
+		le(h.ExternalAttrs)
+		le(offsets[i])
+		out.WriteString(h.Name)
+	}
+	cdSize := out.Len() - cdOffset
+	le(uint32(zipEndOfDirectorySig))
+	le([2]uint16{}) // disk numbers
+	le(uint16(len(entries)))
+	le(uint16(len(entries)))
+	le(uint32(cdSize))
+	le(uint32(cdOffset))
+	le(uint16(0)) // comment length
+	return out.Bytes(), cdOffset
+}
+
+func versionNeeded(h *zip.FileHeader) uint16 {
+	if h.Method == zip.Store {
+		return 10
+	}
+	return 20
+}
+
+// checkAlignment returns ErrMisaligned if any uncompressed file in the
+// archive is not aligned.
+func checkAlignment(r *zip.Reader) error {
+	for _, f := range r.File {
+		offset, err := f.DataOffset()
+		if err != nil {
+			return err
+		}
+		if offset%int64(alignmentOf(&f.FileHeader)) != 0 {
+			return ErrMisaligned
+		}
+	}
+	return nil
+}
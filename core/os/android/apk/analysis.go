@@ -18,8 +18,11 @@ import (
 	"archive/zip"
 	"context"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+	"github.com/google/gapid/core/os/android/manifest"
 )
 
 // engineSignatures is used to identify the middleware engine used based on
@@ -44,19 +47,87 @@ func Analyze(ctx context.Context, apkData []byte) (*Information, error) {
 	if err != nil {
 		return nil, log.Err(ctx, err, "Finding launch activity")
 	}
+	labels, err := GetLabels(ctx, files, m)
+	if err != nil {
+		log.W(ctx, "Couldn't resolve resources of %v: %v", m.Package, err)
+	}
 	return &Information{
 		Name:        m.Package, // TODO
 		VersionCode: int32(m.VersionCode),
-		VersionName: m.VersionName,
+		VersionName: labels.VersionName,
 		Package:     m.Package,
 		Activity:    activity,
 		Action:      action,
 		Engine:      engine(files),
 		ABI:         GatherABIs(files),
 		Debuggable:  m.Application.Debuggable,
+		Label:       labels.Label,
+		Icon:        labels.Icon,
 	}, nil
 }
 
+// Labels holds the user visible label, version name and launcher icon of an
+// APK.
+type Labels struct {
+	Label       string
+	VersionName string
+	// Icon is the PNG data of the highest density launcher icon, or nil.
+	Icon []byte
+}
+
+// GetLabels returns the label, version name and launcher icon of the APK,
+// resolving references to the resource table. If the resources cannot be
+// resolved, the unresolved manifest values are returned with the error.
+func GetLabels(ctx context.Context, files []*zip.File, m manifest.Manifest) (Labels, error) {
+	out := Labels{Label: m.Application.Label, VersionName: m.VersionName}
+	_, labelIsRef := binaryxml.ParseReference(out.Label)
+	_, versionIsRef := binaryxml.ParseReference(out.VersionName)
+	iconID, iconIsRef := binaryxml.ParseReference(m.Application.Icon)
+	if !labelIsRef && !versionIsRef && !iconIsRef {
+		return out, nil
+	}
+	res, err := GetResources(ctx, files)
+	if err != nil {
+		return out, err
+	}
+	resolve := func(s string) string {
+		if id, ok := binaryxml.ParseReference(s); ok {
+			if str, ok := res.ResolveString(id); ok {
+				return str
+			}
+		}
+		return s
+	}
+	out.Label = resolve(out.Label)
+	out.VersionName = resolve(out.VersionName)
+	if iconIsRef {
+		if out.Icon, err = launcherIcon(files, res, iconID); err != nil {
+			return out, log.Err(ctx, err, "Couldn't read APK's launcher icon")
+		}
+	}
+	return out, nil
+}
+
+// launcherIcon returns the PNG data of the highest density variant of the
+// icon resource, or nil if the icon has no PNG variant.
+func launcherIcon(files []*zip.File, res *binaryxml.ResourceTable, id uint32) ([]byte, error) {
+	path, density := "", -1
+	for _, v := range res.Resolve(id) {
+		p := v.Value.String()
+		d := int(v.Config.Density)
+		if d == binaryxml.DensityAny || d == binaryxml.DensityNone {
+			d = 0
+		}
+		if strings.HasSuffix(p, ".png") && d > density {
+			path, density = p, d
+		}
+	}
+	if file := findFile(files, path); file != nil {
+		return readFile(file)
+	}
+	return nil, nil
+}
+
 func engine(files []*zip.File) string {
 	for _, file := range files {
 		_, name := filepath.Split(file.Name)
@@ -29,9 +29,11 @@ import (
 )
 
 const (
-	mainfestPath       = "AndroidManifest.xml"
-	ErrMissingManifest = fault.Const("Couldn't find APK's manifest file.")
-	ErrInvalidAPK      = fault.Const("File is not an APK.")
+	mainfestPath        = "AndroidManifest.xml"
+	resourcesPath       = "resources.arsc"
+	ErrMissingManifest  = fault.Const("Couldn't find APK's manifest file.")
+	ErrMissingResources = fault.Const("Couldn't find APK's resource table.")
+	ErrInvalidAPK       = fault.Const("File is not an APK.")
 )
 
 // Read parses the APK file, returning its contents.
@@ -68,14 +70,40 @@ func GetManifest(ctx context.Context, files []*zip.File) (manifest.Manifest, err
 }
 
 func findManifest(files []*zip.File) *zip.File {
+	return findFile(files, mainfestPath)
+}
+
+// GetResources returns the decoded resources.arsc resource table of the APK.
+func GetResources(ctx context.Context, files []*zip.File) (*binaryxml.ResourceTable, error) {
+	file := findFile(files, resourcesPath)
+	if file == nil {
+		return nil, log.Err(ctx, ErrMissingResources, "")
+	}
+	data, err := readFile(file)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't read APK's resource table")
+	}
+	return binaryxml.DecodeResourceTable(ctx, data)
+}
+
+func findFile(files []*zip.File, name string) *zip.File {
 	for _, file := range files {
-		if file.Name == mainfestPath {
+		if file.Name == name {
 			return file
 		}
 	}
 	return nil
 }
 
+func readFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 // GatherABIs returns the list of ABI directories in the zip file.
 func GatherABIs(files []*zip.File) []*device.ABI {
 	abis := []*device.ABI{}
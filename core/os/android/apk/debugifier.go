@@ -16,14 +16,12 @@ package apk
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
-	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/google/gapid/core/log"
@@ -31,46 +29,44 @@ import (
 )
 
 // ApkDebugifier makes an APK debuggable. The fields in the struct
-// are used to configure the various paths and passwords required,
-// as well as providing a log context.
-// Intended use is ApkDebugifier{Ctx: ..., JarSignCmd: "jarsigner", ..., KeyStorePath: "..."}.Run(...).
+// are used to configure the various paths and passwords required
+// to sign the APK, either with a key from a keystore or a PEM key pair.
+// Intended use is ApkDebugifier{KeyStorePath: "...", ...}.Run(ctx, ...).
 type ApkDebugifier struct {
-	JarSignCmd   string // path to the jarsigner binary
-	ZipAlignCmd  string // path to the zipalign binary
 	KeyPass      string // key passphrase
 	KeyAlias     string // key alias for signing
 	StorePass    string // keystore passphrase
 	KeyStorePath string // path to keystore (e.g. /path/to/debug.keystore)
+	KeyPath      string // path to a PEM private key, used instead of the keystore if set
+	CertPath     string // path to the PEM certificate of KeyPath
 }
 
 // Run takes the path (src) to an APK, sets the debuggable flag in its manifest,
 // re-signs and aligns it, and saves it to a different path (dst).
 func (a ApkDebugifier) Run(ctx context.Context, src string, dst string) error {
-	tempFile, err := ioutil.TempFile("", "debuggable.apk")
+	key, err := a.signingKey(ctx)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tempFile.Name())
 
-	log.I(ctx, "Making apk %s debuggable and saving to %s", src, tempFile.Name())
-	err = a.makeApkDebuggableAndRemoveSignatureFiles(ctx, src, tempFile)
+	log.I(ctx, "Making apk %s debuggable", src)
+	buf := &bytes.Buffer{}
+	err = a.makeApkDebuggableAndRemoveSignatureFiles(ctx, src, buf)
 	if err != nil {
 		return err
 	}
 
-	log.I(ctx, "Signing apk %s", tempFile.Name())
-	err = a.jarSign(ctx, tempFile.Name())
+	log.I(ctx, "Signing and aligning apk")
+	signed, err := Sign(ctx, buf.Bytes(), key)
 	if err != nil {
 		return err
 	}
-
-	log.I(ctx, "Zipaligning %s to %s", tempFile.Name(), dst)
-	err = a.zipAlign(ctx, tempFile.Name(), dst)
-	if err != nil {
+	if err := Verify(ctx, signed); err != nil {
 		return err
 	}
 
-	return nil
+	log.I(ctx, "Saving to %s", dst)
+	return ioutil.WriteFile(dst, signed, 0644)
 }
 
 func expandHomeDir(p string) string {
@@ -84,47 +80,21 @@ func expandHomeDir(p string) string {
 	return filepath.Join(user.HomeDir, strings.TrimLeft(p, "~"))
 }
 
-func (a ApkDebugifier) execCommand(ctx context.Context, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	log.I(ctx, "Executing %v", cmd.Args)
-	out, err := cmd.CombinedOutput()
-	logger := log.From(ctx).Writer(log.Debug)
-	if err != nil {
-		logger = log.From(ctx).Writer(log.Error)
+func (a ApkDebugifier) signingKey(ctx context.Context) (*SigningKey, error) {
+	if a.KeyPath != "" {
+		return LoadPEM(ctx, expandHomeDir(a.KeyPath), expandHomeDir(a.CertPath))
 	}
-	logger.Write(out)
-	return err
-}
-
-func (a ApkDebugifier) jarSign(ctx context.Context, apk string) error {
-	return a.execCommand(ctx,
-		expandHomeDir(a.JarSignCmd),
-		"-sigalg", "SHA1withRSA",
-		"-digestalg", "SHA1",
-		"-keystore", expandHomeDir(a.KeyStorePath),
-		"-storepass", a.StorePass,
-		"-keypass", a.KeyPass,
-		apk,
-		a.KeyAlias,
-	)
-}
-
-func (a ApkDebugifier) zipAlign(ctx context.Context, src string, dst string) error {
-	return a.execCommand(ctx, expandHomeDir(a.ZipAlignCmd), "-v", "-f", "4", src, dst)
+	return LoadKeyStore(ctx, expandHomeDir(a.KeyStorePath), a.StorePass, a.KeyAlias, a.KeyPass)
 }
 
-func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(ctx context.Context, src string, outFile *os.File) error {
+func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(ctx context.Context, src string, out io.Writer) error {
 	inZip, err := zip.OpenReader(src)
 	if err != nil {
 		return err
 	}
 	defer inZip.Close()
 
-	defer outFile.Close()
-	w := zip.NewWriter(outFile)
-	defer w.Close()
-
-	jarSignatureFilePattern := regexp.MustCompile(`META-INF/([^/]*(DSA|RSA|SF)|MANIFEST\.MF)`)
+	w := zip.NewWriter(out)
 
 	for _, zf := range inZip.File {
 		if jarSignatureFilePattern.MatchString(zf.Name) {
@@ -163,7 +133,7 @@ func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(ctx context.Cont
 		}
 	}
 
-	return nil
+	return w.Close()
 }
 
 func IsApkDebuggable(ctx context.Context, apk string) (bool, error) {
@@ -0,0 +1,278 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"golang.org/x/crypto/pkcs12"
+)
+
+const (
+	ErrInvalidKeyStore  = fault.Const("Invalid key store.")
+	ErrKeyStorePassword = fault.Const("Key store password is incorrect.")
+	ErrKeyPassword      = fault.Const("Key password is incorrect.")
+	ErrKeyNotFound      = fault.Const("Key alias not found in key store.")
+	ErrUnsupportedKey   = fault.Const("Unsupported signing key type. Only RSA and ECDSA keys are supported.")
+	ErrInvalidPEM       = fault.Const("Invalid PEM file.")
+)
+
+const (
+	jksMagic         = 0xfeedfeed
+	jksPrivateKeyTag = 1
+	jksTrustedTag    = 2
+	// jksWhitener is mixed into the key store integrity digest.
+	jksWhitener = "Mighty Aphrodite"
+)
+
+// SigningKey is a private key and its certificate used to sign APKs.
+type SigningKey struct {
+	PrivateKey  crypto.Signer
+	Certificate *x509.Certificate
+}
+
+// LoadKeyStore loads the signing key with the given alias from the Java key
+// store at path. Both JKS and PKCS#12 key stores are supported. PKCS#12 key
+// stores hold a single key, so alias and keyPass are ignored for them.
+func LoadKeyStore(ctx context.Context, path, storePass, alias, keyPass string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't read key store")
+	}
+	if len(data) >= 4 && binary.BigEndian.Uint32(data) == jksMagic {
+		return parseJKS(data, storePass, alias, keyPass)
+	}
+	key, cert, err := pkcs12.Decode(data, storePass)
+	if err != nil {
+		if err == pkcs12.ErrIncorrectPassword {
+			return nil, ErrKeyStorePassword
+		}
+		return nil, log.Err(ctx, err, "Couldn't decode PKCS#12 key store")
+	}
+	return newSigningKey(key, cert)
+}
+
+// LoadPEM loads a signing key from the PEM encoded private key and
+// certificate files. The private key may be in PKCS#1, PKCS#8 or SEC 1 form.
+func LoadPEM(ctx context.Context, keyPath, certPath string) (*SigningKey, error) {
+	keyData, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	certData, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock, _ := pem.Decode(keyData)
+	certBlock, _ := pem.Decode(certData)
+	if keyBlock == nil || certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return nil, ErrInvalidPEM
+	}
+	var key interface{}
+	switch keyBlock.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	}
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't parse private key")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't parse certificate")
+	}
+	return newSigningKey(key, cert)
+}
+
+func newSigningKey(key interface{}, cert *x509.Certificate) (*SigningKey, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{key, cert}, nil
+	case *ecdsa.PrivateKey:
+		return &SigningKey{key, cert}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// jksPassword returns the password encoded as the key store expects it.
+func jksPassword(password string) []byte {
+	out := []byte{}
+	for _, c := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return out
+}
+
+// jksReader reads the big-endian fields of a JKS key store.
+type jksReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *jksReader) read(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if int64(n) > int64(r.r.Len()) {
+		r.err = ErrInvalidKeyStore
+		return nil
+	}
+	data := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, data)
+	return data
+}
+
+func (r *jksReader) u16() uint16 {
+	if data := r.read(2); data != nil {
+		return binary.BigEndian.Uint16(data)
+	}
+	return 0
+}
+
+func (r *jksReader) u32() uint32 {
+	if data := r.read(4); data != nil {
+		return binary.BigEndian.Uint32(data)
+	}
+	return 0
+}
+
+func (r *jksReader) str() string { return string(r.read(uint32(r.u16()))) }
+
+// parseJKS parses the Sun JKS key store format, returning the private key
+// entry with the given alias.
+func parseJKS(data []byte, storePass, alias, keyPass string) (*SigningKey, error) {
+	if len(data) < sha1.Size {
+		return nil, ErrInvalidKeyStore
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	h := sha1.New()
+	h.Write(jksPassword(storePass))
+	h.Write([]byte(jksWhitener))
+	h.Write(body)
+	if !bytes.Equal(h.Sum(nil), digest) {
+		return nil, ErrKeyStorePassword
+	}
+
+	r := &jksReader{r: bytes.NewReader(body)}
+	r.u32() // magic
+	version := r.u32()
+	if version != 1 && version != 2 {
+		return nil, ErrInvalidKeyStore
+	}
+	readCert := func() []byte {
+		if version == 2 {
+			r.str() // certificate type
+		}
+		return r.read(r.u32())
+	}
+	for i, count := uint32(0), r.u32(); i < count && r.err == nil; i++ {
+		tag := r.u32()
+		name := r.str()
+		r.read(8) // timestamp
+		switch tag {
+		case jksPrivateKeyTag:
+			protected := r.read(r.u32())
+			chain := make([][]byte, r.u32())
+			for i := range chain {
+				chain[i] = readCert()
+			}
+			if r.err != nil || !strings.EqualFold(name, alias) {
+				continue
+			}
+			if len(chain) == 0 {
+				return nil, ErrInvalidKeyStore
+			}
+			key, err := recoverJKSKey(protected, keyPass)
+			if err != nil {
+				return nil, err
+			}
+			cert, err := x509.ParseCertificate(chain[0])
+			if err != nil {
+				return nil, err
+			}
+			return newSigningKey(key, cert)
+		case jksTrustedTag:
+			readCert()
+		default:
+			return nil, ErrInvalidKeyStore
+		}
+	}
+	if r.err != nil {
+		return nil, ErrInvalidKeyStore
+	}
+	return nil, ErrKeyNotFound
+}
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo structure.
+type encryptedPrivateKeyInfo struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.RawValue `asn1:"optional"`
+	}
+	EncryptedData []byte
+}
+
+// recoverJKSKey decrypts a private key protected by the Sun JKS key
+// protector: the key is XORed with a SHA-1 based key stream seeded with a
+// random salt, and followed by a SHA-1 check of the plain key.
+func recoverJKSKey(protected []byte, keyPass string) (interface{}, error) {
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(protected, &info); err != nil {
+		return nil, ErrInvalidKeyStore
+	}
+	data := info.EncryptedData
+	if len(data) < 2*sha1.Size {
+		return nil, ErrInvalidKeyStore
+	}
+	salt, encrypted, check := data[:sha1.Size], data[sha1.Size:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	password := jksPassword(keyPass)
+	plain := make([]byte, len(encrypted))
+	digest := salt
+	for i := 0; i < len(encrypted); i += sha1.Size {
+		h := sha1.New()
+		h.Write(password)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(encrypted); j++ {
+			plain[i+j] = encrypted[i+j] ^ digest[j]
+		}
+	}
+	h := sha1.New()
+	h.Write(password)
+	h.Write(plain)
+	if !bytes.Equal(h.Sum(nil), check) {
+		return nil, ErrKeyPassword
+	}
+	return x509.ParsePKCS8PrivateKey(plain)
+}
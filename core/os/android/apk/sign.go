@@ -0,0 +1,386 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	jarManifestPath = "META-INF/MANIFEST.MF"
+	// signerName is the base name of the v1 signature files.
+	signerName = "META-INF/CERT"
+
+	apkSigBlockMagic  = "APK Sig Block 42"
+	apkSigV2BlockID   = 0x7109871a
+	sigRSAPKCS1SHA256 = 0x0103
+	sigECDSASHA256    = 0x0201
+	// apkChunkSize is the size of the chunks digested by the v2 scheme.
+	apkChunkSize = 1 << 20
+	// maxManifestLine is the maximum length of a line in a JAR manifest.
+	maxManifestLine = 72
+)
+
+var (
+	// jarSignatureFilePattern matches the files of a JAR signature.
+	jarSignatureFilePattern = regexp.MustCompile(`^META-INF/([^/]*\.(DSA|RSA|EC|SF)|MANIFEST\.MF)$`)
+
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// Sign signs the APK with key using both the JAR signing scheme (v1) and
+// the APK Signature Scheme v2, aligning the uncompressed files as zipalign
+// does. Any existing JAR signature is replaced.
+func Sign(ctx context.Context, apk []byte, key *SigningKey) ([]byte, error) {
+	entries, err := readEntries(apk)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't read APK")
+	}
+	v1, err := signV1(entries, key)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't create the v1 signature")
+	}
+	zipped, cdOffset := writeAligned(append(v1, entries...))
+	signed, err := signV2(zipped, cdOffset, key)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Couldn't create the v2 signature")
+	}
+	return signed, nil
+}
+
+// readEntries returns the files of the zip archive, except those of a JAR
+// signature.
+func readEntries(apk []byte) ([]*zipEntry, error) {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return nil, ErrInvalidAPK
+	}
+	entries := []*zipEntry{}
+	for _, f := range r.File {
+		if jarSignatureFilePattern.MatchString(f.Name) {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		e := &zipEntry{
+			header: f.FileHeader,
+			raw:    apk[offset : offset+int64(f.CompressedSize64)],
+		}
+		if !f.FileInfo().IsDir() {
+			if e.digest, err = digestOf(f); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// digestOf returns the SHA-256 digest of the uncompressed file.
+func digestOf(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// storedEntry returns an uncompressed zip entry holding data.
+func storedEntry(name string, data []byte) *zipEntry {
+	e := &zipEntry{raw: data}
+	e.header = zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		UncompressedSize64: uint64(len(data)),
+		CompressedSize64:   uint64(len(data)),
+		ModifiedDate:       0x21, // 1980-01-01
+	}
+	return e
+}
+
+// manifestAttribute returns the "name: value" manifest line, wrapped to the
+// maximum line length with continuation lines.
+func manifestAttribute(name, value string) string {
+	line := name + ": " + value
+	out, max := "", maxManifestLine
+	for len(line) > max {
+		out += line[:max] + "\r\n "
+		line = line[max:]
+		max = maxManifestLine - 1 // Continuation lines start with a space.
+	}
+	return out + line + "\r\n"
+}
+
+func b64(data []byte) string { return base64.StdEncoding.EncodeToString(data) }
+
+func sha256Of(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// signV1 returns the JAR signature files of the entries.
+func signV1(entries []*zipEntry, key *SigningKey) ([]*zipEntry, error) {
+	manifest := &bytes.Buffer{}
+	manifest.WriteString(manifestAttribute("Manifest-Version", "1.0"))
+	manifest.WriteString(manifestAttribute("Created-By", "1.0 (Android)"))
+	manifest.WriteString("\r\n")
+
+	sf := &bytes.Buffer{}
+	sf.WriteString(manifestAttribute("Signature-Version", "1.0"))
+	sf.WriteString(manifestAttribute("Created-By", "1.0 (Android)"))
+
+	sections := &bytes.Buffer{}
+	sorted := append([]*zipEntry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].header.Name < sorted[j].header.Name })
+	for _, e := range sorted {
+		if e.digest == nil {
+			continue
+		}
+		section := manifestAttribute("Name", e.header.Name) +
+			manifestAttribute("SHA-256-Digest", b64(e.digest)) + "\r\n"
+		manifest.WriteString(section)
+		sections.WriteString(manifestAttribute("Name", e.header.Name))
+		sections.WriteString(manifestAttribute("SHA-256-Digest", b64(sha256Of([]byte(section)))))
+		sections.WriteString("\r\n")
+	}
+
+	sf.WriteString(manifestAttribute("SHA-256-Digest-Manifest", b64(sha256Of(manifest.Bytes()))))
+	// Tells v2 aware verifiers to reject the APK if the v2 signature is
+	// stripped.
+	sf.WriteString(manifestAttribute("X-Android-APK-Signed", "2"))
+	sf.WriteString("\r\n")
+	sf.Write(sections.Bytes())
+
+	block, ext, err := signPKCS7(sf.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	return []*zipEntry{
+		storedEntry(jarManifestPath, manifest.Bytes()),
+		storedEntry(signerName+".SF", sf.Bytes()),
+		storedEntry(signerName+ext, block),
+	}, nil
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	IssuerAndSerial    issuerAndSerial
+	DigestAlgorithm    algorithmIdentifier
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	// Content is the explicitly tagged [0] content.
+	Content asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// signatureAlgorithm returns the algorithm used to sign with the key.
+func signatureAlgorithm(key *SigningKey) x509.SignatureAlgorithm {
+	if _, ok := key.PrivateKey.(*ecdsa.PrivateKey); ok {
+		return x509.ECDSAWithSHA256
+	}
+	return x509.SHA256WithRSA
+}
+
+// signatureOf signs the SHA-256 digest of data with the key.
+func signatureOf(data []byte, key *SigningKey) ([]byte, error) {
+	return key.PrivateKey.Sign(rand.Reader, sha256Of(data), crypto.SHA256)
+}
+
+// signPKCS7 returns the detached PKCS#7 signature of data, and the file
+// extension of the signature block.
+func signPKCS7(data []byte, key *SigningKey) ([]byte, string, error) {
+	sig, err := signatureOf(data, key)
+	if err != nil {
+		return nil, "", err
+	}
+	sigAlgorithm, ext := oidRSA, ".RSA"
+	if signatureAlgorithm(key) == x509.ECDSAWithSHA256 {
+		sigAlgorithm, ext = oidECDSA256, ".EC"
+	}
+	sha256Algorithm := algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	content, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{sha256Algorithm},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      key.Certificate.Raw,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerial: issuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: key.Certificate.RawIssuer},
+				Serial: key.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:    sha256Algorithm,
+			SignatureAlgorithm: algorithmIdentifier{Algorithm: sigAlgorithm},
+			Signature:          sig,
+		}},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	block, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      content,
+		},
+	})
+	return block, ext, err
+}
+
+// v2SignatureAlgorithm returns the v2 scheme identifier of the algorithm.
+func v2SignatureAlgorithm(algorithm x509.SignatureAlgorithm) uint32 {
+	if algorithm == x509.ECDSAWithSHA256 {
+		return sigECDSASHA256
+	}
+	return sigRSAPKCS1SHA256
+}
+
+func u32(v uint32) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, v)
+	return out
+}
+
+func u64(v uint64) []byte {
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint64(out, v)
+	return out
+}
+
+// prefixed returns the concatenation of the data, prefixed with its
+// 32-bit length.
+func prefixed(data ...[]byte) []byte {
+	body := bytes.Join(data, nil)
+	return append(u32(uint32(len(body))), body...)
+}
+
+// contentDigest returns the v2 scheme digest of the sections: the SHA-256
+// of the digests of each 1MB chunk.
+func contentDigest(sections ...[]byte) []byte {
+	chunks := [][]byte{}
+	for _, s := range sections {
+		for len(s) > apkChunkSize {
+			chunks = append(chunks, s[:apkChunkSize])
+			s = s[apkChunkSize:]
+		}
+		chunks = append(chunks, s)
+	}
+	top := sha256.New()
+	top.Write([]byte{0x5a})
+	top.Write(u32(uint32(len(chunks))))
+	for _, c := range chunks {
+		h := sha256.New()
+		h.Write([]byte{0xa5})
+		h.Write(u32(uint32(len(c))))
+		h.Write(c)
+		top.Write(h.Sum(nil))
+	}
+	return top.Sum(nil)
+}
+
+// signV2 inserts an APK Signing Block holding the v2 signature before the
+// central directory of the zip archive.
+func signV2(apk []byte, cdOffset int, key *SigningKey) ([]byte, error) {
+	eocdOffset := len(apk) - zipEndOfDirectorySize
+	entries, cd, eocd := apk[:cdOffset], apk[cdOffset:eocdOffset], apk[eocdOffset:]
+
+	id := v2SignatureAlgorithm(signatureAlgorithm(key))
+	// The central directory offset in the digested end of central directory
+	// is that of the signing block, which is cdOffset.
+	digest := contentDigest(entries, cd, eocd)
+	signed := bytes.Join([][]byte{
+		prefixed(prefixed(u32(id), prefixed(digest))),
+		prefixed(prefixed(key.Certificate.Raw)),
+		prefixed(), // additional attributes
+	}, nil)
+	sig, err := signatureOf(signed, key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(key.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	signer := bytes.Join([][]byte{
+		prefixed(signed),
+		prefixed(prefixed(u32(id), prefixed(sig))),
+		prefixed(publicKey),
+	}, nil)
+	value := prefixed(prefixed(signer))
+	pair := bytes.Join([][]byte{u64(uint64(4 + len(value))), u32(apkSigV2BlockID), value}, nil)
+	size := u64(uint64(len(pair) + 8 + len(apkSigBlockMagic)))
+	block := bytes.Join([][]byte{size, pair, size, []byte(apkSigBlockMagic)}, nil)
+
+	out := bytes.Join([][]byte{entries, block, cd, eocd}, nil)
+	binary.LittleEndian.PutUint32(out[len(out)-6:], uint32(cdOffset+len(block)))
+	return out, nil
+}
@@ -0,0 +1,256 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/apk"
+)
+
+func newKey(ctx context.Context, key crypto.Signer) *apk.SigningKey {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Android Debug"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.For(ctx, "CreateCertificate").ThatError(err).Succeeded()
+	cert, err := x509.ParseCertificate(der)
+	assert.For(ctx, "ParseCertificate").ThatError(err).Succeeded()
+	return &apk.SigningKey{PrivateKey: key, Certificate: cert}
+}
+
+func newRSAKey(ctx context.Context) *apk.SigningKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.For(ctx, "GenerateKey").ThatError(err).Succeeded()
+	return newKey(ctx, key)
+}
+
+func newECDSAKey(ctx context.Context) *apk.SigningKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.For(ctx, "GenerateKey").ThatError(err).Succeeded()
+	return newKey(ctx, key)
+}
+
+var longName = "res/raw/" + strings.Repeat("a_very_long_file_name_", 5) + ".bin"
+
+func unsignedAPK(ctx context.Context) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, f := range []struct {
+		name   string
+		method uint16
+		data   string
+	}{
+		{"AndroidManifest.xml", zip.Deflate, "manifest"},
+		{"classes.dex", zip.Deflate, strings.Repeat("dex", 1000)},
+		{"resources.arsc", zip.Store, "arsc"},
+		{"lib/arm64-v8a/libfoo.so", zip.Store, "ELF"},
+		{longName, zip.Store, "x"},
+		{"META-INF/OLD.SF", zip.Deflate, "old signature"},
+		{"META-INF/OLD.RSA", zip.Deflate, "old signature"},
+	} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		assert.For(ctx, "CreateHeader").ThatError(err).Succeeded()
+		fw.Write([]byte(f.data))
+	}
+	assert.For(ctx, "Close").ThatError(w.Close()).Succeeded()
+	return buf.Bytes()
+}
+
+func TestSign(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	for name, key := range map[string]*apk.SigningKey{
+		"RSA":   newRSAKey(ctx),
+		"ECDSA": newECDSAKey(ctx),
+	} {
+		ctx := log.V{"key": name}.Bind(ctx)
+		signed, err := apk.Sign(ctx, unsignedAPK(ctx), key)
+		assert.For(ctx, "Sign").ThatError(err).Succeeded()
+		assert.For(ctx, "Verify").ThatError(apk.Verify(ctx, signed)).Succeeded()
+
+		r, err := zip.NewReader(bytes.NewReader(signed), int64(len(signed)))
+		assert.For(ctx, "NewReader").ThatError(err).Succeeded()
+		names := []string{}
+		for _, f := range r.File {
+			names = append(names, f.Name)
+			offset, _ := f.DataOffset()
+			switch {
+			case strings.HasSuffix(f.Name, ".so"):
+				assert.For(ctx, "%v offset", f.Name).That(offset % apk.PageAlignment).Equals(int64(0))
+			case f.Method == zip.Store:
+				assert.For(ctx, "%v offset", f.Name).That(offset % apk.Alignment).Equals(int64(0))
+			}
+		}
+		assert.For(ctx, "Files").ThatSlice(names).Equals([]string{
+			"META-INF/MANIFEST.MF",
+			"META-INF/CERT.SF",
+			"META-INF/CERT." + map[string]string{"RSA": "RSA", "ECDSA": "EC"}[name],
+			"AndroidManifest.xml",
+			"classes.dex",
+			"resources.arsc",
+			"lib/arm64-v8a/libfoo.so",
+			longName,
+		})
+
+		// Re-signing an already signed APK replaces the signatures.
+		resigned, err := apk.Sign(ctx, signed, key)
+		assert.For(ctx, "Resign").ThatError(err).Succeeded()
+		assert.For(ctx, "Verify resigned").ThatError(apk.Verify(ctx, resigned)).Succeeded()
+
+		// Tampering with a file must break the signatures.
+		offset, _ := r.File[5].DataOffset()
+		tampered := append([]byte{}, signed...)
+		tampered[offset] ^= 0xff
+		assert.For(ctx, "Verify tampered").ThatError(apk.Verify(ctx, tampered)).Failed()
+	}
+}
+
+func TestVerifyUnsigned(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	err := apk.Verify(ctx, unsignedAPK(ctx))
+	assert.For(ctx, "Verify").ThatError(err).Failed()
+}
+
+// jksPassword encodes the password as the JKS format expects.
+func jksPassword(password string) []byte {
+	out := []byte{}
+	for _, c := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return out
+}
+
+// writeJKS writes a JKS key store holding the key with the given alias.
+func writeJKS(ctx context.Context, path string, key *apk.SigningKey, alias, storePass, keyPass string) {
+	plain, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	assert.For(ctx, "MarshalPKCS8PrivateKey").ThatError(err).Succeeded()
+	password := jksPassword(keyPass)
+	salt := make([]byte, sha1.Size)
+	rand.Read(salt)
+	protected := append([]byte{}, salt...)
+	digest := salt
+	for i := 0; i < len(plain); i += sha1.Size {
+		h := sha1.Sum(append(append([]byte{}, password...), digest...))
+		digest = h[:]
+		for j := 0; j < sha1.Size && i+j < len(plain); j++ {
+			protected = append(protected, plain[i+j]^digest[j])
+		}
+	}
+	check := sha1.Sum(append(append([]byte{}, password...), plain...))
+	protected = append(protected, check[:]...)
+	info, err := asn1.Marshal(struct {
+		Algorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue
+		}
+		EncryptedData []byte
+	}{
+		Algorithm: struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue
+		}{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}, asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+	assert.For(ctx, "Marshal").ThatError(err).Succeeded()
+
+	buf := &bytes.Buffer{}
+	be := func(v interface{}) { binary.Write(buf, binary.BigEndian, v) }
+	str := func(s string) { be(uint16(len(s))); buf.WriteString(s) }
+	be(uint32(0xfeedfeed))
+	be(uint32(2))
+	be(uint32(1))
+	be(uint32(1)) // private key entry
+	str(alias)
+	be(int64(0))
+	be(uint32(len(info)))
+	buf.Write(info)
+	be(uint32(1))
+	str("X.509")
+	be(uint32(len(key.Certificate.Raw)))
+	buf.Write(key.Certificate.Raw)
+	sum := sha1.Sum(append(append(jksPassword(storePass), "Mighty Aphrodite"...), buf.Bytes()...))
+	buf.Write(sum[:])
+	assert.For(ctx, "WriteFile").ThatError(ioutil.WriteFile(path, buf.Bytes(), 0644)).Succeeded()
+}
+
+func TestLoadKeyStore(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	tmp, err := ioutil.TempDir("", "apk_keystore")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(tmp)
+
+	key := newRSAKey(ctx)
+	path := filepath.Join(tmp, "debug.keystore")
+	writeJKS(ctx, path, key, "androiddebugkey", "android", "keypass")
+
+	got, err := apk.LoadKeyStore(ctx, path, "android", "AndroidDebugKey", "keypass")
+	assert.For(ctx, "LoadKeyStore").ThatError(err).Succeeded()
+	assert.For(ctx, "Key").That(got.PrivateKey).DeepEquals(key.PrivateKey)
+	assert.For(ctx, "Certificate").That(got.Certificate.Equal(key.Certificate)).Equals(true)
+
+	_, err = apk.LoadKeyStore(ctx, path, "wrong", "androiddebugkey", "keypass")
+	assert.For(ctx, "Wrong store password").ThatError(err).Equals(apk.ErrKeyStorePassword)
+	_, err = apk.LoadKeyStore(ctx, path, "android", "androiddebugkey", "wrong")
+	assert.For(ctx, "Wrong key password").ThatError(err).Equals(apk.ErrKeyPassword)
+	_, err = apk.LoadKeyStore(ctx, path, "android", "missing", "keypass")
+	assert.For(ctx, "Missing alias").ThatError(err).Equals(apk.ErrKeyNotFound)
+}
+
+func TestLoadPEM(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	tmp, err := ioutil.TempDir("", "apk_pem")
+	assert.For(ctx, "TempDir").ThatError(err).Succeeded()
+	defer os.RemoveAll(tmp)
+
+	key := newECDSAKey(ctx)
+	der, err := x509.MarshalECPrivateKey(key.PrivateKey.(*ecdsa.PrivateKey))
+	assert.For(ctx, "MarshalECPrivateKey").ThatError(err).Succeeded()
+	keyPath, certPath := filepath.Join(tmp, "key.pem"), filepath.Join(tmp, "cert.pem")
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0644)
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: key.Certificate.Raw}), 0644)
+
+	got, err := apk.LoadPEM(ctx, keyPath, certPath)
+	assert.For(ctx, "LoadPEM").ThatError(err).Succeeded()
+	assert.For(ctx, "Key").That(got.PrivateKey).DeepEquals(key.PrivateKey)
+
+	_, err = apk.LoadPEM(ctx, certPath, certPath)
+	assert.For(ctx, "Invalid key").ThatError(err).Failed()
+}
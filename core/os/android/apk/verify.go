@@ -0,0 +1,291 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	ErrNotSigned        = fault.Const("APK is not signed.")
+	ErrInvalidSignature = fault.Const("APK signature is invalid.")
+)
+
+// Verify checks that the APK is aligned and has valid JAR (v1) and APK
+// Signature Scheme v2 signatures, as produced by Sign.
+func Verify(ctx context.Context, apk []byte) error {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return log.Err(ctx, ErrInvalidAPK, "")
+	}
+	if err := checkAlignment(r); err != nil {
+		return log.Err(ctx, err, "Alignment")
+	}
+	if err := verifyV1(r); err != nil {
+		return log.Err(ctx, err, "JAR signature")
+	}
+	if err := verifyV2(apk); err != nil {
+		return log.Err(ctx, err, "APK Signature Scheme v2")
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// manifestSections parses the sections of a JAR manifest or signature file.
+func manifestSections(data []byte) []map[string]string {
+	text := strings.Replace(string(data), "\r\n ", "", -1)
+	out := []map[string]string{}
+	for _, section := range strings.Split(text, "\r\n\r\n") {
+		if section == "" {
+			continue
+		}
+		attributes := map[string]string{}
+		for _, line := range strings.Split(section, "\r\n") {
+			if parts := strings.SplitN(line, ": ", 2); len(parts) == 2 {
+				attributes[parts[0]] = parts[1]
+			}
+		}
+		out = append(out, attributes)
+	}
+	return out
+}
+
+// verifyV1 checks the JAR signature of the zip archive.
+func verifyV1(r *zip.Reader) error {
+	files := map[string][]byte{}
+	var sf, block []byte
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		files[f.Name] = data
+		switch {
+		case strings.HasSuffix(f.Name, ".SF") && jarSignatureFilePattern.MatchString(f.Name):
+			sf = data
+		case strings.HasSuffix(f.Name, ".RSA") || strings.HasSuffix(f.Name, ".EC"):
+			if jarSignatureFilePattern.MatchString(f.Name) {
+				block = data
+			}
+		}
+	}
+	manifest, ok := files[jarManifestPath]
+	if !ok || sf == nil || block == nil {
+		return ErrNotSigned
+	}
+	if err := verifyPKCS7(block, sf); err != nil {
+		return err
+	}
+
+	sections := manifestSections(sf)
+	if len(sections) == 0 || sections[0]["SHA-256-Digest-Manifest"] != b64(sha256Of(manifest)) {
+		return ErrInvalidSignature
+	}
+	signed := map[string]bool{}
+	for _, section := range manifestSections(manifest)[1:] {
+		name := section["Name"]
+		data, ok := files[name]
+		if !ok || section["SHA-256-Digest"] != b64(sha256Of(data)) {
+			return ErrInvalidSignature
+		}
+		signed[name] = true
+	}
+	for name := range files {
+		if !signed[name] && !jarSignatureFilePattern.MatchString(name) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// verifyPKCS7 checks the detached PKCS#7 signature block of data.
+func verifyPKCS7(block, data []byte) error {
+	info := contentInfo{}
+	if _, err := asn1.Unmarshal(block, &info); err != nil || !info.ContentType.Equal(oidSignedData) {
+		return ErrInvalidSignature
+	}
+	sd := signedData{}
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil || len(sd.SignerInfos) != 1 {
+		return ErrInvalidSignature
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil || len(certs) == 0 {
+		return ErrInvalidSignature
+	}
+	si := sd.SignerInfos[0]
+	algorithm := x509.SHA256WithRSA
+	if si.SignatureAlgorithm.Algorithm.Equal(oidECDSA256) {
+		algorithm = x509.ECDSAWithSHA256
+	}
+	if err := certs[0].CheckSignature(algorithm, data, si.Signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// lpReader reads the length-prefixed fields of an APK Signing Block.
+type lpReader struct {
+	data []byte
+	err  error
+}
+
+func (r *lpReader) u32() uint32 {
+	if r.err == nil && len(r.data) < 4 {
+		r.err = ErrInvalidSignature
+	}
+	if r.err != nil {
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *lpReader) next() *lpReader {
+	n := r.u32()
+	if r.err == nil && uint64(n) > uint64(len(r.data)) {
+		r.err = ErrInvalidSignature
+	}
+	if r.err != nil {
+		return &lpReader{err: r.err}
+	}
+	out := &lpReader{data: r.data[:n]}
+	r.data = r.data[n:]
+	return out
+}
+
+// findSigningBlock returns the offset of the APK Signing Block and the
+// value of the pair with the given ID.
+func findSigningBlock(apk []byte, cdOffset int, id uint32) (int, []byte, error) {
+	magic := len(apkSigBlockMagic)
+	if cdOffset < 24+magic || string(apk[cdOffset-magic:cdOffset]) != apkSigBlockMagic {
+		return 0, nil, ErrNotSigned
+	}
+	size := binary.LittleEndian.Uint64(apk[cdOffset-magic-8:])
+	if size > uint64(cdOffset-8) || size < uint64(8+magic) {
+		return 0, nil, ErrInvalidSignature
+	}
+	start := cdOffset - int(size) - 8
+	if binary.LittleEndian.Uint64(apk[start:]) != size {
+		return 0, nil, ErrInvalidSignature
+	}
+	pairs := apk[start+8 : cdOffset-magic-8]
+	for len(pairs) >= 12 {
+		n := binary.LittleEndian.Uint64(pairs)
+		if n < 4 || n > uint64(len(pairs)-8) {
+			return 0, nil, ErrInvalidSignature
+		}
+		if binary.LittleEndian.Uint32(pairs[8:]) == id {
+			return start, pairs[12 : 8+n], nil
+		}
+		pairs = pairs[8+n:]
+	}
+	return 0, nil, ErrNotSigned
+}
+
+// verifyV2 checks the APK Signature Scheme v2 signature of the APK.
+func verifyV2(apk []byte) error {
+	eocdOffset := bytes.LastIndex(apk, u32(zipEndOfDirectorySig))
+	if eocdOffset < 0 || len(apk)-eocdOffset < zipEndOfDirectorySize {
+		return ErrInvalidAPK
+	}
+	cdOffset := int(binary.LittleEndian.Uint32(apk[eocdOffset+16:]))
+	if cdOffset > eocdOffset {
+		return ErrInvalidAPK
+	}
+	start, value, err := findSigningBlock(apk, cdOffset, apkSigV2BlockID)
+	if err != nil {
+		return err
+	}
+
+	// The digested end of central directory points to the signing block.
+	eocd := append([]byte{}, apk[eocdOffset:]...)
+	binary.LittleEndian.PutUint32(eocd[16:], uint32(start))
+	digest := contentDigest(apk[:start], apk[cdOffset:eocdOffset], eocd)
+
+	signers := (&lpReader{data: value}).next()
+	count := 0
+	for ; len(signers.data) > 0 && signers.err == nil; count++ {
+		signer := signers.next()
+		signed := signer.next()
+		signedBytes := signed.data
+		sigs, publicKey := signer.next(), signer.next()
+		digests, certs := signed.next(), signed.next()
+		if signer.err != nil || signed.err != nil {
+			return ErrInvalidSignature
+		}
+		cert, err := x509.ParseCertificate(certs.next().data)
+		if err != nil || !bytes.Equal(cert.RawSubjectPublicKeyInfo, publicKey.data) {
+			return ErrInvalidSignature
+		}
+
+		verified := false
+		for len(sigs.data) > 0 && sigs.err == nil {
+			sig := sigs.next()
+			id, data := sig.u32(), sig.next().data
+			algorithm := x509.SHA256WithRSA
+			switch id {
+			case sigRSAPKCS1SHA256:
+			case sigECDSASHA256:
+				algorithm = x509.ECDSAWithSHA256
+			default:
+				continue
+			}
+			if sig.err != nil || cert.CheckSignature(algorithm, signedBytes, data) != nil {
+				return ErrInvalidSignature
+			}
+			verified = true
+		}
+		if !verified {
+			return ErrInvalidSignature
+		}
+
+		for len(digests.data) > 0 && digests.err == nil {
+			d := digests.next()
+			id, data := d.u32(), d.next().data
+			if (id == sigRSAPKCS1SHA256 || id == sigECDSASHA256) && !bytes.Equal(data, digest) {
+				return ErrInvalidSignature
+			}
+		}
+		if digests.err != nil || sigs.err != nil {
+			return ErrInvalidSignature
+		}
+	}
+	if signers.err != nil || count == 0 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
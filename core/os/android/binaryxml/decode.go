@@ -150,22 +150,42 @@ func decodeChunk(r binary.Reader, x *xmlTree) (chunk, error) {
 	return c, err
 }
 
+// decodeLength decodes the UTF-16 length of a string pool entry. Lengths
+// greater than 0x7fff are stored in two 16-bit words, with the high bit of
+// the first word set.
 func decodeLength(r binary.Reader) uint32 {
 	length := uint32(r.Uint16())
 	if length&0x8000 != 0 {
-		panic("UNTESTED CODE")
-		length = (length << 16) | uint32(r.Uint16())
+		length = ((length & 0x7fff) << 16) | uint32(r.Uint16())
 	}
 	return length
 }
 
 func encodeLength(w binary.Writer, length uint32) {
-	if length >= 0x8000 {
-		panic("TODO: UNSUPPORTED")
+	if length > 0x7fff {
+		w.Uint16(uint16(length>>16) | 0x8000)
 	}
 	w.Uint16(uint16(length))
 }
 
+// decodeLength8 decodes a length of a UTF-8 string pool entry. Lengths
+// greater than 0x7f are stored in two bytes, with the high bit of the first
+// byte set.
+func decodeLength8(r binary.Reader) uint32 {
+	length := uint32(r.Uint8())
+	if length&0x80 != 0 {
+		length = ((length & 0x7f) << 8) | uint32(r.Uint8())
+	}
+	return length
+}
+
+func encodeLength8(w binary.Writer, length uint32) {
+	if length > 0x7f {
+		w.Uint8(uint8(length>>8) | 0x80)
+	}
+	w.Uint8(uint8(length))
+}
+
 // encodeChunk takes functions that output chunk-specific header and data to a writer, and then uses them to
 // compute header and chunk sizes, as well as writing the whole chunk to a byte array, which is then returned.
 func encodeChunk(chunkType uint16, headerf func(w binary.Writer), dataf func(w binary.Writer)) []byte {
@@ -0,0 +1,419 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/core/log"
+)
+
+// AndroidNamespace is the URI of the android XML namespace.
+const AndroidNamespace = "http://schemas.android.com/apk/res/android"
+
+// Document is a binary XML document that can be edited and encoded.
+type Document struct {
+	// Namespaces are the namespaces declared around the root element.
+	Namespaces []Namespace
+	// Root is the document's root element.
+	Root *Element
+	// UTF8 is true if the strings are encoded as UTF-8 instead of UTF-16.
+	UTF8 bool
+}
+
+// Namespace is an XML namespace declaration.
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Element is an XML element of a Document. Character data is held by text
+// elements, which have no name, in the Children of the enclosing element.
+type Element struct {
+	Namespace  string // namespace URI, or empty
+	Name       string
+	Attributes []*Attribute
+	Children   []*Element
+	Text       string // character data of a text element
+	// Namespaces are the namespaces declared around the element. Those
+	// declared around the root element are held by the Document.
+	Namespaces []Namespace
+}
+
+// NewText returns a text element holding the character data.
+func NewText(text string) *Element { return &Element{Text: text} }
+
+// Attribute is an attribute of an Element.
+type Attribute struct {
+	Namespace string // namespace URI, or empty
+	Name      string
+	// Resource is the resource identifier of the attribute, or 0 if the
+	// attribute is not a resource attribute.
+	Resource uint32
+	// Raw is the raw string value of the attribute, or empty.
+	Raw   string
+	Value Value
+}
+
+// Value is the typed value of an attribute. Values are created with
+// StringValue, BoolValue, IntValue, HexValue, ReferenceValue and FloatValue.
+type Value interface {
+	fmt.Stringer
+}
+
+// valString is a string value, resolved to the string pool on encoding.
+type valString string
+
+func (v valString) String() string { return string(v) }
+
+// StringValue returns a string attribute value.
+func StringValue(s string) Value { return valString(s) }
+
+// BoolValue returns a boolean attribute value.
+func BoolValue(b bool) Value { return valIntBoolean(b) }
+
+// IntValue returns a decimal integer attribute value.
+func IntValue(i int32) Value { return valIntDec(i) }
+
+// HexValue returns a hexadecimal integer attribute value.
+func HexValue(i uint32) Value { return valIntHex(i) }
+
+// ReferenceValue returns a resource reference attribute value.
+func ReferenceValue(id uint32) Value { return valReference(id) }
+
+// FloatValue returns a floating point attribute value.
+func FloatValue(f float32) Value { return valFloat(f) }
+
+// Child returns the first child element with the given name, or nil.
+func (e *Element) Child(name string) *Element {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns all the child elements with the given name.
+func (e *Element) ChildrenNamed(name string) []*Element {
+	out := []*Element{}
+	for _, c := range e.Children {
+		if c.Name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Attribute returns the attribute with the given namespace and name, or nil.
+func (e *Element) Attribute(namespace, name string) *Attribute {
+	for _, a := range e.Attributes {
+		if a.Namespace == namespace && a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// SetAttribute replaces the attribute with the same namespace and name as a,
+// or adds a if there is no such attribute.
+func (e *Element) SetAttribute(a *Attribute) {
+	for i, existing := range e.Attributes {
+		if existing.Namespace == a.Namespace && existing.Name == a.Name {
+			e.Attributes[i] = a
+			return
+		}
+	}
+	e.Attributes = append(e.Attributes, a)
+}
+
+// DecodeDocument decodes a binary Android XML file to a Document.
+func DecodeDocument(ctx context.Context, data []byte) (*Document, error) {
+	tree, err := decodeXmlTree(bytes.NewReader(data))
+	if err != nil {
+		return nil, log.Err(ctx, err, "Decoding binary XML")
+	}
+	return tree.document()
+}
+
+// document converts the decoded tree to a Document.
+func (c *xmlTree) document() (*Document, error) {
+	d := &Document{UTF8: c.strings.flags&stringPoolUTF8Flag != 0}
+	str := func(r stringPoolRef) string {
+		if !r.isValid() {
+			return ""
+		}
+		return r.get()
+	}
+	stack := []*Element{}
+	// Namespaces declared within the root element, waiting for the element
+	// they are declared around.
+	pending := []Namespace{}
+	for _, chunk := range c.chunks {
+		switch chunk := chunk.(type) {
+		case *xmlStartNamespace:
+			ns := Namespace{
+				Prefix: str(chunk.namespacePrefix),
+				URI:    str(chunk.namespaceURI),
+			}
+			if len(stack) == 0 {
+				d.Namespaces = append(d.Namespaces, ns)
+			} else {
+				pending = append(pending, ns)
+			}
+		case *xmlStartElement:
+			e := &Element{Namespace: str(chunk.namespace), Name: str(chunk.name)}
+			if len(pending) > 0 {
+				e.Namespaces, pending = pending, []Namespace{}
+			}
+			for _, at := range chunk.attributes {
+				a := &Attribute{
+					Namespace: str(at.namespace),
+					Name:      str(at.name),
+					Raw:       str(at.rawValue),
+					Value:     at.typedValue,
+				}
+				if idx := at.name.stringPoolIndex(); idx < uint32(len(c.resourceMap.ids)) {
+					a.Resource = c.resourceMap.ids[idx]
+				}
+				if s, ok := at.typedValue.(valStringID); ok {
+					a.Value = valString(str(stringPoolRef(s)))
+				}
+				e.Attributes = append(e.Attributes, a)
+			}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			case d.Root == nil:
+				d.Root = e
+			default:
+				return nil, fmt.Errorf("Multiple root elements")
+			}
+			stack = append(stack, e)
+		case *xmlEndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("Unbalanced end element")
+			}
+			stack = stack[:len(stack)-1]
+		case *xmlCData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				text := NewText(str(chunk.data))
+				if len(pending) > 0 {
+					text.Namespaces, pending = pending, []Namespace{}
+				}
+				parent.Children = append(parent.Children, text)
+			}
+		}
+	}
+	if d.Root == nil {
+		return nil, fmt.Errorf("No root element")
+	}
+	return d, nil
+}
+
+// Encode encodes the document to the binary Android XML format.
+func (d *Document) Encode() []byte {
+	return d.tree().encode()
+}
+
+// XML returns the document as an XML string, as returned by Decode.
+func (d *Document) XML() string {
+	return d.tree().toXmlString()
+}
+
+// resourceName is an attribute name string mapped to a resource identifier.
+type resourceName struct {
+	name string
+	id   uint32
+}
+
+// treeBuilder builds the string pool and chunks of an xmlTree.
+type treeBuilder struct {
+	tree    *xmlTree
+	strings map[string]stringPoolRef
+	line    uint32
+}
+
+// str returns the pool reference of a string that is not an attribute name
+// mapped to a resource.
+func (b *treeBuilder) str(s string) stringPoolRef {
+	if r, ok := b.strings[s]; ok {
+		return r
+	}
+	pool := b.tree.strings
+	r := stringPoolRef{pool, uint32(len(pool.strings))}
+	pool.strings = append(pool.strings, s)
+	pool.ptrs = append(pool.ptrs, len(pool.ptrs))
+	b.strings[s] = r
+	return r
+}
+
+// optStr returns the pool reference of s, or an invalid reference if s is
+// empty.
+func (b *treeBuilder) optStr(s string) stringPoolRef {
+	if s == "" {
+		return invalidStringPoolRef
+	}
+	return b.str(s)
+}
+
+// tree builds the chunk tree of the document. Attribute names that map to
+// resources are placed first in the string pool, sorted by resource
+// identifier, with the resource map holding their identifiers.
+func (d *Document) tree() *xmlTree {
+	tree := &xmlTree{strings: &stringPool{}, resourceMap: &xmlResourceMap{}}
+	tree.strings.setRoot(tree)
+	tree.resourceMap.setRoot(tree)
+	if d.UTF8 {
+		tree.strings.flags |= stringPoolUTF8Flag
+	}
+
+	resources := map[resourceName]bool{}
+	var collect func(e *Element)
+	collect = func(e *Element) {
+		for _, a := range e.Attributes {
+			if a.Resource != 0 {
+				resources[resourceName{a.Name, a.Resource}] = true
+			}
+		}
+		for _, c := range e.Children {
+			collect(c)
+		}
+	}
+	if d.Root != nil {
+		collect(d.Root)
+	}
+	sorted := make([]resourceName, 0, len(resources))
+	for r := range resources {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		return a.id < b.id || (a.id == b.id && a.name < b.name)
+	})
+	resourceRefs := map[resourceName]stringPoolRef{}
+	for i, r := range sorted {
+		tree.strings.strings = append(tree.strings.strings, r.name)
+		tree.strings.ptrs = append(tree.strings.ptrs, i)
+		tree.resourceMap.ids = append(tree.resourceMap.ids, r.id)
+		resourceRefs[r] = stringPoolRef{tree.strings, uint32(i)}
+	}
+
+	b := &treeBuilder{tree: tree, strings: map[string]stringPoolRef{}}
+	for _, ns := range d.Namespaces {
+		b.line++
+		tree.chunks = append(tree.chunks, &xmlStartNamespace{
+			lineNumber:      b.line,
+			comment:         invalidStringPoolRef,
+			namespacePrefix: b.str(ns.Prefix),
+			namespaceURI:    b.str(ns.URI),
+		})
+	}
+	var add func(e *Element)
+	add = func(e *Element) {
+		for _, ns := range e.Namespaces {
+			b.line++
+			tree.chunks = append(tree.chunks, &xmlStartNamespace{
+				lineNumber:      b.line,
+				comment:         invalidStringPoolRef,
+				namespacePrefix: b.str(ns.Prefix),
+				namespaceURI:    b.str(ns.URI),
+			})
+		}
+		b.line++
+		if e.Name == "" {
+			tree.chunks = append(tree.chunks, &xmlCData{
+				lineNumber: b.line,
+				comment:    invalidStringPoolRef,
+				data:       b.str(e.Text),
+				typedValue: valNull(0),
+			})
+			b.endNamespaces(e.Namespaces)
+			return
+		}
+		start := &xmlStartElement{
+			lineNumber: b.line,
+			comment:    invalidStringPoolRef,
+			namespace:  b.optStr(e.Namespace),
+			name:       b.str(e.Name),
+		}
+		for _, a := range e.Attributes {
+			raw := b.optStr(a.Raw)
+			if s, ok := a.Value.(valString); ok && a.Raw == "" {
+				raw = b.str(string(s)) // aapt stores string values as raw values too.
+			}
+			at := xmlAttribute{
+				namespace:  b.optStr(a.Namespace),
+				rawValue:   raw,
+				typedValue: b.value(a.Value),
+			}
+			if a.Resource != 0 {
+				at.name = resourceRefs[resourceName{a.Name, a.Resource}]
+			} else {
+				at.name = b.str(a.Name)
+			}
+			start.attributes = append(start.attributes, at)
+		}
+		sort.Stable(attributesByResourceId{start.attributes, tree})
+		tree.chunks = append(tree.chunks, start)
+		for _, c := range e.Children {
+			add(c)
+		}
+		tree.chunks = append(tree.chunks, &xmlEndElement{
+			lineNumber: b.line,
+			comment:    invalidStringPoolRef,
+			namespace:  start.namespace,
+			name:       start.name,
+		})
+		b.endNamespaces(e.Namespaces)
+	}
+	if d.Root != nil {
+		add(d.Root)
+	}
+	b.endNamespaces(d.Namespaces)
+	for _, c := range tree.chunks {
+		c.setRoot(tree)
+	}
+	return tree
+}
+
+// endNamespaces appends the chunks ending the namespaces l, in reverse order.
+func (b *treeBuilder) endNamespaces(l []Namespace) {
+	for i := len(l) - 1; i >= 0; i-- {
+		b.tree.chunks = append(b.tree.chunks, &xmlEndNamespace{
+			lineNumber:      b.line,
+			comment:         invalidStringPoolRef,
+			namespacePrefix: b.str(l[i].Prefix),
+			namespaceURI:    b.str(l[i].URI),
+		})
+	}
+}
+
+// value returns the typed value to encode for v.
+func (b *treeBuilder) value(v Value) typedValue {
+	switch v := v.(type) {
+	case valString:
+		return valStringID(b.str(string(v)))
+	case typedValue:
+		return v
+	default:
+		return valNull(0)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, fn := range []string{
+		"testdata/manifest1.binxml",
+		"testdata/manifest2.binxml",
+		"testdata/manifest3.binxml",
+		"testdata/manifest4.binxml",
+		"testdata/manifest5.binxml",
+		"testdata/manifest6.binxml",
+		"testdata/manifest7.binxml",
+	} {
+		originalData, err := ioutil.ReadFile(fn)
+		assert.For(ctx, "%v", fn).ThatError(err).Succeeded()
+		original, err := decodeXmlTree(bytes.NewReader(originalData))
+		assert.For(ctx, "%v", fn).ThatError(err).Succeeded()
+
+		doc, err := DecodeDocument(ctx, originalData)
+		assert.For(ctx, "%v decode", fn).ThatError(err).Succeeded()
+		encoded := doc.Encode()
+		tree, err := decodeXmlTree(bytes.NewReader(encoded))
+		assert.For(ctx, "%v re-decode", fn).ThatError(err).Succeeded()
+		assert.For(ctx, "%v xml", fn).ThatString(tree.toXmlString()).Equals(original.toXmlString())
+
+		again, err := DecodeDocument(ctx, encoded)
+		assert.For(ctx, "%v decode encoded", fn).ThatError(err).Succeeded()
+		assert.For(ctx, "%v document", fn).That(again).DeepEquals(doc)
+	}
+}
+
+func TestDocumentEncode(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, utf8 := range []bool{false, true} {
+		// UTF-8 string lengths are limited to 0x7fff bytes.
+		long := strings.Repeat("long string ", 3000)
+		if utf8 {
+			long = long[:1000]
+		}
+		doc := &Document{
+			Namespaces: []Namespace{{Prefix: "android", URI: AndroidNamespace}},
+			UTF8:       utf8,
+			Root: &Element{
+				Name: "manifest",
+				Attributes: []*Attribute{
+					{Name: "package", Value: StringValue("com.example.ünïcode")},
+					{Namespace: AndroidNamespace, Name: "versionCode", Resource: 0x0101021b, Value: IntValue(42)},
+				},
+				Children: []*Element{{
+					Name: "application",
+					Attributes: []*Attribute{
+						{Namespace: AndroidNamespace, Name: "label", Resource: 0x01010001, Value: ReferenceValue(0x7f010000)},
+						{Namespace: AndroidNamespace, Name: "debuggable", Resource: 0x0101000f, Value: BoolValue(true)},
+						{Name: "name", Value: StringValue(long)},
+					},
+					Children: []*Element{NewText("text")},
+				}},
+			},
+		}
+		data := doc.Encode()
+		got, err := DecodeDocument(ctx, data)
+		assert.For(ctx, "decode").ThatError(err).Succeeded()
+		assert.For(ctx, "package").ThatString(got.Root.Attribute("", "package").Value).Equals("com.example.ünïcode")
+		app := got.Root.Child("application")
+		assert.For(ctx, "application").That(app).IsNotNil()
+		// Attributes are sorted by resource identifier.
+		assert.For(ctx, "first attribute").ThatString(app.Attributes[0].Name).Equals("label")
+		assert.For(ctx, "debuggable").ThatString(app.Attribute(AndroidNamespace, "debuggable").Value).Equals("true")
+		assert.For(ctx, "resource").That(app.Attribute(AndroidNamespace, "debuggable").Resource).Equals(uint32(0x0101000f))
+		assert.For(ctx, "non resource name").That(app.Attribute("", "name").Resource).Equals(uint32(0))
+		assert.For(ctx, "long").ThatString(app.Attribute("", "name").Value).Equals(long)
+		assert.For(ctx, "text").ThatString(app.Children[0].Text).Equals("text")
+		assert.For(ctx, "utf8").That(got.UTF8).Equals(utf8)
+
+		xml, err := Decode(ctx, data)
+		assert.For(ctx, "xml").ThatError(err).Succeeded()
+		assert.For(ctx, "xml").ThatString(xml).Contains(`android:versionCode="42"`)
+	}
+}
+
+func TestDocumentNestedNamespaces(t *testing.T) {
+	ctx := log.Testing(t)
+	const tools = "http://schemas.android.com/tools"
+	doc := &Document{
+		Namespaces: []Namespace{{Prefix: "android", URI: AndroidNamespace}},
+		Root: &Element{
+			Name: "manifest",
+			Children: []*Element{{
+				Name:       "application",
+				Namespaces: []Namespace{{Prefix: "tools", URI: tools}},
+				Attributes: []*Attribute{
+					{Namespace: tools, Name: "ignore", Raw: "all", Value: StringValue("all")},
+				},
+				Children: []*Element{
+					{Name: "activity"},
+					{Namespaces: []Namespace{{Prefix: "tools", URI: tools}}, Text: "text"},
+				},
+			}},
+		},
+	}
+	starts, ends := 0, 0
+	for _, c := range doc.tree().chunks {
+		switch c.(type) {
+		case *xmlStartNamespace:
+			starts++
+		case *xmlEndNamespace:
+			ends++
+		}
+	}
+	assert.For(ctx, "namespace ends").That(ends).Equals(starts)
+	data := doc.Encode()
+	got, err := DecodeDocument(ctx, data)
+	assert.For(ctx, "decode").ThatError(err).Succeeded()
+	assert.For(ctx, "document").That(got).DeepEquals(doc)
+	again, err := DecodeDocument(ctx, got.Encode())
+	assert.For(ctx, "decode encoded").ThatError(err).Succeeded()
+	assert.For(ctx, "round trip").That(again).DeepEquals(doc)
+}
+
+func TestDocumentStringPool(t *testing.T) {
+	doc := &Document{
+		Namespaces: []Namespace{{Prefix: "android", URI: AndroidNamespace}},
+		Root: &Element{
+			Name: "manifest",
+			Attributes: []*Attribute{
+				{Namespace: AndroidNamespace, Name: "versionCode", Resource: 0x0101021b, Value: IntValue(42)},
+			},
+		},
+	}
+	count := 0
+	for _, s := range doc.tree().strings.strings {
+		if s == "versionCode" {
+			count++
+		}
+	}
+	// Resource attribute names are only held by the resource mapped strings.
+	assert.To(t).For("versionCode strings").That(count).Equals(1)
+}
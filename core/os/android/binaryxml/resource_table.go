@@ -0,0 +1,493 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+const (
+	resTableEntryComplex = 0x0001
+	resTableTypeSparse   = 0x01
+	noEntry              = 0xffffffff
+	// maxReferenceDepth limits the references followed by Resolve.
+	maxReferenceDepth = 16
+)
+
+// Densities of ResourceConfig.
+const (
+	DensityDefault = 0
+	DensityMedium  = 160
+	DensityAny     = 0xfffe
+	DensityNone    = 0xffff
+)
+
+// ResourceTable is a decoded resources.arsc resource table.
+type ResourceTable struct {
+	Packages []*ResourcePackage
+	strings  *stringPool
+}
+
+// ResourcePackage is a package of resources in a ResourceTable.
+type ResourcePackage struct {
+	ID    uint32
+	Name  string
+	Types []*ResourceType
+}
+
+// ResourceType holds the resources of a type, such as string or drawable.
+type ResourceType struct {
+	ID   uint8
+	Name string
+	// Flags are the configuration change flags of each entry.
+	Flags []uint32
+	// Configs are the entries for each configuration.
+	Configs []*ResourceTypeConfig
+}
+
+// ResourceTypeConfig holds the entries of a type for one configuration.
+type ResourceTypeConfig struct {
+	Config  ResourceConfig
+	Entries map[uint16]*ResourceEntry
+}
+
+// ResourceEntry is a resource value in a configuration.
+type ResourceEntry struct {
+	Key string
+	// Value is the value of the entry, or nil for complex (bag) entries.
+	Value Value
+}
+
+// ResourceConfig is the device configuration a resource value applies to.
+// Only the commonly used parts of the configuration are decoded.
+type ResourceConfig struct {
+	MCC        uint16
+	MNC        uint16
+	Language   string
+	Country    string
+	Density    uint16
+	SDKVersion uint16
+	// IsDefault is true if the configuration applies to all devices.
+	IsDefault bool
+}
+
+// ResourceValue is a value of a resource in a configuration.
+type ResourceValue struct {
+	Config ResourceConfig
+	Value  Value
+}
+
+// resChunk is a chunk of a resource table, split into its header (following
+// the common chunk header) and data.
+type resChunk struct {
+	ty     uint16
+	header []byte
+	data   []byte
+}
+
+// splitChunks splits the data into its sequence of chunks.
+func splitChunks(data []byte) ([]resChunk, error) {
+	out := []resChunk{}
+	for len(data) > 0 {
+		c, size, err := readChunk(data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+		data = data[size:]
+	}
+	return out, nil
+}
+
+// readChunk reads the chunk at the start of data, returning the chunk and its
+// size in bytes.
+func readChunk(data []byte) (resChunk, uint32, error) {
+	if len(data) < 8 {
+		return resChunk{}, 0, fmt.Errorf("Truncated chunk header")
+	}
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	ty, headerSize, size := r.Uint16(), uint32(r.Uint16()), r.Uint32()
+	if headerSize < 8 || headerSize > size || size > uint32(len(data)) {
+		return resChunk{}, 0, fmt.Errorf("Invalid chunk size %d (header %d) for chunk type 0x%x", size, headerSize, ty)
+	}
+	return resChunk{ty, data[8:headerSize], data[headerSize:size]}, size, nil
+}
+
+func decodeStringPool(c resChunk) (*stringPool, error) {
+	if c.ty != resStringPoolType {
+		return nil, fmt.Errorf("Expected string pool chunk, got type 0x%x", c.ty)
+	}
+	pool := &stringPool{}
+	return pool, pool.decode(c.header, c.data)
+}
+
+// DecodeResourceTable decodes a resources.arsc resource table.
+func DecodeResourceTable(ctx context.Context, data []byte) (*ResourceTable, error) {
+	t, err := decodeResourceTable(data)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Decoding resource table")
+	}
+	return t, nil
+}
+
+func decodeResourceTable(data []byte) (*ResourceTable, error) {
+	chunks, err := splitChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) != 1 || chunks[0].ty != resTableType {
+		return nil, fmt.Errorf("Not a resource table")
+	}
+	if chunks, err = splitChunks(chunks[0].data); err != nil {
+		return nil, err
+	}
+	t := &ResourceTable{}
+	for _, c := range chunks {
+		switch c.ty {
+		case resStringPoolType:
+			if t.strings, err = decodeStringPool(c); err != nil {
+				return nil, err
+			}
+		case resTablePackageType:
+			if t.strings == nil {
+				return nil, fmt.Errorf("Package found before the value string pool")
+			}
+			p, err := t.decodePackage(c)
+			if err != nil {
+				return nil, err
+			}
+			t.Packages = append(t.Packages, p)
+		}
+	}
+	return t, nil
+}
+
+func (t *ResourceTable) decodePackage(c resChunk) (*ResourcePackage, error) {
+	r := endian.Reader(bytes.NewReader(c.header), device.LittleEndian)
+	p := &ResourcePackage{ID: r.Uint32()}
+	name := make([]uint16, 128)
+	for i := range name {
+		name[i] = r.Uint16()
+	}
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	p.Name = strings.TrimRight(string(utf16.Decode(name)), "\x00")
+	typeStringsOffset := r.Uint32()
+	r.Uint32() // lastPublicType
+	keyStringsOffset := r.Uint32()
+	r.Uint32() // lastPublicKey
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	// typeIdOffset was added to the end of the header in later versions.
+	typeIDOffset := uint32(0)
+	if len(c.header) >= 4+256+4*5 {
+		typeIDOffset = r.Uint32()
+	}
+
+	// The string pools are located by offsets from the start of the chunk.
+	headerSize := uint32(len(c.header) + 8)
+	pool := func(offset uint32, what string) (*stringPool, error) {
+		if offset < headerSize || offset-headerSize >= uint32(len(c.data)) {
+			return nil, fmt.Errorf("Package %v has invalid %v offset %d", p.Name, what, offset)
+		}
+		chunk, _, err := readChunk(c.data[offset-headerSize:])
+		if err != nil {
+			return nil, err
+		}
+		return decodeStringPool(chunk)
+	}
+	typeStrings, err := pool(typeStringsOffset, "type strings")
+	if err != nil {
+		return nil, err
+	}
+	keyStrings, err := pool(keyStringsOffset, "key strings")
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := splitChunks(c.data)
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[uint8]*ResourceType{}
+	typeOf := func(id uint8) *ResourceType {
+		ty, ok := types[id]
+		if !ok {
+			ty = &ResourceType{ID: id}
+			if idx := int(id) - 1 - int(typeIDOffset); idx >= 0 && idx < len(typeStrings.strings) {
+				ty.Name = typeStrings.strings[idx]
+			}
+			types[id] = ty
+			p.Types = append(p.Types, ty)
+		}
+		return ty
+	}
+
+	for _, c := range chunks {
+		switch c.ty {
+		case resTableTypeSpecType:
+			r := endian.Reader(bytes.NewReader(c.header), device.LittleEndian)
+			ty := typeOf(r.Uint8())
+			r.Uint8()  // res0
+			r.Uint16() // res1
+			ty.Flags = make([]uint32, r.Uint32())
+			r = endian.Reader(bytes.NewReader(c.data), device.LittleEndian)
+			for i := range ty.Flags {
+				ty.Flags[i] = r.Uint32()
+			}
+			if err := r.Error(); err != nil {
+				return nil, err
+			}
+		case resTableTypeType:
+			id, config, err := t.decodeType(c, keyStrings)
+			if err != nil {
+				return nil, err
+			}
+			ty := typeOf(id)
+			ty.Configs = append(ty.Configs, config)
+		}
+	}
+	return p, nil
+}
+
+// decodeConfig decodes the ResTable_config structure.
+func decodeConfig(data []byte) ResourceConfig {
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	size := r.Uint32()
+	c := ResourceConfig{
+		MCC:      r.Uint16(),
+		MNC:      r.Uint16(),
+		Language: decodeLocalePart(r, 'a'),
+		Country:  decodeLocalePart(r, '0'),
+	}
+	r.Uint8() // orientation
+	r.Uint8() // touchscreen
+	c.Density = r.Uint16()
+	r.Uint32() // input
+	r.Uint32() // screen size
+	c.SDKVersion = r.Uint16()
+
+	c.IsDefault = true
+	if int(size) > len(data) {
+		size = uint32(len(data))
+	}
+	for _, b := range data[4:size] {
+		if b != 0 {
+			c.IsDefault = false
+		}
+	}
+	return c
+}
+
+// decodeLocalePart decodes a language or country code, which is either two
+// characters or three 5-bit values packed with the high bit set. Packed values
+// are offsets from base, which is 'a' for languages and '0' for countries.
+func decodeLocalePart(r binary.Reader, base byte) string {
+	a, b := r.Uint8(), r.Uint8()
+	switch {
+	case a == 0:
+		return ""
+	case a&0x80 != 0:
+		return string([]byte{base + b&0x1f, base + (b>>5 | (a&0x3)<<3), base + (a>>2)&0x1f})
+	default:
+		return string([]byte{a, b})
+	}
+}
+
+// decodeType decodes a ResTable_type chunk, returning the type identifier and
+// its entries for the configuration.
+func (t *ResourceTable) decodeType(c resChunk, keyStrings *stringPool) (uint8, *ResourceTypeConfig, error) {
+	r := endian.Reader(bytes.NewReader(c.header), device.LittleEndian)
+	id := r.Uint8()
+	flags := r.Uint8()
+	r.Uint16() // reserved
+	count := r.Uint32()
+	entriesStart := r.Uint32()
+	if err := r.Error(); err != nil {
+		return 0, nil, err
+	}
+	config := &ResourceTypeConfig{
+		Config:  decodeConfig(c.header[12:]),
+		Entries: map[uint16]*ResourceEntry{},
+	}
+	headerSize := uint32(len(c.header) + 8)
+	if entriesStart < headerSize || entriesStart-headerSize > uint32(len(c.data)) {
+		return 0, nil, fmt.Errorf("Invalid entries start %d", entriesStart)
+	}
+	entries := c.data[entriesStart-headerSize:]
+
+	offsets := map[uint16]uint32{}
+	r = endian.Reader(bytes.NewReader(c.data), device.LittleEndian)
+	for i := uint32(0); i < count; i++ {
+		if flags&resTableTypeSparse != 0 {
+			idx := r.Uint16()
+			offsets[idx] = uint32(r.Uint16()) * 4
+		} else if offset := r.Uint32(); offset != noEntry {
+			offsets[uint16(i)] = offset
+		}
+	}
+	if err := r.Error(); err != nil {
+		return 0, nil, err
+	}
+
+	values := &xmlTree{strings: t.strings}
+	for idx, offset := range offsets {
+		if offset+8 > uint32(len(entries)) {
+			return 0, nil, fmt.Errorf("Invalid entry offset %d", offset)
+		}
+		r := endian.Reader(bytes.NewReader(entries[offset:]), device.LittleEndian)
+		size := r.Uint16()
+		entryFlags := r.Uint16()
+		key := r.Uint32()
+		e := &ResourceEntry{}
+		if key < uint32(len(keyStrings.strings)) {
+			e.Key = keyStrings.strings[key]
+		}
+		if entryFlags&resTableEntryComplex == 0 {
+			if offset+uint32(size) > uint32(len(entries)) {
+				return 0, nil, fmt.Errorf("Invalid entry size %d", size)
+			}
+			r := endian.Reader(bytes.NewReader(entries[offset+uint32(size):]), device.LittleEndian)
+			v, err := decodeValue(r, values)
+			if err != nil {
+				return 0, nil, err
+			}
+			if s, ok := v.(valStringID); ok {
+				e.Value = valString(stringPoolRef(s).get())
+			} else {
+				e.Value = v
+			}
+		}
+		config.Entries[idx] = e
+	}
+	return id, config, nil
+}
+
+// Lookup returns the values of the resource with the given identifier for
+// each configuration, without following references.
+func (t *ResourceTable) Lookup(id uint32) []ResourceValue {
+	pkgID, typeID, entryID := id>>24, uint8(id>>16), uint16(id)
+	out := []ResourceValue{}
+	for _, p := range t.Packages {
+		if p.ID != pkgID {
+			continue
+		}
+		for _, ty := range p.Types {
+			if ty.ID != typeID {
+				continue
+			}
+			for _, c := range ty.Configs {
+				if e, ok := c.Entries[entryID]; ok && e.Value != nil {
+					out = append(out, ResourceValue{c.Config, e.Value})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Name returns the name of the resource as "package:type/key", or an empty
+// string if the resource is not in the table.
+func (t *ResourceTable) Name(id uint32) string {
+	pkgID, typeID, entryID := id>>24, uint8(id>>16), uint16(id)
+	for _, p := range t.Packages {
+		if p.ID != pkgID {
+			continue
+		}
+		for _, ty := range p.Types {
+			if ty.ID != typeID {
+				continue
+			}
+			for _, c := range ty.Configs {
+				if e, ok := c.Entries[entryID]; ok {
+					return fmt.Sprintf("%v:%v/%v", p.Name, ty.Name, e.Key)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Resolve returns the values of the resource with the given identifier for
+// each configuration, following references to other resources.
+func (t *ResourceTable) Resolve(id uint32) []ResourceValue {
+	return t.resolve(id, 0)
+}
+
+func (t *ResourceTable) resolve(id uint32, depth int) []ResourceValue {
+	if depth > maxReferenceDepth {
+		return nil
+	}
+	out := []ResourceValue{}
+	for _, v := range t.Lookup(id) {
+		ref, ok := v.Value.(valReference)
+		if !ok {
+			out = append(out, v)
+			continue
+		}
+		for _, r := range t.resolve(uint32(ref), depth+1) {
+			if !v.Config.IsDefault {
+				r.Config = v.Config
+			}
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ResolveString returns the string value of the resource with the given
+// identifier, preferring the default configuration and otherwise one without
+// a language.
+func (t *ResourceTable) ResolveString(id uint32) (string, bool) {
+	var best *ResourceValue
+	for _, v := range t.Resolve(id) {
+		v := v
+		if _, ok := v.Value.(valString); !ok {
+			continue
+		}
+		switch {
+		case v.Config.IsDefault:
+			return v.Value.String(), true
+		case best == nil, best.Config.Language != "" && v.Config.Language == "":
+			best = &v
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Value.String(), true
+}
+
+// ParseReference parses a resource reference in the form returned by Decode,
+// such as "@0x7f0b0001".
+func ParseReference(s string) (uint32, bool) {
+	var id uint32
+	if _, err := fmt.Sscanf(s, "@0x%x", &id); err != nil || fmt.Sprintf("@0x%x", id) != s {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,243 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/binary"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+const (
+	testStringType   = 0x7f010000
+	testDrawableType = 0x7f020000
+)
+
+// testEntry is an entry of a test resource table type chunk.
+type testEntry struct {
+	key   uint32
+	value typedValue
+}
+
+func encodeTestPool(strs ...string) []byte {
+	return (&stringPool{strings: strs}).encode()
+}
+
+func encodeTestType(id uint8, language string, density uint16, entries []*testEntry) []byte {
+	const configSize = 28
+	return encodeChunk(resTableTypeType, func(w binary.Writer) {
+		w.Uint8(id)
+		w.Uint8(0)  // flags
+		w.Uint16(0) // reserved
+		w.Uint32(uint32(len(entries)))
+		w.Uint32(uint32(8 + 12 + configSize + 4*len(entries)))
+		w.Uint32(configSize)
+		w.Uint16(0) // mcc
+		w.Uint16(0) // mnc
+		if language != "" {
+			w.Data([]byte(language))
+		} else {
+			w.Uint16(0)
+		}
+		w.Uint16(0) // country
+		w.Uint8(0)  // orientation
+		w.Uint8(0)  // touchscreen
+		w.Uint16(density)
+		w.Uint32(0) // input
+		w.Uint32(0) // screen size
+		w.Uint32(0) // version
+	}, func(w binary.Writer) {
+		offset := uint32(0)
+		for _, e := range entries {
+			if e == nil {
+				w.Uint32(noEntry)
+			} else {
+				w.Uint32(offset)
+				offset += 16
+			}
+		}
+		for _, e := range entries {
+			if e != nil {
+				w.Uint16(8) // size
+				w.Uint16(0) // flags
+				w.Uint32(e.key)
+				e.value.encode(w)
+			}
+		}
+	})
+}
+
+// encodeTestTable encodes a resource table with a single package. Type
+// identifiers are offset by typeIDOffset.
+func encodeTestTable(values *stringPool, typeIDOffset uint8) []byte {
+	str := func(s string) typedValue { return valStringID(values.ref(s)) }
+	// The key strings are placed before the type strings, so that they are
+	// only found by the header offsets.
+	const headerSize = 8 + 4 + 256 + 4*5
+	keyStrings := encodeTestPool("app_name", "label", "unused", "icon")
+	typeStrings := encodeTestPool("string", "drawable")
+	pkg := encodeChunk(resTablePackageType, func(w binary.Writer) {
+		w.Uint32(0x7f)
+		name := utf16.Encode([]rune("com.example"))
+		for i := 0; i < 128; i++ {
+			if i < len(name) {
+				w.Uint16(name[i])
+			} else {
+				w.Uint16(0)
+			}
+		}
+		w.Uint32(uint32(headerSize + len(keyStrings))) // typeStrings
+		w.Uint32(0)                                    // lastPublicType
+		w.Uint32(headerSize)                           // keyStrings
+		w.Uint32(0)                                    // lastPublicKey
+		w.Uint32(uint32(typeIDOffset))
+	}, func(w binary.Writer) {
+		w.Data(keyStrings)
+		w.Data(typeStrings)
+		w.Data(encodeChunk(resTableTypeSpecType, func(w binary.Writer) {
+			w.Uint8(1 + typeIDOffset)
+			w.Uint8(0)
+			w.Uint16(0)
+			w.Uint32(3)
+		}, func(w binary.Writer) {
+			w.Uint32(0)
+			w.Uint32(0)
+			w.Uint32(0)
+		}))
+		w.Data(encodeTestType(1+typeIDOffset, "", 0, []*testEntry{
+			{0, str("Example")},
+			{1, valReference(testStringType)},
+			nil,
+		}))
+		w.Data(encodeTestType(1+typeIDOffset, "fr", 0, []*testEntry{
+			{0, str("Exemple")},
+		}))
+		w.Data(encodeTestType(2+typeIDOffset, "", 160, []*testEntry{
+			{3, str("res/mipmap-mdpi/icon.png")},
+		}))
+		w.Data(encodeTestType(2+typeIDOffset, "", 480, []*testEntry{
+			{3, str("res/mipmap-xxhdpi/icon.png")},
+		}))
+	})
+	return encodeChunk(resTableType, func(w binary.Writer) {
+		w.Uint32(1) // packageCount
+	}, func(w binary.Writer) {
+		w.Data(values.encode())
+		w.Data(pkg)
+	})
+}
+
+func TestResourceTable(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, utf8 := range []bool{false, true} {
+		ctx := log.V{"utf8": utf8}.Bind(ctx)
+		values := &stringPool{}
+		if utf8 {
+			values.flags = stringPoolUTF8Flag
+		}
+		table, err := DecodeResourceTable(ctx, encodeTestTable(values, 0))
+		if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+			continue
+		}
+		assert.For(ctx, "packages").That(len(table.Packages)).Equals(1)
+		pkg := table.Packages[0]
+		assert.For(ctx, "package id").That(pkg.ID).Equals(uint32(0x7f))
+		assert.For(ctx, "package name").That(pkg.Name).Equals("com.example")
+		assert.For(ctx, "types").That(len(pkg.Types)).Equals(2)
+		assert.For(ctx, "type name").That(pkg.Types[0].Name).Equals("string")
+		assert.For(ctx, "type flags").That(len(pkg.Types[0].Flags)).Equals(3)
+		assert.For(ctx, "configs").That(len(pkg.Types[0].Configs)).Equals(2)
+		assert.For(ctx, "language").That(pkg.Types[0].Configs[1].Config.Language).Equals("fr")
+
+		assert.For(ctx, "name").That(table.Name(testStringType + 1)).Equals("com.example:string/label")
+		assert.For(ctx, "missing name").That(table.Name(testStringType + 2)).Equals("")
+
+		label, ok := table.ResolveString(testStringType)
+		assert.For(ctx, "app_name").That(ok).Equals(true)
+		assert.For(ctx, "app_name").That(label).Equals("Example")
+		label, ok = table.ResolveString(testStringType + 1)
+		assert.For(ctx, "label").That(ok).Equals(true)
+		assert.For(ctx, "label").That(label).Equals("Example")
+		_, ok = table.ResolveString(testStringType + 2)
+		assert.For(ctx, "unused").That(ok).Equals(false)
+
+		labels := table.Resolve(testStringType + 1)
+		assert.For(ctx, "label values").That(len(labels)).Equals(2)
+
+		icons := table.Lookup(testDrawableType)
+		if assert.For(ctx, "icons").That(len(icons)).Equals(2) {
+			assert.For(ctx, "mdpi density").That(icons[0].Config.Density).Equals(uint16(160))
+			assert.For(ctx, "mdpi").That(icons[0].Value.String()).Equals("res/mipmap-mdpi/icon.png")
+			assert.For(ctx, "xxhdpi density").That(icons[1].Config.Density).Equals(uint16(480))
+			assert.For(ctx, "xxhdpi").That(icons[1].Value.String()).Equals("res/mipmap-xxhdpi/icon.png")
+		}
+	}
+}
+
+func TestResourceTableTypeIDOffset(t *testing.T) {
+	ctx := log.Testing(t)
+	table, err := DecodeResourceTable(ctx, encodeTestTable(&stringPool{}, 2))
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	types := table.Packages[0].Types
+	assert.For(ctx, "types").That(len(types)).Equals(2)
+	assert.For(ctx, "type id").That(types[0].ID).Equals(uint8(3))
+	assert.For(ctx, "type name").That(types[0].Name).Equals("string")
+	assert.For(ctx, "name").That(table.Name(testStringType + 0x20000 + 1)).Equals("com.example:string/label")
+}
+
+func TestDecodeLocalePart(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		data     []byte
+		base     byte
+		expected string
+	}{
+		{[]byte{0, 0}, 'a', ""},
+		{[]byte{'f', 'r'}, 'a', "fr"},
+		{[]byte{'C', 'A'}, '0', "CA"},
+		{[]byte{0xad, 0x05}, 'a', "fil"},
+		{[]byte{0xa4, 0x24}, '0', "419"},
+	} {
+		r := endian.Reader(bytes.NewReader(test.data), device.LittleEndian)
+		assert.For(ctx, "%v", test.expected).That(decodeLocalePart(r, test.base)).Equals(test.expected)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		str string
+		id  uint32
+		ok  bool
+	}{
+		{"@0x7f010000", 0x7f010000, true},
+		{"@0x1", 1, true},
+		{"My App", 0, false},
+		{"@string/app_name", 0, false},
+		{"@0x7f01zz", 0, false},
+	} {
+		id, ok := ParseReference(test.str)
+		assert.For(ctx, "%v ok", test.str).That(ok).Equals(test.ok)
+		assert.For(ctx, "%v id", test.str).That(id).Equals(test.id)
+	}
+}
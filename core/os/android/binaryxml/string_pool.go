@@ -53,7 +53,7 @@ func (r stringPoolRef) get() string {
 	if r.isValid() && int(r.idx) < len(r.sp.ptrs) {
 		return r.sp.strings[r.sp.ptrs[r.idx]]
 	}
-	return fmt.Sprintf("Resource<0x%x>", r)
+	return fmt.Sprintf("Resource<0x%x>", r.idx)
 }
 
 // See:
@@ -66,10 +66,12 @@ type stringPool struct {
 	ptrs    []int // ptrs maps indices in stringPoolRefs to indices in the raw strings array.
 }
 
-func (c *stringPool) decode(header, data []byte) error {
-	const sortedFlag = 1 << 0
-	const utf8Flag = 1 << 8
+const (
+	stringPoolSortedFlag = 1 << 0
+	stringPoolUTF8Flag   = 1 << 8
+)
 
+func (c *stringPool) decode(header, data []byte) error {
 	// dataOffset is the offset of data relative to the start of the chunk.
 	dataOffset := 8 + uint32(len(header))
 
@@ -78,7 +80,7 @@ func (c *stringPool) decode(header, data []byte) error {
 	styleCount := r.Uint32()
 	c.flags = r.Uint32()
 	stringsStart := r.Uint32() - dataOffset
-	stylesStart := r.Uint32() - dataOffset
+	r.Uint32() // stylesStart
 
 	r = endian.Reader(bytes.NewReader(data), device.LittleEndian)
 	indices := make([]uint32, stringCount)
@@ -92,8 +94,12 @@ func (c *stringPool) decode(header, data []byte) error {
 	for i := range c.strings {
 		offset := stringsStart + indices[i]
 		r = endian.Reader(bytes.NewReader(data[offset:]), device.LittleEndian)
-		if c.flags&utf8Flag != 0 {
-			panic("TODO: UTF8 encoded xml support.")
+		if c.flags&stringPoolUTF8Flag != 0 {
+			decodeLength8(r) // UTF-16 length
+			str := make([]byte, decodeLength8(r))
+			r.Data(str)
+			c.strings[i] = string(str)
+			c.ptrs[i] = i
 		} else {
 			runeCount := decodeLength(r)
 			str := make([]uint16, runeCount)
@@ -104,10 +110,8 @@ func (c *stringPool) decode(header, data []byte) error {
 			c.ptrs[i] = i
 		}
 	}
-	if styleCount > 0 {
-		_ = stylesStart
-		panic("TODO: Style decoding")
-	}
+	// Styles are not decoded, as only the strings are needed to read resource
+	// tables. Pools with styles cannot be encoded.
 
 	return nil
 }
@@ -126,6 +130,16 @@ func utf16EncodeStringPoolEntry(str string) []byte {
 	return b.Bytes()
 }
 
+func utf8EncodeStringPoolEntry(str string) []byte {
+	var b bytes.Buffer
+	w := endian.Writer(&b, device.LittleEndian)
+	encodeLength8(w, uint32(len(utf16.Encode([]rune(str)))))
+	encodeLength8(w, uint32(len(str)))
+	w.Data([]byte(str))
+	w.Uint8(0)
+	return b.Bytes()
+}
+
 func (c *stringPool) encode() []byte {
 	if len(c.styles) > 0 {
 		panic("TODO: implement style encoding support.")
@@ -141,7 +155,11 @@ func (c *stringPool) encode() []byte {
 	}, func(w binary.Writer) {
 		encodedStrings := make([][]byte, len(c.strings))
 		for i, str := range c.strings {
-			encodedStrings[i] = utf16EncodeStringPoolEntry(str)
+			if c.flags&stringPoolUTF8Flag != 0 {
+				encodedStrings[i] = utf8EncodeStringPoolEntry(str)
+			} else {
+				encodedStrings[i] = utf16EncodeStringPoolEntry(str)
+			}
 		}
 
 		// encode indices
@@ -54,6 +54,22 @@ func (v valFloatIn) String() string   { return fmt.Sprintf("%fin", float32(v)) }
 func (v valFloatMm) String() string   { return fmt.Sprintf("%fmm", float32(v)) }
 
 func (v valIntBoolean) String() string { return fmt.Sprintf("%t", bool(v)) }
+
+// valOther is a value of a type without a specific representation, such as
+// colors.
+type valOther struct {
+	ty   valueType
+	data uint32
+}
+
+func (v valOther) String() string {
+	switch v.ty {
+	case typeIntColorARGB8, typeIntColorRGB8, typeIntColorARGB4, typeIntColorRGB4:
+		return fmt.Sprintf("#%08x", v.data)
+	default:
+		return fmt.Sprintf("%v<0x%x>", v.ty, v.data)
+	}
+}
 func (v valNull) String() string {
 	return "null" /* Not actually sure about this: 0 -> undefined, !=0 -> empty */
 }
@@ -148,6 +164,10 @@ func (v valNull) encode(w binary.Writer) {
 	writeTypedValueHeader(w, typeNull)
 	w.Uint32(uint32(v))
 }
+func (v valOther) encode(w binary.Writer) {
+	writeTypedValueHeader(w, v.ty)
+	w.Uint32(v.data)
+}
 
 func writeTypedValueHeader(w binary.Writer, ty valueType) {
 	w.Uint16(8)
@@ -209,7 +229,7 @@ func decodeValue(r binary.Reader, xml *xmlTree) (typedValue, error) {
 	case typeDimension:
 		return decodeDimension(r)
 	default:
-		return nil, fmt.Errorf("Value type %v not implemented", ty)
+		return valOther{ty, r.Uint32()}, nil
 	}
 }
 
@@ -81,6 +81,8 @@ type Device interface {
 	ForceStop(ctx context.Context, pkg string) error
 	// SystemProperty returns the system property in string
 	SystemProperty(ctx context.Context, name string) (string, error)
+	// SystemProperties returns all the system properties by name.
+	SystemProperties(ctx context.Context) (map[string]string, error)
 	// SetSystemProperty sets the system property with the given string value
 	SetSystemProperty(ctx context.Context, name, value string) error
 }
@@ -0,0 +1,38 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inputscript implements resolution-independent scripts of user input
+// that can be played on an Android device.
+//
+// A script is a text file with one step per line. Blank lines and lines
+// starting with '#' are ignored. Coordinates are normalized to the range
+// [0, 1] of the display in its current orientation, and are mapped to the
+// touch-screen of the device when the script is played. The steps are:
+//
+//	wait <duration>           sleeps for the duration, for example 1.5s.
+//	wait frame <n>            waits until the application has drawn n frames.
+//	wait logcat <regexp>      waits for a logcat message matching the regexp,
+//	                          logged after the last tap, swipe or key step,
+//	                          or the start of the script if there is none.
+//	timeout <duration>        limits the time of the following waits on
+//	                          conditions. 0 waits forever, the default.
+//	tap <x> <y>               taps the screen.
+//	swipe <x> <y> <x> <y> [<duration>]
+//	                          swipes across the screen, in 300ms by default.
+//	key <name|code>           sends a key event, for example Back.
+//	end                       ends the script and the trace.
+//
+// Scripts can be created from the raw recordings of gapit trace
+// --record.inputs, and then edited by hand.
+package inputscript
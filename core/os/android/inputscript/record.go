@@ -0,0 +1,115 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// tapDistance is the maximum normalized distance moved by a recorded
+	// touch for it to be converted to a tap instead of a swipe.
+	tapDistance = 0.02
+	// coordPrecision is the precision of converted coordinates.
+	coordPrecision = 1000
+)
+
+// Recording is a raw recording of touch-screen events, as recorded by gapit
+// trace --record.inputs.
+type Recording struct {
+	// Orientation is the display orientation during the recording.
+	Orientation int
+	// MinX, MaxX, MinY and MaxY are the touch-screen dimensions of the
+	// recording device.
+	MinX, MaxX, MinY, MaxY int
+	// Touches are the recorded touch-screen events.
+	Touches []RecordedTouch
+	// End is the time the recording ended, or 0 if the end was not recorded.
+	End time.Duration
+}
+
+// RecordedTouch is a touch-screen event of a Recording.
+type RecordedTouch struct {
+	// Time is the time of the event since the start of the recording.
+	Time time.Duration
+	// Frame is the number of frames drawn at the time of the event, or 0 if
+	// unknown.
+	Frame int
+	// X and Y are the raw touch-screen coordinates.
+	X, Y int
+	// Pressed is true if the screen is being touched.
+	Pressed bool
+}
+
+// Script converts the recording to a script. Each press of the screen is
+// converted to a tap or a swipe, preceded by a wait for the frame of the
+// press, or for the time since the previous press if the frame is unknown.
+func (r Recording) Script() *Script {
+	s := &Script{}
+	last := RecordedTouch{}
+	wait := func(t RecordedTouch) {
+		switch {
+		case t.Frame > last.Frame:
+			s.Steps = append(s.Steps, WaitFrame{t.Frame})
+		case t.Time > last.Time:
+			s.Steps = append(s.Steps, Sleep{(t.Time - last.Time).Round(100 * time.Millisecond)})
+		}
+	}
+
+	var press *RecordedTouch
+	for i, t := range r.Touches {
+		switch {
+		case t.Pressed && press == nil:
+			press = &r.Touches[i]
+		case !t.Pressed && press != nil:
+			wait(*press)
+			fromX, fromY := r.normalize(press.X, press.Y)
+			toX, toY := r.normalize(t.X, t.Y)
+			if math.Hypot(toX-fromX, toY-fromY) <= tapDistance {
+				s.Steps = append(s.Steps, Tap{fromX, fromY})
+			} else {
+				d := (t.Time - press.Time).Round(10 * time.Millisecond)
+				s.Steps = append(s.Steps, Swipe{fromX, fromY, toX, toY, d})
+			}
+			last = RecordedTouch{Time: t.Time, Frame: press.Frame}
+			press = nil
+		}
+	}
+	if r.End > 0 {
+		wait(RecordedTouch{Time: r.End})
+		s.Steps = append(s.Steps, End{})
+	}
+	return s
+}
+
+// normalize returns the normalized display coordinates of the raw
+// touch-screen coordinates.
+func (r Recording) normalize(x, y int) (float64, float64) {
+	nx, ny := fromNatural(r.Orientation, scale(x, r.MinX, r.MaxX), scale(y, r.MinY, r.MaxY))
+	return round(nx), round(ny)
+}
+
+// scale returns v mapped from the range [min, max] to [0, 1].
+func scale(v, min, max int) float64 {
+	if max <= min {
+		return 0
+	}
+	return math.Max(0, math.Min(1, float64(v-min)/float64(max-min)))
+}
+
+func round(v float64) float64 {
+	return math.Floor(v*coordPrecision+0.5) / coordPrecision
+}
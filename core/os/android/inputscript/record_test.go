@@ -0,0 +1,54 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/inputscript"
+)
+
+func TestRecordingScript(t *testing.T) {
+	ctx := log.Testing(t)
+	ms := time.Millisecond
+	r := inputscript.Recording{
+		Orientation: 1,
+		MinX:        0, MaxX: 1000,
+		MinY: 0, MaxY: 2000,
+		Touches: []inputscript.RecordedTouch{
+			// Tap with a small movement.
+			{Time: 1000 * ms, Frame: 50, X: 900, Y: 500, Pressed: true},
+			{Time: 1050 * ms, Frame: 52, X: 901, Y: 505, Pressed: true},
+			{Time: 1100 * ms, Frame: 53, X: 901, Y: 505, Pressed: false},
+			// Swipe without frame statistics.
+			{Time: 2340 * ms, X: 500, Y: 0, Pressed: true},
+			{Time: 2500 * ms, X: 500, Y: 1000, Pressed: true},
+			{Time: 2642 * ms, X: 500, Y: 2000, Pressed: false},
+		},
+		End: 5000 * ms,
+	}
+	s := r.Script()
+	assert.For(ctx, "steps").That(s.Steps).DeepEquals([]inputscript.Step{
+		inputscript.WaitFrame{50},
+		inputscript.Tap{0.25, 0.1},
+		inputscript.Sleep{1200 * ms},
+		inputscript.Swipe{0, 0.5, 1, 0.5, 300 * ms},
+		inputscript.Sleep{2400 * ms},
+		inputscript.End{},
+	})
+}
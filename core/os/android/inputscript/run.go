@@ -0,0 +1,221 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
+)
+
+const (
+	// ErrNoTouchScreen is returned by Run if the touch-screen dimensions of
+	// the device cannot be found.
+	ErrNoTouchScreen = fault.Const("Failed to get touch-screen dimensions")
+	// ErrTimeout is returned by Run if a wait exceeds the timeout.
+	ErrTimeout = fault.Const("Timed out waiting for condition")
+	// ErrLogcatClosed is returned by Run if the logcat stream ends while
+	// waiting for a condition.
+	ErrLogcatClosed = fault.Const("Logcat closed while waiting for condition")
+
+	// swipeInterval is the approximate interval between the touch events of a
+	// swipe.
+	swipeInterval = 50 * time.Millisecond
+)
+
+// frameRE matches the frame statistics logged by the GAPID interceptor.
+var frameRE = regexp.MustCompile("NumFrames:([0-9]+)")
+
+// Device is the device a script is played on.
+type Device interface {
+	// KeyEvent simulates a key-event on the device.
+	KeyEvent(ctx context.Context, key android.KeyCode) error
+	// SendTouch simulates touch-screen press or release.
+	SendTouch(ctx context.Context, deviceID, x, y int, pressed bool)
+	// GetTouchDimensions returns the resolution of the touch sensor.
+	GetTouchDimensions(ctx context.Context) (deviceID, minX, maxX, minY, maxY int, ok bool)
+	// GetScreenDimensions returns the resolution of the display.
+	GetScreenDimensions(ctx context.Context) (orientation, width, height int, ok bool)
+}
+
+type runner struct {
+	d       Device
+	logcat  <-chan android.LogcatMessage
+	frame   int
+	timeout time.Duration
+	touch   touchScreen
+	// since is the time of the last input step, or the start of the script.
+	// Logcat waits only match messages logged after it.
+	since time.Time
+}
+
+// Run plays the script on the device. logcat is the stream of the device's
+// logcat messages, which is used to wait on frames and logcat conditions. The
+// message timestamps must be in the local clock. Run returns true if the
+// script reached an End step.
+func Run(ctx context.Context, d Device, logcat <-chan android.LogcatMessage, s *Script) (bool, error) {
+	r := &runner{d: d, logcat: logcat, since: time.Now()}
+	var ok bool
+	r.touch.deviceID, r.touch.minX, r.touch.maxX, r.touch.minY, r.touch.maxY, ok = d.GetTouchDimensions(ctx)
+	if !ok {
+		return false, log.Err(ctx, ErrNoTouchScreen, "")
+	}
+	if r.touch.orientation, _, _, ok = d.GetScreenDimensions(ctx); !ok {
+		log.W(ctx, "Failed to get screen dimensions, assuming natural orientation")
+	}
+
+	for i, step := range s.Steps {
+		ctx := log.V{"step": i}.Bind(ctx)
+		log.I(ctx, "Input: %v", step)
+		switch step := step.(type) {
+		case Sleep:
+			select {
+			case <-time.After(step.Duration):
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		case WaitFrame:
+			if err := r.wait(ctx, func(*android.LogcatMessage) bool { return r.frame >= step.Frame }); err != nil {
+				return false, log.Errf(ctx, err, "Waiting for frame %d (%d seen)", step.Frame, r.frame)
+			}
+		case WaitLogcat:
+			// Messages logged before the last input step must not satisfy
+			// the wait, even if they have not been consumed yet.
+			since := r.since
+			match := func(m *android.LogcatMessage) bool {
+				return m != nil && !m.Timestamp.Before(since) && step.Pattern.MatchString(m.Message)
+			}
+			if err := r.wait(ctx, match); err != nil {
+				return false, log.Errf(ctx, err, "Waiting for logcat '%v'", step.Pattern)
+			}
+		case Timeout:
+			r.timeout = step.Duration
+		case Tap:
+			r.since = time.Now()
+			x, y := r.touch.point(step.X, step.Y)
+			d.SendTouch(ctx, r.touch.deviceID, x, y, true)
+			d.SendTouch(ctx, r.touch.deviceID, x, y, false)
+		case Swipe:
+			r.since = time.Now()
+			r.swipe(ctx, step)
+		case Key:
+			r.since = time.Now()
+			if err := d.KeyEvent(ctx, step.Code); err != nil {
+				return false, log.Errf(ctx, err, "Sending key %v", step.Code)
+			}
+		case End:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// wait consumes logcat messages until cond returns true. cond is also tested
+// once before any messages are consumed, with a nil message.
+func (r *runner) wait(ctx context.Context, cond func(m *android.LogcatMessage) bool) error {
+	var timeout <-chan time.Time
+	if r.timeout > 0 {
+		timeout = time.After(r.timeout)
+	}
+	var msg *android.LogcatMessage
+	for !cond(msg) {
+		select {
+		case m, ok := <-r.logcat:
+			if !ok {
+				return ErrLogcatClosed
+			}
+			msg = &m
+			r.track(m.Message)
+		case <-timeout:
+			return ErrTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// track updates the current frame from the frame statistics in line.
+func (r *runner) track(line string) {
+	if m := frameRE.FindStringSubmatch(line); m != nil {
+		if frame, err := strconv.Atoi(m[1]); err == nil && frame > r.frame {
+			r.frame = frame
+		}
+	}
+}
+
+func (r *runner) swipe(ctx context.Context, s Swipe) {
+	steps := int(s.Duration / swipeInterval)
+	if steps < 1 {
+		steps = 1
+	}
+	start := time.Now()
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / float64(steps)
+		x, y := r.touch.point(s.FromX+(s.ToX-s.FromX)*f, s.FromY+(s.ToY-s.FromY)*f)
+		if wait := time.Duration(f*float64(s.Duration)) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+		r.d.SendTouch(ctx, r.touch.deviceID, x, y, i < steps)
+	}
+}
+
+// touchScreen maps normalized display coordinates to the touch-screen.
+type touchScreen struct {
+	deviceID               int
+	minX, maxX, minY, maxY int
+	// orientation is the display rotation in quarter turns counter-clockwise
+	// from the natural orientation of the touch-screen.
+	orientation int
+}
+
+// point returns the touch-screen coordinates of the normalized display
+// coordinates.
+func (t touchScreen) point(x, y float64) (int, int) {
+	x, y = toNatural(t.orientation, x, y)
+	return t.minX + int(x*float64(t.maxX-t.minX)+0.5), t.minY + int(y*float64(t.maxY-t.minY)+0.5)
+}
+
+// toNatural rotates normalized display coordinates to the natural
+// orientation.
+func toNatural(orientation int, x, y float64) (float64, float64) {
+	switch orientation & 3 {
+	case 1:
+		return 1 - y, x
+	case 2:
+		return 1 - x, 1 - y
+	case 3:
+		return y, 1 - x
+	}
+	return x, y
+}
+
+// fromNatural is the inverse of toNatural.
+func fromNatural(orientation int, x, y float64) (float64, float64) {
+	switch orientation & 3 {
+	case 1:
+		return y, 1 - x
+	case 2:
+		return 1 - x, 1 - y
+	case 3:
+		return 1 - y, x
+	}
+	return x, y
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
+	"github.com/google/gapid/core/os/android/inputscript"
+)
+
+// device is a fake inputscript.Device that records the events sent to it.
+type device struct {
+	orientation int
+	events      []string
+	// logcat receives the next message of released shortly after every touch
+	// release, as if logged by the application in response.
+	logcat   chan<- android.LogcatMessage
+	released []string
+}
+
+func (d *device) KeyEvent(ctx context.Context, key android.KeyCode) error {
+	d.events = append(d.events, fmt.Sprintf("key %v", key))
+	return nil
+}
+
+func (d *device) SendTouch(ctx context.Context, deviceID, x, y int, pressed bool) {
+	d.events = append(d.events, fmt.Sprintf("touch%d %d,%d %v", deviceID, x, y, pressed))
+	if !pressed && len(d.released) > 0 {
+		line := d.released[0]
+		d.released = d.released[1:]
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			d.logcat <- message(line)
+		}()
+	}
+}
+
+func (d *device) GetTouchDimensions(ctx context.Context) (deviceID, minX, maxX, minY, maxY int, ok bool) {
+	return 2, 0, 1000, 0, 2000, true
+}
+
+func (d *device) GetScreenDimensions(ctx context.Context) (orientation, width, height int, ok bool) {
+	if d.orientation%2 == 0 {
+		return d.orientation, 1080, 1920, true
+	}
+	return d.orientation, 1920, 1080, true
+}
+
+// message returns a logcat message with the text, logged now.
+func message(text string) android.LogcatMessage {
+	return android.LogcatMessage{Timestamp: time.Now(), Message: text}
+}
+
+func parse(ctx context.Context, s string) *inputscript.Script {
+	script, err := inputscript.Parse(strings.NewReader(s))
+	assert.For(ctx, "parse").ThatError(err).Succeeded()
+	return script
+}
+
+func TestRun(t *testing.T) {
+	ctx := log.Testing(t)
+	logcat := make(chan android.LogcatMessage, 10)
+	logcat <- message("I/GAPID: NumFrames:10 NumDrawsPerFrame:3")
+	logcat <- message("I/Game: Loading")
+	logcat <- message("I/GAPID: NumFrames:20 NumDrawsPerFrame:3")
+
+	d := &device{logcat: logcat, released: []string{"I/Game: Level loaded"}}
+	end, err := inputscript.Run(ctx, d, logcat, parse(ctx, `
+		wait frame 15
+		tap 0.25 0.5
+		wait logcat Level \w+
+		key Back
+		swipe 0 0 1 1 10ms
+		end
+		key Home`))
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+	assert.For(ctx, "end").That(end).Equals(true)
+	assert.For(ctx, "events").ThatSlice(d.events).Equals([]string{
+		"touch2 250,1000 true",
+		"touch2 250,1000 false",
+		"key Back",
+		"touch2 0,0 true",
+		"touch2 1000,2000 false",
+	})
+}
+
+func TestRunStaleLogcat(t *testing.T) {
+	ctx := log.Testing(t)
+	logcat := make(chan android.LogcatMessage, 10)
+	logcat <- android.LogcatMessage{Timestamp: time.Now().Add(-time.Second), Message: "I/Game: Level loaded"}
+	close(logcat)
+
+	// The matching message was logged before the script started.
+	_, err := inputscript.Run(ctx, &device{}, logcat, parse(ctx, `
+		wait 1ms
+		wait logcat Level \w+`))
+	assert.For(ctx, "stale").ThatError(err).HasCause(inputscript.ErrLogcatClosed)
+}
+
+func TestRunLogcatDuringWait(t *testing.T) {
+	ctx := log.Testing(t)
+	logcat := make(chan android.LogcatMessage, 10)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		logcat <- message("I/Game: Level loaded")
+	}()
+
+	// The matching message is logged during the sleep, before the logcat wait
+	// starts, but after the script started.
+	_, err := inputscript.Run(ctx, &device{}, logcat, parse(ctx, `
+		timeout 1s
+		wait 50ms
+		wait logcat Level \w+`))
+	assert.For(ctx, "err").ThatError(err).Succeeded()
+}
+
+func TestRunOrientation(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		orientation int
+		expected    string
+	}{
+		{0, "touch2 250,200 true"},
+		{1, "touch2 900,500 true"},
+		{2, "touch2 750,1800 true"},
+		{3, "touch2 100,1500 true"},
+	} {
+		d := &device{orientation: test.orientation}
+		_, err := inputscript.Run(ctx, d, nil, parse(ctx, "tap 0.25 0.1"))
+		assert.For(ctx, "err").ThatError(err).Succeeded()
+		assert.For(ctx, "orientation %d", test.orientation).That(d.events[0]).Equals(test.expected)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ctx := log.Testing(t)
+	logcat := make(chan android.LogcatMessage, 10)
+	logcat <- message("I/GAPID: NumFrames:10 NumDrawsPerFrame:3")
+
+	_, err := inputscript.Run(ctx, &device{}, logcat, parse(ctx, "timeout 10ms\nwait frame 11"))
+	assert.For(ctx, "timeout").ThatError(err).HasCause(inputscript.ErrTimeout)
+
+	close(logcat)
+	_, err = inputscript.Run(ctx, &device{}, logcat, parse(ctx, "wait logcat never"))
+	assert.For(ctx, "closed").ThatError(err).HasCause(inputscript.ErrLogcatClosed)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = inputscript.Run(ctx, &device{}, make(chan android.LogcatMessage), parse(ctx, "wait logcat never"))
+	assert.For(ctx, "cancelled").ThatError(err).HasCause(context.DeadlineExceeded)
+}
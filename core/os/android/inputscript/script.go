@@ -0,0 +1,238 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/os/android"
+)
+
+// DefaultSwipeDuration is the duration of swipes that do not specify one.
+const DefaultSwipeDuration = 300 * time.Millisecond
+
+// Script is a sequence of input steps.
+type Script struct {
+	Steps []Step
+}
+
+// Step is a single step of a Script.
+type Step interface {
+	// String returns the step in the script syntax.
+	String() string
+}
+
+// Sleep is a step that waits for a fixed duration.
+type Sleep struct {
+	Duration time.Duration
+}
+
+// WaitFrame is a step that waits until the application has drawn at least
+// Frame frames.
+type WaitFrame struct {
+	Frame int
+}
+
+// WaitLogcat is a step that waits for a logcat line matching Pattern.
+type WaitLogcat struct {
+	Pattern *regexp.Regexp
+}
+
+// Timeout is a step that limits the time of the following waits on
+// conditions. A zero Duration waits forever.
+type Timeout struct {
+	Duration time.Duration
+}
+
+// Tap is a step that taps the screen at the normalized coordinates.
+type Tap struct {
+	X, Y float64
+}
+
+// Swipe is a step that swipes across the screen between the normalized
+// coordinates.
+type Swipe struct {
+	FromX, FromY float64
+	ToX, ToY     float64
+	Duration     time.Duration
+}
+
+// Key is a step that sends a key event.
+type Key struct {
+	Code android.KeyCode
+}
+
+// End is a step that ends the script and the trace.
+type End struct{}
+
+func (s Sleep) String() string      { return fmt.Sprintf("wait %v", s.Duration) }
+func (s WaitFrame) String() string  { return fmt.Sprintf("wait frame %d", s.Frame) }
+func (s WaitLogcat) String() string { return fmt.Sprintf("wait logcat %v", s.Pattern) }
+func (s Timeout) String() string    { return fmt.Sprintf("timeout %v", s.Duration) }
+func (s Tap) String() string        { return fmt.Sprintf("tap %v %v", coord(s.X), coord(s.Y)) }
+func (s Key) String() string        { return fmt.Sprintf("key %v", s.Code) }
+func (s End) String() string        { return "end" }
+
+func (s Swipe) String() string {
+	return fmt.Sprintf("swipe %v %v %v %v %v",
+		coord(s.FromX), coord(s.FromY), coord(s.ToX), coord(s.ToY), s.Duration)
+}
+
+func coord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// String returns the script in the script syntax.
+func (s *Script) String() string {
+	buf := bytes.Buffer{}
+	for _, step := range s.Steps {
+		fmt.Fprintln(&buf, step)
+	}
+	return buf.String()
+}
+
+// Ends returns true if the script contains an End step.
+func (s *Script) Ends() bool {
+	for _, step := range s.Steps {
+		if _, ok := step.(End); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a script from r.
+func Parse(r io.Reader) (*Script, error) {
+	s := &Script{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		step, err := parseStep(text)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		s.Steps = append(s.Steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseStep(text string) (Step, error) {
+	fields := strings.Fields(text)
+	args := fields[1:]
+	switch fields[0] {
+	case "wait":
+		switch {
+		case len(args) == 1:
+			d, err := parseDuration(args[0])
+			return Sleep{d}, err
+		case len(args) == 2 && args[0] == "frame":
+			frame, err := strconv.Atoi(args[1])
+			if err != nil || frame < 0 {
+				return nil, fmt.Errorf("Invalid frame '%v'", args[1])
+			}
+			return WaitFrame{frame}, nil
+		case len(args) >= 2 && args[0] == "logcat":
+			// The pattern is the rest of the line, which may contain spaces.
+			pattern := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text[len("wait"):]), "logcat"))
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid logcat pattern: %v", err)
+			}
+			return WaitLogcat{re}, nil
+		}
+		return nil, fmt.Errorf("Expected 'wait <duration>', 'wait frame <n>' or 'wait logcat <regexp>'")
+	case "timeout":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("Expected 'timeout <duration>'")
+		}
+		d, err := parseDuration(args[0])
+		return Timeout{d}, err
+	case "tap":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Expected 'tap <x> <y>'")
+		}
+		c, err := parseCoords(args)
+		if err != nil {
+			return nil, err
+		}
+		return Tap{c[0], c[1]}, nil
+	case "swipe":
+		if len(args) != 4 && len(args) != 5 {
+			return nil, fmt.Errorf("Expected 'swipe <x> <y> <x> <y> [<duration>]'")
+		}
+		c, err := parseCoords(args[:4])
+		if err != nil {
+			return nil, err
+		}
+		d := DefaultSwipeDuration
+		if len(args) == 5 {
+			if d, err = parseDuration(args[4]); err != nil {
+				return nil, err
+			}
+		}
+		return Swipe{c[0], c[1], c[2], c[3], d}, nil
+	case "key":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("Expected 'key <name|code>'")
+		}
+		if code, ok := android.KeyCode_value[args[0]]; ok {
+			return Key{android.KeyCode(code)}, nil
+		}
+		code, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("Unknown key '%v'", args[0])
+		}
+		return Key{android.KeyCode(code)}, nil
+	case "end":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("Expected 'end'")
+		}
+		return End{}, nil
+	}
+	return nil, fmt.Errorf("Unknown step '%v'", fields[0])
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid duration '%v'", s)
+	}
+	return d, nil
+}
+
+func parseCoords(args []string) ([]float64, error) {
+	out := make([]float64, len(args))
+	for i, a := range args {
+		v, err := strconv.ParseFloat(a, 64)
+		if err != nil || v < 0 || v > 1 {
+			return nil, fmt.Errorf("Invalid coordinate '%v', expected a value between 0 and 1", a)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
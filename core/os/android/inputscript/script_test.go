@@ -0,0 +1,88 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inputscript_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android"
+	"github.com/google/gapid/core/os/android/inputscript"
+)
+
+const script = `
+# Wait for the main menu.
+timeout 1m
+wait logcat MainMenu: ready
+wait frame 100
+tap 0.5 0.75
+wait 1.5s
+swipe 0.1 0.5 0.9 0.5
+swipe 0 0 1 1 1s
+key Back
+key 66
+end
+`
+
+func TestParse(t *testing.T) {
+	ctx := log.Testing(t)
+	s, err := inputscript.Parse(strings.NewReader(script))
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	expected := []inputscript.Step{
+		inputscript.Timeout{time.Minute},
+		inputscript.WaitLogcat{regexp.MustCompile("MainMenu: ready")},
+		inputscript.WaitFrame{100},
+		inputscript.Tap{0.5, 0.75},
+		inputscript.Sleep{1500 * time.Millisecond},
+		inputscript.Swipe{0.1, 0.5, 0.9, 0.5, inputscript.DefaultSwipeDuration},
+		inputscript.Swipe{0, 0, 1, 1, time.Second},
+		inputscript.Key{android.KeyCode_Back},
+		inputscript.Key{android.KeyCode_Enter},
+		inputscript.End{},
+	}
+	assert.For(ctx, "steps").That(s.Steps).DeepEquals(expected)
+	assert.For(ctx, "ends").That(s.Ends()).Equals(true)
+
+	again, err := inputscript.Parse(strings.NewReader(s.String()))
+	assert.For(ctx, "reparse err").ThatError(err).Succeeded()
+	assert.For(ctx, "reparse").That(again).DeepEquals(s)
+}
+
+func TestParseErrors(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		script string
+		err    string
+	}{
+		{"jump", "Line 1: Unknown step 'jump'"},
+		{"\nwait", "Line 2: Expected 'wait <duration>', 'wait frame <n>' or 'wait logcat <regexp>'"},
+		{"wait soon", "Line 1: Invalid duration 'soon'"},
+		{"wait frame -1", "Line 1: Invalid frame '-1'"},
+		{"tap 0.5", "Line 1: Expected 'tap <x> <y>'"},
+		{"tap 1.5 0", "Line 1: Invalid coordinate '1.5', expected a value between 0 and 1"},
+		{"swipe 0 0 1 1 fast", "Line 1: Invalid duration 'fast'"},
+		{"key Nope", "Line 1: Unknown key 'Nope'"},
+		{"# comment\n\nend now", "Line 3: Expected 'end'"},
+	} {
+		_, err := inputscript.Parse(strings.NewReader(test.script))
+		assert.For(ctx, "%q", test.script).ThatError(err).HasMessage(test.err)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"context"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+)
+
+const (
+	ErrNotManifest   = fault.Const("Document is not an Android manifest")
+	ErrNoApplication = fault.Const("Manifest has no application element")
+)
+
+// Resource identifiers of the android attributes set by the Editor.
+const (
+	attrName              = 0x01010003
+	attrDebuggable        = 0x0101000f
+	attrValue             = 0x01010024
+	attrExtractNativeLibs = 0x010104ea
+)
+
+// Editor edits a binary AndroidManifest.xml, as found in APKs.
+type Editor struct {
+	doc *binaryxml.Document
+}
+
+// NewEditor returns an Editor for the binary AndroidManifest.xml data.
+func NewEditor(ctx context.Context, data []byte) (*Editor, error) {
+	doc, err := binaryxml.DecodeDocument(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Root.Name != "manifest" {
+		return nil, log.Err(ctx, ErrNotManifest, "")
+	}
+	hasAndroidNamespace := false
+	for _, ns := range doc.Namespaces {
+		hasAndroidNamespace = hasAndroidNamespace || ns.URI == binaryxml.AndroidNamespace
+	}
+	if !hasAndroidNamespace {
+		doc.Namespaces = append(doc.Namespaces, binaryxml.Namespace{
+			Prefix: "android",
+			URI:    binaryxml.AndroidNamespace,
+		})
+	}
+	return &Editor{doc}, nil
+}
+
+// Document returns the edited document, for edits not covered by the
+// Editor's methods.
+func (e *Editor) Document() *binaryxml.Document { return e.doc }
+
+// Encode returns the edited manifest in binary XML form.
+func (e *Editor) Encode() []byte { return e.doc.Encode() }
+
+// Manifest returns the parsed edited manifest.
+func (e *Editor) Manifest(ctx context.Context) (Manifest, error) {
+	return Parse(ctx, e.doc.XML())
+}
+
+func androidAttribute(name string, id uint32, value binaryxml.Value) *binaryxml.Attribute {
+	return &binaryxml.Attribute{
+		Namespace: binaryxml.AndroidNamespace,
+		Name:      name,
+		Resource:  id,
+		Value:     value,
+	}
+}
+
+func androidName(e *binaryxml.Element) string {
+	if a := e.Attribute(binaryxml.AndroidNamespace, "name"); a != nil && a.Value != nil {
+		return a.Value.String()
+	}
+	return ""
+}
+
+func (e *Editor) application(ctx context.Context) (*binaryxml.Element, error) {
+	if app := e.doc.Root.Child("application"); app != nil {
+		return app, nil
+	}
+	return nil, log.Err(ctx, ErrNoApplication, "")
+}
+
+// SetDebuggable sets the android:debuggable attribute of the application.
+func (e *Editor) SetDebuggable(ctx context.Context, debuggable bool) error {
+	app, err := e.application(ctx)
+	if err != nil {
+		return err
+	}
+	app.SetAttribute(androidAttribute("debuggable", attrDebuggable, binaryxml.BoolValue(debuggable)))
+	return nil
+}
+
+// SetExtractNativeLibs sets the android:extractNativeLibs attribute of the
+// application. If false, native libraries are loaded directly from the APK,
+// which requires them to be stored uncompressed and page aligned.
+func (e *Editor) SetExtractNativeLibs(ctx context.Context, extract bool) error {
+	app, err := e.application(ctx)
+	if err != nil {
+		return err
+	}
+	app.SetAttribute(androidAttribute("extractNativeLibs", attrExtractNativeLibs, binaryxml.BoolValue(extract)))
+	return nil
+}
+
+// AddPermission adds a uses-permission element for the permission, if the
+// manifest does not already use it.
+func (e *Editor) AddPermission(name string) {
+	root := e.doc.Root
+	for _, p := range root.ChildrenNamed("uses-permission") {
+		if androidName(p) == name {
+			return
+		}
+	}
+	permission := &binaryxml.Element{
+		Name:       "uses-permission",
+		Attributes: []*binaryxml.Attribute{androidAttribute("name", attrName, binaryxml.StringValue(name))},
+	}
+	// Permissions are conventionally declared before the application.
+	for i, c := range root.Children {
+		if c.Name == "application" {
+			root.Children = append(root.Children[:i], append([]*binaryxml.Element{permission}, root.Children[i:]...)...)
+			return
+		}
+	}
+	root.Children = append(root.Children, permission)
+}
+
+// SetMetaData sets the value of the application meta-data with the given
+// name, adding the meta-data element if it does not exist.
+func (e *Editor) SetMetaData(ctx context.Context, name, value string) error {
+	app, err := e.application(ctx)
+	if err != nil {
+		return err
+	}
+	valueAttr := androidAttribute("value", attrValue, binaryxml.StringValue(value))
+	for _, m := range app.ChildrenNamed("meta-data") {
+		if androidName(m) == name {
+			m.SetAttribute(valueAttr)
+			return nil
+		}
+	}
+	app.Children = append(app.Children, &binaryxml.Element{
+		Name: "meta-data",
+		Attributes: []*binaryxml.Attribute{
+			androidAttribute("name", attrName, binaryxml.StringValue(name)),
+			valueAttr,
+		},
+	})
+	return nil
+}
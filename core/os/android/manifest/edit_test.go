@@ -0,0 +1,102 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+	"github.com/google/gapid/core/os/android/manifest"
+)
+
+func binaryManifest() []byte {
+	name := func(s string) *binaryxml.Attribute {
+		return &binaryxml.Attribute{
+			Namespace: binaryxml.AndroidNamespace,
+			Name:      "name",
+			Resource:  0x01010003,
+			Value:     binaryxml.StringValue(s),
+		}
+	}
+	doc := &binaryxml.Document{
+		Namespaces: []binaryxml.Namespace{{Prefix: "android", URI: binaryxml.AndroidNamespace}},
+		Root: &binaryxml.Element{
+			Name: "manifest",
+			Attributes: []*binaryxml.Attribute{
+				{Name: "package", Value: binaryxml.StringValue("com.example.app")},
+			},
+			Children: []*binaryxml.Element{
+				{Name: "uses-permission", Attributes: []*binaryxml.Attribute{name("android.permission.CAMERA")}},
+				{
+					Name: "application",
+					Children: []*binaryxml.Element{{
+						Name:       "activity",
+						Attributes: []*binaryxml.Attribute{name("MainActivity")},
+					}},
+				},
+			},
+		},
+	}
+	return doc.Encode()
+}
+
+func TestEditor(t *testing.T) {
+	ctx := log.Testing(t)
+	e, err := manifest.NewEditor(ctx, binaryManifest())
+	assert.For(ctx, "NewEditor").ThatError(err).Succeeded()
+
+	assert.For(ctx, "SetDebuggable").ThatError(e.SetDebuggable(ctx, true)).Succeeded()
+	assert.For(ctx, "SetExtractNativeLibs").ThatError(e.SetExtractNativeLibs(ctx, false)).Succeeded()
+	e.AddPermission("android.permission.INTERNET")
+	e.AddPermission("android.permission.CAMERA")
+	e.AddPermission("android.permission.INTERNET")
+	assert.For(ctx, "SetMetaData").ThatError(e.SetMetaData(ctx, "com.example.trace", "old")).Succeeded()
+	assert.For(ctx, "SetMetaData").ThatError(e.SetMetaData(ctx, "com.example.trace", "new")).Succeeded()
+
+	// Edits must survive encoding.
+	e, err = manifest.NewEditor(ctx, e.Encode())
+	assert.For(ctx, "NewEditor encoded").ThatError(err).Succeeded()
+	m, err := e.Manifest(ctx)
+	assert.For(ctx, "Manifest").ThatError(err).Succeeded()
+	assert.For(ctx, "Package").ThatString(m.Package).Equals("com.example.app")
+	assert.For(ctx, "Debuggable").That(m.Application.Debuggable).Equals(true)
+	assert.For(ctx, "Permissions").ThatSlice(m.Permissions).Equals([]manifest.Permission{
+		{Name: "android.permission.CAMERA"},
+		{Name: "android.permission.INTERNET"},
+	})
+	assert.For(ctx, "MetaData").ThatSlice(m.Application.MetaData).Equals([]manifest.MetaData{
+		{Name: "com.example.trace", Value: "new"},
+	})
+	assert.For(ctx, "Activities").ThatSlice(m.Application.Activities).IsLength(1)
+
+	app := e.Document().Root.Child("application")
+	extract := app.Attribute(binaryxml.AndroidNamespace, "extractNativeLibs")
+	assert.For(ctx, "extractNativeLibs").ThatString(extract.Value).Equals("false")
+	assert.For(ctx, "extractNativeLibs resource").That(extract.Resource).Equals(uint32(0x010104ea))
+}
+
+func TestEditorNotManifest(t *testing.T) {
+	ctx := log.Testing(t)
+	doc := &binaryxml.Document{Root: &binaryxml.Element{Name: "resources"}}
+	_, err := manifest.NewEditor(ctx, doc.Encode())
+	assert.For(ctx, "NewEditor").ThatError(err).HasCause(manifest.ErrNotManifest)
+
+	doc = &binaryxml.Document{Root: &binaryxml.Element{Name: "manifest"}}
+	e, err := manifest.NewEditor(ctx, doc.Encode())
+	assert.For(ctx, "NewEditor").ThatError(err).Succeeded()
+	assert.For(ctx, "SetDebuggable").ThatError(e.SetDebuggable(ctx, true)).HasCause(manifest.ErrNoApplication)
+}
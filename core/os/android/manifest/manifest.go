@@ -62,6 +62,15 @@ func Parse(ctx context.Context, s string) (Manifest, error) {
 type Application struct {
 	Activities []Activity `xml:"activity"`
 	Debuggable bool       `xml:"debuggable,attr"`
+	MetaData   []MetaData `xml:"meta-data"`
+	Label      string     `xml:"label,attr"`
+	Icon       string     `xml:"icon,attr"`
+}
+
+// MetaData represents a name-value pair declared in an Application.
+type MetaData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
 }
 
 // Activity represents an activity declared in an Application.
@@ -66,6 +66,8 @@ func TestParseManifest(_t *testing.T) {
 		VersionCode: 11,
 		VersionName: "1.0",
 		Application: manifest.Application{
+			Label: "@string/app_name",
+			Icon:  "@drawable/ic_launcher",
 			Activities: []manifest.Activity{
 				{
 					Name: "BobsGame",
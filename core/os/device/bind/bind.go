@@ -18,7 +18,10 @@ import (
 	"context"
 	"sync"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/host"
+	"github.com/google/gapid/core/vulkan/loader"
 )
 
 var (
@@ -32,8 +35,31 @@ func Host(ctx context.Context) Device {
 	defer hostMutex.Unlock()
 	if hostDev == nil {
 		hostDev = &Simple{
-			To: host.Instance(ctx),
+			To: hostInstance(ctx),
 		}
 	}
 	return hostDev
 }
+
+// hostInstance returns the host device instance, using the installed Vulkan
+// drivers to describe the Vulkan support if the host device query could not.
+func hostInstance(ctx context.Context) *device.Instance {
+	i := host.Instance(ctx)
+	old := i.GetConfiguration().GetDrivers().GetVulkan()
+	if len(old.GetPhysicalDevices()) > 0 {
+		return i
+	}
+	vk := loader.Driver(ctx)
+	if vk == nil || (old != nil && len(vk.PhysicalDevices) == 0) {
+		return i
+	}
+	out := proto.Clone(i).(*device.Instance)
+	if out.Configuration == nil {
+		out.Configuration = &device.Configuration{}
+	}
+	if out.Configuration.Drivers == nil {
+		out.Configuration.Drivers = &device.Drivers{}
+	}
+	out.Configuration.Drivers.Vulkan = vk
+	return out
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/shell"
+)
+
+// glxinfoTimeout is the longest time glxinfo is given to run, so that a hung
+// X server does not block the host device query.
+const glxinfoTimeout = 5 * time.Second
+
+// addGLXInfo adds the OpenGL driver information reported by glxinfo to the
+// device, if the device information does not already hold it. This is the
+// case when the OpenGL context could not be created in-process.
+// Nothing is added if glxinfo is not installed or there is no X display, as on
+// a headless machine.
+func addGLXInfo(ctx context.Context, d *device.Instance) {
+	c := d.GetConfiguration()
+	if c == nil || c.GetDrivers().GetOpenGL() != nil {
+		return
+	}
+	ctx, cancel := task.WithTimeout(ctx, glxinfoTimeout)
+	defer cancel()
+	buf := &bytes.Buffer{}
+	if err := shell.Command("glxinfo").Capture(buf, nil).Run(ctx); err != nil {
+		log.D(ctx, "Couldn't run glxinfo: %v", err)
+		return
+	}
+	gl := parseGLXInfo(buf.String())
+	if gl == nil {
+		return
+	}
+	if c.Drivers == nil {
+		c.Drivers = &device.Drivers{}
+	}
+	c.Drivers.OpenGL = gl
+	if c.Hardware == nil {
+		c.Hardware = &device.Hardware{}
+	}
+	if c.Hardware.GPU == nil {
+		c.Hardware.GPU = &device.GPU{}
+	}
+	if c.Hardware.GPU.Name == "" {
+		c.Hardware.GPU.Name = gl.Renderer
+	}
+	if c.Hardware.GPU.Vendor == "" {
+		c.Hardware.GPU.Vendor = gl.Vendor
+	}
+}
+
+// parseGLXInfo parses the output of glxinfo, returning nil if no OpenGL
+// renderer is reported. The core profile version and extensions are preferred
+// over the compatibility profile ones, as replay uses a core profile context.
+func parseGLXInfo(s string) *device.OpenGLDriver {
+	values := map[string]string{}
+	extensions := map[string][]string{}
+	list := ""
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if list != "" && strings.HasPrefix(line, " ") {
+			for _, e := range strings.Split(line, ",") {
+				if e = strings.TrimSpace(e); e != "" {
+					extensions[list] = append(extensions[list], e)
+				}
+			}
+			continue
+		}
+		list = ""
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		if strings.HasSuffix(key, "extensions") && value == "" {
+			list = key
+		} else {
+			values[key] = value
+		}
+	}
+
+	renderer := values["OpenGL renderer string"]
+	if renderer == "" {
+		return nil
+	}
+	out := &device.OpenGLDriver{
+		Renderer:   renderer,
+		Vendor:     values["OpenGL vendor string"],
+		Version:    values["OpenGL core profile version string"],
+		Extensions: extensions["OpenGL core profile extensions"],
+	}
+	if out.Version == "" {
+		out.Version = values["OpenGL version string"]
+	}
+	if len(out.Extensions) == 0 {
+		out.Extensions = extensions["OpenGL extensions"]
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+const glxInfo = `name of display: :0
+display: :0  screen: 0
+direct rendering: Yes
+server glx vendor string: SGI
+client glx extensions:
+    GLX_ARB_context_flush_control, GLX_ARB_create_context,
+    GLX_ARB_create_context_profile
+Extended renderer info (GLX_MESA_query_renderer):
+    Vendor: Intel Open Source Technology Center (0x8086)
+    Device: Mesa DRI Intel(R) HD Graphics 620 (Kaby Lake GT2)  (0x5916)
+    Version: 18.0.5
+OpenGL vendor string: Intel Open Source Technology Center
+OpenGL renderer string: Mesa DRI Intel(R) HD Graphics 620 (Kaby Lake GT2)
+OpenGL core profile version string: 4.5 (Core Profile) Mesa 18.0.5
+OpenGL core profile shading language version string: 4.50
+OpenGL core profile context flags: (none)
+OpenGL core profile profile mask: core profile
+OpenGL core profile extensions:
+    GL_3DFX_texture_compression_FXT1, GL_AMD_conservative_depth,
+    GL_ARB_ES2_compatibility, GL_KHR_debug,
+    GL_KHR_no_error
+
+OpenGL version string: 3.0 Mesa 18.0.5
+OpenGL shading language version string: 1.30
+OpenGL context flags: (none)
+OpenGL extensions:
+    GL_3DFX_texture_compression_FXT1, GL_AMD_conservative_depth
+
+OpenGL ES profile version string: OpenGL ES 3.2 Mesa 18.0.5
+OpenGL ES profile extensions:
+    GL_OES_EGL_image
+`
+
+func TestParseGLXInfo(t *testing.T) {
+	ctx := log.Testing(t)
+	assert.For(ctx, "glxinfo").That(parseGLXInfo(glxInfo)).DeepEquals(&device.OpenGLDriver{
+		Renderer: "Mesa DRI Intel(R) HD Graphics 620 (Kaby Lake GT2)",
+		Vendor:   "Intel Open Source Technology Center",
+		Version:  "4.5 (Core Profile) Mesa 18.0.5",
+		Extensions: []string{
+			"GL_3DFX_texture_compression_FXT1", "GL_AMD_conservative_depth",
+			"GL_ARB_ES2_compatibility", "GL_KHR_debug", "GL_KHR_no_error",
+		},
+	})
+
+	compat := `OpenGL vendor string: VMware, Inc.
+OpenGL renderer string: llvmpipe (LLVM 5.0, 256 bits)
+OpenGL version string: 3.0 Mesa 18.0.5
+OpenGL extensions:
+    GL_ARB_multisample, GL_EXT_abgr
+`
+	assert.For(ctx, "compatibility").That(parseGLXInfo(compat)).DeepEquals(&device.OpenGLDriver{
+		Renderer:   "llvmpipe (LLVM 5.0, 256 bits)",
+		Vendor:     "VMware, Inc.",
+		Version:    "3.0 Mesa 18.0.5",
+		Extensions: []string{"GL_ARB_multisample", "GL_EXT_abgr"},
+	})
+
+	assert.For(ctx, "headless").That(parseGLXInfo("Error: unable to open display")).IsNil()
+}
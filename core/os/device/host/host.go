@@ -16,6 +16,7 @@ package host
 
 import (
 	"context"
+	"runtime"
 	"sync"
 
 	"github.com/google/gapid/core/os/device"
@@ -29,6 +30,11 @@ var (
 // Instance returns the device information for the host computer running the
 // code.
 func Instance(ctx context.Context) *device.Instance {
-	hostOnce.Do(func() { host = getHostDevice() })
+	hostOnce.Do(func() {
+		host = getHostDevice()
+		if runtime.GOOS == "linux" {
+			addGLXInfo(ctx, &host)
+		}
+	})
 	return &host
 }
@@ -0,0 +1,155 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Difference is a single difference between two device snapshots.
+type Difference struct {
+	// Key identifies the value that differs, such as "OS.Build" or
+	// "Property.ro.build.fingerprint".
+	Key string
+	// A is the value in the first snapshot, or empty if absent.
+	A string
+	// B is the value in the second snapshot, or empty if absent.
+	B string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%v: %q → %q", d.Key, d.A, d.B)
+}
+
+// Diff returns the differences between the snapshots a and b, sorted by key.
+func Diff(a, b *Snapshot) []Difference {
+	va, vb := a.Values(), b.Values()
+	out := []Difference{}
+	for k, v := range va {
+		if vb[k] != v {
+			out = append(out, Difference{Key: k, A: v, B: vb[k]})
+		}
+	}
+	for k, v := range vb {
+		if _, ok := va[k]; !ok {
+			out = append(out, Difference{Key: k, B: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Values returns the snapshot flattened to a map of value keys to values.
+// Lists of extensions and layers are flattened to a key per entry, so that
+// the differences in the lists are reported individually.
+// Empty and zero values are omitted.
+func (s *Snapshot) Values() map[string]string {
+	out := map[string]string{}
+	add := func(k string, v interface{}) {
+		if str := fmt.Sprint(v); str != "" && str != "0" {
+			out[k] = str
+		}
+	}
+
+	for k, v := range s.GetProperties() {
+		out["Property."+k] = v
+	}
+
+	i := s.GetInstance()
+	if i == nil {
+		return out
+	}
+	add("Serial", i.Serial)
+	add("Name", i.Name)
+
+	c := i.Configuration
+	if c == nil {
+		return out
+	}
+	if os := c.OS; os != nil {
+		add("OS.Kind", os.Kind)
+		add("OS.Name", os.Name)
+		add("OS.Build", os.Build)
+		if os.Major != 0 || os.Minor != 0 || os.Point != 0 {
+			add("OS.Version", fmt.Sprintf("%d.%d.%d", os.Major, os.Minor, os.Point))
+		}
+	}
+	if h := c.Hardware; h != nil {
+		add("Hardware.Name", h.Name)
+		if cpu := h.CPU; cpu != nil {
+			add("CPU.Name", cpu.Name)
+			add("CPU.Vendor", cpu.Vendor)
+			add("CPU.Architecture", cpu.Architecture)
+			add("CPU.Cores", cpu.Cores)
+		}
+		if gpu := h.GPU; gpu != nil {
+			add("GPU.Name", gpu.Name)
+			add("GPU.Vendor", gpu.Vendor)
+		}
+	}
+	abis := make([]string, len(c.ABIs))
+	for i, abi := range c.ABIs {
+		abis[i] = abi.Name
+	}
+	add("ABIs", strings.Join(abis, ", "))
+
+	if gl := c.GetDrivers().GetOpenGL(); gl != nil {
+		add("OpenGL.Renderer", gl.Renderer)
+		add("OpenGL.Vendor", gl.Vendor)
+		add("OpenGL.Version", gl.Version)
+		add("OpenGL.UniformBufferAlignment", gl.UniformBufferAlignment)
+		add("OpenGL.MaxTransformFeedbackSeparateAttribs", gl.MaxTransformFeedbackSeparateAttribs)
+		add("OpenGL.MaxTransformFeedbackInterleavedComponents", gl.MaxTransformFeedbackInterleavedComponents)
+		for _, e := range gl.Extensions {
+			add("OpenGL.Extension."+e, "supported")
+		}
+	}
+	if vk := c.GetDrivers().GetVulkan(); vk != nil {
+		for _, l := range vk.Layers {
+			add("Vulkan.Layer."+l.Name, "present")
+			for _, e := range l.Extensions {
+				add("Vulkan.Layer."+l.Name+".Extension."+e, "supported")
+			}
+		}
+		for _, e := range vk.IcdAndImplicitLayerExtensions {
+			add("Vulkan.Extension."+e, "supported")
+		}
+		for i, d := range vk.PhysicalDevices {
+			prefix := fmt.Sprintf("Vulkan.PhysicalDevice[%d].", i)
+			add(prefix+"Name", d.DeviceName)
+			if d.ApiVersion != 0 {
+				add(prefix+"ApiVersion", vulkanVersion(d.ApiVersion))
+			}
+			if d.DriverVersion != 0 {
+				add(prefix+"DriverVersion", fmt.Sprintf("%d (0x%x)", d.DriverVersion, d.DriverVersion))
+			}
+			if d.VendorID != 0 {
+				add(prefix+"VendorID", fmt.Sprintf("0x%x", d.VendorID))
+			}
+			if d.DeviceID != 0 {
+				add(prefix+"DeviceID", fmt.Sprintf("0x%x", d.DeviceID))
+			}
+		}
+	}
+	return out
+}
+
+// vulkanVersion returns the Vulkan version number v as a string of the form
+// "major.minor.patch".
+func vulkanVersion(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d", v>>22, (v>>12)&0x3ff, v&0xfff)
+}
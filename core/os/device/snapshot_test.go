@@ -0,0 +1,89 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package device_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+func snapshot(build, renderer string, extensions []string, props map[string]string) *device.Snapshot {
+	return &device.Snapshot{
+		Instance: &device.Instance{
+			Serial: "ABC123",
+			Name:   "Pixel",
+			Configuration: &device.Configuration{
+				OS: &device.OS{Kind: device.Android, Build: build, Major: 8, Minor: 1},
+				Hardware: &device.Hardware{
+					GPU: &device.GPU{Name: "Adreno 540"},
+				},
+				ABIs: []*device.ABI{device.AndroidARM64v8a, device.AndroidARMv7a},
+				Drivers: &device.Drivers{
+					OpenGL: &device.OpenGLDriver{
+						Renderer:   renderer,
+						Extensions: extensions,
+					},
+					Vulkan: &device.VulkanDriver{
+						PhysicalDevices: []*device.VulkanPhysicalDevice{{
+							ApiVersion: 1<<22 | 0<<12 | 61,
+							VendorID:   0x5143,
+						}},
+					},
+				},
+			},
+		},
+		Properties: props,
+	}
+}
+
+func TestSnapshotValues(t *testing.T) {
+	ctx := log.Testing(t)
+	s := snapshot("OPM1", "Adreno (TM) 540", []string{"GL_KHR_debug"}, map[string]string{"ro.debuggable": "1"})
+	assert.For(ctx, "values").That(s.Values()).DeepEquals(map[string]string{
+		"Serial":                              "ABC123",
+		"Name":                                "Pixel",
+		"OS.Kind":                             "Android",
+		"OS.Build":                            "OPM1",
+		"OS.Version":                          "8.1.0",
+		"GPU.Name":                            "Adreno 540",
+		"ABIs":                                "arm64-v8a, armeabi-v7a",
+		"OpenGL.Renderer":                     "Adreno (TM) 540",
+		"OpenGL.Extension.GL_KHR_debug":       "supported",
+		"Vulkan.PhysicalDevice[0].ApiVersion": "1.0.61",
+		"Vulkan.PhysicalDevice[0].VendorID":   "0x5143",
+		"Property.ro.debuggable":              "1",
+	})
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	ctx := log.Testing(t)
+	a := snapshot("OPM1", "Adreno (TM) 540", []string{"GL_KHR_debug", "GL_EXT_sRGB"},
+		map[string]string{"ro.debuggable": "1", "ro.secure": "0"})
+	b := snapshot("OPM2", "Adreno (TM) 540", []string{"GL_KHR_debug", "GL_OES_EGL_image"},
+		map[string]string{"ro.debuggable": "0", "ro.hardware": "taimen"})
+
+	assert.For(ctx, "same").ThatSlice(device.Diff(a, a)).IsEmpty()
+	assert.For(ctx, "diff").That(device.Diff(a, b)).DeepEquals([]device.Difference{
+		{Key: "OS.Build", A: "OPM1", B: "OPM2"},
+		{Key: "OpenGL.Extension.GL_EXT_sRGB", A: "supported"},
+		{Key: "OpenGL.Extension.GL_OES_EGL_image", B: "supported"},
+		{Key: "Property.ro.debuggable", A: "1", B: "0"},
+		{Key: "Property.ro.hardware", B: "taimen"},
+		{Key: "Property.ro.secure", A: "0"},
+	})
+}
@@ -138,7 +138,13 @@ func (cmd Cmd) Run(ctx context.Context) error {
 	if cmd.Verbosity {
 		log.I(ctx, "Exec: %v", cmd)
 	}
-	process, err := cmd.Target.Start(cmd)
+	var process Process
+	var err error
+	if t, ok := cmd.Target.(ContextTarget); ok {
+		process, err = t.StartContext(ctx, cmd)
+	} else {
+		process, err = cmd.Target.Start(cmd)
+	}
 	if err != nil {
 		return log.From(ctx).Err(err, "Failed to start process")
 	}
@@ -14,9 +14,19 @@
 
 package shell
 
+import "context"
+
 // Target is the interface for an object that supports execution of Commands.
 type Target interface {
 	// Start is invoked to execute the supplied command.
 	// It must return either a Process object that can be used to control the command, or an error.
 	Start(cmd Cmd) (Process, error)
 }
+
+// ContextTarget is a Target that can use the context of the command's Run to
+// start the command.
+type ContextTarget interface {
+	Target
+	// StartContext is invoked by Run in place of Start.
+	StartContext(ctx context.Context, cmd Cmd) (Process, error)
+}
@@ -0,0 +1,367 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/shell"
+)
+
+// ICD describes a Vulkan installable client driver found by its manifest.
+type ICD struct {
+	// Manifest is the path to the driver's JSON manifest.
+	Manifest string
+	// Library is the path or name of the driver's shared library.
+	Library string
+	// APIVersion is the Vulkan version supported by the driver, such as "1.1.70".
+	APIVersion string
+}
+
+// Layer describes a Vulkan layer found by its manifest.
+type Layer struct {
+	// Manifest is the path to the layer's JSON manifest.
+	Manifest string
+	Name     string
+	// Description is the human readable description of the layer.
+	Description string
+	// Library is the path or name of the layer's shared library.
+	Library string
+	// APIVersion is the Vulkan version the layer was written against.
+	APIVersion string
+	// ImplementationVersion is the version of the layer implementation.
+	ImplementationVersion string
+	// Implicit is true if the layer is enabled without being requested.
+	Implicit bool
+	// InstanceExtensions are the instance extensions provided by the layer.
+	InstanceExtensions []string
+	// DeviceExtensions are the device extensions provided by the layer.
+	DeviceExtensions []string
+}
+
+// FindICDs returns the Vulkan drivers installed on the host, found using the
+// same search rules as the Linux Vulkan loader.
+// FindICDs returns nil on other operating systems.
+func FindICDs(ctx context.Context) []ICD {
+	out := []ICD{}
+	for _, path := range manifests(ctx, "icd.d", "VK_ICD_FILENAMES") {
+		m := struct {
+			ICD struct {
+				LibraryPath string `json:"library_path"`
+				APIVersion  string `json:"api_version"`
+			}
+		}{}
+		if !readManifest(ctx, path, &m) || m.ICD.LibraryPath == "" {
+			continue
+		}
+		out = append(out, ICD{
+			Manifest:   path,
+			Library:    m.ICD.LibraryPath,
+			APIVersion: m.ICD.APIVersion,
+		})
+	}
+	return out
+}
+
+// FindLayers returns the implicit and explicit Vulkan layers installed on the
+// host, found using the same search rules as the Linux Vulkan loader.
+// FindLayers returns nil on other operating systems.
+func FindLayers(ctx context.Context) []Layer {
+	type extension struct {
+		Name string `json:"name"`
+	}
+	type layer struct {
+		Name                  string      `json:"name"`
+		Description           string      `json:"description"`
+		LibraryPath           string      `json:"library_path"`
+		APIVersion            string      `json:"api_version"`
+		ImplementationVersion string      `json:"implementation_version"`
+		InstanceExtensions    []extension `json:"instance_extensions"`
+		DeviceExtensions      []extension `json:"device_extensions"`
+	}
+	names := func(l []extension) []string {
+		out := make([]string, len(l))
+		for i, e := range l {
+			out[i] = e.Name
+		}
+		return out
+	}
+
+	out := []Layer{}
+	for _, implicit := range []bool{true, false} {
+		dir, env := "explicit_layer.d", "VK_LAYER_PATH"
+		if implicit {
+			dir, env = "implicit_layer.d", ""
+		}
+		for _, path := range manifests(ctx, dir, env) {
+			m := struct {
+				Layer  *layer  `json:"layer"`
+				Layers []layer `json:"layers"`
+			}{}
+			if !readManifest(ctx, path, &m) {
+				continue
+			}
+			if m.Layer != nil {
+				m.Layers = append(m.Layers, *m.Layer)
+			}
+			for _, l := range m.Layers {
+				out = append(out, Layer{
+					Manifest:              path,
+					Name:                  l.Name,
+					Description:           l.Description,
+					Library:               l.LibraryPath,
+					APIVersion:            l.APIVersion,
+					ImplementationVersion: l.ImplementationVersion,
+					Implicit:              implicit,
+					InstanceExtensions:    names(l.InstanceExtensions),
+					DeviceExtensions:      names(l.DeviceExtensions),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// Driver returns the description of the Vulkan support of the host, or nil if
+// no Vulkan drivers are installed.
+// The layers and implicit layer extensions are found from the installed
+// manifests. The physical devices and the extensions of the drivers are only
+// found if the vulkaninfo tool is installed and the drivers can be loaded,
+// which may not be the case on a headless machine.
+func Driver(ctx context.Context) *device.VulkanDriver {
+	if len(FindICDs(ctx)) == 0 {
+		return nil
+	}
+
+	out := &device.VulkanDriver{}
+	extensions := map[string]bool{}
+	for _, l := range FindLayers(ctx) {
+		out.Layers = append(out.Layers, &device.VulkanLayer{
+			Name:       l.Name,
+			Extensions: l.InstanceExtensions,
+		})
+		if l.Implicit {
+			for _, e := range l.InstanceExtensions {
+				extensions[e] = true
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := runVulkanInfo(ctx, buf); err != nil {
+		log.D(ctx, "Couldn't run vulkaninfo: %v", err)
+	} else {
+		info := ParseVulkanInfo(buf.String())
+		out.PhysicalDevices = info.PhysicalDevices
+		for _, e := range info.IcdAndImplicitLayerExtensions {
+			extensions[e] = true
+		}
+	}
+
+	for e := range extensions {
+		out.IcdAndImplicitLayerExtensions = append(out.IcdAndImplicitLayerExtensions, e)
+	}
+	sort.Strings(out.IcdAndImplicitLayerExtensions)
+	return out
+}
+
+// vulkaninfoTimeout is the longest time vulkaninfo is given to run, so that a
+// hung driver does not block the host device query.
+const vulkaninfoTimeout = 5 * time.Second
+
+// runVulkanInfo runs vulkaninfo, writing its output to buf.
+func runVulkanInfo(ctx context.Context, buf *bytes.Buffer) error {
+	ctx, cancel := task.WithTimeout(ctx, vulkaninfoTimeout)
+	defer cancel()
+	return shell.Command("vulkaninfo").Capture(buf, nil).Run(ctx)
+}
+
+// ParseVulkanInfo parses the output of the vulkaninfo tool, returning the
+// instance extensions and the physical devices. Layers are not parsed.
+func ParseVulkanInfo(s string) *device.VulkanDriver {
+	const (
+		sectionNone = iota
+		sectionInstanceExtensions
+		sectionOther
+		sectionGPU
+	)
+	out := &device.VulkanDriver{}
+	section := sectionNone
+	var gpu *device.VulkanPhysicalDevice
+	seen := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.Trim(trimmed, "=-") == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			// Unindented lines are section headers. The properties of each
+			// physical device are listed after its "GPU<n>" header, split
+			// into further sections.
+			switch {
+			case strings.HasPrefix(line, "GPU") && len(line) > 3 && line[3] >= '0' && line[3] <= '9':
+				section, gpu, seen = sectionGPU, &device.VulkanPhysicalDevice{}, map[string]bool{}
+				out.PhysicalDevices = append(out.PhysicalDevices, gpu)
+			case section == sectionGPU:
+			case strings.HasPrefix(line, "Instance Extensions"):
+				section = sectionInstanceExtensions
+			default:
+				section = sectionOther
+			}
+			continue
+		}
+
+		switch section {
+		case sectionInstanceExtensions:
+			if i := strings.Index(trimmed, ":"); i > 0 && strings.Contains(trimmed, "extension revision") {
+				out.IcdAndImplicitLayerExtensions = append(out.IcdAndImplicitLayerExtensions,
+					strings.TrimSpace(trimmed[:i]))
+			}
+		case sectionGPU:
+			i := strings.Index(trimmed, "=")
+			if i < 0 {
+				continue
+			}
+			key, value := strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:])
+			if seen[key] {
+				continue // Only use the first value, from VkPhysicalDeviceProperties.
+			}
+			seen[key] = true
+			switch key {
+			case "apiVersion":
+				gpu.ApiVersion = parseVersion(value)
+			case "driverVersion":
+				gpu.DriverVersion = parseVersion(value)
+			case "vendorID":
+				gpu.VendorID = parseVersion(value)
+			case "deviceID":
+				gpu.DeviceID = parseVersion(value)
+			case "deviceName":
+				gpu.DeviceName = value
+			}
+		}
+	}
+	return out
+}
+
+// parseVersion parses a number printed by vulkaninfo, which may be followed
+// or preceded by the same value in another format, such as
+// "4198470 (1.1.70)", "0x401046 (1.1.70)" or "1.1.70 (4198470)".
+func parseVersion(s string) uint32 {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(s))
+	for _, f := range fields {
+		if v, err := strconv.ParseUint(f, 0, 32); err == nil {
+			return uint32(v)
+		}
+	}
+	for _, f := range fields {
+		if parts := strings.Split(f, "."); len(parts) == 3 {
+			major, err1 := strconv.ParseUint(parts[0], 10, 10)
+			minor, err2 := strconv.ParseUint(parts[1], 10, 10)
+			patch, err3 := strconv.ParseUint(parts[2], 10, 12)
+			if err1 == nil && err2 == nil && err3 == nil {
+				return uint32(major<<22 | minor<<12 | patch)
+			}
+		}
+	}
+	return 0
+}
+
+// manifests returns the paths of the JSON manifests in the Vulkan loader
+// search directories with the given name. If env is not empty and the
+// environment variable of that name is set, then the variable lists the
+// manifests or directories to use instead.
+func manifests(ctx context.Context, dir, env string) []string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	var dirs []string
+	if list := os.Getenv(env); env != "" && list != "" {
+		dirs = filepath.SplitList(list)
+	} else {
+		for _, d := range searchDirs() {
+			dirs = append(dirs, filepath.Join(d, "vulkan", dir))
+		}
+	}
+
+	out := []string{}
+	for _, d := range dirs {
+		info, err := os.Stat(d)
+		switch {
+		case err != nil:
+			continue
+		case !info.IsDir():
+			out = append(out, d)
+		default:
+			files, err := ioutil.ReadDir(d)
+			if err != nil {
+				log.D(ctx, "Couldn't read Vulkan manifest directory %v: %v", d, err)
+				continue
+			}
+			for _, f := range files {
+				if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+					out = append(out, filepath.Join(d, f.Name()))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// searchDirs returns the directories searched by the Linux Vulkan loader, in
+// order, without the "vulkan/<kind>.d" suffix.
+func searchDirs() []string {
+	env := func(name, def string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return def
+	}
+	out := filepath.SplitList(env("XDG_CONFIG_DIRS", "/etc/xdg"))
+	out = append(out, "/etc")
+	out = append(out, filepath.SplitList(env("XDG_DATA_DIRS", "/usr/local/share:/usr/share"))...)
+	if home := os.Getenv("HOME"); home != "" || os.Getenv("XDG_DATA_HOME") != "" {
+		out = append(out, env("XDG_DATA_HOME", filepath.Join(home, ".local", "share")))
+	}
+	return out
+}
+
+func readManifest(ctx context.Context, path string, out interface{}) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.D(ctx, "Couldn't read Vulkan manifest %v: %v", path, err)
+		return false
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		log.D(ctx, "Couldn't parse Vulkan manifest %v: %v", path, err)
+		return false
+	}
+	return true
+}
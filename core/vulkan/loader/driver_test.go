@@ -0,0 +1,266 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/vulkan/loader"
+)
+
+const vulkanInfo11 = `===========
+VULKAN INFO
+===========
+
+Vulkan Instance Version: 1.1.70
+
+
+Instance Extensions:
+====================
+Instance Extensions	count = 3
+	VK_EXT_debug_report                 : extension revision  9
+	VK_KHR_surface                      : extension revision 25
+	VK_KHR_xcb_surface                  : extension revision  6
+Layers: count = 1
+=======
+VK_LAYER_LUNARG_api_dump (LunarG debug layer) Vulkan version 1.0.70, layer version 2
+	Layer Extensions	count = 1
+		VK_EXT_debug_marker        : extension revision  4
+	Devices 	count = 1
+		GPU id 	: 0 (Intel(R) HD Graphics 620 (Kaby Lake GT2))
+		Layer-Device Extensions	count = 0
+
+Presentable Surfaces:
+=====================
+GPU id       : 0 (Intel(R) HD Graphics 620 (Kaby Lake GT2))
+Surface type : VK_KHR_xcb_surface
+
+Device Properties and Extensions :
+==================================
+GPU0
+VkPhysicalDeviceProperties:
+===========================
+	apiVersion     = 0x401046  (1.1.70)
+	driverVersion  = 71303175 (0x4400007)
+	vendorID       = 0x8086
+	deviceID       = 0x5916
+	deviceType     = INTEGRATED_GPU
+	deviceName     = Intel(R) HD Graphics 620 (Kaby Lake GT2)
+
+Device Extensions	count = 1
+	VK_KHR_16bit_storage                : extension revision  1
+`
+
+const vulkanInfo13 = `==========
+VULKANINFO
+==========
+
+Vulkan Instance Version: 1.3.224
+
+
+Instance Extensions: count = 2
+===============================
+	VK_EXT_acquire_xlib_display            : extension revision 1
+	VK_KHR_surface                         : extension revision 25
+
+Layers: count = 1
+=================
+VK_LAYER_MESA_device_select (Linux device selection layer) Vulkan version 1.3.211, layer version 1:
+	Layer Extensions: count = 0
+	Devices: count = 2
+		GPU id = 0 (NVIDIA GeForce GTX 1080)
+		Layer-Device Extensions: count = 0
+
+Device Properties and Extensions:
+=================================
+GPU0:
+VkPhysicalDeviceProperties:
+---------------------------
+	apiVersion        = 1.3.224 (4206816)
+	driverVersion     = 2171944960 (0x81754000)
+	vendorID          = 0x10de
+	deviceID          = 0x1b80
+	deviceType        = PHYSICAL_DEVICE_TYPE_DISCRETE_GPU
+	deviceName        = NVIDIA GeForce GTX 1080
+
+VkPhysicalDeviceDriverProperties:
+---------------------------------
+	driverID           = DRIVER_ID_NVIDIA_PROPRIETARY
+	driverName         = NVIDIA
+
+GPU1:
+VkPhysicalDeviceProperties:
+---------------------------
+	apiVersion        = 4206816 (1.3.224)
+	driverVersion     = 0.0.1 (1)
+	vendorID          = 0x10005
+	deviceID          = 0x0000
+	deviceType        = PHYSICAL_DEVICE_TYPE_CPU
+	deviceName        = llvmpipe (LLVM 15.0.6, 256 bits)
+`
+
+func TestParseVulkanInfo(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name     string
+		info     string
+		expected *device.VulkanDriver
+	}{
+		{"1.1", vulkanInfo11, &device.VulkanDriver{
+			IcdAndImplicitLayerExtensions: []string{"VK_EXT_debug_report", "VK_KHR_surface", "VK_KHR_xcb_surface"},
+			PhysicalDevices: []*device.VulkanPhysicalDevice{{
+				ApiVersion:    1<<22 | 1<<12 | 70,
+				DriverVersion: 71303175,
+				VendorID:      0x8086,
+				DeviceID:      0x5916,
+				DeviceName:    "Intel(R) HD Graphics 620 (Kaby Lake GT2)",
+			}},
+		}},
+		{"1.3", vulkanInfo13, &device.VulkanDriver{
+			IcdAndImplicitLayerExtensions: []string{"VK_EXT_acquire_xlib_display", "VK_KHR_surface"},
+			PhysicalDevices: []*device.VulkanPhysicalDevice{{
+				ApiVersion:    1<<22 | 3<<12 | 224,
+				DriverVersion: 0x81754000,
+				VendorID:      0x10de,
+				DeviceID:      0x1b80,
+				DeviceName:    "NVIDIA GeForce GTX 1080",
+			}, {
+				ApiVersion:    1<<22 | 3<<12 | 224,
+				DriverVersion: 1,
+				VendorID:      0x10005,
+				DeviceName:    "llvmpipe (LLVM 15.0.6, 256 bits)",
+			}},
+		}},
+	} {
+		got := loader.ParseVulkanInfo(test.info)
+		assert.For(ctx, "%v", test.name).That(got).DeepEquals(test.expected)
+	}
+}
+
+func TestFindManifests(t *testing.T) {
+	ctx := log.Testing(t)
+	if runtime.GOOS != "linux" {
+		t.Skip("Vulkan manifests are only searched for on Linux")
+	}
+
+	root, err := ioutil.TempDir("", "vulkan_manifests")
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"vulkan/icd.d/intel_icd.x86_64.json": `{
+			"file_format_version": "1.0.0",
+			"ICD": { "library_path": "/usr/lib/libvulkan_intel.so", "api_version": "1.1.70" }
+		}`,
+		"vulkan/icd.d/broken.json": `{ not json`,
+		"vulkan/implicit_layer.d/steam.json": `{
+			"file_format_version": "1.0.0",
+			"layer": {
+				"name": "VK_LAYER_VALVE_steam_overlay",
+				"type": "GLOBAL",
+				"library_path": "libVkLayer_steam.so",
+				"api_version": "1.0.3",
+				"implementation_version": "1",
+				"description": "Steam Overlay Layer",
+				"instance_extensions": [ { "name": "VK_EXT_debug_utils", "spec_version": "1" } ]
+			}
+		}`,
+		"vulkan/explicit_layer.d/lunarg.json": `{
+			"file_format_version": "1.1.0",
+			"layers": [
+				{ "name": "VK_LAYER_LUNARG_api_dump", "library_path": "libVkLayer_api_dump.so" },
+				{
+					"name": "VK_LAYER_LUNARG_monitor",
+					"library_path": "libVkLayer_monitor.so",
+					"device_extensions": [ { "name": "VK_EXT_debug_marker" } ]
+				}
+			]
+		}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	for name, value := range map[string]string{
+		"XDG_CONFIG_DIRS":  filepath.Join(root, "none"),
+		"XDG_DATA_DIRS":    root,
+		"XDG_DATA_HOME":    filepath.Join(root, "none"),
+		"VK_ICD_FILENAMES": "",
+		"VK_LAYER_PATH":    "",
+	} {
+		old, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if ok {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	icds := loader.FindICDs(ctx)
+	// Manifests installed in /etc are always searched.
+	if len(icds) > 0 {
+		icds = icds[len(icds)-1:]
+	}
+	assert.For(ctx, "icds").That(icds).DeepEquals([]loader.ICD{{
+		Manifest:   filepath.Join(root, "vulkan/icd.d/intel_icd.x86_64.json"),
+		Library:    "/usr/lib/libvulkan_intel.so",
+		APIVersion: "1.1.70",
+	}})
+
+	layers := []loader.Layer{}
+	for _, l := range loader.FindLayers(ctx) {
+		if filepath.Dir(filepath.Dir(filepath.Dir(l.Manifest))) == root {
+			layers = append(layers, l)
+		}
+	}
+	assert.For(ctx, "layers").That(layers).DeepEquals([]loader.Layer{{
+		Manifest:              filepath.Join(root, "vulkan/implicit_layer.d/steam.json"),
+		Name:                  "VK_LAYER_VALVE_steam_overlay",
+		Description:           "Steam Overlay Layer",
+		Library:               "libVkLayer_steam.so",
+		APIVersion:            "1.0.3",
+		ImplementationVersion: "1",
+		Implicit:              true,
+		InstanceExtensions:    []string{"VK_EXT_debug_utils"},
+		DeviceExtensions:      []string{},
+	}, {
+		Manifest:           filepath.Join(root, "vulkan/explicit_layer.d/lunarg.json"),
+		Name:               "VK_LAYER_LUNARG_api_dump",
+		Library:            "libVkLayer_api_dump.so",
+		InstanceExtensions: []string{},
+		DeviceExtensions:   []string{},
+	}, {
+		Manifest:           filepath.Join(root, "vulkan/explicit_layer.d/lunarg.json"),
+		Name:               "VK_LAYER_LUNARG_monitor",
+		Library:            "libVkLayer_monitor.so",
+		InstanceExtensions: []string{},
+		DeviceExtensions:   []string{"VK_EXT_debug_marker"},
+	}})
+
+	os.Setenv("VK_ICD_FILENAMES", filepath.Join(root, "vulkan/icd.d/broken.json"))
+	assert.For(ctx, "overridden icds").ThatSlice(loader.FindICDs(ctx)).IsEmpty()
+}
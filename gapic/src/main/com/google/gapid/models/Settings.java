@@ -79,6 +79,7 @@ public class Settings {
   public boolean traceWithoutBuffering = false;
   public int traceFrameCount = 0;
   public String traceIntentArgs = "";
+  public boolean tracePackageLabels = false;
   public boolean skipWelcomeScreen = false;
   public boolean skipFirstRunDialog = false;
   public String[] recentFiles = new String[0];
@@ -217,6 +218,7 @@ public class Settings {
     traceWithoutBuffering = getBoolean(properties, "trace.withoutBuffering", traceWithoutBuffering);
     traceFrameCount = getInt(properties, "trace.frameCount", traceFrameCount);
     traceIntentArgs = properties.getProperty("trace.intentArgs", traceIntentArgs);
+    tracePackageLabels = getBoolean(properties, "trace.packageLabels", tracePackageLabels);
     skipWelcomeScreen = getBoolean(properties, "skip.welcome", skipWelcomeScreen);
     skipFirstRunDialog = getBoolean(properties, "skip.firstTime", skipFirstRunDialog);
     recentFiles = getStringList(properties, "open.recent", recentFiles);
@@ -258,6 +260,7 @@ public class Settings {
     properties.setProperty("trace.midExecution", Boolean.toString(traceMidExecution));
     properties.setProperty("trace.frameCount", Integer.toString(traceFrameCount));
     properties.setProperty("trace.intentArgs", traceIntentArgs);
+    properties.setProperty("trace.packageLabels", Boolean.toString(tracePackageLabels));
     properties.setProperty("skip.welcome", Boolean.toString(skipWelcomeScreen));
     properties.setProperty("skip.firstTime", Boolean.toString(skipFirstRunDialog));
     setStringList(properties, "open.recent", recentFiles);
@@ -38,11 +38,18 @@ public class GapitPkgInfoProcess extends ChildProcess<PkgInfo.PackageList> {
 
   private final String deviceSerial;
   private final float iconDensityScale;
-
-  public GapitPkgInfoProcess(Settings settings, String deviceSerial, float iconDensityScale) {
+  private final boolean labels;
+
+  /**
+   * @param labels whether to resolve the application labels. This pulls every APK from the
+   *     device, so is much slower than listing the packages.
+   */
+  public GapitPkgInfoProcess(
+      Settings settings, String deviceSerial, float iconDensityScale, boolean labels) {
     super("gapit", settings);
     this.deviceSerial = deviceSerial;
     this.iconDensityScale = iconDensityScale;
+    this.labels = labels;
   }
 
   @Override
@@ -64,6 +71,10 @@ public class GapitPkgInfoProcess extends ChildProcess<PkgInfo.PackageList> {
 
     args.add("--icons");
 
+    if (labels) {
+      args.add("--labels");
+    }
+
     args.add("--icondensity");
     args.add(String.valueOf(iconDensityScale));
 
@@ -15,6 +15,7 @@
  */
 package com.google.gapid.views;
 
+import static com.google.gapid.widgets.Widgets.createCheckbox;
 import static com.google.gapid.widgets.Widgets.createComposite;
 import static com.google.gapid.widgets.Widgets.createTreeForViewer;
 import static org.eclipse.jface.dialogs.IDialogConstants.OK_ID;
@@ -84,6 +85,7 @@ public class ActivityPickerDialog extends DialogBase {
   private PackageLabelProvider labelProvider;
   private LocalResourceManager resources;
   private PkgInfo.PackageList packageList;
+  private int loadCount;
   private Action selected;
 
   /**
@@ -205,6 +207,15 @@ public class ActivityPickerDialog extends DialogBase {
     SearchBox search = new SearchBox(container, true);
     search.setLayoutData(new GridData(SWT.FILL, SWT.TOP, true, false));
 
+    Button labels = createCheckbox(container, "Show application names (slower to load)",
+        models.settings.tracePackageLabels);
+    labels.setLayoutData(new GridData(SWT.FILL, SWT.TOP, true, false));
+    labels.addListener(SWT.Selection, e -> {
+      models.settings.tracePackageLabels = labels.getSelection();
+      setPackageList(null);
+      load(getShell());
+    });
+
     loading = LoadablePanel.create(container, widgets, p -> createTreeForViewer(p, SWT.BORDER));
     loading.setLayoutData(new GridData(SWT.FILL, SWT.FILL, true, true));
     tree = Widgets.createTreeViewer(loading.getContents());
@@ -230,8 +241,11 @@ public class ActivityPickerDialog extends DialogBase {
       tree.setFilters(new ViewerFilter() {
         @Override
         public boolean select(Viewer viewer, Object parentElement, Object element) {
-          return !(element instanceof PkgInfo.Package) ||
-              pattern.matcher(((PkgInfo.Package)element).getName()).find();
+          if (!(element instanceof PkgInfo.Package)) {
+            return true;
+          }
+          PkgInfo.Package pkg = (PkgInfo.Package)element;
+          return pattern.matcher(pkg.getName()).find() || pattern.matcher(pkg.getLabel()).find();
         }
       });
     });
@@ -248,19 +262,29 @@ public class ActivityPickerDialog extends DialogBase {
   }
 
   private void load(Shell shell) {
+    // Ignore the results of earlier loads that finish after this one.
+    int id = ++loadCount;
     float iconDensityScale = DPIUtil.getDeviceZoom() / 100.0f;
-    GapitPkgInfoProcess process =
-        new GapitPkgInfoProcess(models.settings, device.getSerial(), iconDensityScale);
+    GapitPkgInfoProcess process = new GapitPkgInfoProcess(models.settings, device.getSerial(),
+        iconDensityScale, models.settings.tracePackageLabels);
     Futures.addCallback(process.start(), new FutureCallback<PkgInfo.PackageList>() {
       @Override
       public void onFailure(Throwable t) {
         LOG.log(Level.WARNING, "Failed to read package info", t);
-        Widgets.scheduleIfNotDisposed(shell, () -> showError(t.getMessage()));
+        Widgets.scheduleIfNotDisposed(shell, () -> {
+          if (id == loadCount) {
+            showError(t.getMessage());
+          }
+        });
       }
 
       @Override
       public void onSuccess(PkgInfo.PackageList result) {
-        Widgets.scheduleIfNotDisposed(shell, () -> setPackageList(result));
+        Widgets.scheduleIfNotDisposed(shell, () -> {
+          if (id == loadCount) {
+            setPackageList(result);
+          }
+        });
       }
     });
   }
@@ -351,7 +375,13 @@ public class ActivityPickerDialog extends DialogBase {
     @Override
     public StyledString getStyledText(Object element) {
       if (element instanceof PkgInfo.Package) {
-        return new StyledString(((PkgInfo.Package)element).getName());
+        PkgInfo.Package pkg = (PkgInfo.Package)element;
+        if (pkg.getLabel().isEmpty()) {
+          return new StyledString(pkg.getName());
+        }
+        StyledString result = new StyledString(pkg.getLabel());
+        result.append(" (" + pkg.getName() + ")", theme.structureStyler());
+        return result;
       } else if (element instanceof PkgInfo.Activity) {
         PkgInfo.Activity a = (PkgInfo.Activity)element;
         return new StyledString(
@@ -16,11 +16,15 @@ package gapidapk
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android"
 	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/android/apk"
 	"github.com/google/gapid/gapidapk/pkginfo"
 )
 
@@ -76,3 +80,50 @@ func PackageList(ctx context.Context, d adb.Device, includeIcons bool, iconDensi
 
 	return out, nil
 }
+
+// ResolveLabels pulls the APK of each package in the list from the device and
+// fills in the application label and version name from its manifest and
+// resources. If includeIcons is true, packages without an icon are given the
+// launcher icon of the APK.
+func ResolveLabels(ctx context.Context, d adb.Device, pkgs *pkginfo.PackageList, includeIcons bool) error {
+	tmp, err := ioutil.TempDir("", "gapid-pkginfo")
+	if err != nil {
+		return log.Err(ctx, err, "Creating temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, pkg := range pkgs.Packages {
+		ctx := log.V{"package": pkg.Name}.Bind(ctx)
+		labels, err := packageLabels(ctx, d, pkg.Name, filepath.Join(tmp, pkg.Name+".apk"))
+		if err != nil {
+			log.W(ctx, "Couldn't resolve labels: %v", err)
+			continue
+		}
+		pkg.Label, pkg.VersionName = labels.Label, labels.VersionName
+		if includeIcons && pkg.Icon < 0 && labels.Icon != nil {
+			pkg.Icon = int32(len(pkgs.Icons))
+			pkgs.Icons = append(pkgs.Icons, labels.Icon)
+		}
+	}
+	return nil
+}
+
+func packageLabels(ctx context.Context, d adb.Device, name, path string) (apk.Labels, error) {
+	defer os.Remove(path)
+	if err := (&android.InstalledPackage{Name: name, Device: d}).Pull(ctx, path); err != nil {
+		return apk.Labels{}, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return apk.Labels{}, err
+	}
+	files, err := apk.Read(ctx, data)
+	if err != nil {
+		return apk.Labels{}, err
+	}
+	m, err := apk.GetManifest(ctx, files)
+	if err != nil {
+		return apk.Labels{}, err
+	}
+	return apk.GetLabels(ctx, files, m)
+}
@@ -0,0 +1,343 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// StatePath is a path to a piece of global API state.
+// A path starts with the name of a global and is followed by zero or more
+// '.field' selectors and '[]' map or array entry selectors. For example
+// 'Contexts[].Bound.ArrayBuffer'.
+type StatePath string
+
+// Parent returns the path that encloses p, or an empty path if p is the path
+// to a global.
+func (p StatePath) Parent() StatePath {
+	if i := strings.LastIndexAny(string(p), ".["); i > 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// Contains returns true if o is equal to p or is a sub-path of p.
+func (p StatePath) Contains(o StatePath) bool {
+	for ; o != ""; o = o.Parent() {
+		if o == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Footprint is the set of global state that a command may read and write.
+// The footprint is conservative: it contains every access on every path
+// through the command, including those through called subroutines.
+type Footprint struct {
+	// Command is the command function.
+	Command *semantic.Function
+	// Reads is the sorted list of state paths the command may read.
+	Reads []StatePath
+	// Writes is the sorted list of state paths the command may write.
+	Writes []StatePath
+}
+
+// Footprints returns the state footprints of all the commands in the API.
+func Footprints(api *semantic.API) map[*semantic.Function]*Footprint {
+	out := map[*semantic.Function]*Footprint{}
+	shared := &footprintShared{
+		memo:  map[footprintCall]*footprintResult{},
+		stack: map[*semantic.Function]bool{},
+	}
+	for _, f := range api.Functions {
+		if f.Subroutine || f.Extern || f.Block == nil {
+			continue
+		}
+		res := newFootprintResult()
+		s := &footprintScope{
+			shared: shared,
+			res:    res,
+			locals: map[semantic.Node][]StatePath{},
+		}
+		s.traverse(f.Block)
+		out[f] = &Footprint{
+			Command: f,
+			Reads:   sortedPaths(res.reads),
+			Writes:  sortedPaths(res.writes),
+		}
+	}
+	return out
+}
+
+// footprintCall is the key to the memoized footprint of a subroutine call
+// with a given set of parameters bound to state paths.
+type footprintCall struct {
+	function *semantic.Function
+	bindings string
+}
+
+// footprintResult holds the state accessed by a function.
+type footprintResult struct {
+	reads   map[StatePath]struct{}
+	writes  map[StatePath]struct{}
+	returns map[StatePath]struct{} // The state paths that may be returned.
+}
+
+func newFootprintResult() *footprintResult {
+	return &footprintResult{
+		reads:   map[StatePath]struct{}{},
+		writes:  map[StatePath]struct{}{},
+		returns: map[StatePath]struct{}{},
+	}
+}
+
+type footprintShared struct {
+	memo  map[footprintCall]*footprintResult
+	stack map[*semantic.Function]bool // Functions currently being traversed.
+}
+
+// footprintScope is the state used to traverse a single function.
+type footprintScope struct {
+	shared *footprintShared
+	res    *footprintResult
+	locals map[semantic.Node][]StatePath // Locals and parameters -> state paths
+}
+
+// traverse is the visitor function for all statements and expressions of a
+// function.
+func (s *footprintScope) traverse(n semantic.Node) {
+	switch n := n.(type) {
+	case semantic.Type, *semantic.Callable:
+		// Not interested in these.
+
+	case *semantic.DeclareLocal:
+		// Locals that alias state do not read the state until they are used.
+		if ps := s.paths(n.Local.Value); len(ps) > 0 {
+			s.locals[n.Local] = ps
+		}
+
+	case *semantic.Assign:
+		s.write(n.LHS, n.Operator != "=")
+		s.read(n.RHS)
+
+	case *semantic.ArrayAssign:
+		s.write(n.To, n.Operator != "=")
+		s.read(n.Value)
+
+	case *semantic.MapAssign:
+		s.write(n.To, n.Operator != "=")
+		s.read(n.Value)
+
+	case *semantic.MapRemove:
+		s.read(n.Key)
+		for _, p := range s.paths(n.Map) {
+			s.res.writes[p+"[]"] = struct{}{}
+		}
+
+	case *semantic.MapIteration:
+		if ps := s.paths(n.Map); len(ps) > 0 {
+			add(s.res.reads, ps)
+			s.locals[n.ValueIterator] = suffix(ps, "[]")
+		}
+		s.traverse(n.Block)
+
+	case *semantic.Return:
+		if n.Value != nil {
+			add(s.res.returns, s.paths(n.Value))
+		}
+
+	case *semantic.Call:
+		s.call(n)
+
+	case semantic.Expression:
+		s.read(n)
+
+	default:
+		semantic.Visit(n, s.traverse)
+	}
+}
+
+// read records a read of the state referenced by n, if any.
+func (s *footprintScope) read(n semantic.Expression) {
+	add(s.res.reads, s.paths(n))
+}
+
+// write records a write of the state referenced by n, if any. If modify is
+// true then a read is also recorded.
+func (s *footprintScope) write(n semantic.Expression, modify bool) {
+	ps := s.paths(n)
+	add(s.res.writes, ps)
+	if modify {
+		add(s.res.reads, ps)
+	}
+}
+
+// paths returns the state paths that n may reference, or nil if n does not
+// reference state. Any sub-expressions of n that are not part of the paths
+// are traversed.
+func (s *footprintScope) paths(n semantic.Expression) []StatePath {
+	switch n := n.(type) {
+	case *semantic.Global:
+		return []StatePath{StatePath(n.Name())}
+
+	case *semantic.Local:
+		return s.locals[n]
+
+	case *semantic.Parameter:
+		return s.locals[n]
+
+	case *semantic.Member:
+		return suffix(s.paths(n.Object), "."+n.Field.Name())
+
+	case *semantic.MapIndex:
+		s.read(n.Index)
+		ps := s.paths(n.Map)
+		// Indexing looks up the key in the container itself.
+		add(s.res.reads, ps)
+		return suffix(ps, "[]")
+
+	case *semantic.ArrayIndex:
+		s.read(n.Index)
+		ps := s.paths(n.Array)
+		// Indexing looks up the key in the container itself.
+		add(s.res.reads, ps)
+		return suffix(ps, "[]")
+
+	case *semantic.Cast:
+		return s.paths(n.Object)
+
+	case *semantic.Select:
+		// The select may evaluate to any of its choices.
+		s.read(n.Value)
+		set := map[StatePath]struct{}{}
+		for _, c := range n.Choices {
+			for _, e := range c.Conditions {
+				s.read(e)
+			}
+			add(set, s.paths(c.Expression))
+		}
+		add(set, s.paths(n.Default))
+		return sortedPaths(set)
+
+	case *semantic.Call:
+		return s.call(n)
+
+	case nil:
+		return nil
+
+	default:
+		semantic.Visit(n, s.traverse)
+		return nil
+	}
+}
+
+// call processes the call n, merging the footprint of the called subroutine
+// into the scope's footprint. call returns the state paths that the
+// subroutine may return.
+func (s *footprintScope) call(n *semantic.Call) []StatePath {
+	f := n.Target.Function
+	locals := map[semantic.Node][]StatePath{}
+	bindings := []string{}
+	bind := func(n semantic.Node, name string, ps []StatePath) {
+		if !f.Subroutine {
+			add(s.res.reads, ps)
+			return
+		}
+		// Defer the read to the uses of the parameter.
+		locals[n] = ps
+		bindings = append(bindings, name+"="+join(ps))
+	}
+	params := f.FullParameters
+	if obj := n.Target.Object; obj != nil {
+		if ps := s.paths(obj); len(ps) > 0 {
+			if f.This != nil {
+				bind(f.This, "this", ps)
+			} else {
+				add(s.res.reads, ps)
+			}
+		}
+		params = params[1:]
+	}
+	for i, a := range n.Arguments {
+		ps := s.paths(a)
+		if len(ps) == 0 || i >= len(params) {
+			continue
+		}
+		bind(params[i], params[i].Name(), ps)
+	}
+	if !f.Subroutine || f.Block == nil || s.shared.stack[f] {
+		return nil
+	}
+
+	key := footprintCall{f, strings.Join(bindings, ",")}
+	res, ok := s.shared.memo[key]
+	if !ok {
+		res = newFootprintResult()
+		s.shared.stack[f] = true
+		callee := &footprintScope{shared: s.shared, res: res, locals: locals}
+		callee.traverse(f.Block)
+		delete(s.shared.stack, f)
+		s.shared.memo[key] = res
+	}
+	for p := range res.reads {
+		s.res.reads[p] = struct{}{}
+	}
+	for p := range res.writes {
+		s.res.writes[p] = struct{}{}
+	}
+	// Every returned path may be accessed through the result.
+	return sortedPaths(res.returns)
+}
+
+// add adds the paths ps to the set m.
+func add(m map[StatePath]struct{}, ps []StatePath) {
+	for _, p := range ps {
+		m[p] = struct{}{}
+	}
+}
+
+// suffix returns the paths ps, each followed by the selector sel.
+func suffix(ps []StatePath, sel string) []StatePath {
+	if len(ps) == 0 {
+		return nil
+	}
+	out := make([]StatePath, len(ps))
+	for i, p := range ps {
+		out[i] = p + StatePath(sel)
+	}
+	return out
+}
+
+// join returns the paths ps as a single string.
+func join(ps []StatePath) string {
+	strs := make([]string, len(ps))
+	for i, p := range ps {
+		strs[i] = string(p)
+	}
+	return strings.Join(strs, "|")
+}
+
+func sortedPaths(m map[StatePath]struct{}) []StatePath {
+	out := make([]StatePath, 0, len(m))
+	for p := range m {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
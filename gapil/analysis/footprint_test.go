@@ -0,0 +1,96 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/analysis"
+)
+
+func TestFootprints(t *testing.T) {
+	ctx := log.Testing(t)
+
+	common := `
+class Buffer { u32 Size }
+class Context {
+  map!(u32, ref!Buffer) Buffers
+  ref!Buffer            Bound
+  u32                   Count
+}
+map!(u32, ref!Context) Contexts
+u32 Current
+u32 Counter
+sub ref!Context GetContext() { return Contexts[Current] }
+`
+
+	type paths []analysis.StatePath
+
+	for _, test := range []struct {
+		source string
+		reads  paths
+		writes paths
+	}{
+		{`cmd void c() { }`, paths{}, paths{}},
+		{`cmd void c(u32 a) { Counter = a }`, paths{}, paths{"Counter"}},
+		{`cmd void c() { Counter += 1 }`, paths{"Counter"}, paths{"Counter"}},
+		{`cmd void c() { x := Counter  Current = x }`, paths{"Counter"}, paths{"Current"}},
+		{`cmd void c() { ctx := GetContext()  ctx.Count = 1 }`,
+			paths{"Contexts", "Current"}, paths{"Contexts[].Count"}},
+		{`cmd void c(u32 id) { ctx := GetContext()  ctx.Bound = ctx.Buffers[id] }`,
+			paths{"Contexts", "Contexts[].Buffers", "Contexts[].Buffers[]", "Current"}, paths{"Contexts[].Bound"}},
+		{`cmd void c(u32 id) { GetContext().Buffers[id] = new!Buffer() }`,
+			paths{"Contexts", "Contexts[].Buffers", "Current"}, paths{"Contexts[].Buffers[]"}},
+		{`cmd void c(u32 id) { delete(GetContext().Buffers, id) }`,
+			paths{"Contexts", "Current"}, paths{"Contexts[].Buffers[]"}},
+		{`sub void s(ref!Buffer b) { b.Size = 4 }  cmd void c() { s(GetContext().Bound) }`,
+			paths{"Contexts", "Current"}, paths{"Contexts[].Bound.Size"}},
+		{`cmd void c() { for _, _, v in Contexts { v.Count = 0 } }`,
+			paths{"Contexts"}, paths{"Contexts[].Count"}},
+		{`cmd void c(u32 id) { if id in Contexts { Counter = 1 } }`,
+			paths{"Contexts"}, paths{"Counter"}},
+		{`sub ref!Buffer b(bool x) { ctx := GetContext()  return switch x { case true: ctx.Bound  case false: ctx.Buffers[0] } }
+		  cmd void c(bool x) { b(x).Size = 4 }`,
+			paths{"Contexts", "Contexts[].Buffers", "Current"}, paths{"Contexts[].Bound.Size", "Contexts[].Buffers[].Size"}},
+	} {
+		ctx := log.V{"source": test.source}.Bind(ctx)
+		api, _, err := compile(ctx, common+" "+test.source)
+		assert.With(ctx).ThatError(err).Succeeded()
+		footprints := analysis.Footprints(api)
+		var fp *analysis.Footprint
+		for f, v := range footprints {
+			if f.Name() == "c" {
+				fp = v
+			}
+		}
+		if !assert.With(ctx).That(fp).IsNotNil() {
+			continue
+		}
+		assert.With(ctx).ThatSlice(fp.Reads).Equals(test.reads)
+		assert.With(ctx).ThatSlice(fp.Writes).Equals(test.writes)
+	}
+}
+
+func TestStatePath(t *testing.T) {
+	assert := assert.To(t)
+	p := analysis.StatePath("Contexts[].Bound.Size")
+	assert.For("parent").That(p.Parent()).Equals(analysis.StatePath("Contexts[].Bound"))
+	assert.For("grandparent").That(p.Parent().Parent()).Equals(analysis.StatePath("Contexts[]"))
+	assert.For("root").That(analysis.StatePath("Contexts").Parent()).Equals(analysis.StatePath(""))
+	assert.For("contains").That(analysis.StatePath("Contexts[]").Contains(p)).Equals(true)
+	assert.For("not contains").That(analysis.StatePath("Current").Contains(p)).Equals(false)
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapil/parser"
+)
+
+func TestComments(t *testing.T) {
+	assert := assert.To(t)
+	get := func(src string) []string {
+		m := parse.NewCSTMap()
+		api, errs := parser.Parse("test", src, m)
+		assert.For("errs").ThatSlice(errs).IsEmpty()
+		return comments(m.CST(api))
+	}
+	src := "// A\ncmd void F(u32 a /* B */) { // C\n  x := a // D  \n} /* E */\n"
+	got := get(src)
+	assert.For("comments").ThatSlice(got).Equals([]string{"// A", "/* B */", "// C", "// D", "/* E */"})
+
+	assert.For("same").ThatError(equalComments(got, get(src))).Succeeded()
+	assert.For("dropped").ThatError(equalComments(got, get("// A\ncmd void F(u32 a /* B */) { // C\n  x := a\n} /* E */\n"))).
+		HasMessage(`comment 3: "// D" != "/* E */"`)
+	assert.For("moved").ThatError(equalComments(got, get("// A\ncmd void F(u32 a /* B */) { // D\n  x := a // C\n} /* E */\n"))).
+		HasMessage(`comment 2: "// C" != "// D"`)
+	assert.For("added").ThatError(equalComments(got, get(src+"// F\n"))).
+		HasMessage(`comment count 5 != 6`)
+}
@@ -104,6 +104,13 @@ func (p *printer) markup(n ast.Node) {
 	case *ast.Block:
 		p.align(n)
 
+		// Separate statements that share a line.
+		for i, s := range n.Statements {
+			if i > 0 {
+				p.inject(s, beforePrefix, "•")
+			}
+		}
+
 		// •{}•
 		p.inject(n, beforePrefix, "•")
 		p.inject(n, afterSuffix, "•")
@@ -298,7 +305,7 @@ func (p *printer) print(n parse.Node) {
 	}
 
 	// print the prefix comments.
-	p.separator(n.Prefix())
+	p.separator(n.Prefix(), true)
 
 	// emit any afterPrefix injections.
 	if s, ok := p.injections[injectKey{n, afterPrefix}]; ok {
@@ -332,7 +339,7 @@ func (p *printer) print(n parse.Node) {
 	}
 
 	// print the suffix comments.
-	p.separator(n.Suffix())
+	p.separator(n.Suffix(), false)
 
 	// emit any afterSuffix injections.
 	if s, ok := p.injections[injectKey{n, afterSuffix}]; ok {
@@ -407,20 +414,43 @@ func (p *printer) align(n ast.Node) {
 
 // separator writes sep to the indenter iff it is a comment.
 // All comments are preceeded with a soft whitespace.
-func (p *printer) separator(sep parse.Separator) {
-	for _, sep := range sep {
-		s := sep.Token().String()
+// leading is true if sep is the prefix of a node, in which case block comments
+// are followed by a soft whitespace so they are not joined to the node. Block
+// comments that start a line and are followed by the node on the same line are
+// moved to their own line, so they do not disturb the alignment of the node.
+func (p *printer) separator(sep parse.Separator, leading bool) {
+	for i, f := range sep {
+		s := f.Token().String()
 		switch {
 		case strings.HasPrefix(s, "//"), strings.HasPrefix(s, "/*"):
+			startsLine := !p.indenter.midline
 			p.write("•")
 			// Write the '/' to the indenter to get new lines indented.
 			p.write(s[:1])
 			// Write the rest of the comment skipping the indenter, as we don't want
 			// to change new-line indentation within the comments.
 			p.indenter.out.Write([]byte(s[1:]))
+			if leading && strings.HasPrefix(s, "/*") && !newlineFollows(sep, i) {
+				if startsLine {
+					p.write("\n")
+				} else {
+					p.write("•")
+				}
+			}
 
 		case strings.HasPrefix(s, "\n"):
 			p.write(s)
 		}
 	}
 }
+
+// newlineFollows returns true if any of the separators after the i'th entry
+// of sep is a new line.
+func newlineFollows(sep parse.Separator, i int) bool {
+	for _, s := range sep[i+1:] {
+		if strings.HasPrefix(s.Token().String(), "\n") {
+			return true
+		}
+	}
+	return false
+}
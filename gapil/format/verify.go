@@ -0,0 +1,185 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/parser"
+)
+
+// Source parses and re-formats the api source src, returning the formatted
+// source.
+func Source(filename string, src []byte) ([]byte, error) {
+	_, _, out, err := format(filename, src)
+	return out, err
+}
+
+// Verify re-formats the api source src like Source, but also checks that the
+// formatted source parses to an AST equivalent to that of src with the same
+// sequence of comments, and that formatting the formatted source makes no
+// further changes.
+func Verify(filename string, src []byte) ([]byte, error) {
+	before, beforeCST, out, err := format(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	after, afterCST, again, err := format(filename, out)
+	if err != nil {
+		return nil, fmt.Errorf("Formatted source does not parse: %v", err)
+	}
+	if err := Equal(before, after); err != nil {
+		return nil, fmt.Errorf("Formatted source is not equivalent: %v", err)
+	}
+	if err := equalComments(comments(beforeCST), comments(afterCST)); err != nil {
+		return nil, fmt.Errorf("Formatted source has different comments: %v", err)
+	}
+	if !bytes.Equal(out, again) {
+		return nil, fmt.Errorf("Formatting is not idempotent at line %d", firstDifference(out, again))
+	}
+	return out, nil
+}
+
+// format parses and formats src, returning the parsed AST, the root of its
+// CST and the formatted source.
+func format(filename string, src []byte) (*ast.API, parse.Node, []byte, error) {
+	m := parse.NewCSTMap()
+	api, errs := parser.Parse(filename, string(src), m)
+	if len(errs) > 0 {
+		return nil, nil, nil, errs
+	}
+	buf := &bytes.Buffer{}
+	Format(api, m, buf)
+	return api, m.CST(api), buf.Bytes(), nil
+}
+
+// comments returns the comments held by the separators of the CST node n and
+// its descendants, in source order. Trailing whitespace is trimmed.
+func comments(n parse.Node) []string {
+	out := []string{}
+	sep := func(s parse.Separator) {
+		for _, f := range s {
+			if c := f.Token().String(); strings.HasPrefix(c, "//") || strings.HasPrefix(c, "/*") {
+				out = append(out, strings.TrimRight(c, " \t\r\n"))
+			}
+		}
+	}
+	var visit func(n parse.Node)
+	visit = func(n parse.Node) {
+		sep(n.Prefix())
+		if b, ok := n.(*parse.Branch); ok {
+			for _, c := range b.Children {
+				visit(c)
+			}
+		}
+		sep(n.Suffix())
+	}
+	if n != nil {
+		visit(n)
+	}
+	return out
+}
+
+// equalComments returns nil if the comment lists a and b are identical,
+// otherwise it returns an error describing the first difference found.
+func equalComments(a, b []string) error {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return fmt.Errorf("comment %d: %q != %q", i, a[i], b[i])
+		}
+	}
+	if len(a) != len(b) {
+		return fmt.Errorf("comment count %d != %d", len(a), len(b))
+	}
+	return nil
+}
+
+// firstDifference returns the 1-based line number of the first line that
+// differs between a and b.
+func firstDifference(a, b []byte) int {
+	line := 1
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '\n' {
+			line++
+		}
+	}
+	return line
+}
+
+// Equal returns nil if the AST nodes a and b are structurally identical,
+// otherwise it returns an error describing the first difference found.
+func Equal(a, b ast.Node) error {
+	return equal("", reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equal(path string, a, b reflect.Value) error {
+	if a.IsValid() != b.IsValid() {
+		return fmt.Errorf("%v: one side is nil", path)
+	}
+	if !a.IsValid() {
+		return nil
+	}
+	if a.Type() != b.Type() {
+		return fmt.Errorf("%v: %v != %v", path, a.Type(), b.Type())
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				return fmt.Errorf("%v: one side is nil", path)
+			}
+			return nil
+		}
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			return fmt.Errorf("%v: %v != %v", path, a.Elem().Type(), b.Elem().Type())
+		}
+		return equal(path, a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i, c := 0, a.NumField(); i < c; i++ {
+			f := a.Type().Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			p := f.Name
+			if path != "" {
+				p = path + "." + f.Name
+			}
+			if err := equal(p, a.Field(i), b.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return fmt.Errorf("%v: length %d != %d", path, a.Len(), b.Len())
+		}
+		for i, c := 0, a.Len(); i < c; i++ {
+			if err := equal(fmt.Sprintf("%v[%d]", path, i), a.Index(i), b.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if a.Interface() != b.Interface() {
+			return fmt.Errorf("%v: %#v != %#v", path, a.Interface(), b.Interface())
+		}
+		return nil
+	}
+}
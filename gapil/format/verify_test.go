@@ -0,0 +1,114 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/format"
+	"github.com/google/gapid/gapil/parser"
+)
+
+func TestVerify(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			name: "Alignment",
+			src: `class C {
+  u32 a // A
+  bool   bb = true
+}
+`,
+			expected: `class C {
+  u32  a         // A
+  bool bb = true
+}
+`,
+		},
+		{
+			name: "LeadingBlockComment",
+			src: `class C {
+  u32 a
+  /* B */ u32 bb
+}
+
+cmd void F() {
+  /* x */ x := 1
+  /* y */y := 2
+}
+`,
+			expected: `class C {
+  u32 a
+  /* B */
+  u32 bb
+}
+
+cmd void F() {
+  /* x */
+  x := 1
+  /* y */
+  y := 2
+}
+`,
+		},
+		{
+			name: "TrailingBlockComment",
+			src: `cmd void F(u32 a /* A */) { // F
+  x := a /* x */
+  y := /* one */ 1
+} /* end */
+`,
+			expected: `cmd void F(u32 a /* A */) { // F
+  x := a /* x */
+  y := /* one */ 1
+} /* end */
+`,
+		},
+		{
+			name:     "SharedLine",
+			src:      "cmd void F() {x := 1 y := 2}\n",
+			expected: "cmd void F() { x := 1 y := 2 }\n",
+		},
+	} {
+		got, err := format.Verify(test.name, []byte(test.src))
+		if assert.For("%v err", test.name).ThatError(err).Succeeded() {
+			assert.For("%v", test.name).ThatString(string(got)).Equals(test.expected)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	assert := assert.To(t)
+	parseAPI := func(src string) *ast.API {
+		api, errs := parser.Parse("test", src, parse.NewCSTMap())
+		assert.For("errs").ThatSlice(errs).IsEmpty()
+		return api
+	}
+	a := parseAPI("cmd void F() { x := 1 }")
+	assert.For("same").ThatError(format.Equal(a, parseAPI("cmd void F() {\n  x := 1\n}"))).Succeeded()
+	assert.For("value").ThatError(format.Equal(a, parseAPI("cmd void F() { x := 2 }"))).
+		HasMessage(`Commands[0].Block.Statements[0].RHS.Value: "1" != "2"`)
+	assert.For("name").ThatError(format.Equal(a, parseAPI("cmd void G() { x := 1 }"))).
+		HasMessage(`Commands[0].Generic.Name.Value: "F" != "G"`)
+	assert.For("length").ThatError(format.Equal(a, parseAPI("cmd void F() { x := 1\n y := 1 }"))).
+		HasMessage(`Commands[0].Block.Statements: length 1 != 2`)
+}
@@ -0,0 +1,276 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/text/parse"
+	"github.com/google/gapid/gapil"
+	"github.com/google/gapid/gapil/format"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapil/validate"
+)
+
+// Stage is a stage of the checking pipeline.
+type Stage string
+
+const (
+	ParseStage    = Stage("parse")
+	FormatStage   = Stage("format")
+	ResolveStage  = Stage("resolve")
+	ValidateStage = Stage("validate")
+	SemanticStage = Stage("semantic")
+	ExecuteStage  = Stage("execute")
+)
+
+// Failure is the error returned by Check and CheckSource when a stage of the
+// pipeline panics, or produces a result that disagrees with the program.
+type Failure struct {
+	Stage Stage
+	Panic bool
+	Err   error
+}
+
+func (f *Failure) Error() string {
+	if f.Panic {
+		return fmt.Sprintf("%v panicked: %v", f.Stage, f.Err)
+	}
+	return fmt.Sprintf("%v failed: %v", f.Stage, f.Err)
+}
+
+// Same returns true if err is a Failure of the same kind as f.
+func (f *Failure) Same(err error) bool {
+	o, ok := err.(*Failure)
+	return ok && o.Stage == f.Stage && o.Panic == f.Panic
+}
+
+// Executor is the interface implemented by types that can compile and
+// execute a resolved program.
+type Executor interface {
+	// Execute performs each of the program's calls in order, returning the
+	// final values of the globals keyed by name. Values are sign-extended
+	// (signed types) or zero-extended (unsigned types) to 64 bits. Float
+	// values are their IEEE 754 bits.
+	Execute(ctx context.Context, api *semantic.API, mappings *resolver.Mappings, p *Program) (map[string]uint64, error)
+}
+
+// Check runs the program p through the parse, format, resolve and validate
+// stages, then checks that the resolved API matches p. If e is not nil, then
+// the program's calls are executed by e and the resulting global values
+// compared against those of the reference interpreter.
+// As p is type-correct, any error reported by a stage is a failure.
+func Check(ctx context.Context, p *Program, e Executor) error {
+	src := string(p.Source())
+
+	var api *semantic.API
+	var mappings *resolver.Mappings
+	stages := []struct {
+		stage Stage
+		f     func() error
+	}{
+		{ParseStage, func() error {
+			_, errs := parser.Parse("fuzz.api", src, parse.NewCSTMap())
+			return errorList(errs)
+		}},
+		{FormatStage, func() error {
+			_, err := format.Verify("fuzz.api", []byte(src))
+			return err
+		}},
+		{ResolveStage, func() error {
+			processor := gapil.NewProcessor()
+			processor.Loader = gapil.NewDataLoader([]byte(src))
+			a, errs := processor.Resolve("fuzz.api")
+			api, mappings = a, processor.Mappings
+			return errorList(errs)
+		}},
+		{ValidateStage, func() error {
+			validate.Validate(api, mappings, nil)
+			return nil
+		}},
+		{SemanticStage, func() error {
+			return compare(api, p)
+		}},
+		{ExecuteStage, func() error {
+			if e == nil {
+				return nil
+			}
+			got, err := e.Execute(ctx, api, mappings, p)
+			if err != nil {
+				return err
+			}
+			return diffGlobals(p, p.Run(), got)
+		}},
+	}
+	for _, s := range stages {
+		if err := run(s.stage, s.f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSource runs arbitrary source data through the parse, format, resolve
+// and validate stages. As the data may not be valid, parse and resolve errors
+// are not failures, but panics are. Data that parses must also survive the
+// format stage, so an error from format.Verify is a failure too.
+func CheckSource(ctx context.Context, data []byte) error {
+	var parsed bool
+	if err := run(ParseStage, func() error {
+		_, errs := parser.Parse("fuzz.api", string(data), parse.NewCSTMap())
+		parsed = len(errs) == 0
+		return nil
+	}); err != nil {
+		return err
+	}
+	if parsed {
+		if err := run(FormatStage, func() error {
+			_, err := format.Verify("fuzz.api", data)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	processor := gapil.Processor{
+		Mappings:            resolver.NewMappings(),
+		Loader:              gapil.NewDataLoader(data),
+		Parsed:              map[string]gapil.ParseResult{},
+		Resolved:            map[string]gapil.ResolveResult{},
+		ResolveOnParseError: true,
+	}
+	var api *semantic.API
+	var errs parse.ErrorList
+	if err := run(ResolveStage, func() error {
+		api, errs = processor.Resolve("fuzz.api")
+		return nil
+	}); err != nil {
+		return err
+	}
+	if api == nil || len(errs) > 0 {
+		return nil
+	}
+	return run(ValidateStage, func() error {
+		validate.Validate(api, processor.Mappings, nil)
+		return nil
+	})
+}
+
+// run calls f, returning a Failure if f returns an error or panics.
+func run(stage Stage, f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := make([]byte, 1<<16)
+			stack = stack[:runtime.Stack(stack, false)]
+			err = &Failure{stage, true, fmt.Errorf("%v\n%s", r, stack)}
+		}
+	}()
+	if err := f(); err != nil {
+		return &Failure{stage, false, err}
+	}
+	return nil
+}
+
+func errorList(errs parse.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// compare returns an error if the globals, subroutines and commands of api
+// do not match those of p.
+func compare(api *semantic.API, p *Program) error {
+	problems := []string{}
+	check := func(what string, got, expect interface{}) {
+		if got != expect {
+			problems = append(problems, fmt.Sprintf("%v: got %v, expected %v", what, got, expect))
+		}
+	}
+	check("global count", len(api.Globals), len(p.Globals))
+	for i, g := range api.Globals {
+		if i < len(p.Globals) {
+			check("global name", g.Name(), p.Globals[i].Name)
+			check(g.Name()+" type", g.Type, semantic.Type(p.Globals[i].Type))
+		}
+	}
+	functions := func(l []*semantic.Function, expect map[string][]*Local) {
+		for _, f := range l {
+			params, ok := expect[f.Name()]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unexpected function %v", f.Name()))
+				continue
+			}
+			delete(expect, f.Name())
+			got := f.CallParameters()
+			check(f.Name()+" parameter count", len(got), len(params))
+			for i := 0; i < len(got) && i < len(params); i++ {
+				check(f.Name()+" parameter", got[i].Name(), params[i].Name)
+				check(got[i].Name()+" type", got[i].Type, semantic.Type(params[i].Type))
+			}
+		}
+		for name := range expect {
+			problems = append(problems, fmt.Sprintf("missing function %v", name))
+		}
+	}
+	subs := map[string][]*Local{}
+	for _, s := range p.Subs {
+		subs[s.Name] = s.Params
+	}
+	cmds := map[string][]*Local{}
+	for _, c := range p.Cmds {
+		cmds[c.Name] = c.Params
+	}
+	functions(api.Subroutines, subs)
+	functions(api.Functions, cmds)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%v", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// diffGlobals returns an error listing the globals of p that differ between
+// expect and got. As the bits of a NaN depend on the order of the operands,
+// any two NaNs are considered equal.
+func diffGlobals(p *Program, expect, got map[string]uint64) error {
+	types := make(map[string]*semantic.Builtin, len(p.Globals))
+	for _, g := range p.Globals {
+		types[g.Name] = g.Type
+	}
+	isNaN := func(name string, v uint64) bool {
+		t := types[name]
+		return isFloat(t) && math.IsNaN(toFloat(t, v))
+	}
+	problems := []string{}
+	for name, e := range expect {
+		if g, ok := got[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%v: missing", name))
+		} else if g != e && !(isNaN(name, g) && isNaN(name, e)) {
+			problems = append(problems, fmt.Sprintf("%v: got 0x%x, expected 0x%x", name, g, e))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("global values disagree:\n%v", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuzz provides fuzz testing of the api parser, resolver, validator
+// and compiler.
+//
+// Generate synthesises random, type-correct programs of bool, integer and
+// float values, which Check runs through each of the stages, reporting panics
+// and disagreements between the stages and a reference interpreter. Mutate
+// applies random type-preserving changes to a program, and failing programs
+// can be shrunk with Reduce.
+//
+// The fuzz targets can be run with:
+//
+//	go test -fuzz=FuzzGenerate ./gapil/fuzz/execute
+//	go test -fuzz=FuzzSource ./gapil/fuzz
+//
+// When built with the gofuzz tag, Fuzz is an entry point for
+// https://github.com/dvyukov/go-fuzz.
+package fuzz
@@ -0,0 +1,305 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// intTypes is the list of integer types used by generated programs.
+var intTypes = []*semantic.Builtin{
+	semantic.Int8Type, semantic.Uint8Type,
+	semantic.Int16Type, semantic.Uint16Type,
+	semantic.Int32Type, semantic.Uint32Type,
+	semantic.Int64Type, semantic.Uint64Type,
+}
+
+// floatTypes is the list of floating-point types used by generated programs.
+var floatTypes = []*semantic.Builtin{semantic.Float32Type, semantic.Float64Type}
+
+// bits returns the size of the bool, integer or float type t in bits.
+func bits(t *semantic.Builtin) uint {
+	switch t {
+	case semantic.BoolType, semantic.Int8Type, semantic.Uint8Type:
+		return 8
+	case semantic.Int16Type, semantic.Uint16Type:
+		return 16
+	case semantic.Int32Type, semantic.Uint32Type, semantic.Float32Type:
+		return 32
+	case semantic.Int64Type, semantic.Uint64Type, semantic.Float64Type:
+		return 64
+	}
+	panic(fmt.Errorf("Unsupported type %v", t.Name()))
+}
+
+func isSigned(t *semantic.Builtin) bool {
+	switch t {
+	case semantic.Int8Type, semantic.Int16Type, semantic.Int32Type, semantic.Int64Type:
+		return true
+	}
+	return false
+}
+
+func isFloat(t *semantic.Builtin) bool {
+	return t == semantic.Float32Type || t == semantic.Float64Type
+}
+
+// toFloat returns the value v of the float type t.
+func toFloat(t *semantic.Builtin, v uint64) float64 {
+	if t == semantic.Float32Type {
+		return float64(math.Float32frombits(uint32(v)))
+	}
+	return math.Float64frombits(v)
+}
+
+// fromFloat returns f rounded to the float type t, as held by a Literal.
+func fromFloat(t *semantic.Builtin, f float64) uint64 {
+	if t == semantic.Float32Type {
+		return uint64(math.Float32bits(float32(f)))
+	}
+	return math.Float64bits(f)
+}
+
+func hasBoolResult(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+// norm returns v truncated to the size of t, then sign-extended (signed types)
+// or zero-extended (unsigned and float types) to 64 bits.
+func norm(t *semantic.Builtin, v uint64) uint64 {
+	if t == semantic.BoolType {
+		if v != 0 {
+			return 1
+		}
+		return 0
+	}
+	shift := 64 - bits(t)
+	if isSigned(t) {
+		return uint64(int64(v<<shift) >> shift)
+	}
+	return (v << shift) >> shift
+}
+
+// Run executes the program's calls with a reference interpreter and returns
+// the final values of all the globals, keyed by name.
+func (p *Program) Run() map[string]uint64 {
+	globals := map[*Global]uint64{}
+	for _, g := range p.Globals {
+		if g.Init != nil {
+			globals[g] = g.Init.Value
+		} else {
+			globals[g] = 0
+		}
+	}
+	for _, c := range p.Calls {
+		e := &evaluator{globals: globals, locals: map[*Local]uint64{}}
+		for i, p := range c.Cmd.Params {
+			e.locals[p] = norm(p.Type, c.Args[i])
+		}
+		e.block(c.Cmd.Body)
+	}
+	out := make(map[string]uint64, len(globals))
+	for g, v := range globals {
+		out[g.Name] = v
+	}
+	return out
+}
+
+type evaluator struct {
+	globals map[*Global]uint64
+	locals  map[*Local]uint64
+}
+
+func (e *evaluator) block(l []Stmt) {
+	for _, s := range l {
+		switch s := s.(type) {
+		case *Declare:
+			e.locals[s.Local] = e.expr(s.Value)
+		case *Assign:
+			v := e.expr(s.Value)
+			switch s.Op {
+			case "+=":
+				v = arithmetic(s.Global.Type, "+", e.globals[s.Global], v)
+			case "-=":
+				v = arithmetic(s.Global.Type, "-", e.globals[s.Global], v)
+			}
+			e.globals[s.Global] = norm(s.Global.Type, v)
+		case *If:
+			if e.expr(s.Cond) != 0 {
+				e.block(s.Then)
+			} else {
+				e.block(s.Else)
+			}
+		default:
+			panic(fmt.Errorf("Unknown statement type %T", s))
+		}
+	}
+}
+
+func (e *evaluator) expr(x Expr) uint64 {
+	switch x := x.(type) {
+	case *Literal:
+		return norm(x.Of, x.Value)
+	case *LocalRef:
+		return e.locals[x.Local]
+	case *GlobalRef:
+		return e.globals[x.Global]
+	case *Not:
+		return norm(semantic.BoolType, 1^e.expr(x.Value))
+	case *Cast:
+		return convert(x.Value.Type(), x.To, e.expr(x.Value))
+	case *CallSub:
+		sub := &evaluator{globals: e.globals, locals: map[*Local]uint64{}}
+		for i, p := range x.Sub.Params {
+			sub.locals[p] = e.expr(x.Args[i])
+		}
+		for _, d := range x.Sub.Body {
+			sub.locals[d.Local] = sub.expr(d.Value)
+		}
+		return sub.expr(x.Sub.Return)
+	case *Binary:
+		return e.binary(x)
+	}
+	panic(fmt.Errorf("Unknown expression type %T", x))
+}
+
+func (e *evaluator) binary(x *Binary) uint64 {
+	t := x.LHS.Type()
+	a, b := e.expr(x.LHS), e.expr(x.RHS)
+	if !hasBoolResult(x.Op) {
+		return arithmetic(t, x.Op, a, b)
+	}
+	cond := func(v bool) uint64 {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	if isFloat(t) {
+		// Comparisons are ordered: all are false if either operand is NaN.
+		a, b := toFloat(t, a), toFloat(t, b)
+		switch x.Op {
+		case "==":
+			return cond(a == b)
+		case "!=":
+			return cond(a < b || a > b)
+		case "<":
+			return cond(a < b)
+		case "<=":
+			return cond(a <= b)
+		case ">":
+			return cond(a > b)
+		case ">=":
+			return cond(a >= b)
+		}
+		panic(fmt.Errorf("Unknown operator %v", x.Op))
+	}
+	less := func(a, b uint64) bool {
+		if isSigned(t) {
+			return int64(a) < int64(b)
+		}
+		return a < b
+	}
+	switch x.Op {
+	case "==":
+		return cond(a == b)
+	case "!=":
+		return cond(a != b)
+	case "<":
+		return cond(less(a, b))
+	case "<=":
+		return cond(!less(b, a))
+	case ">":
+		return cond(less(b, a))
+	case ">=":
+		return cond(!less(a, b))
+	case "&&":
+		return cond(a != 0 && b != 0)
+	case "||":
+		return cond(a != 0 || b != 0)
+	}
+	panic(fmt.Errorf("Unknown operator %v", x.Op))
+}
+
+// arithmetic returns the result of the arithmetic operator op applied to the
+// values a and b of type t.
+func arithmetic(t *semantic.Builtin, op string, a, b uint64) uint64 {
+	switch t {
+	case semantic.Float32Type:
+		// Each operation is explicitly rounded to 32 bits.
+		x, y := math.Float32frombits(uint32(a)), math.Float32frombits(uint32(b))
+		switch op {
+		case "+":
+			return uint64(math.Float32bits(x + y))
+		case "-":
+			return uint64(math.Float32bits(x - y))
+		case "*":
+			return uint64(math.Float32bits(x * y))
+		}
+	case semantic.Float64Type:
+		x, y := math.Float64frombits(a), math.Float64frombits(b)
+		switch op {
+		case "+":
+			return math.Float64bits(x + y)
+		case "-":
+			return math.Float64bits(x - y)
+		case "*":
+			return math.Float64bits(x * y)
+		}
+	default:
+		switch op {
+		case "+":
+			return norm(t, a+b)
+		case "-":
+			return norm(t, a-b)
+		case "*":
+			return norm(t, a*b)
+		case "&":
+			return norm(t, a&b)
+		case "|":
+			return norm(t, a|b)
+		}
+	}
+	panic(fmt.Errorf("Unknown operator %v for %v", op, t.Name()))
+}
+
+// convert returns the value v of type from converted to type to. Integers are
+// truncated or extended, and conversions to floats are rounded to nearest.
+// Floats are never converted to integers, as out of range values have no
+// defined result.
+func convert(from, to *semantic.Builtin, v uint64) uint64 {
+	if !isFloat(to) {
+		return norm(to, v)
+	}
+	switch {
+	case isFloat(from):
+		return fromFloat(to, toFloat(from, v))
+	case to == semantic.Float32Type && isSigned(from):
+		return uint64(math.Float32bits(float32(int64(v))))
+	case to == semantic.Float32Type:
+		return uint64(math.Float32bits(float32(v)))
+	case isSigned(from):
+		return math.Float64bits(float64(int64(v)))
+	default:
+		return math.Float64bits(float64(v))
+	}
+}
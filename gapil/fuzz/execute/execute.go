@@ -0,0 +1,186 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package execute provides a fuzz.Executor that compiles programs with the
+// gapil compiler and runs them with the gapil executor.
+package execute
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gapid/gapil/compiler"
+	"github.com/google/gapid/gapil/executor"
+	"github.com/google/gapid/gapil/fuzz"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/replay/builder"
+)
+
+// Executor is a fuzz.Executor that compiles and executes programs for the
+// host ABI.
+type Executor struct {
+	// Settings are the compiler settings. EmitExec is always enabled.
+	Settings compiler.Settings
+	// Optimize enables LLVM optimizations of the compiled program.
+	Optimize bool
+}
+
+var _ fuzz.Executor = Executor{}
+
+// Execute implements the fuzz.Executor interface.
+func (e Executor) Execute(ctx context.Context, a *semantic.API, mappings *resolver.Mappings, p *fuzz.Program) (map[string]uint64, error) {
+	settings := e.Settings
+	settings.EmitExec = true
+	program, err := compiler.Compile(a, mappings, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	env := executor.New(program, e.Optimize).NewEnv(ctx, &capture.Capture{})
+	defer env.Dispose()
+
+	for _, c := range p.Calls {
+		types := make([]semantic.Type, len(c.Cmd.Params))
+		for i, p := range c.Cmd.Params {
+			types[i] = p.Type
+		}
+		// Command parameters are preceded by the 64-bit thread index.
+		offsets, size := layout(types, 8)
+		cmd := &cmd{name: c.Cmd.Name, data: make([]byte, size)}
+		for i, v := range c.Args {
+			store(types[i], v, cmd.data[offsets[i]:])
+		}
+		if err := env.Execute(ctx, cmd); err != nil {
+			return nil, fmt.Errorf("%v: %v", c.Cmd.Name, err)
+		}
+	}
+
+	types := make([]semantic.Type, len(a.Globals))
+	for i, g := range a.Globals {
+		types[i] = g.Type
+	}
+	out := make(map[string]uint64, len(a.Globals))
+	offsets, _ := layout(types, 0)
+	for i, offset := range offsets {
+		g := a.Globals[i]
+		out[g.Name()] = load(g.Type, env.Globals[offset:])
+	}
+	return out, nil
+}
+
+// layout returns the offsets of each of the fields of a structure with the
+// given field types, starting at base, and the size of the structure. Fields
+// are naturally aligned, matching the layout used by the host ABI.
+func layout(types []semantic.Type, base int) (offsets []int, size int) {
+	offsets = make([]int, len(types))
+	offset := base
+	for i, t := range types {
+		size := sizeOf(t)
+		offset = (offset + size - 1) &^ (size - 1)
+		offsets[i] = offset
+		offset += size
+	}
+	// Round up to the largest possible field alignment.
+	return offsets, (offset + 7) &^ 7
+}
+
+func sizeOf(t semantic.Type) int {
+	switch t {
+	case semantic.BoolType, semantic.Int8Type, semantic.Uint8Type:
+		return 1
+	case semantic.Int16Type, semantic.Uint16Type:
+		return 2
+	case semantic.Int32Type, semantic.Uint32Type, semantic.Float32Type:
+		return 4
+	case semantic.Int64Type, semantic.Uint64Type, semantic.Float64Type:
+		return 8
+	}
+	panic(fmt.Errorf("Unsupported type %v", t.Name()))
+}
+
+// load returns the little-endian value of type t at the start of data,
+// sign-extended or zero-extended to 64 bits. Floats are returned as their
+// IEEE 754 bits.
+func load(t semantic.Type, data []byte) uint64 {
+	switch t {
+	case semantic.BoolType:
+		if data[0] != 0 {
+			return 1
+		}
+		return 0
+	case semantic.Int8Type:
+		return uint64(int8(data[0]))
+	case semantic.Uint8Type:
+		return uint64(data[0])
+	case semantic.Int16Type:
+		return uint64(int16(binary.LittleEndian.Uint16(data)))
+	case semantic.Uint16Type:
+		return uint64(binary.LittleEndian.Uint16(data))
+	case semantic.Int32Type:
+		return uint64(int32(binary.LittleEndian.Uint32(data)))
+	case semantic.Uint32Type, semantic.Float32Type:
+		return uint64(binary.LittleEndian.Uint32(data))
+	default:
+		return binary.LittleEndian.Uint64(data)
+	}
+}
+
+// store writes the value v of type t to the start of data in little-endian.
+func store(t semantic.Type, v uint64, data []byte) {
+	switch sizeOf(t) {
+	case 1:
+		data[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(data, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(data, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(data, v)
+	}
+}
+
+// cmd is an api.Cmd holding the encoded parameters of a command call.
+type cmd struct {
+	name string
+	data []byte // Sized by layout to hold the thread index and parameters.
+}
+
+var (
+	_ api.Cmd            = &cmd{}
+	_ executor.Encodable = &cmd{}
+)
+
+func (c *cmd) API() api.API                                                       { return nil }
+func (c *cmd) Caller() api.CmdID                                                  { return 0 }
+func (c *cmd) SetCaller(api.CmdID)                                                {}
+func (c *cmd) Thread() uint64                                                     { return 0 }
+func (c *cmd) SetThread(uint64)                                                   {}
+func (c *cmd) CmdName() string                                                    { return c.name }
+func (c *cmd) CmdParams() api.Properties                                          { return nil }
+func (c *cmd) CmdResult() *api.Property                                           { return nil }
+func (c *cmd) CmdFlags(context.Context, api.CmdID, *api.GlobalState) api.CmdFlags { return 0 }
+func (c *cmd) Extras() *api.CmdExtras                                             { return &api.CmdExtras{} }
+func (c *cmd) Mutate(context.Context, api.CmdID, *api.GlobalState, *builder.Builder) error {
+	return nil
+}
+
+// Encode implements the executor.Encodable interface.
+func (c *cmd) Encode(out []byte) bool {
+	copy(out, c.data)
+	return true
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execute_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/fuzz"
+	"github.com/google/gapid/gapil/fuzz/execute"
+)
+
+// TestExecute checks that the compiled programs agree with the reference
+// interpreter.
+func TestExecute(t *testing.T) {
+	ctx := log.Testing(t)
+	e := execute.Executor{}
+	for seed := int64(0); seed < 100; seed++ {
+		p := fuzz.Generate(rand.New(rand.NewSource(seed)), fuzz.DefaultOptions)
+		if err := fuzz.Check(ctx, p, e); err != nil {
+			f := err.(*fuzz.Failure)
+			fuzz.Reduce(p, func(p *fuzz.Program) bool { return f.Same(fuzz.Check(ctx, p, e)) })
+			log.E(ctx, "Seed %v: %v\nReduced program:\n%v", seed, err, p)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package execute_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/fuzz"
+	"github.com/google/gapid/gapil/fuzz/execute"
+)
+
+// FuzzGenerate checks that randomly generated and mutated programs pass
+// through the pipeline, and that the compiled programs agree with the
+// reference interpreter.
+// Run with: go test -fuzz=FuzzGenerate ./gapil/fuzz/execute
+func FuzzGenerate(f *testing.F) {
+	e := execute.Executor{}
+	for seed := int64(0); seed < 16; seed++ {
+		f.Add(seed, uint8(seed))
+	}
+	f.Fuzz(func(t *testing.T, seed int64, mutations uint8) {
+		ctx := log.Testing(t)
+		r := rand.New(rand.NewSource(seed))
+		p := fuzz.Generate(r, fuzz.DefaultOptions)
+		for i := 0; i < int(mutations); i++ {
+			fuzz.Mutate(r, p)
+		}
+		if err := fuzz.Check(ctx, p, e); err != nil {
+			failure := err.(*fuzz.Failure)
+			fuzz.Reduce(p, func(p *fuzz.Program) bool { return failure.Same(fuzz.Check(ctx, p, e)) })
+			t.Fatalf("%v\nReduced program:\n%v", err, p)
+		}
+	})
+}
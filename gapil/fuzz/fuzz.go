@@ -14,9 +14,8 @@
 
 // +build gofuzz
 
-// Package fuzz is a fuzzing test for the api parser and compiler.
-//
 // See: https://github.com/dvyukov/go-fuzz
+
 package fuzz
 
 import (
@@ -0,0 +1,53 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package fuzz_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/fuzz"
+)
+
+// FuzzSource checks mutations of the corpus api files.
+// Run with: go test -fuzz=FuzzSource ./gapil/fuzz
+func FuzzSource(f *testing.F) {
+	files, err := filepath.Glob("corpus/*.api")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx := log.Testing(t)
+		if err := fuzz.CheckSource(ctx, data); err != nil {
+			failure := err.(*fuzz.Failure)
+			reduced := fuzz.ReduceSource(data, func(data []byte) bool {
+				return failure.Same(fuzz.CheckSource(ctx, data))
+			})
+			t.Fatalf("%v\nReduced source:\n%s", err, reduced)
+		}
+	})
+}
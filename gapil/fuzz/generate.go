@@ -0,0 +1,313 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// Options controls the size and shape of generated programs.
+type Options struct {
+	MaxGlobals    int // Maximum number of global variables.
+	MaxSubs       int // Maximum number of subroutines.
+	MaxCmds       int // Maximum number of commands.
+	MaxParams     int // Maximum number of parameters per function.
+	MaxStatements int // Maximum number of statements per block.
+	MaxDepth      int // Maximum depth of expression and statement trees.
+	MaxCalls      int // Maximum number of command calls.
+}
+
+// DefaultOptions are the default options used for program generation.
+var DefaultOptions = Options{
+	MaxGlobals:    8,
+	MaxSubs:       4,
+	MaxCmds:       4,
+	MaxParams:     4,
+	MaxStatements: 6,
+	MaxDepth:      4,
+	MaxCalls:      8,
+}
+
+// Generate returns a new random, type-correct program using the random
+// source r.
+func Generate(r *rand.Rand, o Options) *Program {
+	g := &generator{r: r, o: o, p: &Program{}}
+	g.generate()
+	return g.p
+}
+
+type generator struct {
+	r      *rand.Rand
+	o      Options
+	p      *Program
+	scope  []*Local // Locals and parameters visible to the current block.
+	locals int      // Number of locals declared in the current function.
+	subs   []*Sub   // Subroutines callable from the current function.
+}
+
+func (g *generator) generate() {
+	for i, n := 0, 1+g.r.Intn(g.o.MaxGlobals); i < n; i++ {
+		t := g.typ()
+		glob := &Global{Name: fmt.Sprintf("g%d", i), Type: t}
+		if g.r.Intn(2) == 0 {
+			glob.Init = g.literal(t)
+		}
+		g.p.Globals = append(g.p.Globals, glob)
+	}
+
+	for i, n := 0, g.r.Intn(g.o.MaxSubs+1); i < n; i++ {
+		// Subroutines can only call those declared before them, which
+		// prevents recursion.
+		g.subs = g.p.Subs
+		s := &Sub{Name: fmt.Sprintf("s%d", i), Result: g.typ(), Params: g.params()}
+		g.function(s.Params)
+		for j, n := 0, g.r.Intn(g.o.MaxStatements); j < n; j++ {
+			s.Body = append(s.Body, g.declare(0))
+		}
+		s.Return = g.expr(s.Result, 0)
+		g.p.Subs = append(g.p.Subs, s)
+	}
+
+	g.subs = g.p.Subs
+	for i, n := 0, 1+g.r.Intn(g.o.MaxCmds); i < n; i++ {
+		c := &Cmd{Name: fmt.Sprintf("c%d", i), Params: g.params()}
+		g.function(c.Params)
+		c.Body = g.block(0)
+		g.p.Cmds = append(g.p.Cmds, c)
+	}
+
+	for i, n := 0, 1+g.r.Intn(g.o.MaxCalls); i < n; i++ {
+		c := g.p.Cmds[g.r.Intn(len(g.p.Cmds))]
+		args := make([]uint64, len(c.Params))
+		for j, p := range c.Params {
+			args[j] = g.literal(p.Type).Value
+		}
+		g.p.Calls = append(g.p.Calls, &Call{Cmd: c, Args: args})
+	}
+}
+
+// function resets the generator state for a new function with the given
+// parameters.
+func (g *generator) function(params []*Local) {
+	g.scope = append([]*Local{}, params...)
+	g.locals = 0
+}
+
+func (g *generator) params() []*Local {
+	out := make([]*Local, g.r.Intn(g.o.MaxParams+1))
+	for i := range out {
+		out[i] = &Local{Name: fmt.Sprintf("p%d", i), Type: g.typ()}
+	}
+	return out
+}
+
+// typ returns a random bool, integer or float type.
+func (g *generator) typ() *semantic.Builtin {
+	if g.r.Intn(len(intTypes)+len(floatTypes)+1) == 0 {
+		return semantic.BoolType
+	}
+	return g.numberType()
+}
+
+func (g *generator) intType() *semantic.Builtin {
+	return intTypes[g.r.Intn(len(intTypes))]
+}
+
+// numberType returns a random integer or float type.
+func (g *generator) numberType() *semantic.Builtin {
+	i := g.r.Intn(len(intTypes) + len(floatTypes))
+	if i < len(intTypes) {
+		return intTypes[i]
+	}
+	return floatTypes[i-len(intTypes)]
+}
+
+// literal returns a random literal of type t, biased towards interesting
+// values.
+func (g *generator) literal(t *semantic.Builtin) *Literal {
+	if t == semantic.BoolType {
+		return &Literal{t, uint64(g.r.Intn(2))}
+	}
+	if isFloat(t) {
+		return &Literal{t, fromFloat(t, g.float(t))}
+	}
+	var v uint64
+	switch g.r.Intn(6) {
+	case 0:
+		v = 0
+	case 1:
+		v = 1
+	case 2: // Maximum value.
+		v = ^uint64(0)
+		if isSigned(t) {
+			v >>= 65 - bits(t)
+		}
+	case 3: // Minimum value, or -1 for signed 64-bit integers.
+		if isSigned(t) {
+			v = ^uint64(0) << (bits(t) - 1)
+			if bits(t) == 64 {
+				v = ^uint64(0)
+			}
+		}
+	default:
+		v = uint64(g.r.Int63())
+	}
+	return &Literal{t, norm(t, v)}
+}
+
+// float returns a random finite value representable by the float type t.
+func (g *generator) float(t *semantic.Builtin) float64 {
+	max := math.MaxFloat64
+	if t == semantic.Float32Type {
+		max = math.MaxFloat32
+	}
+	switch g.r.Intn(7) {
+	case 0:
+		return 0
+	case 1:
+		return 1
+	case 2:
+		return -0.5
+	case 3: // Largest finite value.
+		return max
+	case 4: // Smallest denormal value.
+		return math.SmallestNonzeroFloat32
+	default:
+		return g.r.NormFloat64() * math.Pow(10, float64(g.r.Intn(20)-10))
+	}
+}
+
+// block returns a random list of statements for a command body.
+func (g *generator) block(depth int) []Stmt {
+	scope := len(g.scope)
+	defer func() { g.scope = g.scope[:scope] }()
+
+	out := []Stmt{}
+	for i, n := 0, 1+g.r.Intn(g.o.MaxStatements); i < n; i++ {
+		switch g.r.Intn(4) {
+		case 0:
+			out = append(out, g.declare(depth))
+		case 1:
+			if depth < g.o.MaxDepth {
+				s := &If{Cond: g.expr(semantic.BoolType, depth+1), Then: g.block(depth + 1)}
+				if g.r.Intn(2) == 0 {
+					s.Else = g.block(depth + 1)
+				}
+				out = append(out, s)
+				break
+			}
+			fallthrough
+		default:
+			glob := g.p.Globals[g.r.Intn(len(g.p.Globals))]
+			s := &Assign{Global: glob, Op: "=", Value: g.expr(glob.Type, depth+1)}
+			if glob.Type != semantic.BoolType {
+				s.Op = []string{"=", "+=", "-="}[g.r.Intn(3)]
+			}
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (g *generator) declare(depth int) *Declare {
+	t := g.typ()
+	s := &Declare{Value: g.expr(t, depth+1)}
+	s.Local = &Local{Name: fmt.Sprintf("l%d", g.locals), Type: t}
+	g.locals++
+	g.scope = append(g.scope, s.Local)
+	return s
+}
+
+// expr returns a random expression of type t.
+func (g *generator) expr(t *semantic.Builtin, depth int) Expr {
+	if depth >= g.o.MaxDepth {
+		return g.leaf(t)
+	}
+	depth++
+	switch g.r.Intn(5) {
+	case 0:
+		return g.leaf(t)
+	case 1:
+		if t == semantic.BoolType {
+			return &Not{g.expr(t, depth)}
+		}
+		if isFloat(t) {
+			// Integers and floats can be converted to floats.
+			return &Cast{To: t, Value: g.expr(g.numberType(), depth)}
+		}
+		return &Cast{To: t, Value: g.expr(g.intType(), depth)}
+	case 2:
+		if s := g.sub(t); s != nil {
+			args := make([]Expr, len(s.Params))
+			for i, p := range s.Params {
+				args[i] = g.expr(p.Type, depth)
+			}
+			return &CallSub{Sub: s, Args: args}
+		}
+		return g.leaf(t)
+	default:
+		if t != semantic.BoolType {
+			ops := arithmeticOpsFor(t)
+			return &Binary{Op: ops[g.r.Intn(len(ops))], LHS: g.expr(t, depth), RHS: g.expr(t, depth)}
+		}
+		switch g.r.Intn(3) {
+		case 0:
+			op := logicalOps[g.r.Intn(len(logicalOps))]
+			return &Binary{Op: op, LHS: g.expr(t, depth), RHS: g.expr(t, depth)}
+		default:
+			op := comparisonOps[g.r.Intn(len(comparisonOps))]
+			o := g.numberType()
+			return &Binary{Op: op, LHS: g.expr(o, depth), RHS: g.expr(o, depth)}
+		}
+	}
+}
+
+// leaf returns a literal, or a random local, parameter or global of type t.
+func (g *generator) leaf(t *semantic.Builtin) Expr {
+	candidates := []Expr{}
+	for _, l := range g.scope {
+		if l.Type == t {
+			candidates = append(candidates, &LocalRef{l})
+		}
+	}
+	for _, glob := range g.p.Globals {
+		if glob.Type == t {
+			candidates = append(candidates, &GlobalRef{glob})
+		}
+	}
+	if len(candidates) == 0 || g.r.Intn(3) == 0 {
+		return g.literal(t)
+	}
+	return candidates[g.r.Intn(len(candidates))]
+}
+
+// sub returns a random callable subroutine that returns t, or nil if there
+// are none.
+func (g *generator) sub(t *semantic.Builtin) *Sub {
+	candidates := []*Sub{}
+	for _, s := range g.subs {
+		if s.Result == t {
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[g.r.Intn(len(candidates))]
+}
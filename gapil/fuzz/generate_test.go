@@ -0,0 +1,170 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz_test
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapil/fuzz"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// TestGenerate checks that generated programs pass through the pipeline
+// without failure.
+func TestGenerate(t *testing.T) {
+	ctx := log.Testing(t)
+	for seed := int64(0); seed < 200; seed++ {
+		p := fuzz.Generate(rand.New(rand.NewSource(seed)), fuzz.DefaultOptions)
+		if err := fuzz.Check(ctx, p, nil); err != nil {
+			f := err.(*fuzz.Failure)
+			fuzz.Reduce(p, func(p *fuzz.Program) bool { return f.Same(fuzz.Check(ctx, p, nil)) })
+			log.E(ctx, "Seed %v: %v\nReduced program:\n%v", seed, err, p)
+		}
+	}
+}
+
+// TestMutate checks that mutated programs remain type-correct.
+func TestMutate(t *testing.T) {
+	ctx := log.Testing(t)
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		p := fuzz.Generate(r, fuzz.DefaultOptions)
+		for i := 0; i < 20; i++ {
+			fuzz.Mutate(r, p)
+		}
+		err := fuzz.Check(ctx, p, nil)
+		assert.For(ctx, "Seed %v", seed).ThatError(err).Succeeded()
+	}
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.To(t)
+	u8, s16 := semantic.Uint8Type, semantic.Int16Type
+	a := &fuzz.Global{Name: "a", Type: u8, Init: &fuzz.Literal{Of: u8, Value: 250}}
+	b := &fuzz.Global{Name: "b", Type: s16}
+	c := &fuzz.Global{Name: "c", Type: semantic.BoolType}
+	p0 := &fuzz.Local{Name: "p0", Type: u8}
+	cmd := &fuzz.Cmd{Name: "c0", Params: []*fuzz.Local{p0}, Body: []fuzz.Stmt{
+		&fuzz.Assign{Global: a, Op: "+=", Value: &fuzz.LocalRef{Local: p0}},
+		&fuzz.Assign{Global: b, Op: "-=", Value: &fuzz.Cast{To: s16, Value: &fuzz.GlobalRef{Global: a}}},
+		&fuzz.If{
+			Cond: &fuzz.Binary{Op: "<", LHS: &fuzz.GlobalRef{Global: b}, RHS: &fuzz.Literal{Of: s16, Value: 0}},
+			Then: []fuzz.Stmt{&fuzz.Assign{Global: c, Op: "=", Value: &fuzz.Literal{Of: semantic.BoolType, Value: 1}}},
+		},
+	}}
+	p := &fuzz.Program{
+		Globals: []*fuzz.Global{a, b, c},
+		Cmds:    []*fuzz.Cmd{cmd},
+		Calls: []*fuzz.Call{
+			{Cmd: cmd, Args: []uint64{10}},
+			{Cmd: cmd, Args: []uint64{1}},
+		},
+	}
+	assert.For("source").ThatString(string(p.Source())).Equals(`u8 a = as!u8(250)
+s16 b
+bool c
+
+cmd void c0(u8 p0) {
+  a += p0
+  b -= as!s16(a)
+  if (b < as!s16(0)) {
+    c = true
+  }
+}
+`)
+	// a: 250 + 10 = 4 (wrapped), then 5.
+	// b: 0 - 4 - 5 = -9.
+	assert.For("globals").That(p.Run()).DeepEquals(map[string]uint64{
+		"a": 5,
+		"b": ^uint64(8),
+		"c": 1,
+	})
+	assert.For("check").ThatError(fuzz.Check(log.Testing(t), p, nil)).Succeeded()
+}
+
+func TestRunFloat(t *testing.T) {
+	assert := assert.To(t)
+	f32, f64 := semantic.Float32Type, semantic.Float64Type
+	a := &fuzz.Global{Name: "a", Type: f32}
+	b := &fuzz.Global{Name: "b", Type: f64}
+	c := &fuzz.Global{Name: "c", Type: semantic.BoolType}
+	p0 := &fuzz.Local{Name: "p0", Type: semantic.Uint32Type}
+	cmd := &fuzz.Cmd{Name: "c0", Params: []*fuzz.Local{p0}, Body: []fuzz.Stmt{
+		&fuzz.Assign{Global: a, Op: "+=", Value: &fuzz.Cast{To: f32, Value: &fuzz.LocalRef{Local: p0}}},
+		&fuzz.Assign{Global: b, Op: "=", Value: &fuzz.Binary{Op: "*",
+			LHS: &fuzz.Cast{To: f64, Value: &fuzz.GlobalRef{Global: a}},
+			RHS: &fuzz.Literal{Of: f64, Value: math.Float64bits(-0.5)},
+		}},
+		&fuzz.Assign{Global: c, Op: "=", Value: &fuzz.Binary{Op: "!=",
+			LHS: &fuzz.GlobalRef{Global: b},
+			RHS: &fuzz.GlobalRef{Global: b},
+		}},
+	}}
+	p := &fuzz.Program{
+		Globals: []*fuzz.Global{a, b, c},
+		Cmds:    []*fuzz.Cmd{cmd},
+		Calls:   []*fuzz.Call{{Cmd: cmd, Args: []uint64{16777217}}},
+	}
+	assert.For("source").ThatString(string(p.Source())).Equals(`f32 a
+f64 b
+bool c
+
+cmd void c0(u32 p0) {
+  a += as!f32(p0)
+  b = (as!f64(a) * as!f64(-0.5))
+  c = (b != b)
+}
+`)
+	// 16777217 is not representable as a f32, and rounds to 16777216.
+	assert.For("globals").That(p.Run()).DeepEquals(map[string]uint64{
+		"a": uint64(math.Float32bits(16777216)),
+		"b": math.Float64bits(-8388608),
+		"c": 0,
+	})
+	assert.For("check").ThatError(fuzz.Check(log.Testing(t), p, nil)).Succeeded()
+}
+
+func TestReduce(t *testing.T) {
+	assert := assert.To(t)
+	hasMul := func(p *fuzz.Program) bool { return bytes.Contains(p.Source(), []byte(" * ")) }
+	for seed := int64(0); ; seed++ {
+		p := fuzz.Generate(rand.New(rand.NewSource(seed)), fuzz.DefaultOptions)
+		if !hasMul(p) {
+			continue
+		}
+		before := len(p.Source())
+		fuzz.Reduce(p, hasMul)
+		assert.For("still failing").That(hasMul(p)).Equals(true)
+		assert.For("reduced").That(len(p.Source()) < before).Equals(true)
+		assert.For("calls").That(len(p.Calls)).Equals(0)
+		assert.For("check").ThatError(fuzz.Check(log.Testing(t), p, nil)).Succeeded()
+		return
+	}
+}
+
+func TestReduceSource(t *testing.T) {
+	assert := assert.To(t)
+	src := "a\nb\nc\nd\ne\nf\ng\n"
+	got := fuzz.ReduceSource([]byte(src), func(data []byte) bool {
+		return strings.Contains(string(data), "c\n") && strings.Contains(string(data), "f\n")
+	})
+	assert.For("reduced").ThatString(string(got)).Equals("c\nf\n")
+}
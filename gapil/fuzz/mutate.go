@@ -0,0 +1,136 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"math/rand"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+var (
+	arithmeticOps = []string{"+", "-", "*", "&", "|"}
+	floatOps      = []string{"+", "-", "*"}
+	logicalOps    = []string{"&&", "||", "==", "!="}
+	comparisonOps = []string{"==", "!=", "<", "<=", ">", ">="}
+)
+
+// Mutate applies a random type-preserving mutation to p using the random
+// source r. p is modified in place and returned.
+func Mutate(r *rand.Rand, p *Program) *Program {
+	g := &generator{r: r, p: p}
+	exprs := []*Expr{}
+	visitExprs(p, func(e *Expr) { exprs = append(exprs, e) })
+	ifs := []*If{}
+	assigns := []*Assign{}
+	var stmts func(l []Stmt)
+	stmts = func(l []Stmt) {
+		for _, s := range l {
+			switch s := s.(type) {
+			case *Assign:
+				assigns = append(assigns, s)
+			case *If:
+				ifs = append(ifs, s)
+				stmts(s.Then)
+				stmts(s.Else)
+			}
+		}
+	}
+	for _, c := range p.Cmds {
+		stmts(c.Body)
+	}
+
+	switch r.Intn(5) {
+	case 0: // Mutate an expression.
+		if len(exprs) == 0 {
+			break
+		}
+		e := exprs[r.Intn(len(exprs))]
+		switch x := (*e).(type) {
+		case *Binary:
+			x.Op = mutateOp(r, x)
+		case *Not:
+			*e = x.Value
+		default:
+			if x.Type() == semantic.BoolType {
+				*e = &Not{x}
+			} else {
+				ops := arithmeticOpsFor(x.Type())
+				*e = &Binary{Op: ops[r.Intn(len(ops))], LHS: x, RHS: g.literal(x.Type())}
+			}
+		}
+	case 1: // Swap the branches of a conditional.
+		if len(ifs) == 0 {
+			break
+		}
+		s := ifs[r.Intn(len(ifs))]
+		s.Then, s.Else = s.Else, s.Then
+		if len(s.Then) == 0 {
+			// An empty then block is legal, but uninteresting.
+			s.Then, s.Else = s.Else, nil
+			s.Cond = &Not{s.Cond}
+		}
+	case 2: // Change the operator of an assignment.
+		if len(assigns) == 0 {
+			break
+		}
+		s := assigns[r.Intn(len(assigns))]
+		if s.Global.Type != semantic.BoolType {
+			s.Op = []string{"=", "+=", "-="}[r.Intn(3)]
+		}
+	case 3: // Change the arguments of a call.
+		if len(p.Calls) == 0 {
+			break
+		}
+		c := p.Calls[r.Intn(len(p.Calls))]
+		for i, param := range c.Cmd.Params {
+			if r.Intn(2) == 0 {
+				c.Args[i] = g.literal(param.Type).Value
+			}
+		}
+	case 4: // Duplicate a call.
+		if len(p.Calls) == 0 {
+			break
+		}
+		i := r.Intn(len(p.Calls))
+		c := *p.Calls[i]
+		c.Args = append([]uint64{}, c.Args...)
+		p.Calls = append(p.Calls[:i+1], append([]*Call{&c}, p.Calls[i+1:]...)...)
+	}
+	return p
+}
+
+// mutateOp returns a random operator that can replace the operator of e
+// without changing the types of the expression or its operands.
+func mutateOp(r *rand.Rand, e *Binary) string {
+	switch {
+	case !hasBoolResult(e.Op):
+		ops := arithmeticOpsFor(e.Type())
+		return ops[r.Intn(len(ops))]
+	case e.LHS.Type() == semantic.BoolType:
+		return logicalOps[r.Intn(len(logicalOps))]
+	default:
+		return comparisonOps[r.Intn(len(comparisonOps))]
+	}
+}
+
+// arithmeticOpsFor returns the arithmetic operators that can be applied to
+// values of the integer or float type t.
+func arithmeticOpsFor(t *semantic.Builtin) []string {
+	if isFloat(t) {
+		return floatOps
+	}
+	return arithmeticOps
+}
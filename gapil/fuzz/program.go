@@ -0,0 +1,290 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// Program is a randomly generated, type-correct gapil program along with a
+// sequence of command calls to execute against it.
+type Program struct {
+	Globals []*Global
+	Subs    []*Sub
+	Cmds    []*Cmd
+	Calls   []*Call
+}
+
+// Global is a global variable declaration.
+type Global struct {
+	Name string
+	Type *semantic.Builtin
+	Init *Literal // Optional initial value.
+}
+
+// Local is a local variable or a parameter.
+type Local struct {
+	Name string
+	Type *semantic.Builtin
+}
+
+// Sub is a subroutine declaration.
+// Subroutines are pure: they only declare locals and return a value.
+type Sub struct {
+	Name   string
+	Result *semantic.Builtin
+	Params []*Local
+	Body   []*Declare
+	Return Expr
+}
+
+// Cmd is a command declaration.
+type Cmd struct {
+	Name   string
+	Params []*Local
+	Body   []Stmt
+}
+
+// Call is a single invocation of a command.
+type Call struct {
+	Cmd  *Cmd
+	Args []uint64
+}
+
+// Stmt is the interface implemented by all statements.
+type Stmt interface {
+	write(w *writer)
+}
+
+// Declare is a local variable declaration statement.
+type Declare struct {
+	Local *Local
+	Value Expr
+}
+
+// Assign is an assignment to a global.
+type Assign struct {
+	Global *Global
+	Op     string // '=', '+=' or '-='
+	Value  Expr
+}
+
+// If is a conditional statement.
+type If struct {
+	Cond Expr
+	Then []Stmt
+	Else []Stmt
+}
+
+// Expr is the interface implemented by all expressions.
+type Expr interface {
+	// Type returns the type of the expression.
+	Type() *semantic.Builtin
+	write(w *writer)
+}
+
+// Literal is a constant value of a builtin type.
+// Values are held sign-extended (signed types) or zero-extended (unsigned
+// types) to 64 bits. Float values are held as their IEEE 754 bits.
+type Literal struct {
+	Of    *semantic.Builtin
+	Value uint64
+}
+
+// LocalRef is a read of a local or parameter.
+type LocalRef struct{ Local *Local }
+
+// GlobalRef is a read of a global.
+type GlobalRef struct{ Global *Global }
+
+// Binary is a binary operator expression.
+type Binary struct {
+	Op       string
+	LHS, RHS Expr
+}
+
+// Not is a logical not expression.
+type Not struct{ Value Expr }
+
+// Cast is an explicit conversion between integers, or to a float.
+type Cast struct {
+	To    *semantic.Builtin
+	Value Expr
+}
+
+// CallSub is a subroutine call expression.
+type CallSub struct {
+	Sub  *Sub
+	Args []Expr
+}
+
+func (e *Literal) Type() *semantic.Builtin   { return e.Of }
+func (e *LocalRef) Type() *semantic.Builtin  { return e.Local.Type }
+func (e *GlobalRef) Type() *semantic.Builtin { return e.Global.Type }
+func (e *Not) Type() *semantic.Builtin       { return semantic.BoolType }
+func (e *Cast) Type() *semantic.Builtin      { return e.To }
+func (e *CallSub) Type() *semantic.Builtin   { return e.Sub.Result }
+func (e *Binary) Type() *semantic.Builtin {
+	if hasBoolResult(e.Op) {
+		return semantic.BoolType
+	}
+	return e.LHS.Type()
+}
+
+// Source returns the gapil source for the program.
+func (p *Program) Source() []byte {
+	w := &writer{}
+	for _, g := range p.Globals {
+		g.write(w)
+	}
+	for _, s := range p.Subs {
+		w.line("")
+		s.write(w)
+	}
+	for _, c := range p.Cmds {
+		w.line("")
+		c.write(w)
+	}
+	return w.buf.Bytes()
+}
+
+// String returns the gapil source for the program followed by the list of
+// calls as comments.
+func (p *Program) String() string {
+	w := &writer{}
+	w.buf.Write(p.Source())
+	if len(p.Calls) > 0 {
+		w.line("")
+	}
+	for _, c := range p.Calls {
+		args := make([]string, len(c.Args))
+		for i, a := range c.Args {
+			args[i] = (&Literal{c.Cmd.Params[i].Type, a}).String()
+		}
+		w.line("// %v(%v)", c.Cmd.Name, strings.Join(args, ", "))
+	}
+	return w.buf.String()
+}
+
+type writer struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (w *writer) line(msg string, args ...interface{}) {
+	w.buf.WriteString(strings.Repeat("  ", w.indent))
+	fmt.Fprintf(&w.buf, msg, args...)
+	w.buf.WriteRune('\n')
+}
+
+func (w *writer) expr(e Expr) string {
+	sub := &writer{}
+	e.write(sub)
+	return sub.buf.String()
+}
+
+func (w *writer) block(l []Stmt) {
+	w.indent++
+	for _, s := range l {
+		s.write(w)
+	}
+	w.indent--
+}
+
+func params(l []*Local) string {
+	out := make([]string, len(l))
+	for i, p := range l {
+		out[i] = p.Type.Name() + " " + p.Name
+	}
+	return strings.Join(out, ", ")
+}
+
+func (g *Global) write(w *writer) {
+	if g.Init != nil {
+		w.line("%v %v = %v", g.Type.Name(), g.Name, w.expr(g.Init))
+	} else {
+		w.line("%v %v", g.Type.Name(), g.Name)
+	}
+}
+
+func (s *Sub) write(w *writer) {
+	w.line("sub %v %v(%v) {", s.Result.Name(), s.Name, params(s.Params))
+	w.indent++
+	for _, d := range s.Body {
+		d.write(w)
+	}
+	w.line("return %v", w.expr(s.Return))
+	w.indent--
+	w.line("}")
+}
+
+func (c *Cmd) write(w *writer) {
+	w.line("cmd void %v(%v) {", c.Name, params(c.Params))
+	w.block(c.Body)
+	w.line("}")
+}
+
+func (s *Declare) write(w *writer) {
+	w.line("%v := %v", s.Local.Name, w.expr(s.Value))
+}
+
+func (s *Assign) write(w *writer) {
+	w.line("%v %v %v", s.Global.Name, s.Op, w.expr(s.Value))
+}
+
+func (s *If) write(w *writer) {
+	w.line("if %v {", w.expr(s.Cond))
+	w.block(s.Then)
+	if len(s.Else) > 0 {
+		w.line("} else {")
+		w.block(s.Else)
+	}
+	w.line("}")
+}
+
+func (e *Literal) String() string {
+	switch {
+	case e.Of == semantic.BoolType:
+		return fmt.Sprint(e.Value != 0)
+	case isFloat(e.Of):
+		f := strconv.FormatFloat(toFloat(e.Of, e.Value), 'g', -1, int(bits(e.Of)))
+		return fmt.Sprintf("as!%v(%v)", e.Of.Name(), f)
+	case isSigned(e.Of):
+		return fmt.Sprintf("as!%v(%d)", e.Of.Name(), int64(e.Value))
+	default:
+		return fmt.Sprintf("as!%v(%d)", e.Of.Name(), e.Value)
+	}
+}
+
+func (e *Literal) write(w *writer)   { w.buf.WriteString(e.String()) }
+func (e *LocalRef) write(w *writer)  { w.buf.WriteString(e.Local.Name) }
+func (e *GlobalRef) write(w *writer) { w.buf.WriteString(e.Global.Name) }
+func (e *Not) write(w *writer)       { fmt.Fprintf(&w.buf, "!(%v)", w.expr(e.Value)) }
+func (e *Cast) write(w *writer)      { fmt.Fprintf(&w.buf, "as!%v(%v)", e.To.Name(), w.expr(e.Value)) }
+func (e *Binary) write(w *writer) {
+	fmt.Fprintf(&w.buf, "(%v %v %v)", w.expr(e.LHS), e.Op, w.expr(e.RHS))
+}
+func (e *CallSub) write(w *writer) {
+	args := make([]string, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = w.expr(a)
+	}
+	fmt.Fprintf(&w.buf, "%v(%v)", e.Sub.Name, strings.Join(args, ", "))
+}
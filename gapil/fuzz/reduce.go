@@ -0,0 +1,300 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuzz
+
+import (
+	"bytes"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// Reduce attempts to find a smaller program than p for which failing still
+// returns true. p is modified in place and returned.
+//
+// Reduce repeatedly removes calls, commands, subroutines, globals and
+// statements, and simplifies expressions, keeping each change that preserves
+// the failure. Declarations are only removed once they are no longer
+// referenced, so the reduced program remains type-correct.
+func Reduce(p *Program, failing func(*Program) bool) *Program {
+	for {
+		reduced := false
+		for _, edit := range edits(p) {
+			undo := edit()
+			if failing(p) {
+				reduced = true
+				break
+			}
+			undo()
+		}
+		if !reduced {
+			return p
+		}
+	}
+}
+
+// ReduceSource attempts to find a smaller source than data for which failing
+// still returns true, by removing runs of lines.
+func ReduceSource(data []byte, failing func([]byte) bool) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for n := len(lines) / 2; n > 0; n /= 2 {
+		for i := 0; i+n <= len(lines); {
+			candidate := append(append([][]byte{}, lines[:i]...), lines[i+n:]...)
+			if failing(bytes.Join(candidate, nil)) {
+				lines = candidate
+			} else {
+				i += n
+			}
+		}
+	}
+	return bytes.Join(lines, nil)
+}
+
+// edit is a function that modifies a program, returning a function that
+// reverts the modification.
+type edit func() (undo func())
+
+// edits returns all the candidate edits for p, roughly ordered from the
+// largest reduction to the smallest.
+func edits(p *Program) []edit {
+	out := []edit{}
+	refs := references(p)
+
+	for i := range p.Calls {
+		out = append(out, remove(&p.Calls, i))
+	}
+	for i, c := range p.Cmds {
+		i, c := i, c
+		out = append(out, func() func() {
+			calls := p.Calls
+			p.Calls = []*Call{}
+			for _, call := range calls {
+				if call.Cmd != c {
+					p.Calls = append(p.Calls, call)
+				}
+			}
+			cmds := p.Cmds
+			p.Cmds = append(append([]*Cmd{}, cmds[:i]...), cmds[i+1:]...)
+			return func() { p.Calls, p.Cmds = calls, cmds }
+		})
+	}
+	for i, s := range p.Subs {
+		if refs[s] == 0 {
+			out = append(out, remove(&p.Subs, i))
+		}
+	}
+	for i, g := range p.Globals {
+		if refs[g] == 0 {
+			out = append(out, remove(&p.Globals, i))
+		}
+	}
+
+	for _, c := range p.Cmds {
+		out = append(out, blockEdits(&c.Body, refs)...)
+	}
+	for _, s := range p.Subs {
+		for i, d := range s.Body {
+			if refs[d.Local] == 0 {
+				out = append(out, remove(&s.Body, i))
+			}
+		}
+	}
+
+	for _, g := range p.Globals {
+		if g := g; g.Init != nil {
+			out = append(out, func() func() {
+				init := g.Init
+				g.Init = nil
+				return func() { g.Init = init }
+			})
+		}
+	}
+	visitExprs(p, func(e *Expr) {
+		out = append(out, exprEdits(e)...)
+	})
+	return out
+}
+
+// blockEdits returns the edits that remove or flatten statements of the
+// block b.
+func blockEdits(b *[]Stmt, refs map[interface{}]int) []edit {
+	out := []edit{}
+	for i, s := range *b {
+		i := i
+		switch s := s.(type) {
+		case *Declare:
+			if refs[s.Local] == 0 {
+				out = append(out, remove(b, i))
+			}
+		case *Assign:
+			out = append(out, remove(b, i))
+		case *If:
+			out = append(out, remove(b, i))
+			for _, body := range [][]Stmt{s.Then, s.Else} {
+				body := body
+				out = append(out, func() func() {
+					old := *b
+					*b = append(append(append([]Stmt{}, old[:i]...), body...), old[i+1:]...)
+					return func() { *b = old }
+				})
+			}
+			out = append(out, blockEdits(&s.Then, refs)...)
+			out = append(out, blockEdits(&s.Else, refs)...)
+		}
+	}
+	return out
+}
+
+// exprEdits returns the edits that simplify the expression at e.
+func exprEdits(e *Expr) []edit {
+	old := *e
+	set := func(n Expr) edit {
+		return func() func() {
+			*e = n
+			return func() { *e = old }
+		}
+	}
+	out := []edit{}
+	if l, ok := old.(*Literal); !ok || l.Value != 0 {
+		out = append(out, set(&Literal{old.Type(), 0}))
+	}
+	switch x := old.(type) {
+	case *Binary:
+		if !hasBoolResult(x.Op) || x.LHS.Type() == semantic.BoolType {
+			out = append(out, set(x.LHS), set(x.RHS))
+		}
+	case *Not:
+		out = append(out, set(x.Value))
+	case *Cast:
+		if x.Value.Type() == x.To {
+			out = append(out, set(x.Value))
+		}
+	}
+	return out
+}
+
+// remove returns an edit that removes the i'th element of the slice at l.
+func remove(l interface{}, i int) edit {
+	switch l := l.(type) {
+	case *[]*Call:
+		return func() func() {
+			old := *l
+			*l = append(append([]*Call{}, old[:i]...), old[i+1:]...)
+			return func() { *l = old }
+		}
+	case *[]*Sub:
+		return func() func() {
+			old := *l
+			*l = append(append([]*Sub{}, old[:i]...), old[i+1:]...)
+			return func() { *l = old }
+		}
+	case *[]*Global:
+		return func() func() {
+			old := *l
+			*l = append(append([]*Global{}, old[:i]...), old[i+1:]...)
+			return func() { *l = old }
+		}
+	case *[]*Declare:
+		return func() func() {
+			old := *l
+			*l = append(append([]*Declare{}, old[:i]...), old[i+1:]...)
+			return func() { *l = old }
+		}
+	case *[]Stmt:
+		return func() func() {
+			old := *l
+			*l = append(append([]Stmt{}, old[:i]...), old[i+1:]...)
+			return func() { *l = old }
+		}
+	}
+	panic("Unsupported slice type")
+}
+
+// references returns the number of references to each global, subroutine and
+// local in p.
+func references(p *Program) map[interface{}]int {
+	out := map[interface{}]int{}
+	visitExprs(p, func(e *Expr) {
+		switch x := (*e).(type) {
+		case *LocalRef:
+			out[x.Local]++
+		case *GlobalRef:
+			out[x.Global]++
+		case *CallSub:
+			out[x.Sub]++
+		}
+	})
+	var stmts func(l []Stmt)
+	stmts = func(l []Stmt) {
+		for _, s := range l {
+			switch s := s.(type) {
+			case *Assign:
+				out[s.Global]++
+			case *If:
+				stmts(s.Then)
+				stmts(s.Else)
+			}
+		}
+	}
+	for _, c := range p.Cmds {
+		stmts(c.Body)
+	}
+	return out
+}
+
+// visitExprs calls cb with a pointer to every expression in p, parents before
+// children.
+func visitExprs(p *Program, cb func(*Expr)) {
+	var expr func(e *Expr)
+	expr = func(e *Expr) {
+		cb(e)
+		switch x := (*e).(type) {
+		case *Binary:
+			expr(&x.LHS)
+			expr(&x.RHS)
+		case *Not:
+			expr(&x.Value)
+		case *Cast:
+			expr(&x.Value)
+		case *CallSub:
+			for i := range x.Args {
+				expr(&x.Args[i])
+			}
+		}
+	}
+	var stmts func(l []Stmt)
+	stmts = func(l []Stmt) {
+		for _, s := range l {
+			switch s := s.(type) {
+			case *Declare:
+				expr(&s.Value)
+			case *Assign:
+				expr(&s.Value)
+			case *If:
+				expr(&s.Cond)
+				stmts(s.Then)
+				stmts(s.Else)
+			}
+		}
+	}
+	for _, s := range p.Subs {
+		for _, d := range s.Body {
+			expr(&d.Value)
+		}
+		expr(&s.Return)
+	}
+	for _, c := range p.Cmds {
+		stmts(c.Body)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/gapid/core/app/crash"
@@ -43,9 +44,11 @@ const (
 )
 
 type fullAnalysis struct {
-	docs     map[string]*docAnalysis
-	roots    map[string]*rootAnalysis // Root document path -> rootAnalysis
-	mappings *resolver.Mappings       // AST node to semantic node map
+	docs      map[string]*docAnalysis
+	roots     map[string]*rootAnalysis // Root document path -> rootAnalysis
+	mappings  *resolver.Mappings       // AST node to semantic node map
+	callsOnce sync.Once                // Guards calls
+	calls     *callGraph               // Lazily built call graph
 }
 
 type rootAnalysis struct {
@@ -0,0 +1,209 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	ls "github.com/google/gapid/core/langsvr"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// callGraph holds all the calls made by each of the functions in the analysed
+// APIs.
+type callGraph struct {
+	functions []*semantic.Function                    // All functions, in declaration order.
+	calls     map[*semantic.Function][]*semantic.Call // Caller -> calls made
+}
+
+// callGraph returns the call graph for the analysis, building it on first use.
+func (fa *fullAnalysis) callGraph() *callGraph {
+	fa.callsOnce.Do(func() { fa.calls = buildCallGraph(fa) })
+	return fa.calls
+}
+
+func buildCallGraph(fa *fullAnalysis) *callGraph {
+	g := &callGraph{calls: map[*semantic.Function][]*semantic.Call{}}
+	seen := map[*semantic.Function]bool{}
+	for _, root := range fa.roots {
+		api := root.sem
+		if api == nil {
+			continue
+		}
+		for _, list := range [][]*semantic.Function{
+			api.Functions, api.Subroutines, api.Methods, api.Externs,
+		} {
+			for _, f := range list {
+				if seen[f] {
+					continue
+				}
+				seen[f] = true
+				g.functions = append(g.functions, f)
+				if f.Block == nil {
+					continue
+				}
+				var traverse func(n semantic.Node)
+				traverse = func(n semantic.Node) {
+					switch n := n.(type) {
+					case *semantic.Call:
+						if n.Target != nil && n.Target.Function != nil {
+							g.calls[f] = append(g.calls[f], n)
+						}
+					case *semantic.Callable:
+						if n.Object != nil {
+							traverse(n.Object)
+						}
+						return // Don't traverse into the called function.
+					case semantic.Type:
+						return // Don't traverse into the type.
+					}
+					semantic.Visit(n, traverse)
+				}
+				traverse(f.Block)
+			}
+		}
+	}
+	return g
+}
+
+// callHierarchyItem returns the call hierarchy item representing f.
+func (s *server) callHierarchyItem(fa *fullAnalysis, f *semantic.Function) (ls.CallHierarchyItem, bool) {
+	if f.AST == nil || f.AST.Generic == nil {
+		return ls.CallHierarchyItem{}, false
+	}
+	doc := s.nodeDoc(fa, f.AST)
+	if doc == nil {
+		return ls.CallHierarchyItem{}, false
+	}
+	kind, detail := ls.KindFunction, "cmd"
+	switch {
+	case f.This != nil:
+		kind, detail = ls.KindMethod, "method"
+	case f.Subroutine:
+		detail = "sub"
+	case f.Extern:
+		detail = "extern"
+	}
+	return ls.CallHierarchyItem{
+		Name:           f.Name(),
+		Kind:           kind,
+		Detail:         detail,
+		Location:       fa.nodeLocation(doc, f.AST),
+		SelectionRange: fa.nodeRange(doc, f.AST.Generic.Name),
+	}, true
+}
+
+// findFunction returns the function that is represented by item, or nil if
+// the function cannot be found.
+func (s *server) findFunction(fa *fullAnalysis, item ls.CallHierarchyItem) *semantic.Function {
+	for _, f := range fa.callGraph().functions {
+		if i, ok := s.callHierarchyItem(fa, f); ok &&
+			i.Location.URI == item.Location.URI &&
+			i.SelectionRange.Start == item.SelectionRange.Start {
+			return f
+		}
+	}
+	return nil
+}
+
+// PrepareCallHierarchy returns the call hierarchy items for the callable
+// symbol in the specified document at position.
+func (s *server) PrepareCallHierarchy(ctx context.Context, doc *ls.Document, pos ls.Position) ([]ls.CallHierarchyItem, error) {
+	da, err := s.docAnalysis(ctx, doc)
+	if da == nil || err != nil {
+		return nil, err
+	}
+	for _, n := range da.walkUp(doc.Body().Offset(pos)) {
+		var f *semantic.Function
+		switch sem := partial(n.sem).(type) {
+		case *semantic.Function:
+			f = sem
+		case *semantic.Callable:
+			f = sem.Function
+		case *semantic.Call:
+			if sem.Target != nil {
+				f = sem.Target.Function
+			}
+		}
+		if f == nil {
+			continue
+		}
+		if item, ok := s.callHierarchyItem(da.full, f); ok {
+			return []ls.CallHierarchyItem{item}, nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// IncomingCalls returns the list of callers of the given item.
+func (s *server) IncomingCalls(ctx context.Context, item ls.CallHierarchyItem) ([]ls.IncomingCall, error) {
+	fa := s.analyzer.results(ctx, s)
+	if fa == nil {
+		return nil, nil
+	}
+	f := s.findFunction(fa, item)
+	if f == nil {
+		return nil, nil
+	}
+	g := fa.callGraph()
+	out := []ls.IncomingCall{}
+	for _, caller := range g.functions {
+		ranges := []ls.Range{}
+		for _, call := range g.calls[caller] {
+			if call.Target.Function == f && call.AST != nil {
+				ranges = append(ranges, s.nodeLocation(fa, call.AST).Range)
+			}
+		}
+		if len(ranges) == 0 {
+			continue
+		}
+		if from, ok := s.callHierarchyItem(fa, caller); ok {
+			out = append(out, ls.IncomingCall{From: from, Ranges: ranges})
+		}
+	}
+	return out, nil
+}
+
+// OutgoingCalls returns the list of callees of the given item.
+func (s *server) OutgoingCalls(ctx context.Context, item ls.CallHierarchyItem) ([]ls.OutgoingCall, error) {
+	fa := s.analyzer.results(ctx, s)
+	if fa == nil {
+		return nil, nil
+	}
+	f := s.findFunction(fa, item)
+	if f == nil {
+		return nil, nil
+	}
+	order := []*semantic.Function{}
+	ranges := map[*semantic.Function][]ls.Range{}
+	for _, call := range fa.callGraph().calls[f] {
+		callee := call.Target.Function
+		if _, ok := ranges[callee]; !ok {
+			order = append(order, callee)
+			ranges[callee] = []ls.Range{}
+		}
+		if call.AST != nil {
+			ranges[callee] = append(ranges[callee], s.nodeLocation(fa, call.AST).Range)
+		}
+	}
+	out := make([]ls.OutgoingCall, 0, len(order))
+	for _, callee := range order {
+		if to, ok := s.callHierarchyItem(fa, callee); ok {
+			out = append(out, ls.OutgoingCall{To: to, Ranges: ranges[callee]})
+		}
+	}
+	return out, nil
+}
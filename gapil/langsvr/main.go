@@ -82,6 +82,9 @@ var (
 	_ ls.CompletionProvider       = (*server)(nil)
 	_ ls.SignatureProvider        = (*server)(nil)
 	_ ls.CodeLensProvider         = (*server)(nil)
+	_ ls.SemanticTokensProvider   = (*server)(nil)
+	_ ls.FoldingRangeProvider     = (*server)(nil)
+	_ ls.CallHierarchyProvider    = (*server)(nil)
 )
 
 // Config is is the configuration data sent from the client, held in the
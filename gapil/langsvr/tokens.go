@@ -0,0 +1,157 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	ls "github.com/google/gapid/core/langsvr"
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+// SemanticTokens returns the list of semantic tokens for the specified
+// document.
+func (s *server) SemanticTokens(ctx context.Context, doc *ls.Document) (ls.SemanticTokenList, error) {
+	da, err := s.docAnalysis(ctx, doc)
+	if da == nil || err != nil {
+		return nil, err
+	}
+	tokens := ls.SemanticTokenList{}
+	var traverse func(n ast.Node)
+	traverse = func(n ast.Node) {
+		if ident, ok := n.(*ast.Identifier); ok {
+			sems := da.full.mappings.ASTToSemantic[ident]
+			if len(sems) == 0 {
+				return
+			}
+			ty, mods, ok := tokenType(sems[0])
+			if !ok {
+				return
+			}
+			if declName(s.definition(sems[0])) == ident {
+				mods |= ls.DeclarationModifier
+			}
+			tokens.Add(da.full.nodeRange(doc, ident), ty, mods)
+			return
+		}
+		ast.Visit(n, traverse)
+	}
+	traverse(da.ast)
+	return tokens, nil
+}
+
+// FoldingRanges returns the list of foldable ranges for the specified
+// document.
+func (s *server) FoldingRanges(ctx context.Context, doc *ls.Document) (ls.FoldingRangeList, error) {
+	da, err := s.docAnalysis(ctx, doc)
+	if da == nil || err != nil {
+		return nil, err
+	}
+	folds := ls.FoldingRangeList{}
+	if imports := da.ast.Imports; len(imports) > 1 {
+		first := da.full.nodeRange(doc, imports[0])
+		last := da.full.nodeRange(doc, imports[len(imports)-1])
+		folds.Add(ls.Range{Start: first.Start, End: last.End}, ls.ImportsFolding)
+	}
+	var traverse func(n ast.Node)
+	traverse = func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.Block, *ast.Class, *ast.Enum:
+			if da.full.mappings.CST(n) != nil {
+				folds.Add(da.full.nodeRange(doc, n), "")
+			}
+		case *ast.Identifier:
+			return
+		}
+		ast.Visit(n, traverse)
+	}
+	traverse(da.ast)
+	return folds, nil
+}
+
+// declName returns the identifier that names the declaration n, or nil if n
+// is not a named declaration.
+func declName(n ast.Node) *ast.Identifier {
+	switch n := n.(type) {
+	case *ast.Identifier:
+		return n
+	case *ast.Class:
+		return n.Name
+	case *ast.Enum:
+		return n.Name
+	case *ast.EnumEntry:
+		return n.Name
+	case *ast.Field:
+		return n.Name
+	case *ast.Parameter:
+		return n.Name
+	case *ast.Pseudonym:
+		return n.Name
+	case *ast.Definition:
+		return n.Name
+	case *ast.DeclareLocal:
+		return n.Name
+	}
+	return nil
+}
+
+// tokenType returns the semantic token type and modifiers for the semantic
+// node sem.
+func tokenType(sem semantic.Node) (ls.SemanticTokenType, ls.SemanticTokenModifiers, bool) {
+	switch sem := partial(sem).(type) {
+	case *semantic.Class:
+		return ls.ClassToken, 0, true
+	case *semantic.Enum:
+		return ls.EnumToken, 0, true
+	case *semantic.EnumEntry:
+		return ls.EnumMemberToken, ls.ReadonlyModifier, true
+	case *semantic.Pseudonym, *semantic.Builtin, *semantic.StaticArray,
+		*semantic.Map, *semantic.Pointer, *semantic.Slice, *semantic.Reference:
+		return ls.TypeToken, 0, true
+	case *semantic.Field, *semantic.Member:
+		return ls.PropertyToken, 0, true
+	case *semantic.Global:
+		return ls.VariableToken, ls.StaticModifier, true
+	case *semantic.Local:
+		return ls.VariableToken, ls.ReadonlyModifier, true
+	case *semantic.Parameter:
+		return ls.ParameterToken, 0, true
+	case *semantic.Definition:
+		return ls.MacroToken, ls.ReadonlyModifier, true
+	case *semantic.DefinitionUsage:
+		return ls.MacroToken, ls.ReadonlyModifier, true
+	case *semantic.Function:
+		return functionTokenType(sem)
+	case *semantic.Callable:
+		return functionTokenType(sem.Function)
+	case *semantic.Call:
+		if sem.Target != nil && sem.Target.Function != nil {
+			return functionTokenType(sem.Target.Function)
+		}
+	}
+	return 0, 0, false
+}
+
+func functionTokenType(f *semantic.Function) (ls.SemanticTokenType, ls.SemanticTokenModifiers, bool) {
+	ty, mods := ls.FunctionToken, ls.SemanticTokenModifiers(0)
+	if f.This != nil {
+		ty = ls.MethodToken
+	}
+	if f.Extern {
+		mods |= ls.DefaultLibraryModifier
+	}
+	return ty, mods, true
+}
@@ -0,0 +1,289 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/gapil/semantic"
+)
+
+var (
+	tsKeywords = map[string]string{
+		"break":      "break_",
+		"case":       "case_",
+		"catch":      "catch_",
+		"class":      "class_",
+		"const":      "const_",
+		"continue":   "continue_",
+		"debugger":   "debugger_",
+		"default":    "default_",
+		"delete":     "delete_",
+		"do":         "do_",
+		"else":       "else_",
+		"enum":       "enum_",
+		"export":     "export_",
+		"extends":    "extends_",
+		"false":      "false_",
+		"finally":    "finally_",
+		"for":        "for_",
+		"function":   "function_",
+		"if":         "if_",
+		"import":     "import_",
+		"in":         "in_",
+		"instanceof": "instanceof_",
+		"let":        "let_",
+		"new":        "new_",
+		"null":       "null_",
+		"package":    "package_",
+		"private":    "private_",
+		"protected":  "protected_",
+		"public":     "public_",
+		"return":     "return_",
+		"static":     "static_",
+		"super":      "super_",
+		"switch":     "switch_",
+		"this":       "this_",
+		"throw":      "throw_",
+		"true":       "true_",
+		"try":        "try_",
+		"type":       "type_",
+		"typeof":     "typeof_",
+		"var":        "var_",
+		"void":       "void_",
+		"while":      "while_",
+		"with":       "with_",
+		"yield":      "yield_",
+		"Int64":      "Int64_", // Collides with the Int64 binding helper.
+		"MapOf":      "MapOf_", // Collides with the MapOf binding helper.
+		"Ref":        "Ref_",   // Collides with the Ref binding helper.
+		"Slice":      "Slice_", // Collides with the Slice binding helper.
+	}
+	pythonKeywords = map[string]string{
+		"and":      "and_",
+		"as":       "as_",
+		"assert":   "assert_",
+		"async":    "async_",
+		"await":    "await_",
+		"break":    "break_",
+		"class":    "class_",
+		"continue": "continue_",
+		"def":      "def_",
+		"del":      "del_",
+		"elif":     "elif_",
+		"else":     "else_",
+		"except":   "except_",
+		"False":    "False_",
+		"finally":  "finally_",
+		"for":      "for_",
+		"from":     "from_",
+		"global":   "global_",
+		"if":       "if_",
+		"import":   "import_",
+		"in":       "in_",
+		"is":       "is_",
+		"lambda":   "lambda_",
+		"None":     "None_",
+		"nonlocal": "nonlocal_",
+		"not":      "not_",
+		"or":       "or_",
+		"pass":     "pass_",
+		"raise":    "raise_",
+		"return":   "return_",
+		"True":     "True_",
+		"try":      "try_",
+		"while":    "while_",
+		"with":     "with_",
+		"yield":    "yield_",
+		"Any":      "Any_",   // Collides with typing.Any.
+		"List":     "List_",  // Collides with typing.List.
+		"MapOf":    "MapOf_", // Collides with the MapOf binding helper.
+		"Ref":      "Ref_",   // Collides with the Ref binding helper.
+		"Slice":    "Slice_", // Collides with the Slice binding helper.
+	}
+)
+
+// TypeScriptName converts an api type or command name to the TypeScript
+// declaration name. Apart from reserved words, names match the serialized
+// proto names so the bindings line up with decoded service.Value messages.
+// Property and enum member names may be reserved words in TypeScript, so
+// templates should emit them with ProtoName.
+func (Functions) TypeScriptName(obj interface{}) string {
+	return nameOptions{Remap: tsKeywords}.convert(nameOf(obj))
+}
+
+// PythonName converts an api name to the Python binding form.
+// Unlike TypeScript, Python does not allow reserved words as attribute names,
+// so field and enum entry names are remapped too.
+func (Functions) PythonName(obj interface{}) string {
+	return nameOptions{Remap: pythonKeywords}.convert(nameOf(obj))
+}
+
+// TypeScriptType returns the TypeScript type expression for the api type ty.
+// 64-bit integers and pointers map to the Int64 helper, slices, references
+// and maps to the Slice, Ref and MapOf helpers declared by the bindings
+// template.
+func (f *Functions) TypeScriptType(ty interface{}) (string, error) {
+	switch ty := ty.(type) {
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.VoidType:
+			return "void", nil
+		case semantic.AnyType:
+			return "unknown", nil
+		case semantic.BoolType:
+			return "boolean", nil
+		case semantic.StringType, semantic.MessageType:
+			return "string", nil
+		case semantic.IntType, semantic.UintType, semantic.SizeType,
+			semantic.Int64Type, semantic.Uint64Type:
+			return "Int64", nil
+		case semantic.CharType,
+			semantic.Int8Type, semantic.Uint8Type,
+			semantic.Int16Type, semantic.Uint16Type,
+			semantic.Int32Type, semantic.Uint32Type,
+			semantic.Float32Type, semantic.Float64Type:
+			return "number", nil
+		}
+	case *semantic.Enum, *semantic.Class, *semantic.Pseudonym:
+		return f.TypeScriptName(ty), nil
+	case *semantic.Pointer:
+		return "Int64", nil
+	case *semantic.Slice:
+		return "Slice", nil
+	case *semantic.StaticArray:
+		el, err := f.TypeScriptType(ty.ValueType)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Array<%s>", el), nil
+	case *semantic.Reference:
+		to, err := f.TypeScriptType(ty.To)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Ref<%s>", to), nil
+	case *semantic.Map:
+		k, err := f.TypeScriptType(ty.KeyType)
+		if err != nil {
+			return "", err
+		}
+		v, err := f.TypeScriptType(ty.ValueType)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("MapOf<%s, %s>", k, v), nil
+	case semantic.Node:
+		t, err := semantic.TypeOf(ty)
+		if err != nil {
+			return "", err
+		}
+		if t != ty {
+			return f.TypeScriptType(t)
+		}
+	}
+	return "", fmt.Errorf("No TypeScript binding for %v (%T)", ty, ty)
+}
+
+// PythonType returns the Python type annotation for the api type ty.
+// Slices, references and maps use the Slice, Ref and MapOf helpers declared by
+// the bindings template.
+func (f *Functions) PythonType(ty interface{}) (string, error) {
+	switch ty := ty.(type) {
+	case *semantic.Builtin:
+		switch ty {
+		case semantic.VoidType:
+			return "None", nil
+		case semantic.AnyType:
+			return "Any", nil
+		case semantic.BoolType:
+			return "bool", nil
+		case semantic.StringType, semantic.MessageType:
+			return "str", nil
+		case semantic.Float32Type, semantic.Float64Type:
+			return "float", nil
+		}
+		if semantic.IsNumeric(ty) {
+			return "int", nil
+		}
+	case *semantic.Enum, *semantic.Class, *semantic.Pseudonym:
+		return f.PythonName(ty), nil
+	case *semantic.Pointer:
+		return "int", nil
+	case *semantic.Slice:
+		return "Slice", nil
+	case *semantic.StaticArray:
+		el, err := f.PythonType(ty.ValueType)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("List[%s]", el), nil
+	case *semantic.Reference:
+		to, err := f.PythonType(ty.To)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Ref[%s]", to), nil
+	case *semantic.Map:
+		k, err := f.PythonType(ty.KeyType)
+		if err != nil {
+			return "", err
+		}
+		v, err := f.PythonType(ty.ValueType)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("MapOf[%s, %s]", k, v), nil
+	case semantic.Node:
+		t, err := semantic.TypeOf(ty)
+		if err != nil {
+			return "", err
+		}
+		if t != ty {
+			return f.PythonType(t)
+		}
+	}
+	return "", fmt.Errorf("No Python binding for %v (%T)", ty, ty)
+}
+
+// PythonPseudonyms returns the pseudonyms of api ordered so that each one
+// follows the pseudonyms its type refers to. Python evaluates type aliases
+// eagerly, so they must be declared in dependency order.
+func (*Functions) PythonPseudonyms(api *semantic.API) []*semantic.Pseudonym {
+	out := make([]*semantic.Pseudonym, 0, len(api.Pseudonyms))
+	done := map[*semantic.Pseudonym]bool{}
+	var visit func(ty semantic.Type)
+	visit = func(ty semantic.Type) {
+		switch ty := ty.(type) {
+		case *semantic.Pseudonym:
+			if !done[ty] {
+				done[ty] = true
+				visit(ty.To)
+				out = append(out, ty)
+			}
+		case *semantic.StaticArray:
+			visit(ty.ValueType)
+		case *semantic.Reference:
+			visit(ty.To)
+		case *semantic.Map:
+			visit(ty.KeyType)
+			visit(ty.ValueType)
+		}
+	}
+	for _, p := range api.Pseudonyms {
+		visit(p)
+	}
+	return out
+}
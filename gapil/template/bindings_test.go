@@ -0,0 +1,117 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"testing"
+
+	"github.com/google/gapid/gapil/ast"
+	"github.com/google/gapid/gapil/parser"
+	"github.com/google/gapid/gapil/resolver"
+	"github.com/google/gapid/gapil/semantic"
+)
+
+const bindingsAPI = `
+enum E { A = 1 }
+class C { u32 from }
+type u64 Handle
+u8  G8
+s64 G64
+f32 GF
+bool GB
+string GS
+E GE
+C GC
+Handle GH
+u32* GP
+u32[] GSl
+u16[4] GA
+ref!C GR
+map!(u32, ref!C) GM
+`
+
+func resolveBindingsAPI(t *testing.T) *semantic.API {
+	mappings := resolver.NewMappings()
+	parsed, errs := parser.Parse("bindings_test.api", bindingsAPI, mappings)
+	if len(errs) > 0 {
+		t.Fatalf("Parse failed: %v", errs)
+	}
+	api, errs := resolver.Resolve([]*ast.API{parsed}, mappings)
+	if len(errs) > 0 {
+		t.Fatalf("Resolve failed: %v", errs)
+	}
+	return api
+}
+
+func TestBindingTypes(t *testing.T) {
+	api := resolveBindingsAPI(t)
+	f := &Functions{}
+	for _, test := range []struct {
+		global string
+		ts     string
+		py     string
+	}{
+		{"G8", "number", "int"},
+		{"G64", "Int64", "int"},
+		{"GF", "number", "float"},
+		{"GB", "boolean", "bool"},
+		{"GS", "string", "str"},
+		{"GE", "E", "E"},
+		{"GC", "C", "C"},
+		{"GH", "Handle", "Handle"},
+		{"GP", "Int64", "int"},
+		{"GSl", "Slice", "Slice"},
+		{"GA", "Array<number>", "List[int]"},
+		{"GR", "Ref<C>", "Ref[C]"},
+		{"GM", "MapOf<number, Ref<C>>", "MapOf[int, Ref[C]]"},
+	} {
+		var global *semantic.Global
+		for _, g := range api.Globals {
+			if g.Name() == test.global {
+				global = g
+			}
+		}
+		if global == nil {
+			t.Fatalf("Global %v not found", test.global)
+		}
+		if got, err := f.TypeScriptType(global); err != nil || got != test.ts {
+			t.Errorf("TypeScriptType(%v) returned unexpected value. Expected: %v, got: %v (%v)", test.global, test.ts, got, err)
+		}
+		if got, err := f.PythonType(global); err != nil || got != test.py {
+			t.Errorf("PythonType(%v) returned unexpected value. Expected: %v, got: %v (%v)", test.global, test.py, got, err)
+		}
+	}
+}
+
+func TestBindingNames(t *testing.T) {
+	f := Functions{}
+	for _, test := range []struct {
+		name string
+		ts   string
+		py   string
+	}{
+		{"target", "target", "target"},
+		{"from", "from", "from_"},
+		{"type", "type_", "type"},
+		{"Slice", "Slice_", "Slice_"},
+	} {
+		if got := f.TypeScriptName(test.name); got != test.ts {
+			t.Errorf("TypeScriptName(%#v) returned unexpected value. Expected: %#v, got: %#v", test.name, test.ts, got)
+		}
+		if got := f.PythonName(test.name); got != test.py {
+			t.Errorf("PythonName(%#v) returned unexpected value. Expected: %#v, got: %#v", test.name, test.py, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import "github.com/google/gapid/gapis/resolve/dependencygraph"
+
+// The test API has no hand-written dependency graph behaviour, so its
+// commands are described by the footprints statically derived from test.api.
+func init() {
+	f, err := dependencygraph.LoadStaticFootprints([]byte(embedded["footprint.json"]))
+	if err != nil {
+		panic(err)
+	}
+	dependencygraph.RegisterStaticFootprints(f)
+}
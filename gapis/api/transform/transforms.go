@@ -16,7 +16,9 @@ package transform
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/api"
 	"github.com/google/gapid/gapis/config"
 )
@@ -27,6 +29,8 @@ type Transforms []Transformer
 // Transform sequentially transforms the commands by each of the transformers in
 // the list, before writing the final output to the output command Writer.
 func (l Transforms) Transform(ctx context.Context, cmds []api.Cmd, out Writer) {
+	ctx, end := log.StartSpan(ctx, "Transform")
+	defer end()
 	chain := out
 	for i := len(l) - 1; i >= 0; i-- {
 		s := out.State()
@@ -41,10 +45,20 @@ func (l Transforms) Transform(ctx context.Context, cmds []api.Cmd, out Writer) {
 	})
 	for p, ok := chain.(TransformWriter); ok; p, ok = chain.(TransformWriter) {
 		chain = p.O
+		ctx, end := log.StartSpan(ctx, "Flush "+transformerName(p.T))
 		p.T.Flush(ctx, chain)
+		end()
 	}
 }
 
+// transformerName returns the name of the transformer t, used to identify its spans.
+func transformerName(t Transformer) string {
+	if n, ok := t.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", t)
+}
+
 // Add is a convenience function for appending the list of Transformers t to the
 // end of the Transforms list, after filtering out nil Transformers.
 func (l *Transforms) Add(t ...Transformer) {
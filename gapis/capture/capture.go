@@ -63,6 +63,15 @@ type Capture struct {
 	APIs         []api.API
 	Observed     interval.U64RangeList
 	InitialState *InitialState
+	Logcat       []Logcat
+}
+
+// Logcat is a logcat message recorded while tracing the capture.
+type Logcat struct {
+	*LogcatMessage
+	// Command is the last command traced before the message was logged, or
+	// api.CmdNoID if the message was logged before the first command.
+	Command api.CmdID
 }
 
 type InitialState struct {
@@ -234,21 +243,45 @@ func Import(ctx context.Context, name string, data []byte) (*path.Capture, error
 	return &path.Capture{Id: path.NewID(id)}, nil
 }
 
+// ExportOptions controls the format of exported captures.
+type ExportOptions struct {
+	// Compatible writes the capture without compression or an index, in the
+	// pack version 2 format that can be read by older versions.
+	Compatible bool
+}
+
 // Export encodes the given capture and associated resources
 // and writes it to the supplied io.Writer in the pack file format,
 // producing output suitable for use with Import or opening in the trace editor.
 func Export(ctx context.Context, p *path.Capture, w io.Writer) error {
+	return ExportWithOptions(ctx, p, w, ExportOptions{})
+}
+
+// ExportWithOptions is like Export, but writes the capture in the format
+// controlled by opts.
+func ExportWithOptions(ctx context.Context, p *path.Capture, w io.Writer, opts ExportOptions) error {
 	c, err := ResolveFromPath(ctx, p)
 	if err != nil {
 		return err
 	}
-	return c.Export(ctx, w)
+	return c.ExportWithOptions(ctx, w, opts)
 }
 
 // Export encodes the given capture and associated resources
 // and writes it to the supplied io.Writer in the .gfxtrace format.
+// The stream is compressed and ends with an index of its root groups.
 func (c *Capture) Export(ctx context.Context, w io.Writer) error {
-	writer, err := pack.NewWriter(w)
+	return c.ExportWithOptions(ctx, w, ExportOptions{})
+}
+
+// ExportWithOptions is like Export, but writes the capture in the format
+// controlled by opts.
+func (c *Capture) ExportWithOptions(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	packOpts := pack.Options{Compress: true, Index: true}
+	if opts.Compatible {
+		packOpts = pack.Options{}
+	}
+	writer, err := pack.NewWriterWithOptions(w, packOpts)
 	if err != nil {
 		return err
 	}
@@ -258,7 +291,10 @@ func (c *Capture) Export(ctx context.Context, w io.Writer) error {
 	// which protoconv functions need to handle resources.
 	ctx = id.PutRemapper(ctx, e)
 
-	return e.encode(ctx)
+	if err := e.encode(ctx); err != nil {
+		return err
+	}
+	return writer.Close()
 }
 
 func toProto(ctx context.Context, c *Capture) (*Record, error) {
@@ -300,44 +336,27 @@ func fromProto(ctx context.Context, r *Record) (out *Capture, err error) {
 	// which protoconv functions need to handle resources.
 	ctx = id.PutRemapper(ctx, d)
 
-	if err := pack.Read(ctx, bytes.NewReader(data.([]byte)), d, false); err != nil {
-		switch err := errors.Cause(err).(type) {
-		case pack.ErrUnsupportedVersion:
-			log.E(ctx, "%v", err)
-			switch {
-			case err.Version.Major > pack.MaxMajorVersion:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason:        messages.ErrFileTooNew(),
-					SuggestUpdate: true,
-				}
-			case err.Version.Major < pack.MinMajorVersion:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason: messages.ErrFileTooOld(),
-				}
-			default:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason: messages.ErrFileCannotBeRead(),
-				}
-			}
-		case ErrUnsupportedVersion:
-			switch {
-			case err.Version > CurrentCaptureVersion:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason:        messages.ErrFileTooNew(),
-					SuggestUpdate: true,
-				}
-			case err.Version < CurrentCaptureVersion:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason: messages.ErrFileTooOld(),
-				}
-			default:
-				return nil, &service.ErrUnsupportedVersion{
-					Reason: messages.ErrFileCannotBeRead(),
-				}
-			}
+	in := bytes.NewReader(data.([]byte))
+	// Indexed streams are checked from their header and index first, seeking
+	// over the commands, so that unsupported captures are rejected before
+	// anything is decoded.
+	info, err := readIndexedInfo(ctx, in)
+	if err != nil {
+		return nil, loadError(ctx, err)
+	}
+	if info != nil {
+		if v := info.header.Version; v != CurrentCaptureVersion {
+			return nil, loadError(ctx, ErrUnsupportedVersion{Version: v})
 		}
+		d.builder.cmds = make([]api.Cmd, 0, info.numRootCommands)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
+
+	if err := pack.ReadParallel(ctx, in, d, false, 0); err != nil {
+		return nil, loadError(ctx, err)
+	}
 	d.flush(ctx)
 	if d.header == nil {
 		return nil, log.Err(ctx, nil, "Capture was missing header chunk")
@@ -345,6 +364,47 @@ func fromProto(ctx context.Context, r *Record) (out *Capture, err error) {
 	return d.builder.build(r.Name, d.header), nil
 }
 
+// loadError returns the error to report for err, returned while loading a
+// capture stream.
+func loadError(ctx context.Context, err error) error {
+	switch err := errors.Cause(err).(type) {
+	case pack.ErrUnsupportedVersion:
+		log.E(ctx, "%v", err)
+		switch {
+		case err.Version.Major > pack.MaxMajorVersion:
+			return &service.ErrUnsupportedVersion{
+				Reason:        messages.ErrFileTooNew(),
+				SuggestUpdate: true,
+			}
+		case err.Version.Major < pack.MinMajorVersion:
+			return &service.ErrUnsupportedVersion{
+				Reason: messages.ErrFileTooOld(),
+			}
+		default:
+			return &service.ErrUnsupportedVersion{
+				Reason: messages.ErrFileCannotBeRead(),
+			}
+		}
+	case ErrUnsupportedVersion:
+		switch {
+		case err.Version > CurrentCaptureVersion:
+			return &service.ErrUnsupportedVersion{
+				Reason:        messages.ErrFileTooNew(),
+				SuggestUpdate: true,
+			}
+		case err.Version < CurrentCaptureVersion:
+			return &service.ErrUnsupportedVersion{
+				Reason: messages.ErrFileTooOld(),
+			}
+		default:
+			return &service.ErrUnsupportedVersion{
+				Reason: messages.ErrFileCannotBeRead(),
+			}
+		}
+	}
+	return err
+}
+
 type builder struct {
 	apis         []api.API
 	seenAPIs     map[api.ID]struct{}
@@ -352,6 +412,7 @@ type builder struct {
 	cmds         []api.Cmd
 	resIDs       []id.ID
 	initialState *InitialState
+	logcat       []Logcat
 }
 
 func newBuilder() *builder {
@@ -433,5 +494,6 @@ func (b *builder) build(name string, header *Header) *Capture {
 		Observed:     b.observed,
 		APIs:         b.apis,
 		InitialState: b.initialState,
+		Logcat:       b.logcat,
 	}
 }
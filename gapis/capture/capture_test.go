@@ -16,9 +16,11 @@ package capture_test
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/gapis/api"
@@ -38,21 +40,141 @@ func TestCaptureExportImport(t *testing.T) {
 	}
 	ctx = capture.Put(ctx, p)
 
+	for _, test := range []struct {
+		opts    capture.ExportOptions
+		version string
+	}{
+		{capture.ExportOptions{}, "3.0"},
+		{capture.ExportOptions{Compatible: true}, "2.0"},
+	} {
+		ctx := log.V{"options": test.opts}.Bind(ctx)
+		buf := &bytes.Buffer{}
+		err = capture.ExportWithOptions(ctx, p, buf, test.opts)
+		if !assert.For(ctx, "capture.Export").ThatError(err).Succeeded() {
+			continue
+		}
+		assert.For(ctx, "version").That(string(buf.Bytes()[11:14])).Equals(test.version)
+
+		ip, err := capture.Import(ctx, "imported", buf.Bytes())
+		if !assert.For(ctx, "capture.Import").ThatError(err).Succeeded() {
+			continue
+		}
+
+		ic, err := capture.Resolve(capture.Put(ctx, ip))
+		if !assert.For(ctx, "capture.Resolve").ThatError(err).Succeeded() {
+			continue
+		}
+
+		assert.For(ctx, "got").That(ic.Commands).DeepEquals(cmds)
+	}
+}
+
+func TestCaptureLogcat(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	header := &capture.Header{Abi: device.WindowsX86_64}
+	cmds := []api.Cmd{testcmd.P, testcmd.Q}
+	p, err := capture.New(ctx, "test", header, cmds)
+	if !assert.For(ctx, "capture.New").ThatError(err).Succeeded() {
+		return
+	}
+
+	// Append logcat messages to the exported capture, as gapit does.
 	buf := &bytes.Buffer{}
-	err = capture.Export(capture.Put(ctx, p), p, buf)
+	a := pack.NewAppender(buf)
+	err = capture.Export(capture.Put(ctx, p), p, a)
 	if !assert.For(ctx, "capture.Export").ThatError(err).Succeeded() {
 		return
 	}
+	w, err := a.Writer()
+	if !assert.For(ctx, "Appender.Writer").ThatError(err).Succeeded() {
+		return
+	}
+	before := &capture.LogcatMessage{Tag: "before", Position: 0}
+	after := &capture.LogcatMessage{Tag: "after", Position: a.Objects()}
+	for _, m := range []*capture.LogcatMessage{before, after} {
+		assert.For(ctx, "Object").ThatError(w.Object(ctx, m)).Succeeded()
+	}
 
 	ip, err := capture.Import(ctx, "imported", buf.Bytes())
 	if !assert.For(ctx, "capture.Import").ThatError(err).Succeeded() {
 		return
 	}
-
 	ic, err := capture.Resolve(capture.Put(ctx, ip))
 	if !assert.For(ctx, "capture.Resolve").ThatError(err).Succeeded() {
 		return
 	}
+	expected := []capture.Logcat{
+		{LogcatMessage: before, Command: api.CmdNoID},
+		{LogcatMessage: after, Command: 1},
+	}
+	checkLogcat(ctx, ic.Logcat, expected)
+
+	// The messages must survive another export and import.
+	buf.Reset()
+	err = capture.Export(capture.Put(ctx, ip), ip, buf)
+	if !assert.For(ctx, "re-export").ThatError(err).Succeeded() {
+		return
+	}
+	rp, err := capture.Import(ctx, "reimported", buf.Bytes())
+	if !assert.For(ctx, "re-import").ThatError(err).Succeeded() {
+		return
+	}
+	rc, err := capture.Resolve(capture.Put(ctx, rp))
+	if !assert.For(ctx, "re-resolve").ThatError(err).Succeeded() {
+		return
+	}
+	checkLogcat(ctx, rc.Logcat, expected)
+}
 
-	assert.For(ctx, "got").That(ic.Commands).DeepEquals(cmds)
+func checkLogcat(ctx context.Context, got, expected []capture.Logcat) {
+	if !assert.For(ctx, "logcat").That(len(got)).Equals(len(expected)) {
+		return
+	}
+	for i, l := range got {
+		assert.For(ctx, "tag").That(l.Tag).Equals(expected[i].Tag)
+		assert.For(ctx, "command").That(l.Command).Equals(expected[i].Command)
+	}
+}
+
+func TestCaptureIndex(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	header := &capture.Header{Abi: device.WindowsX86_64}
+	cmds := []api.Cmd{testcmd.P, testcmd.Q}
+	p, err := capture.New(ctx, "test", header, cmds)
+	if !assert.For(ctx, "capture.New").ThatError(err).Succeeded() {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	a := pack.NewAppender(buf)
+	err = capture.Export(capture.Put(ctx, p), p, a)
+	if !assert.For(ctx, "capture.Export").ThatError(err).Succeeded() {
+		return
+	}
+
+	// Exported captures are indexed.
+	_, err = pack.ReadIndex(ctx, bytes.NewReader(buf.Bytes()))
+	assert.For(ctx, "ReadIndex").ThatError(err).Succeeded()
+
+	// Appending to the stream hides the index, so the stream is read in full.
+	w, err := a.Writer()
+	if !assert.For(ctx, "Appender.Writer").ThatError(err).Succeeded() {
+		return
+	}
+	err = w.Object(ctx, &capture.LogcatMessage{Tag: "after", Position: a.Objects()})
+	assert.For(ctx, "Object").ThatError(err).Succeeded()
+
+	_, err = pack.ReadIndex(ctx, bytes.NewReader(buf.Bytes()))
+	assert.For(ctx, "ReadIndex (appended)").ThatError(err).Equals(pack.ErrNoIndex)
+
+	ip, err := capture.Import(ctx, "imported", buf.Bytes())
+	if !assert.For(ctx, "capture.Import (appended)").ThatError(err).Succeeded() {
+		return
+	}
+	ic, err := capture.Resolve(capture.Put(ctx, ip))
+	if assert.For(ctx, "capture.Resolve (appended)").ThatError(err).Succeeded() {
+		assert.For(ctx, "got").That(ic.Commands).DeepEquals(cmds)
+	}
 }
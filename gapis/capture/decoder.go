@@ -3,6 +3,7 @@ package capture
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/gapid/core/data/id"
@@ -20,6 +21,11 @@ type decoder struct {
 	header  *Header
 	builder *builder
 	groups  map[uint64]interface{}
+	// objects is the number of objects read from the stream.
+	objects uint64
+	// cmdEnds holds the value of objects after the end of each command.
+	cmdEnds []uint64
+	logcat  []*LogcatMessage
 }
 
 func newDecoder() *decoder {
@@ -49,6 +55,7 @@ func (d *decoder) RemapID(ctx context.Context, id id.ID) (int64, error) {
 }
 
 func (d *decoder) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	d.objects++
 	obj, err := d.decode(ctx, msg)
 	if err != nil {
 		return err
@@ -58,6 +65,7 @@ func (d *decoder) BeginGroup(ctx context.Context, msg proto.Message, id uint64)
 }
 
 func (d *decoder) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	d.objects++
 	obj, err := d.decode(ctx, msg)
 	if err != nil {
 		return err
@@ -67,6 +75,11 @@ func (d *decoder) BeginChildGroup(ctx context.Context, msg proto.Message, id, pa
 }
 
 func (d *decoder) EndGroup(ctx context.Context, id uint64) error {
+	d.objects++
+	return d.endGroup(ctx, id)
+}
+
+func (d *decoder) endGroup(ctx context.Context, id uint64) error {
 	obj := d.groups[id]
 	delete(d.groups, id)
 
@@ -74,6 +87,7 @@ func (d *decoder) EndGroup(ctx context.Context, id uint64) error {
 	case *cmdGroup:
 		obj.invoked = true
 		id := d.builder.addCmd(ctx, obj.cmd)
+		d.cmdEnds = append(d.cmdEnds, d.objects)
 		for _, c := range obj.children {
 			c.SetCaller(id)
 		}
@@ -83,11 +97,13 @@ func (d *decoder) EndGroup(ctx context.Context, id uint64) error {
 }
 
 func (d *decoder) Object(ctx context.Context, msg proto.Message) error {
+	d.objects++
 	_, err := d.decode(ctx, msg)
 	return err
 }
 
 func (d *decoder) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	d.objects++
 	obj, err := d.decode(ctx, msg)
 	if err != nil {
 		return err
@@ -181,6 +197,9 @@ func (d *decoder) decode(ctx context.Context, in proto.Message) (interface{}, er
 
 	case *InitialState:
 		d.builder.initialState = obj
+
+	case *LogcatMessage:
+		d.logcat = append(d.logcat, obj)
 	}
 
 	return obj, nil
@@ -188,6 +207,15 @@ func (d *decoder) decode(ctx context.Context, in proto.Message) (interface{}, er
 
 func (d *decoder) flush(ctx context.Context) {
 	for k := range d.groups {
-		d.EndGroup(ctx, k)
+		d.endGroup(ctx, k)
+	}
+	for _, m := range d.logcat {
+		// Find the last command that ended before the message was logged.
+		i := sort.Search(len(d.cmdEnds), func(i int) bool { return d.cmdEnds[i] > m.Position })
+		cmd := api.CmdNoID
+		if i > 0 {
+			cmd = api.CmdID(i - 1)
+		}
+		d.builder.logcat = append(d.builder.logcat, Logcat{m, cmd})
 	}
 }
@@ -40,7 +40,10 @@ func (e *encoder) encode(ctx context.Context) error {
 		}
 	}
 
-	for _, cmd := range e.c.Commands {
+	// cmdEnds holds the number of objects written after the end of each
+	// command, which are the positions of the logcat messages.
+	cmdEnds := make([]uint64, len(e.c.Commands))
+	for i, cmd := range e.c.Commands {
 		cmdID, err := e.startCmd(ctx, cmd)
 		if err != nil {
 			return err
@@ -51,6 +54,18 @@ func (e *encoder) encode(ctx context.Context) error {
 		if err := e.endCmd(ctx, cmd); err != nil {
 			return err
 		}
+		cmdEnds[i] = e.w.Objects()
+	}
+
+	for _, l := range e.c.Logcat {
+		msg := *l.LogcatMessage
+		msg.Position = 0
+		if l.Command != api.CmdNoID {
+			msg.Position = cmdEnds[l.Command]
+		}
+		if err := e.w.Object(ctx, &msg); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,98 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"context"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/pkg/errors"
+)
+
+// errInfoComplete is returned by infoEvents to stop reading the stream once
+// the header has been read.
+const errInfoComplete = fault.Const("Capture info complete")
+
+// indexedInfo is the summary of an indexed capture stream.
+type indexedInfo struct {
+	// header is the capture header.
+	header *Header
+	// numRootCommands is the number of commands that have no caller.
+	numRootCommands uint64
+}
+
+// readIndexedInfo reads the summary of the capture stream using its index,
+// seeking over the commands. It returns nil if the stream has no index.
+func readIndexedInfo(ctx context.Context, r io.ReadSeeker) (*indexedInfo, error) {
+	index, err := pack.ReadIndex(ctx, r)
+	switch errors.Cause(err) {
+	case nil:
+	case pack.ErrNoIndex:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	events := &infoEvents{info: &indexedInfo{}}
+	state := proto.MessageName(&GlobalState{})
+	for _, g := range index.Groups {
+		if g.Type != state {
+			events.info.numRootCommands++
+		}
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := pack.Read(ctx, r, events, false); err != nil && errors.Cause(err) != errInfoComplete {
+		return nil, err
+	}
+	if events.info.header == nil {
+		return nil, log.Err(ctx, nil, "Capture was missing header chunk")
+	}
+	return events.info, nil
+}
+
+// infoEvents is the pack.Events implementation used by readIndexedInfo to
+// read the header.
+type infoEvents struct {
+	info *indexedInfo
+}
+
+func (e *infoEvents) BeginGroup(ctx context.Context, msg proto.Message, id uint64) error {
+	return nil
+}
+
+func (e *infoEvents) BeginChildGroup(ctx context.Context, msg proto.Message, id, parentID uint64) error {
+	return nil
+}
+
+func (e *infoEvents) EndGroup(ctx context.Context, id uint64) error {
+	return nil
+}
+
+func (e *infoEvents) Object(ctx context.Context, msg proto.Message) error {
+	if h, ok := msg.(*Header); ok {
+		e.info.header = h
+		return errInfoComplete
+	}
+	return nil
+}
+
+func (e *infoEvents) ChildObject(ctx context.Context, msg proto.Message, parentID uint64) error {
+	return nil
+}
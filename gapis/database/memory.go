@@ -23,20 +23,35 @@ import (
 	"sync"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/data/id"
 	"github.com/google/gapid/core/data/protoconv"
 	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
 )
 
 // NewInMemory builds a new in memory database.
 func NewInMemory(ctx context.Context) Database {
-	m := &memory{}
+	return NewInMemoryWithExecutor(ctx, task.Go)
+}
+
+// NewInMemoryWithExecutor builds a new in memory database that uses exec to
+// run resolves. Each resolve is run with the priority and client of the
+// context that first requested it.
+func NewInMemoryWithExecutor(ctx context.Context, exec task.Executor) Database {
+	m := &memory{executor: exec}
 	m.records = map[id.ID]*record{}
 	m.resolveCtx = Put(ctx, m)
 	return m
 }
 
+var (
+	resolveHitCounter     = benchmark.Integer(benchmark.Name("database.resolve", benchmark.Labels{"result": "hit"}))
+	resolveWaitCounter    = benchmark.Integer(benchmark.Name("database.resolve", benchmark.Labels{"result": "wait"}))
+	resolveMissCounter    = benchmark.Integer(benchmark.Name("database.resolve", benchmark.Labels{"result": "miss"}))
+	resolveLatencyCounter = benchmark.Histogram("database.resolve.latency", benchmark.LatencyBuckets)
+)
+
 var sha1Pool = sync.Pool{New: func() interface{} { return sha1.New() }}
 
 func generateID(ty recordType, encoded []byte) id.ID {
@@ -72,6 +87,8 @@ type resolveState struct {
 	finished   chan struct{}   // Signal that resolve has finished. Set to nil when done.
 	waiting    uint32          // Number of go-routines waiting for the resolve
 	cancel     func()          // Cancels ctx
+	started    bool            // True once a task has started the resolve
+	priority   task.Priority   // Highest priority the resolve was scheduled at
 	callstacks []callstack
 }
 
@@ -136,6 +153,7 @@ type memory struct {
 	mutex      sync.Mutex
 	records    map[id.ID]*record
 	resolveCtx context.Context
+	executor   task.Executor
 }
 
 // Implements Database
@@ -173,12 +191,45 @@ func (d *memory) Store(ctx context.Context, val interface{}) (id.ID, error) {
 
 // Implements Database
 func (d *memory) Resolve(ctx context.Context, id id.ID) (interface{}, error) {
+	ctx, end := log.StartSpan(ctx, "database.Resolve")
+	defer end()
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	return d.resolveLocked(ctx, id)
 }
 
-// resolve function must be called with a locked mutex and returns with a locked
+// resolveTask returns a task that builds r, unless another task has already
+// started building it.
+func (d *memory) resolveTask(r *record, rs *resolveState) task.Task {
+	return func(ctx context.Context) error {
+		d.mutex.Lock()
+		started := rs.started
+		rs.started = true
+		d.mutex.Unlock()
+		if !started {
+			d.runResolve(ctx, r, rs)
+		}
+		return nil
+	}
+}
+
+// runResolve builds r and signals rs once it has finished.
+func (d *memory) runResolve(ctx context.Context, r *record, rs *resolveState) {
+	defer d.resolvePanicHandler(ctx)
+	ctx, end := log.StartSpan(ctx, "Resolve "+string(r.ty))
+	defer end()
+	start := resolveLatencyCounter.Start()
+	err := r.resolve(ctx)
+	resolveLatencyCounter.Stop(start)
+
+	// Signal that the resolvable has finished.
+	d.mutex.Lock()
+	close(rs.finished)
+	rs.err, rs.finished = err, nil
+	d.mutex.Unlock()
+}
+
+// resolveLocked must be called with a locked mutex and returns with a locked
 // mutex.
 func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, error) {
 	// Look up the record with the provided identifier.
@@ -189,6 +240,14 @@ func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, erro
 	}
 
 	rs := r.resolveState
+	switch {
+	case rs == nil:
+		resolveMissCounter.Increment()
+	case rs.finished == nil:
+		resolveHitCounter.Increment()
+	default:
+		resolveWaitCounter.Increment()
+	}
 	if rs == nil {
 		// First request for this resolvable.
 
@@ -197,28 +256,20 @@ func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, erro
 
 		// Build a cancellable context for the resolve from database's resolve
 		// context. We use this as we don't to cancel the resolve if a single
-		// caller cancel's their context.
-		resolveCtx, cancel := task.WithCancel(d.resolveCtx)
+		// caller cancel's their context. The resolve is scheduled on behalf of
+		// the caller, so it inherits the caller's scheduling properties.
+		resolveCtx, cancel := task.WithCancel(task.Inherit(d.resolveCtx, ctx))
 
 		rs = &resolveState{
 			ctx:      rc.bind(resolveCtx),
 			finished: make(chan struct{}),
 			cancel:   cancel,
+			priority: task.GetPriority(resolveCtx),
 		}
 		r.resolveState = rs
 
 		// Build the resolvable on a separate go-routine.
-		ctx := rs.ctx
-		crash.Go(func() {
-			defer d.resolvePanicHandler(ctx)
-			err := r.resolve(ctx)
-
-			// Signal that the resolvable has finished.
-			d.mutex.Lock()
-			close(rs.finished)
-			rs.err, rs.finished = err, nil
-			d.mutex.Unlock()
-		})
+		d.executor(rs.ctx, d.resolveTask(r, rs))
 	}
 
 	if finished := rs.finished; finished != nil {
@@ -226,11 +277,29 @@ func (d *memory) resolveLocked(ctx context.Context, id id.ID) (interface{}, erro
 		// Increment the waiting go-routine counter.
 		rs.waiting++
 		rs.callstacks = append(rs.callstacks, getCallstack(4))
+
+		// A caller running on a pool holds one of its workers. If the resolve
+		// is still queued it may need that worker, so build it on the caller.
+		inline := !rs.started && task.InPool(ctx)
+		if inline {
+			rs.started = true
+		} else if p := task.GetPriority(ctx); !rs.started && p > rs.priority {
+			// The resolve is queued at a lower priority than this caller.
+			// Schedule it again at the caller's priority. Whichever task runs
+			// first builds it, the other does nothing.
+			rs.priority = p
+			d.executor(task.Inherit(rs.ctx, ctx), d.resolveTask(r, rs))
+		}
+
 		// Wait for either the resolve to finish or ctx to be cancelled.
 		d.mutex.Unlock()
-		select {
-		case <-finished:
-		case <-task.ShouldStop(ctx):
+		if inline {
+			d.runResolve(task.Inherit(rs.ctx, ctx), r, rs)
+		} else {
+			select {
+			case <-finished:
+			case <-task.ShouldStop(ctx):
+			}
 		}
 		d.mutex.Lock()
 
@@ -0,0 +1,148 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/database"
+)
+
+// resolvers maps testResolvable names to the function that resolves them.
+var resolvers = map[string]func(context.Context) (interface{}, error){}
+
+type testResolvable struct {
+	Name string `protobuf:"bytes,1,opt,name=name"`
+}
+
+func (r *testResolvable) Reset()         { *r = testResolvable{} }
+func (r *testResolvable) String() string { return proto.CompactTextString(r) }
+func (*testResolvable) ProtoMessage()    {}
+
+func (r *testResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	return resolvers[r.Name](ctx)
+}
+
+// resolve builds r on a new go-routine.
+func resolve(ctx context.Context, r database.Resolvable) task.Handle {
+	return task.Go(ctx, func(ctx context.Context) error {
+		_, err := database.Build(ctx, r)
+		return err
+	})
+}
+
+// waitQueued waits until pool has n queued tasks.
+func waitQueued(pool *task.PriorityPool, n int) {
+	for pool.Queued() != n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestResolveNestedQueued(t *testing.T) {
+	ctx := log.Testing(t)
+	pool := task.NewPriorityPool(1, nil, "resolve")
+	defer pool.Shutdown(ctx)
+	ctx = database.Put(ctx, database.NewInMemoryWithExecutor(ctx, pool.Execute))
+
+	outer, inner := &testResolvable{"nested.outer"}, &testResolvable{"nested.inner"}
+	started, start := task.NewSignal()
+	queued, queue := task.NewSignal()
+	resolvers[outer.Name] = func(ctx context.Context) (interface{}, error) {
+		start(ctx)
+		queued.Wait(ctx)
+		// The pool only has one worker, held by this resolve, so this would
+		// deadlock if it waited for the queued inner resolve.
+		v, err := database.Build(ctx, inner)
+		if err != nil {
+			return nil, err
+		}
+		return "outer(" + v.(string) + ")", nil
+	}
+	resolvers[inner.Name] = func(ctx context.Context) (interface{}, error) {
+		return "inner", nil
+	}
+
+	var got interface{}
+	h := task.Go(ctx, func(ctx context.Context) error {
+		var err error
+		got, err = database.Build(ctx, outer)
+		return err
+	})
+	started.Wait(ctx)
+
+	// Queue the inner resolve from outside the pool before the outer resolve
+	// asks for it.
+	first := resolve(ctx, inner)
+	waitQueued(pool, 1)
+	queue(ctx)
+
+	assert.For(ctx, "outer").ThatError(h.Result(ctx)).Succeeded()
+	assert.For(ctx, "value").That(got).Equals("outer(inner)")
+	assert.For(ctx, "inner").ThatError(first.Result(ctx)).Succeeded()
+}
+
+func TestResolvePromoted(t *testing.T) {
+	ctx := log.Testing(t)
+	pool := task.NewPriorityPool(1, nil, "resolve")
+	defer pool.Shutdown(ctx)
+	ctx = database.Put(ctx, database.NewInMemoryWithExecutor(ctx, pool.Execute))
+
+	mutex := sync.Mutex{}
+	order := []string{}
+	block := &testResolvable{"promoted.block"}
+	release, unblock := task.NewSignal()
+	started, start := task.NewSignal()
+	resolvers[block.Name] = func(ctx context.Context) (interface{}, error) {
+		start(ctx)
+		release.Wait(ctx)
+		return block.Name, nil
+	}
+	for _, name := range []string{"promoted.background", "promoted.normal"} {
+		name := name
+		resolvers[name] = func(ctx context.Context) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+			return name, nil
+		}
+	}
+	background, normal := &testResolvable{"promoted.background"}, &testResolvable{"promoted.normal"}
+
+	handles := []task.Handle{resolve(ctx, block)}
+	started.Wait(ctx)
+	handles = append(handles, resolve(task.PutPriority(ctx, task.Background), background))
+	waitQueued(pool, 1)
+	handles = append(handles, resolve(ctx, normal))
+	waitQueued(pool, 2)
+	// An interactive caller waiting on the background resolve should get it
+	// built ahead of the normal one.
+	handles = append(handles, resolve(task.PutPriority(ctx, task.Interactive), background))
+	waitQueued(pool, 3)
+	unblock(ctx)
+
+	for _, h := range handles {
+		assert.For(ctx, "result").ThatError(h.Result(ctx)).Succeeded()
+	}
+	assert.For(ctx, "order").ThatSlice(order).Equals([]string{
+		"promoted.background", "promoted.normal",
+	})
+}
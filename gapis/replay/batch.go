@@ -118,6 +118,8 @@ func (m *Manager) execute(
 		"capture": captureID,
 		"device":  d.Instance().GetName(),
 	}.Bind(ctx)
+	ctx, end := log.StartSpan(ctx, "Replay")
+	defer end()
 
 	intent := Intent{path.NewDevice(deviceID), capturePath}
 
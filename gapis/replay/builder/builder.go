@@ -570,7 +570,8 @@ func (b *Builder) Write(rng memory.Range, resourceID id.ID) {
 // sent to the replay virtual-machine and a ResponseDecoder for interpreting
 // the responses.
 func (b *Builder) Build(ctx context.Context) (gapir.Payload, ResponseDecoder, error) {
-	ctx = log.Enter(ctx, "Build")
+	ctx, end := log.StartSpan(ctx, "Build")
+	defer end()
 	if config.DebugReplayBuilder {
 		log.I(ctx, "Instruction count: %d", len(b.instructions))
 		b.assertResourceSizesAreAsExpected(ctx)
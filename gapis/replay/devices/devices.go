@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device"
@@ -66,15 +67,20 @@ func ForReplay(ctx context.Context, p *path.Capture) ([]*path.Device, error) {
 			}
 		}
 		if p > 0 {
+			missing, scored := missingExtensions(c.Header.GetDevice(), instance)
 			ctx := log.V{
 				"device": instance,
 			}.Bind(ctx)
-			log.D(ctx, "Priority %d", p)
-			filtered = append(filtered, prioritizedDevice{device, p})
+			if scored {
+				log.D(ctx, "Priority %d, missing extensions %d", p, missing)
+			} else {
+				log.D(ctx, "Priority %d, extensions not compared", p)
+			}
+			filtered = append(filtered, prioritizedDevice{device, p, missing, scored})
 		}
 	}
 
-	sort.Sort(prioritizedDevices(filtered))
+	sort.Stable(prioritizedDevices(filtered))
 
 	paths := make([]*path.Device, len(filtered))
 	for i, d := range filtered {
@@ -99,9 +105,45 @@ func Sorted(ctx context.Context) []bind.Device {
 	return append(androidDevices, nonAndroidDevices...)
 }
 
+// missingExtensions returns the number of OpenGL or OpenGL ES extensions
+// supported by the capture device that are not supported by the replay device.
+// The extensions are only compared if both devices report them for the same
+// API, so scored is false if OpenGL ES is compared with desktop OpenGL, or
+// either device has no OpenGL driver information. Vulkan drivers are not
+// compared, as the device information only holds the instance extensions,
+// while the capture depends on the device extensions.
+func missingExtensions(capture, replay *device.Instance) (missing int, scored bool) {
+	c := capture.GetConfiguration().GetDrivers().GetOpenGL()
+	r := replay.GetConfiguration().GetDrivers().GetOpenGL()
+	if len(c.GetExtensions()) == 0 || len(r.GetExtensions()) == 0 || isGLES(c) != isGLES(r) {
+		return 0, false
+	}
+	supported := make(map[string]bool, len(r.Extensions))
+	for _, e := range r.Extensions {
+		supported[e] = true
+	}
+	for _, e := range c.Extensions {
+		if !supported[e] {
+			missing++
+		}
+	}
+	return missing, true
+}
+
+// isGLES returns true if the driver implements OpenGL ES rather than desktop
+// OpenGL.
+func isGLES(d *device.OpenGLDriver) bool {
+	return strings.HasPrefix(d.GetVersion(), "OpenGL ES")
+}
+
 type prioritizedDevice struct {
 	device   bind.Device
 	priority uint32
+	// missing is the number of extensions of the capture device that are not
+	// supported by the device, if scored. Used to order devices of equal
+	// priority, with the scored devices first.
+	missing int
+	scored  bool
 }
 
 type prioritizedDevices []prioritizedDevice
@@ -111,7 +153,13 @@ func (p prioritizedDevices) Len() int {
 }
 
 func (p prioritizedDevices) Less(i, j int) bool {
-	return p[i].priority < p[j].priority
+	if p[i].priority != p[j].priority {
+		return p[i].priority < p[j].priority
+	}
+	if p[i].scored != p[j].scored {
+		return p[i].scored
+	}
+	return p[i].missing < p[j].missing
 }
 
 func (p prioritizedDevices) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
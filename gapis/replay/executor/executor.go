@@ -117,7 +117,8 @@ func (e executor) HandleCrashDump(ctx context.Context, dump *gapir.CrashDump, co
 
 // HandleResourceRequest implements gapir.ReplayResponseHandler interface.
 func (e executor) HandleResourceRequest(ctx context.Context, req *gapir.ResourceRequest, conn *gapir.Connection) error {
-	ctx = log.Enter(ctx, "handleResourceRequest")
+	ctx, end := log.StartSpan(ctx, "handleResourceRequest")
+	defer end()
 	if req == nil {
 		return log.Err(ctx, nil, "Cannot handle nil resource request")
 	}
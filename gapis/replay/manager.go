@@ -16,11 +16,13 @@ package replay
 
 import (
 	"context"
+	"strconv"
 	"sync"
-
 	"time"
 
+	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device/bind"
 	gapir "github.com/google/gapid/gapir/client"
@@ -92,7 +94,18 @@ func (m *Manager) Replay(
 		Priority:     defaultPriority,
 		Precondition: defaultBatchDelay,
 	}
-	if hints != nil {
+	if hints == nil {
+		// Without explicit hints, schedule by the priority of the task making
+		// the request.
+		switch task.GetPriority(ctx) {
+		case task.Interactive:
+			b.Priority = highPriorty
+			b.Precondition = nil
+		case task.Background:
+			b.Priority = lowestPriority
+			b.Precondition = backgroundBatchDelay
+		}
+	} else {
 		if hints.Preview {
 			b.Priority = lowPriority
 		}
@@ -105,6 +118,11 @@ func (m *Manager) Replay(
 			b.Precondition = backgroundBatchDelay
 		}
 	}
+	latency := benchmark.Histogram(benchmark.Name("replay.latency", benchmark.Labels{
+		"priority": strconv.Itoa(b.Priority),
+	}), benchmark.LatencyBuckets)
+	defer latency.Stop(latency.Start())
+
 	return s.Schedule(ctx, req, b)
 }
 
@@ -123,7 +141,10 @@ func (m *Manager) createScheduler(ctx context.Context, device bind.Device) {
 	log.I(ctx, "New scheduler for device: %v", deviceID)
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	m.schedulers[deviceID] = scheduler.New(ctx, m.batch)
+	queued := benchmark.Integer(benchmark.Name("replay.queued", benchmark.Labels{
+		"device": deviceID.String(),
+	}))
+	m.schedulers[deviceID] = scheduler.NewWithQueueCounter(ctx, m.batch, queued)
 }
 
 func (m *Manager) destroyScheduler(ctx context.Context, device bind.Device) {
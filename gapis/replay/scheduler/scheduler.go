@@ -19,9 +19,9 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
-	"sync/atomic"
 	"time"
 
+	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/app/crash"
 	"github.com/google/gapid/core/event/task"
 )
@@ -64,18 +64,24 @@ type Batch struct {
 type Scheduler struct {
 	pending  chan *job
 	exec     Executor
-	queueLen uint32
+	queueLen *benchmark.IntegerCounter
 }
 
 // New returns a new Scheduler that will execute Tasks with exec.
 func New(ctx context.Context, exec Executor) *Scheduler {
-	s := &Scheduler{exec: exec, pending: make(chan *job, 32)}
+	return NewWithQueueCounter(ctx, exec, new(benchmark.IntegerCounter))
+}
+
+// NewWithQueueCounter returns a new Scheduler that will execute Tasks with
+// exec, keeping the number of queued tasks in the counter queued.
+func NewWithQueueCounter(ctx context.Context, exec Executor, queued *benchmark.IntegerCounter) *Scheduler {
+	s := &Scheduler{exec: exec, pending: make(chan *job, 32), queueLen: queued}
 	crash.Go(func() { s.run(ctx) })
 	return s
 }
 
 // NumTasksQueued returns the number of queued tasks.
-func (s *Scheduler) NumTasksQueued() int { return int(s.queueLen) }
+func (s *Scheduler) NumTasksQueued() int { return int(s.queueLen.Get()) }
 
 // Schedule schedules t to be executed on s. Tasks with compatible batches may
 // be executed together.
@@ -137,7 +143,7 @@ func (s *Scheduler) run(ctx context.Context) {
 			}
 			interrupts = append(interrupts, interrupt)
 		}
-		atomic.AddUint32(&s.queueLen, 1)
+		s.queueLen.Increment()
 	}
 
 	for !task.Stopped(ctx) {
@@ -176,7 +182,7 @@ func (s *Scheduler) run(ctx context.Context) {
 			best.exec(ctx, s.exec)
 			// Drop the batch.
 			delete(bins, best.batch)
-			atomic.AddUint32(&s.queueLen, -uint32(len(best.jobs)))
+			s.queueLen.Add(-int64(len(best.jobs)))
 			// Rebuild interrupts.
 			interrupts = interrupts[:casePreconditions]
 			for _, b := range bins {
@@ -190,6 +190,8 @@ func (r *DependencyGraphResolvable) Resolve(ctx context.Context) (interface{}, e
 			if _, ok := behaviourProviders[a]; !ok {
 				if bp, ok := a.(DependencyGraphBehaviourProvider); ok {
 					behaviourProviders[a] = bp.GetDependencyGraphBehaviourProvider(ctx)
+				} else if f := getStaticFootprints(a.Name()); f != nil {
+					behaviourProviders[a] = NewStaticBehaviourProvider(f)
 				} else {
 					// API does not provide dependency information, always keep
 					// commands for such APIs.
@@ -0,0 +1,132 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dependencygraph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// StaticFootprints is the statically analysed set of global state paths that
+// each command of an API may read and write, as generated by the
+// 'apic footprint --json' command.
+type StaticFootprints struct {
+	// API is the name of the API the footprints were generated for.
+	API string `json:"api"`
+	// Commands is a map of command name to footprint.
+	Commands map[string]StaticFootprint `json:"commands"`
+}
+
+// StaticFootprint is the set of global state paths a single command may read
+// and write. Paths are of the form 'Global.field[].field', where '[]' denotes
+// the entries of a map or array.
+type StaticFootprint struct {
+	Reads  []string `json:"reads"`
+	Writes []string `json:"writes"`
+}
+
+// LoadStaticFootprints decodes the JSON footprint data generated by
+// 'apic footprint --json'.
+func LoadStaticFootprints(data []byte) (*StaticFootprints, error) {
+	out := &StaticFootprints{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var (
+	staticFootprintsMutex sync.Mutex
+	staticFootprints      = map[string]*StaticFootprints{}
+)
+
+// RegisterStaticFootprints registers the footprints f for the API named f.API.
+// If that API does not implement DependencyGraphBehaviourProvider then the
+// dependency graph uses the footprints to describe the API's commands.
+func RegisterStaticFootprints(f *StaticFootprints) {
+	staticFootprintsMutex.Lock()
+	defer staticFootprintsMutex.Unlock()
+	staticFootprints[f.API] = f
+}
+
+// getStaticFootprints returns the footprints registered for the API with the
+// given name, or nil if there are none.
+func getStaticFootprints(api string) *StaticFootprints {
+	staticFootprintsMutex.Lock()
+	defer staticFootprintsMutex.Unlock()
+	return staticFootprints[api]
+}
+
+// StaticStateKey is a StateKey for a statically analysed global state path.
+// The key does not distinguish between the individual entries of a map or
+// array, so a path containing '[]' stands for every entry.
+type StaticStateKey string
+
+// Parent returns the state path enclosing k.
+func (k StaticStateKey) Parent() StateKey {
+	if i := strings.LastIndexAny(string(k), ".["); i > 0 {
+		return k[:i]
+	}
+	return nil
+}
+
+// NewStaticBehaviourProvider returns a BehaviourProvider that reports the
+// state reads and writes of each command using the statically analysed
+// footprints. This can be used by APIs that do not implement a hand-written
+// BehaviourProvider.
+// The provider is conservative. Writes to a path containing '[]' may only
+// write some of the entries, so are reported as modifications. Commands
+// without a footprint, or that observe memory, are kept alive as footprints
+// do not describe memory.
+func NewStaticBehaviourProvider(f *StaticFootprints) BehaviourProvider {
+	return &staticBehaviourProvider{f}
+}
+
+type staticBehaviourProvider struct {
+	footprints *StaticFootprints
+}
+
+// GetBehaviourForAtom returns state reads/writes that the given command
+// performs, as described by its static footprint.
+func (p *staticBehaviourProvider) GetBehaviourForAtom(
+	ctx context.Context, s *api.GlobalState, id api.CmdID, cmd api.Cmd, g *DependencyGraph) AtomBehaviour {
+
+	b := AtomBehaviour{}
+	fp, ok := p.footprints.Commands[cmd.CmdName()]
+	if !ok {
+		b.KeepAlive = true
+	}
+	for _, r := range fp.Reads {
+		b.Read(g, StaticStateKey(r))
+	}
+	for _, w := range fp.Writes {
+		if strings.Contains(w, "[]") {
+			b.Modify(g, StaticStateKey(w))
+		} else {
+			b.Write(g, StaticStateKey(w))
+		}
+	}
+	if o := cmd.Extras().Observations(); o != nil && (len(o.Reads) > 0 || len(o.Writes) > 0) {
+		b.KeepAlive = true
+	}
+	if err := cmd.Mutate(ctx, id, s, nil /* builder */); err != nil {
+		b.Aborted = true
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dependencygraph
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/testcmd"
+)
+
+func TestStaticStateKeyParent(t *testing.T) {
+	assert := assert.To(t)
+	k := StaticStateKey("Contexts[].Bound.Size")
+	assert.For("parent").That(k.Parent()).Equals(StaticStateKey("Contexts[].Bound"))
+	assert.For("grandparent").That(k.Parent().Parent()).Equals(StaticStateKey("Contexts[]"))
+	assert.For("map").That(k.Parent().Parent().Parent()).Equals(StaticStateKey("Contexts"))
+	assert.For("root").That(StaticStateKey("Contexts").Parent()).IsNil()
+}
+
+func TestLoadStaticFootprints(t *testing.T) {
+	assert := assert.To(t)
+	f, err := LoadStaticFootprints([]byte(`{
+  "api": "test",
+  "commands": {
+    "cmdA": { "reads": ["Current"], "writes": ["Contexts[].Count"] }
+  }
+}`))
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("api").That(f.API).Equals("test")
+	assert.For("reads").ThatSlice(f.Commands["cmdA"].Reads).Equals([]string{"Current"})
+	assert.For("writes").ThatSlice(f.Commands["cmdA"].Writes).Equals([]string{"Contexts[].Count"})
+}
+
+func TestStaticBehaviourProvider(t *testing.T) {
+	ctx := log.Testing(t)
+	g := &DependencyGraph{
+		addressMap: addressMapping{
+			address: map[StateKey]StateAddress{nil: NullStateAddress},
+			key:     map[StateAddress]StateKey{NullStateAddress: nil},
+			parent:  map[StateAddress]StateAddress{NullStateAddress: NullStateAddress},
+		},
+	}
+	p := NewStaticBehaviourProvider(&StaticFootprints{
+		API: "test",
+		Commands: map[string]StaticFootprint{
+			"A": {Reads: []string{"Current"}, Writes: []string{"Counter", "Contexts[].Count"}},
+		},
+	})
+
+	b := p.GetBehaviourForAtom(ctx, nil, 0, &testcmd.A{}, g)
+	assert.For(ctx, "reads").ThatSlice(b.Reads).Equals([]StateAddress{g.addressMap.addressOf(StaticStateKey("Current"))})
+	assert.For(ctx, "writes").ThatSlice(b.Writes).Equals([]StateAddress{g.addressMap.addressOf(StaticStateKey("Counter"))})
+	// A write to a collapsed entry may not overwrite every entry.
+	assert.For(ctx, "modifies").ThatSlice(b.Modifies).Equals([]StateAddress{g.addressMap.addressOf(StaticStateKey("Contexts[].Count"))})
+	assert.For(ctx, "keep alive").That(b.KeepAlive).Equals(false)
+
+	b = p.GetBehaviourForAtom(ctx, nil, api.CmdID(1), &testcmd.B{}, g)
+	assert.For(ctx, "unknown command keep alive").That(b.KeepAlive).Equals(true)
+}
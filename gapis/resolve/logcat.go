@@ -0,0 +1,49 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// Logcat resolves the logcat messages recorded while tracing the capture.
+func Logcat(ctx context.Context, p *path.Logcat) (*service.Logcat, error) {
+	c, err := capture.ResolveFromPath(ctx, p.Capture)
+	if err != nil {
+		return nil, err
+	}
+	out := &service.Logcat{Messages: make([]*service.LogcatMessage, len(c.Logcat))}
+	for i, m := range c.Logcat {
+		var cmd *path.Command
+		if m.Command != api.CmdNoID {
+			cmd = p.Capture.Command(uint64(m.Command))
+		}
+		out.Messages[i] = &service.LogcatMessage{
+			Timestamp: m.Timestamp,
+			Priority:  m.Priority,
+			Tag:       m.Tag,
+			ProcessId: m.ProcessId,
+			ThreadId:  m.ThreadId,
+			Message:   m.Message,
+			Command:   cmd,
+		}
+	}
+	return out, nil
+}
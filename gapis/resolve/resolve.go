@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/gapid/core/data/dictionary"
 	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/math/sint"
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/bind"
@@ -253,6 +254,8 @@ func ResolveService(ctx context.Context, p path.Node) (interface{}, error) {
 // p without converting the potentially internal result to a service
 // representation.
 func ResolveInternal(ctx context.Context, p path.Node) (interface{}, error) {
+	ctx, end := log.StartSpan(ctx, fmt.Sprintf("Resolve %T", p))
+	defer end()
 	switch p := p.(type) {
 	case *path.ArrayIndex:
 		return ArrayIndex(ctx, p)
@@ -290,6 +293,8 @@ func ResolveInternal(ctx context.Context, p path.Node) (interface{}, error) {
 		return GlobalState(ctx, p)
 	case *path.ImageInfo:
 		return ImageInfo(ctx, p)
+	case *path.Logcat:
+		return Logcat(ctx, p)
 	case *path.MapIndex:
 		return MapIndex(ctx, p)
 	case *path.Memory:
@@ -312,6 +317,8 @@ func ResolveInternal(ctx context.Context, p path.Node) (interface{}, error) {
 		return State(ctx, p)
 	case *path.StateTree:
 		return StateTree(ctx, p)
+	case *path.StateTreeDiff:
+		return StateTreeDiff(ctx, p)
 	case *path.StateTreeNode:
 		return StateTreeNode(ctx, p)
 	case *path.StateTreeNodeForPath:
@@ -165,6 +165,10 @@ type stn struct {
 	children       []*stn
 	isSubgroup     bool
 	subgroupOffset uint64
+	// The following are only used by state tree diffs.
+	tree   *stateTree                  // If non-nil, the tree used to build the children.
+	change service.StateTreeNodeChange // How the node differs between the states.
+	from   reflect.Value               // The value in the state compared against.
 }
 
 func (n *stn) index(ctx context.Context, i uint64, tree *stateTree) (*stn, error) {
@@ -200,6 +204,10 @@ func (n *stn) buildChildren(ctx context.Context, tree *stateTree) {
 		return
 	}
 
+	if n.tree != nil {
+		tree = n.tree
+	}
+
 	v, t, children := n.value, n.value.Type(), []*stn{}
 
 	dict := dictionary.From(v.Interface())
@@ -292,6 +300,13 @@ func (n *stn) buildChildren(ctx context.Context, tree *stateTree) {
 		}
 	}
 
+	// Children of added and removed diff nodes are also added or removed.
+	if n.tree != nil {
+		for _, c := range children {
+			c.tree, c.change = n.tree, n.change
+		}
+	}
+
 	n.children = children
 }
 
@@ -314,6 +329,10 @@ func isNil(v reflect.Value) bool {
 func (n *stn) service(ctx context.Context, tree *stateTree) *service.StateTreeNode {
 	n.buildChildren(ctx, tree)
 	preview, previewIsValue := stateValuePreview(n.value)
+	var fromPreview *box.Value
+	if n.from.IsValid() {
+		fromPreview, _ = stateValuePreview(n.from)
+	}
 	return &service.StateTreeNode{
 		NumChildren:    uint64(len(n.children)),
 		Name:           n.name,
@@ -321,6 +340,8 @@ func (n *stn) service(ctx context.Context, tree *stateTree) *service.StateTreeNo
 		Preview:        preview,
 		PreviewIsValue: previewIsValue,
 		Constants:      n.consts,
+		Change:         n.change,
+		FromPreview:    fromPreview,
 	}
 }
 
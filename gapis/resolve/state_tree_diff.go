@@ -0,0 +1,209 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/google/gapid/core/data/compare"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/box"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// StateTreeDiff resolves the specified state tree diff path.
+func StateTreeDiff(ctx context.Context, c *path.StateTreeDiff) (*service.StateTree, error) {
+	id, err := database.Store(ctx, &StateTreeDiffResolvable{c.State, c.From})
+	if err != nil {
+		return nil, err
+	}
+	return &service.StateTree{
+		Root: &path.StateTreeNode{Tree: path.NewID(id)},
+	}, nil
+}
+
+// Resolve builds and returns a *StateTree holding only the nodes that differ
+// between the two states.
+// Resolve implements the database.Resolver interface.
+func (r *StateTreeDiffResolvable) Resolve(ctx context.Context) (interface{}, error) {
+	// The trees are built here instead of being resolved from the database, as
+	// the diff takes ownership of their nodes.
+	to, err := (&StateTreeResolvable{Path: r.Path}).Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := (&StateTreeResolvable{Path: r.From}).Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return diffStateTrees(ctx, from.(*stateTree), to.(*stateTree))
+}
+
+// diffStateTrees returns a state tree holding only the nodes of the trees from
+// and to that have been added, removed or modified.
+// Subgrouping is not supported by diff trees, so from and to must not use it.
+func diffStateTrees(ctx context.Context, from, to *stateTree) (*stateTree, error) {
+	d := stateTreeDiffer{ctx, from, to, map[stnPair]struct{}{}}
+	root, err := d.diff(from.root, to.root)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		root = &stn{value: to.root.value, path: to.root.path, children: []*stn{}}
+	}
+	root.name = "root"
+	return &stateTree{to.globalState, to.state, root, to.api, 0}, nil
+}
+
+type stateTreeDiffer struct {
+	ctx      context.Context
+	from, to *stateTree
+	seen     map[stnPair]struct{}
+}
+
+// stnPair identifies a pair of addressable values that have been compared,
+// which stops state that references itself from being compared forever.
+type stnPair struct {
+	typ      reflect.Type
+	from, to uintptr
+}
+
+// diff returns a modified node holding the differences between from and to,
+// or nil if they are equal.
+func (d *stateTreeDiffer) diff(from, to *stn) (*stn, error) {
+	if from.value.IsValid() && to.value.IsValid() && from.value.CanAddr() && to.value.CanAddr() {
+		key := stnPair{to.value.Type(), from.value.UnsafeAddr(), to.value.UnsafeAddr()}
+		if _, seen := d.seen[key]; seen {
+			return nil, nil
+		}
+		d.seen[key] = struct{}{}
+	}
+
+	if isMemorySlice(from.value) && isMemorySlice(to.value) {
+		equal, err := d.memorySlicesEqual(box.AsMemorySlice(from.value), box.AsMemorySlice(to.value))
+		if err != nil || equal {
+			return nil, err
+		}
+		// The elements of the slice are only loaded if the node is expanded.
+		return d.modified(from, to, nil), nil
+	}
+
+	from.buildChildren(d.ctx, d.from)
+	to.buildChildren(d.ctx, d.to)
+
+	children := []*stn{}
+	if len(from.children) == 0 && len(to.children) == 0 {
+		if stateValuesEqual(from.value, to.value) {
+			return nil, nil
+		}
+	} else {
+		fromByName := make(map[string]*stn, len(from.children))
+		for _, c := range from.children {
+			fromByName[c.name] = c
+		}
+		for _, c := range to.children {
+			f, ok := fromByName[c.name]
+			if !ok {
+				c.tree, c.change = d.to, service.StateTreeNodeChange_Added
+				children = append(children, c)
+				continue
+			}
+			delete(fromByName, c.name)
+			n, err := d.diff(f, c)
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				children = append(children, n)
+			}
+		}
+		for _, c := range from.children {
+			if _, removed := fromByName[c.name]; removed {
+				c.tree, c.change = d.from, service.StateTreeNodeChange_Removed
+				children = append(children, c)
+			}
+		}
+		if len(children) == 0 {
+			return nil, nil
+		}
+	}
+
+	return d.modified(from, to, children), nil
+}
+
+// modified returns the node of the value that was modified from from to to.
+// If children is nil, the children of the node are built from to when needed.
+func (d *stateTreeDiffer) modified(from, to *stn, children []*stn) *stn {
+	return &stn{
+		name:     to.name,
+		value:    to.value,
+		path:     to.path,
+		consts:   to.consts,
+		children: children,
+		change:   service.StateTreeNodeChange_Modified,
+		from:     from.value,
+	}
+}
+
+// memorySlicesEqual returns true if the slices from and to refer to the same
+// memory, holding the same data in both states. The data is compared by its
+// resource identifier, so the elements of the slices are not loaded.
+func (d *stateTreeDiffer) memorySlicesEqual(from, to memory.Slice) (bool, error) {
+	if from.Root() != to.Root() || from.Base() != to.Base() || from.Size() != to.Size() ||
+		from.Count() != to.Count() || from.Pool() != to.Pool() || from.ElementType() != to.ElementType() {
+		return false, nil
+	}
+	fromID, err := memorySliceID(d.ctx, from, d.from)
+	if err != nil {
+		return false, err
+	}
+	toID, err := memorySliceID(d.ctx, to, d.to)
+	if err != nil {
+		return false, err
+	}
+	return fromID == toID, nil
+}
+
+// memorySliceID returns the resource identifier of the data of the slice s in
+// the state of tree.
+func memorySliceID(ctx context.Context, s memory.Slice, tree *stateTree) (id.ID, error) {
+	pool, err := tree.globalState.Memory.Get(s.Pool())
+	if err != nil {
+		return id.ID{}, err
+	}
+	return pool.Slice(memory.Range{Base: s.Base(), Size: s.Size()}).ResourceID(ctx)
+}
+
+// isMemorySlice returns true if v holds a memory.Slice.
+func isMemorySlice(v reflect.Value) bool {
+	return v.IsValid() && box.IsMemorySlice(v.Type())
+}
+
+// stateValuesEqual returns true if the state tree leaf values a and b are
+// equal.
+func stateValuesEqual(a, b reflect.Value) bool {
+	switch {
+	case !a.IsValid() || !b.IsValid():
+		return a.IsValid() == b.IsValid()
+	case a.Type() != b.Type():
+		return false
+	default:
+		return compare.DeepEqual(a.Interface(), b.Interface())
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/api/testcmd"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/box"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestStateTreeDiff(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	header := capture.Header{Abi: device.AndroidARM64v8a}
+	c, err := capture.New(ctx, "test-capture", &header, []api.Cmd{})
+	if err != nil {
+		panic(err)
+	}
+	ctx = capture.Put(ctx, c)
+	fromPath := c.Command(0).StateAfter()
+	toPath := c.Command(1).StateAfter()
+	gs, err := capture.NewState(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	// Write some data to 0x1000.
+	e := gs.MemoryEncoder(memory.ApplicationPool, memory.Range{Base: 0x1000, Size: 0x8000})
+	for i := 0; i < 0x1000; i++ {
+		e.I64(int64(i * 10))
+	}
+
+	newTree := func(state TestState, p *path.State) *stateTree {
+		return &stateTree{
+			globalState: gs,
+			root: &stn{
+				name:  "root",
+				value: reflect.ValueOf(state),
+				path:  p,
+			},
+			api: &path.API{Id: path.NewID(id.ID(testcmd.APIID))},
+		}
+	}
+
+	referenceA := *testState.ReferenceA
+	referenceA.Map = map[int]string{1: "one", 5: "FIVE", 10: "ten"}
+	referenceA.Slice = memory.NewSlice(0x1000, 0x1000, 3*intSize, 3, memory.ApplicationPool, intType)
+	changed := testState
+	changed.Int = 43
+	changed.ReferenceA = &referenceA
+	changed.ReferenceC = &TestStruct{}
+
+	tree, err := diffStateTrees(ctx, newTree(testState, fromPath), newTree(changed, toPath))
+	if !assert.For(ctx, "diffStateTrees").ThatError(err).Succeeded() {
+		return
+	}
+	root := &path.StateTreeNode{Indices: []uint64{}}
+
+	for _, test := range []struct {
+		path     *path.StateTreeNode
+		expected *service.StateTreeNode
+	}{
+		{
+			root,
+			&service.StateTreeNode{
+				NumChildren: 3,
+				Name:        "root",
+				ValuePath:   toPath.Path(),
+				Change:      service.StateTreeNodeChange_Modified,
+			},
+		}, {
+			root.Index(0),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "Int",
+				ValuePath:      toPath.Field("Int").Path(),
+				Preview:        box.NewValue(43),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Modified,
+				FromPreview:    box.NewValue(42),
+			},
+		}, {
+			root.Index(1),
+			&service.StateTreeNode{
+				NumChildren: 2,
+				Name:        "ReferenceA",
+				ValuePath:   toPath.Field("ReferenceA").Path(),
+				Change:      service.StateTreeNodeChange_Modified,
+			},
+		}, {
+			root.Index(1, 0),
+			&service.StateTreeNode{
+				NumChildren: 3,
+				Name:        "Map",
+				ValuePath:   toPath.Field("ReferenceA").Field("Map").Path(),
+				Change:      service.StateTreeNodeChange_Modified,
+			},
+		}, {
+			root.Index(1, 0, 0),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "5",
+				ValuePath:      toPath.Field("ReferenceA").Field("Map").MapIndex(5).Path(),
+				Preview:        box.NewValue("FIVE"),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Modified,
+				FromPreview:    box.NewValue("five"),
+			},
+		}, {
+			root.Index(1, 0, 1),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "10",
+				ValuePath:      toPath.Field("ReferenceA").Field("Map").MapIndex(10).Path(),
+				Preview:        box.NewValue("ten"),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Added,
+			},
+		}, {
+			root.Index(1, 0, 2),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "9",
+				ValuePath:      fromPath.Field("ReferenceA").Field("Map").MapIndex(9).Path(),
+				Preview:        box.NewValue("nine"),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Removed,
+			},
+		}, {
+			root.Index(1, 1),
+			&service.StateTreeNode{
+				NumChildren:    3,
+				Name:           "Slice",
+				ValuePath:      toPath.Field("ReferenceA").Field("Slice").Path(),
+				Preview:        box.NewValue(referenceA.Slice),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Modified,
+				FromPreview:    box.NewValue(testState.ReferenceA.Slice),
+			},
+		}, {
+			root.Index(1, 1, 2),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "2",
+				ValuePath:      toPath.Field("ReferenceA").Field("Slice").ArrayIndex(2).Path(),
+				Preview:        box.NewValue(memory.Int(20)),
+				PreviewIsValue: true,
+			},
+		}, {
+			root.Index(2),
+			&service.StateTreeNode{
+				NumChildren: 10,
+				Name:        "ReferenceC",
+				ValuePath:   toPath.Field("ReferenceC").Path(),
+				Change:      service.StateTreeNodeChange_Modified,
+				FromPreview: box.NewValue((*TestStruct)(nil)),
+			},
+		}, {
+			root.Index(2, 1),
+			&service.StateTreeNode{
+				NumChildren:    0,
+				Name:           "Int",
+				ValuePath:      toPath.Field("ReferenceC").Field("Int").Path(),
+				Preview:        box.NewValue(0),
+				PreviewIsValue: true,
+				Change:         service.StateTreeNodeChange_Added,
+			},
+		},
+	} {
+		node, err := stateTreeNode(ctx, tree, test.path)
+		if assert.For(ctx, "stateTreeNode(%v)", test.path).
+			ThatError(err).Succeeded() {
+			assert.For(ctx, "stateTreeNode(%v)", test.path).
+				That(node).DeepEquals(test.expected)
+		}
+	}
+
+	tree, err = diffStateTrees(ctx, newTree(testState, fromPath), newTree(testState, toPath))
+	if !assert.For(ctx, "diffStateTrees").ThatError(err).Succeeded() {
+		return
+	}
+	node, err := stateTreeNode(ctx, tree, root)
+	if assert.For(ctx, "unchanged").ThatError(err).Succeeded() {
+		assert.For(ctx, "unchanged").That(node).DeepEquals(&service.StateTreeNode{
+			NumChildren: 0,
+			Name:        "root",
+			ValuePath:   toPath.Path(),
+		})
+	}
+}
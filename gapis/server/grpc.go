@@ -32,6 +32,7 @@ import (
 	"github.com/google/gapid/gapis/service"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 
 	xctx "golang.org/x/net/context"
 )
@@ -51,7 +52,7 @@ func Listen(ctx context.Context, addr string, cfg Config) error {
 func NewWithListener(ctx context.Context, l net.Listener, cfg Config, srvChan chan<- *grpc.Server) error {
 	s := &grpcServer{
 		handler:   New(ctx, cfg),
-		bindCtx:   func(c context.Context) context.Context { return keys.Clone(c, ctx) },
+		bindCtx:   func(c context.Context) context.Context { return bindClient(keys.Clone(c, ctx)) },
 		keepAlive: make(chan struct{}, 1),
 	}
 	return grpcutil.ServeWithListener(ctx, l, func(ctx context.Context, listener net.Listener, server *grpc.Server) error {
@@ -77,6 +78,15 @@ type grpcServer struct {
 	inFlightRPCs uint32
 }
 
+// bindClient returns ctx tagged with the address of the RPC's peer, so that
+// tasks from each connected client are scheduled fairly.
+func bindClient(ctx context.Context) context.Context {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return task.PutClient(ctx, p.Addr.String())
+	}
+	return ctx
+}
+
 // inRPC should be called at the start of an RPC call. The returned function
 // should be called when the RPC call finishes.
 func (s *grpcServer) inRPC() func() {
@@ -59,6 +59,9 @@ type Config struct {
 	DeviceScanDone   task.Signal
 	LogBroadcaster   *log.Broadcaster
 	IdleTimeout      time.Duration
+	// ExportCompatible exports and saves captures in the format that can be
+	// read by older versions.
+	ExportCompatible bool
 }
 
 // Server is the server interface to GAPIS.
@@ -74,6 +77,7 @@ func New(ctx context.Context, cfg Config) Server {
 		cfg.EnableLocalFiles,
 		cfg.DeviceScanDone,
 		cfg.LogBroadcaster,
+		capture.ExportOptions{Compatible: cfg.ExportCompatible},
 		bytes.Buffer{},
 	}
 }
@@ -84,6 +88,7 @@ type server struct {
 	enableLocalFiles bool
 	deviceScanDone   task.Signal
 	logBroadcaster   *log.Broadcaster
+	exportOptions    capture.ExportOptions
 	profile          bytes.Buffer
 }
 
@@ -92,7 +97,8 @@ func (s *server) Ping(ctx context.Context) error {
 }
 
 func (s *server) GetServerInfo(ctx context.Context) (*service.ServerInfo, error) {
-	ctx = log.Enter(ctx, "GetServerInfo")
+	ctx, end := log.StartSpan(ctx, "GetServerInfo")
+	defer end()
 	return s.info, nil
 }
 
@@ -101,7 +107,8 @@ func (s *server) CheckForUpdates(ctx context.Context, includePrereleases bool) (
 		githubOrg  = "google"
 		githubRepo = "gapid"
 	)
-	ctx = log.Enter(ctx, "CheckForUpdates")
+	ctx, end := log.StartSpan(ctx, "CheckForUpdates")
+	defer end()
 	client := github.NewClient(nil)
 	options := &github.ListOptions{}
 	releases, _, err := client.Repositories.ListReleases(ctx, githubOrg, githubRepo, options)
@@ -138,7 +145,8 @@ func (s *server) CheckForUpdates(ctx context.Context, includePrereleases bool) (
 }
 
 func (s *server) GetAvailableStringTables(ctx context.Context) ([]*stringtable.Info, error) {
-	ctx = log.Enter(ctx, "GetAvailableStringTables")
+	ctx, end := log.StartSpan(ctx, "GetAvailableStringTables")
+	defer end()
 	infos := make([]*stringtable.Info, len(s.stbs))
 	for i, table := range s.stbs {
 		infos[i] = table.Info
@@ -147,7 +155,8 @@ func (s *server) GetAvailableStringTables(ctx context.Context) ([]*stringtable.I
 }
 
 func (s *server) GetStringTable(ctx context.Context, info *stringtable.Info) (*stringtable.StringTable, error) {
-	ctx = log.Enter(ctx, "GetStringTable")
+	ctx, end := log.StartSpan(ctx, "GetStringTable")
+	defer end()
 	for _, table := range s.stbs {
 		if table.Info.CultureCode == info.CultureCode {
 			return table, nil
@@ -157,7 +166,8 @@ func (s *server) GetStringTable(ctx context.Context, info *stringtable.Info) (*s
 }
 
 func (s *server) ImportCapture(ctx context.Context, name string, data []uint8) (*path.Capture, error) {
-	ctx = log.Enter(ctx, "ImportCapture")
+	ctx, end := log.StartSpan(ctx, "ImportCapture")
+	defer end()
 	p, err := capture.Import(ctx, name, data)
 	if err != nil {
 		return nil, err
@@ -170,16 +180,18 @@ func (s *server) ImportCapture(ctx context.Context, name string, data []uint8) (
 }
 
 func (s *server) ExportCapture(ctx context.Context, c *path.Capture) ([]byte, error) {
-	ctx = log.Enter(ctx, "ExportCapture")
+	ctx, end := log.StartSpan(ctx, "ExportCapture")
+	defer end()
 	b := bytes.Buffer{}
-	if err := capture.Export(ctx, c, &b); err != nil {
+	if err := capture.ExportWithOptions(ctx, c, &b, s.exportOptions); err != nil {
 		return nil, err
 	}
 	return b.Bytes(), nil
 }
 
 func (s *server) LoadCapture(ctx context.Context, path string) (*path.Capture, error) {
-	ctx = log.Enter(ctx, "LoadCapture")
+	ctx, end := log.StartSpan(ctx, "LoadCapture")
+	defer end()
 	if !s.enableLocalFiles {
 		return nil, fmt.Errorf("Server not configured to allow reading of local files")
 	}
@@ -200,7 +212,8 @@ func (s *server) LoadCapture(ctx context.Context, path string) (*path.Capture, e
 }
 
 func (s *server) SaveCapture(ctx context.Context, c *path.Capture, path string) error {
-	ctx = log.Enter(ctx, "SaveCapture")
+	ctx, end := log.StartSpan(ctx, "SaveCapture")
+	defer end()
 	if !s.enableLocalFiles {
 		return fmt.Errorf("Server not configured to allow writing of local files")
 	}
@@ -209,11 +222,12 @@ func (s *server) SaveCapture(ctx context.Context, c *path.Capture, path string)
 		return err
 	}
 	defer f.Close()
-	return capture.Export(ctx, c, f)
+	return capture.ExportWithOptions(ctx, c, f, s.exportOptions)
 }
 
 func (s *server) GetDevices(ctx context.Context) ([]*path.Device, error) {
-	ctx = log.Enter(ctx, "GetDevices")
+	ctx, end := log.StartSpan(ctx, "GetDevices")
+	defer end()
 	s.deviceScanDone.Wait(ctx)
 	devices := bind.GetRegistry(ctx).Devices()
 	paths := make([]*path.Device, len(devices))
@@ -241,7 +255,8 @@ func (p prioritizedDevices) Less(i, j int) bool {
 func (p prioritizedDevices) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 
 func (s *server) GetDevicesForReplay(ctx context.Context, p *path.Capture) ([]*path.Device, error) {
-	ctx = log.Enter(ctx, "GetDevicesForReplay")
+	ctx, end := log.StartSpan(ctx, "GetDevicesForReplay")
+	defer end()
 	s.deviceScanDone.Wait(ctx)
 	return devices.ForReplay(ctx, p)
 }
@@ -255,7 +270,16 @@ func (s *server) GetFramebufferAttachment(
 	hints *service.UsageHints,
 ) (*path.ImageInfo, error) {
 
-	ctx = log.Enter(ctx, "GetFramebufferAttachment")
+	ctx, end := log.StartSpan(ctx, "GetFramebufferAttachment")
+	defer end()
+	if hints != nil {
+		switch {
+		case hints.Primary:
+			ctx = task.PutPriority(ctx, task.Interactive)
+		case hints.Preview, hints.Background:
+			ctx = task.PutPriority(ctx, task.Background)
+		}
+	}
 	if err := replaySettings.Device.Validate(); err != nil {
 		return nil, log.Errf(ctx, err, "Invalid path: %v", replaySettings.Device)
 	}
@@ -266,7 +290,8 @@ func (s *server) GetFramebufferAttachment(
 }
 
 func (s *server) Get(ctx context.Context, p *path.Any) (interface{}, error) {
-	ctx = log.Enter(ctx, "Get")
+	ctx, end := log.StartSpan(ctx, "Get")
+	defer end()
 	if err := p.Validate(); err != nil {
 		return nil, log.Errf(ctx, err, "Invalid path: %v", p)
 	}
@@ -278,7 +303,8 @@ func (s *server) Get(ctx context.Context, p *path.Any) (interface{}, error) {
 }
 
 func (s *server) Set(ctx context.Context, p *path.Any, v interface{}) (*path.Any, error) {
-	ctx = log.Enter(ctx, "Set")
+	ctx, end := log.StartSpan(ctx, "Set")
+	defer end()
 	if err := p.Validate(); err != nil {
 		return nil, log.Errf(ctx, err, "Invalid path: %v", p)
 	}
@@ -286,7 +312,8 @@ func (s *server) Set(ctx context.Context, p *path.Any, v interface{}) (*path.Any
 }
 
 func (s *server) Follow(ctx context.Context, p *path.Any) (*path.Any, error) {
-	ctx = log.Enter(ctx, "Follow")
+	ctx, end := log.StartSpan(ctx, "Follow")
+	defer end()
 	if err := p.Validate(); err != nil {
 		return nil, log.Errf(ctx, err, "Invalid path: %v", p)
 	}
@@ -294,7 +321,8 @@ func (s *server) Follow(ctx context.Context, p *path.Any) (*path.Any, error) {
 }
 
 func (s *server) GetLogStream(ctx context.Context, handler log.Handler) error {
-	ctx = log.Enter(ctx, "GetLogStream")
+	ctx, end := log.StartSpan(ctx, "GetLogStream")
+	defer end()
 	closed := make(chan struct{})
 	handler = log.OnClosed(handler, func() { close(closed) })
 	handler = log.Channel(handler, 64)
@@ -310,29 +338,34 @@ func (s *server) GetLogStream(ctx context.Context, handler log.Handler) error {
 }
 
 func (s *server) Find(ctx context.Context, req *service.FindRequest, handler service.FindHandler) error {
-	ctx = log.Enter(ctx, "Find")
+	ctx, end := log.StartSpan(ctx, "Find")
+	defer end()
 	return resolve.Find(ctx, req, handler)
 }
 
 func (s *server) BeginCPUProfile(ctx context.Context) error {
-	ctx = log.Enter(ctx, "BeginCPUProfile")
+	ctx, end := log.StartSpan(ctx, "BeginCPUProfile")
+	defer end()
 	s.profile.Reset()
 	return pprof.StartCPUProfile(&s.profile)
 }
 
 func (s *server) EndCPUProfile(ctx context.Context) ([]byte, error) {
-	ctx = log.Enter(ctx, "EndCPUProfile")
+	ctx, end := log.StartSpan(ctx, "EndCPUProfile")
+	defer end()
 	pprof.StopCPUProfile()
 	return s.profile.Bytes(), nil
 }
 
 func (s *server) GetPerformanceCounters(ctx context.Context) (string, error) {
-	ctx = log.Enter(ctx, "GetPerformanceCounters")
+	ctx, end := log.StartSpan(ctx, "GetPerformanceCounters")
+	defer end()
 	return fmt.Sprintf("%+v", benchmark.GlobalCounters.All()), nil
 }
 
 func (s *server) GetProfile(ctx context.Context, name string, debug int32) ([]byte, error) {
-	ctx = log.Enter(ctx, "GetProfile")
+	ctx, end := log.StartSpan(ctx, "GetProfile")
+	defer end()
 	p := pprof.Lookup(name)
 	if p == nil {
 		return []byte{}, fmt.Errorf("Profile not found: %s", name)
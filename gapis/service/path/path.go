@@ -76,6 +76,7 @@ func (n *FramebufferObservation) Path() *Any    { return &Any{&Any_Fbo{n}} }
 func (n *Field) Path() *Any                     { return &Any{&Any_Field{n}} }
 func (n *GlobalState) Path() *Any               { return &Any{&Any_GlobalState{n}} }
 func (n *ImageInfo) Path() *Any                 { return &Any{&Any_ImageInfo{n}} }
+func (n *Logcat) Path() *Any                    { return &Any{&Any_Logcat{n}} }
 func (n *MapIndex) Path() *Any                  { return &Any{&Any_MapIndex{n}} }
 func (n *Memory) Path() *Any                    { return &Any{&Any_Memory{n}} }
 func (n *Mesh) Path() *Any                      { return &Any{&Any_Mesh{n}} }
@@ -87,6 +88,7 @@ func (n *Result) Path() *Any                    { return &Any{&Any_Result{n}} }
 func (n *Slice) Path() *Any                     { return &Any{&Any_Slice{n}} }
 func (n *State) Path() *Any                     { return &Any{&Any_State{n}} }
 func (n *StateTree) Path() *Any                 { return &Any{&Any_StateTree{n}} }
+func (n *StateTreeDiff) Path() *Any             { return &Any{&Any_StateTreeDiff{n}} }
 func (n *StateTreeNode) Path() *Any             { return &Any{&Any_StateTreeNode{n}} }
 func (n *StateTreeNodeForPath) Path() *Any      { return &Any{&Any_StateTreeNodeForPath{n}} }
 func (n *Thumbnail) Path() *Any                 { return &Any{&Any_Thumbnail{n}} }
@@ -110,6 +112,7 @@ func (n FramebufferObservation) Parent() Node    { return n.Command }
 func (n Field) Parent() Node                     { return oneOfNode(n.Struct) }
 func (n GlobalState) Parent() Node               { return n.After }
 func (n ImageInfo) Parent() Node                 { return nil }
+func (n Logcat) Parent() Node                    { return n.Capture }
 func (n MapIndex) Parent() Node                  { return oneOfNode(n.Map) }
 func (n Memory) Parent() Node                    { return n.After }
 func (n Mesh) Parent() Node                      { return oneOfNode(n.Object) }
@@ -121,6 +124,7 @@ func (n Result) Parent() Node                    { return n.Command }
 func (n Slice) Parent() Node                     { return oneOfNode(n.Array) }
 func (n State) Parent() Node                     { return n.After }
 func (n StateTree) Parent() Node                 { return n.State }
+func (n StateTreeDiff) Parent() Node             { return n.State }
 func (n StateTreeNode) Parent() Node             { return nil }
 func (n StateTreeNodeForPath) Parent() Node      { return nil }
 func (n Thumbnail) Parent() Node                 { return oneOfNode(n.Object) }
@@ -141,6 +145,7 @@ func (n *Events) SetParent(p Node)                    { n.Capture, _ = p.(*Captu
 func (n *FramebufferObservation) SetParent(p Node)    { n.Command, _ = p.(*Command) }
 func (n *GlobalState) SetParent(p Node)               { n.After, _ = p.(*Command) }
 func (n *ImageInfo) SetParent(p Node)                 {}
+func (n *Logcat) SetParent(p Node)                    { n.Capture, _ = p.(*Capture) }
 func (n *Memory) SetParent(p Node)                    { n.After, _ = p.(*Command) }
 func (n *Parameter) SetParent(p Node)                 { n.Command, _ = p.(*Command) }
 func (n *Report) SetParent(p Node)                    { n.Capture, _ = p.(*Capture) }
@@ -149,6 +154,7 @@ func (n *Resources) SetParent(p Node)                 { n.Capture, _ = p.(*Captu
 func (n *Result) SetParent(p Node)                    { n.Command, _ = p.(*Command) }
 func (n *State) SetParent(p Node)                     { n.After, _ = p.(*Command) }
 func (n *StateTree) SetParent(p Node)                 { n.State, _ = p.(*State) }
+func (n *StateTreeDiff) SetParent(p Node)             { n.State, _ = p.(*State) }
 func (n *StateTreeNode) SetParent(p Node)             {}
 func (n *StateTreeNodeForPath) SetParent(p Node)      {}
 
@@ -220,6 +226,9 @@ func (n GlobalState) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.global-sta
 // Format implements fmt.Formatter to print the version.
 func (n ImageInfo) Format(f fmt.State, c rune) { fmt.Fprintf(f, "image-info<%x>", n.Id) }
 
+// Format implements fmt.Formatter to print the version.
+func (n Logcat) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.logcat", n.Parent()) }
+
 // Format implements fmt.Formatter to print the version.
 func (n MapIndex) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v[%x]", n.Parent(), n.Key) }
 
@@ -259,6 +268,11 @@ func (n State) Format(f fmt.State, c rune) {
 // Format implements fmt.Formatter to print the version.
 func (n StateTree) Format(f fmt.State, c rune) { fmt.Fprintf(f, "%v.tree", n.State) }
 
+// Format implements fmt.Formatter to print the version.
+func (n StateTreeDiff) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%v.tree-diff<from: %v>", n.State, n.From)
+}
+
 // Format implements fmt.Formatter to print the version.
 func (n StateTreeNode) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, "state-tree<%v>[%v]", n.Tree, printIndices(n.Indices))
@@ -522,6 +536,11 @@ func (n *Capture) Contexts() *Contexts {
 	return &Contexts{Capture: n}
 }
 
+// Logcat returns the path node to the capture's logcat messages.
+func (n *Capture) Logcat() *Logcat {
+	return &Logcat{Capture: n}
+}
+
 // Commands returns the path node to the capture's commands.
 func (n *Capture) Commands() *Commands {
 	return &Commands{
@@ -670,6 +689,12 @@ func (n *State) Tree() *StateTree {
 	return &StateTree{State: n}
 }
 
+// TreeDiff returns the path node to the state tree holding the differences
+// between this state and the state from.
+func (n *State) TreeDiff(from *State) *StateTreeDiff {
+	return &StateTreeDiff{State: n, From: from}
+}
+
 func (n *GlobalState) Field(name string) *Field       { return NewField(name, n) }
 func (n *State) Field(name string) *Field             { return NewField(name, n) }
 func (n *Parameter) ArrayIndex(i uint64) *ArrayIndex  { return NewArrayIndex(i, n) }
@@ -205,6 +205,11 @@ func (n *ImageInfo) Validate() error {
 	return checkNotNilAndValidate(n, n.Id, "id")
 }
 
+// Validate checks the path is valid.
+func (n *Logcat) Validate() error {
+	return checkNotNilAndValidate(n, n.Capture, "capture")
+}
+
 // Validate checks the path is valid.
 func (n *MapIndex) Validate() error {
 	return anyErr(
@@ -269,6 +274,14 @@ func (n *StateTree) Validate() error {
 	return checkNotNilAndValidate(n, n.State, "state")
 }
 
+// Validate checks the path is valid.
+func (n *StateTreeDiff) Validate() error {
+	return anyErr(
+		checkNotNilAndValidate(n, n.State, "state"),
+		checkNotNilAndValidate(n, n.From, "from"),
+	)
+}
+
 // Validate checks the path is valid.
 func (n *StateTreeNode) Validate() error {
 	return checkIsValid(n, n.Tree, "tree")
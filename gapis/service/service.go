@@ -203,6 +203,8 @@ func NewValue(v interface{}) *Value {
 		return &Value{&Value_Event{v}}
 	case *Events:
 		return &Value{&Value_Events{v}}
+	case *Logcat:
+		return &Value{&Value_Logcat{v}}
 	case *Memory:
 		return &Value{&Value_Memory{v}}
 	case *path.Any:
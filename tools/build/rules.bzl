@@ -13,7 +13,7 @@
 # limitations under the License.
 
 load("//tools/build/rules:android.bzl", "android_native_app_glue", "android_native")
-load("//tools/build/rules:apic.bzl", "apic_compile", "apic_template")
+load("//tools/build/rules:apic.bzl", "apic_compile", "apic_footprint", "apic_template")
 load("//tools/build/rules:cc.bzl", "cc_copts", "cc_stripped_binary", "strip")
 load("//tools/build/rules:common.bzl", "generate", "copy", "copy_to", "copy_tree", "filter")
 load("//tools/build/rules:dynlib.bzl", "android_dynamic_library", "cc_dynamic_library")
@@ -162,3 +162,50 @@ apic_compile = rule(
     },
     fragments = ["cpp"],
 )
+
+def _apic_footprint_impl(ctx):
+    api = ctx.attr.api
+    apilist = api.includes.to_list()
+    out = ctx.new_file(ctx.label.name + ".json")
+
+    ctx.actions.run(
+        inputs = apilist,
+        outputs = [out],
+        arguments = [
+            "footprint",
+            "--json",
+            "--search", api_search_path(apilist),
+            "--out", out.path,
+            api.main.path,
+        ],
+        mnemonic = "apic",
+        progress_message = "apic generating footprints for " + api.main.short_path,
+        executable = ctx.executable._apic,
+        use_default_shell_env = True,
+    )
+
+    return [
+        DefaultInfo(files = depset([out])),
+    ]
+
+"""Adds a rule generating the static state footprints of an API's commands"""
+apic_footprint = rule(
+    _apic_footprint_impl,
+    attrs = {
+        "api": attr.label(
+            allow_files = False,
+            mandatory = True,
+            providers = [
+                "apiname",
+                "main",
+                "includes",
+            ],
+        ),
+        "_apic": attr.label(
+            executable = True,
+            cfg = "host",
+            allow_files = True,
+            default = Label("//cmd/apic:apic"),
+        ),
+    },
+)